@@ -0,0 +1,241 @@
+// Package app 服务实例的依赖容器：配置、日志、数据库、Redis 和会话 / 限流存储都由 App 持有，
+// 通过 router.SetupRouter(app) 注入到中间件和 handler，不依赖全局变量，
+// 同一进程中可以创建多个互不影响的实例（如并行的集成测试）。
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"trae-go/config"
+	"trae-go/notify"
+	"trae-go/pkg/cache"
+	"trae-go/pkg/lifecycle"
+	"trae-go/pkg/logger"
+	"trae-go/pkg/metrics"
+	"trae-go/pkg/replica"
+	"trae-go/pkg/store"
+	"trae-go/pkg/tracing"
+	"trae-go/repository"
+	"trae-go/service"
+	"trae-go/webhooks"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// App 一个服务实例
+type App struct {
+	Logger *zap.Logger
+	DB     *gorm.DB
+	Redis  *redis.Client // store.driver 为 memory 时为 nil
+	Store  store.Store
+	// 只读副本，未配置 database.replicas 或通过 WithDB 传入数据库时为 nil
+	Replicas *replica.Set
+
+	// 业务层：默认基于 DB 的 GORM 仓储，测试可以通过 WithRepositories 换成内存实现
+	Repos       repository.Repositories
+	Books       service.BookService
+	Students    service.StudentService
+	Circulation service.CirculationService
+	// 图书目录缓存，cache.enabled 为 false 或 store.driver 为 memory（没有 Redis）时为 nil
+	Cache *cache.Cache
+	// webhook 订阅管理，直接读写数据库，不受 WithRepositories 影响
+	Webhooks *webhooks.Manager
+	// 邮件通知的入队、偏好和发送历史，同样直接读写数据库
+	Notifier *notify.Notifier
+
+	// 启动时间和是否正在退出，/readyz 和 /admin/status 使用
+	Lifecycle *lifecycle.State
+	// 本实例的数据库连接池和借阅业务指标，与进程级的指标一起由 /metrics 暴露
+	Metrics *metrics.DBCollector
+
+	cfg      atomic.Pointer[config.Config]
+	logLevel *zap.AtomicLevel // 由 New 创建 logger 时才有，热更新日志级别用
+	closers  []closer
+}
+
+// closer New 中创建、需要在 Close 时释放的资源
+type closer struct {
+	name  string
+	close func() error
+}
+
+type options struct {
+	cfg    *config.Config
+	logger *zap.Logger
+	db     *gorm.DB
+	redis  *redis.Client
+	store  store.Store
+	repos  repository.Repositories
+}
+
+// Option 创建 App 的选项。通过选项传入的资源由调用方负责关闭
+type Option func(*options)
+
+// WithConfig 使用 cfg，不传时使用 config.Current() 的副本
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) { o.cfg = cfg }
+}
+
+// WithLogger 使用已有的 logger，不传时按 cfg.Log 创建
+func WithLogger(l *zap.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithDB 使用已打开的数据库，不传时按 cfg.Database 打开
+func WithDB(db *gorm.DB) Option {
+	return func(o *options) { o.db = db }
+}
+
+// WithRedis 使用已连接的 Redis，store.driver 为 redis 且没有传 WithStore 时用它创建存储
+func WithRedis(rdb *redis.Client) Option {
+	return func(o *options) { o.redis = rdb }
+}
+
+// WithStore 使用已有的会话 / 限流存储，不传时按 cfg.Store 创建
+func WithStore(s store.Store) Option {
+	return func(o *options) { o.store = s }
+}
+
+// WithRepositories 使用指定的仓储（如 repository.NewMemory()），不传时基于数据库创建
+func WithRepositories(r repository.Repositories) Option {
+	return func(o *options) { o.repos = r }
+}
+
+// New 按选项组装服务实例，没有通过选项提供的依赖按配置创建。出错时已创建的资源会被释放
+func New(opts ...Option) (a *App, err error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.cfg == nil {
+		c := *config.Current()
+		o.cfg = &c
+	}
+	cfg := o.cfg
+
+	a = &App{Lifecycle: lifecycle.New()}
+	a.cfg.Store(cfg)
+	defer func() {
+		if err != nil {
+			a.Close()
+			a = nil
+		}
+	}()
+
+	a.Logger = o.logger
+	if a.Logger == nil {
+		l, lvl := logger.New(cfg.Log, cfg.Server.Mode)
+		a.Logger, a.logLevel = l, &lvl
+		a.onClose("logger", func() error { l.Sync(); return nil })
+	}
+
+	a.DB = o.db
+	if a.DB == nil {
+		db, err := config.OpenDatabase(cfg.Database, cfg.Tracing.Enabled)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect database: %w", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sql db: %w", err)
+		}
+		a.DB = db
+		a.onClose("database", sqlDB.Close)
+		if len(cfg.Database.Replicas) > 0 {
+			set, err := replica.Register(db, cfg.Database, a.Logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to register database replicas: %w", err)
+			}
+			a.Replicas = set
+			a.onClose("replicas", set.Close)
+		}
+	}
+
+	a.Repos = o.repos
+	if a.Repos == nil {
+		a.Repos = repository.NewGorm(a.DB)
+	}
+
+	a.Redis, a.Store = o.redis, o.store
+	if a.Store == nil {
+		if err := a.openStore(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	a.Books = service.NewBookService(a.Repos)
+	a.Students = service.NewStudentService(a.Repos)
+	a.Circulation = service.NewCirculationService(a.Repos)
+	if cfg.Cache.Enabled && a.Redis != nil {
+		a.Cache = cache.New(a.Redis, cfg.Cache.TTLDuration())
+		a.Books = service.NewCachedBookService(a.Books, a.Cache)
+		a.Circulation = service.NewCachedCirculationService(a.Circulation, a.Cache)
+	}
+	a.Webhooks = webhooks.NewManager(a.DB)
+	a.Notifier = notify.NewNotifier(replica.Primary(a.DB), cfg.Notify)
+	a.Metrics = metrics.NewDBCollector(a.DB, cfg.Loan.PeriodDuration())
+	return a, nil
+}
+
+// openStore 按 store.driver 创建会话和限流存储
+func (a *App) openStore(cfg *config.Config) error {
+	if cfg.Store.Driver == store.DriverMemory {
+		a.Logger.Warn("using in-memory store: sessions and rate limits are per process and lost on restart")
+		s := store.NewMemoryStore()
+		a.Store = s
+		a.onClose("store", s.Close)
+		return nil
+	}
+	if a.Redis == nil {
+		rdb, err := config.OpenRedis(cfg.Redis)
+		if err != nil {
+			return fmt.Errorf("failed to connect redis: %w", err)
+		}
+		rdb.AddHook(metrics.RedisHook{})
+		if cfg.Tracing.Enabled {
+			rdb.AddHook(tracing.RedisHook{})
+		}
+		a.Redis = rdb
+		a.onClose("redis", rdb.Close)
+	}
+	a.Store = store.NewRedisStore(a.Redis)
+	return nil
+}
+
+func (a *App) onClose(name string, fn func() error) {
+	a.closers = append(a.closers, closer{name, fn})
+}
+
+// Config 当前生效的配置，返回值只读
+func (a *App) Config() *config.Config {
+	return a.cfg.Load()
+}
+
+// SetConfig 配置热更新：之后的请求立即使用新的限流、CORS 配置；logger 由 New 创建时同时更新日志级别
+func (a *App) SetConfig(cfg *config.Config) {
+	a.cfg.Store(cfg)
+	if a.logLevel != nil {
+		a.logLevel.SetLevel(logger.ParseLevel(cfg.Log.Level))
+	}
+}
+
+// Close 按创建的逆序释放 New 中创建的资源：先 Redis / 存储，再数据库，最后刷新日志
+func (a *App) Close() error {
+	var errs []error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		c := a.closers[i]
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+			continue
+		}
+		if c.name != "logger" {
+			a.Logger.Info(c.name + " closed")
+		}
+	}
+	a.closers = nil
+	return errors.Join(errs...)
+}
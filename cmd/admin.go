@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"trae-go/models"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	adminName     string
+	adminPassword string
+	adminUpdate   bool
+)
+
+var createAdminCmd = &cobra.Command{
+	Use:   "create-admin",
+	Short: "创建管理员账号",
+	Long: "创建管理员账号。没有 --password 时从标准输入读取一行作为密码。\n" +
+		"用户已存在时报错，加 --update 则把该用户设为管理员并重置密码。",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(adminName) < 3 || len(adminName) > 32 {
+			return errors.New("--username must be 3 to 32 characters")
+		}
+		password := adminPassword
+		if password == "" {
+			fmt.Fprint(os.Stderr, "password: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("read password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+		if len(password) < 6 || len(password) > 72 {
+			return errors.New("password must be 6 to 72 characters")
+		}
+
+		db, closeDB, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		var existing []models.User
+		if err := db.Unscoped().Where("name = ?", adminName).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			user := models.User{Name: adminName, Password: string(hash), Identify: models.UserRoleAdmin}
+			if err := db.Create(&user).Error; err != nil {
+				return err
+			}
+			fmt.Printf("created admin %s (id %d)\n", user.Name, user.ID)
+			return nil
+		}
+		if !adminUpdate {
+			return fmt.Errorf("user %s already exists, use --update to promote it and reset the password", adminName)
+		}
+		err = db.Unscoped().Model(&existing[0]).Updates(map[string]interface{}{
+			"password":   string(hash),
+			"identify":   models.UserRoleAdmin,
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		fmt.Printf("updated admin %s (id %d)\n", adminName, existing[0].ID)
+		return nil
+	},
+}
+
+func init() {
+	createAdminCmd.Flags().StringVarP(&adminName, "username", "u", "", "用户名")
+	createAdminCmd.Flags().StringVar(&adminPassword, "password", "", "密码，不提供时从标准输入读取")
+	createAdminCmd.Flags().BoolVar(&adminUpdate, "update", false, "用户已存在时把它设为管理员并重置密码（已删除的用户会被恢复）")
+	createAdminCmd.MarkFlagRequired("username")
+	rootCmd.AddCommand(createAdminCmd)
+}
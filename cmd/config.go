@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"trae-go/config"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "配置相关命令",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "检查配置文件，有问题时逐条列出并以非零状态码退出",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateConfig(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: ok\n", strings.Join(config.Files(), " + "))
+		return nil
+	},
+}
+
+// validateConfig 校验已加载的配置（含环境变量覆盖），有问题时逐条列在返回的错误中
+func validateConfig() error {
+	errs := config.AppConfig.Validate()
+	if len(errs) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problem(s) found in config %s:", len(errs), strings.Join(config.Files(), " + "))
+	for _, err := range errs {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return errors.New(b.String())
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
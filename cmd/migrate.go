@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"trae-go/migrations"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// migrateTimeout 迁移（包括等待其它实例释放迁移锁）的最长时间
+const migrateTimeout = 10 * time.Minute
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "数据库迁移",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "执行所有未执行的迁移",
+	Args:  cobra.NoArgs,
+	RunE: withMigrator(func(ctx context.Context, m *migrations.Migrator, args []string) error {
+		done, err := m.Up(ctx)
+		printMigrations("applied", done)
+		return err
+	}),
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [n]",
+	Short: "回滚最近的 n 个迁移（默认 1）",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: withMigrator(func(ctx context.Context, m *migrations.Migrator, args []string) error {
+		steps := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("n must be a positive integer")
+			}
+			steps = n
+		}
+		done, err := m.Down(ctx, steps)
+		printMigrations("rolled back", done)
+		return err
+	}),
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看每个迁移的执行情况",
+	Args:  cobra.NoArgs,
+	RunE: withMigrator(func(ctx context.Context, m *migrations.Migrator, args []string) error {
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state = "applied"
+				at = s.AppliedAt.Local().Format(time.RFC3339)
+			}
+			if s.Missing {
+				state = "applied (missing file)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return w.Flush()
+	}),
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+// withMigrator 连接数据库并创建 Migrator，再执行 fn
+func withMigrator(fn func(ctx context.Context, m *migrations.Migrator, args []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		db, closeDB, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		m, err := migrations.New(db)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), migrateTimeout)
+		defer cancel()
+		return fn(ctx, m, args)
+	}
+}
+
+func printMigrations(verb string, list []migrations.Migration) {
+	if len(list) == 0 {
+		fmt.Println("nothing to do")
+		return
+	}
+	for _, mig := range list {
+		fmt.Printf("%s %04d_%s\n", verb, mig.Version, mig.Name)
+	}
+}
+
+// prepareSchema 启动服务前检查迁移：开启 auto_migrate 时直接执行，否则有未执行的迁移就拒绝启动
+func prepareSchema(db *gorm.DB, autoMigrate bool) error {
+	m, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	if autoMigrate {
+		_, err := m.Up(ctx)
+		return err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migration(s), run `migrate up` first", len(pending))
+	}
+	return nil
+}
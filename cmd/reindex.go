@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// reindexTables 需要重建索引的业务表
+var reindexTables = []string{"books", "book_copies", "students", "book_students", "loan_archives", "users"}
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "重建业务表的索引并更新查询优化器统计信息",
+	Long:  "对业务表执行 REINDEX 和 ANALYZE。大量导入或删除数据后执行，可以恢复索引效率、让查询计划重新估算行数。",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, closeDB, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		dialect := db.Dialector.Name()
+		for _, table := range reindexTables {
+			start := time.Now()
+			var stmts []string
+			switch dialect {
+			case "postgres":
+				stmts = []string{"REINDEX TABLE " + table, "ANALYZE " + table}
+			case "sqlite":
+				stmts = []string{"REINDEX " + table, "ANALYZE " + table}
+			case "mysql":
+				// InnoDB 的 OPTIMIZE TABLE 会重建表和索引并更新统计信息
+				stmts = []string{"OPTIMIZE TABLE " + table, "ANALYZE TABLE " + table}
+			default:
+				return fmt.Errorf("reindex not supported for dialect %q", dialect)
+			}
+			for _, stmt := range stmts {
+				if err := db.Exec(stmt).Error; err != nil {
+					return fmt.Errorf("%s: %w", stmt, err)
+				}
+			}
+			fmt.Printf("reindexed %s in %s\n", table, time.Since(start).Round(time.Millisecond))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+}
@@ -0,0 +1,75 @@
+// Package cmd 命令行入口：serve 启动 HTTP 服务，其余子命令用于迁移、初始化数据和日常维护。
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"trae-go/app"
+	"trae-go/config"
+	"trae-go/pkg/logger"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var (
+	cfgFile string // --config，配置文件路径
+	env     string // --env，叠加 config.<env>.yaml
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "trae-go",
+	Short: "图书馆管理系统",
+	Long:  "图书馆管理系统。不带子命令时等同于 serve。",
+	// 所有子命令共用的初始化：读取配置、初始化日志
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.InitConfig(cfgFile, env); err != nil {
+			return err
+		}
+		if cmd != serveCmd && cmd != cmd.Root() {
+			logger.ConsoleOutput = os.Stderr
+		}
+		logger.InitLogger(config.AppConfig.Log, config.AppConfig.Server.Mode)
+		return nil
+	},
+	RunE:          runServe,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "配置文件路径（默认取 $APP_CONFIG，再默认 ./config.yaml）")
+	rootCmd.PersistentFlags().StringVarP(&env, "env", "e", "", "运行环境，如 dev、test、prod，会叠加同目录下的 config.<env>.yaml（默认取 $APP_ENV）")
+}
+
+// Execute 执行命令行，出错时以非零状态码退出
+func Execute() {
+	err := rootCmd.Execute()
+	if logger.L != nil {
+		logger.L.Sync() // 退出前刷新缓冲
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// openDB 按配置连接数据库，需要数据库的子命令共用
+func openDB() (*gorm.DB, func(), error) {
+	db, err := config.InitDatabase()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get sql db: %w", err)
+	}
+	return db, func() { sqlDB.Close() }, nil
+}
+
+// openApp 按配置创建服务实例。写入图书、学生的子命令通过其中的 service 写入，
+// 和 HTTP 接口一样产生领域事件、使图书缓存失效
+func openApp() (*app.App, error) {
+	return app.New(app.WithConfig(config.Current()), app.WithLogger(logger.L))
+}
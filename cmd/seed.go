@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"trae-go/models"
+	"trae-go/service"
+
+	"github.com/spf13/cobra"
+)
+
+var seedForce bool
+
+// 演示用的初始数据
+var (
+	seedBooks = []service.BookInput{
+		{Title: "The Go Programming Language", Author: "Alan A. A. Donovan", ISBN: "9780134190440", Stock: 5},
+		{Title: "Go 语言实战", Author: "William Kennedy", ISBN: "9787115423085", Stock: 3},
+		{Title: "Designing Data-Intensive Applications", Author: "Martin Kleppmann", ISBN: "9781449373320", Stock: 2},
+		{Title: "深入理解计算机系统", Author: "Randal E. Bryant", ISBN: "9787111544937", Stock: 4},
+		{Title: "The Pragmatic Programmer", Author: "David Thomas", ISBN: "9780135957059", Stock: 1},
+	}
+	seedStudents = []service.StudentInput{
+		{Name: "张三", Email: "zhangsan@example.com"},
+		{Name: "李四", Email: "lisi@example.com"},
+		{Name: "Tom", Email: "tom@example.com"},
+	}
+)
+
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "写入演示用的图书和学生数据",
+	Long: "写入演示用的图书和学生数据，与 import 一样通过 service 写入，产生领域事件并使图书缓存失效。\n" +
+		"图书表或学生表已有数据时默认不做任何事，加 --force 时按 isbn / email 更新已有的演示数据，其余新建。",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := openApp()
+		if err != nil {
+			return err
+		}
+		defer a.Close()
+
+		var books, students int64
+		if err := a.DB.Model(&models.Book{}).Count(&books).Error; err != nil {
+			return err
+		}
+		if err := a.DB.Model(&models.Student{}).Count(&students).Error; err != nil {
+			return err
+		}
+		if (books > 0 || students > 0) && !seedForce {
+			fmt.Printf("database already has %d book(s) and %d student(s), skipped (use --force to seed anyway)\n", books, students)
+			return nil
+		}
+
+		bookRes, err := a.Books.Import(cmd.Context(), seedBooks, false)
+		if err != nil {
+			return err
+		}
+		studentRes, err := a.Students.Import(cmd.Context(), seedStudents, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("seeded books: %d created, %d updated, %d unchanged; students: %d created, %d updated, %d unchanged\n",
+			bookRes.Created, bookRes.Updated, bookRes.Unchanged, studentRes.Created, studentRes.Updated, studentRes.Unchanged)
+		return nil
+	},
+}
+
+func init() {
+	seedCmd.Flags().BoolVar(&seedForce, "force", false, "已有数据时仍然写入，按 isbn / email 更新已有的演示数据")
+	rootCmd.AddCommand(seedCmd)
+}
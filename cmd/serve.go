@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"trae-go/app"
+	"trae-go/config"
+	"trae-go/events"
+	"trae-go/jobs"
+	"trae-go/notify"
+	"trae-go/pkg/logger"
+	"trae-go/pkg/mail"
+	"trae-go/pkg/replica"
+	"trae-go/pkg/tracing"
+	"trae-go/router"
+	"trae-go/webhooks"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// 配置缺失或无法解析时使用的默认超时
+const (
+	defaultReadTimeout     = 15 * time.Second
+	defaultWriteTimeout    = 30 * time.Second
+	defaultIdleTimeout     = 60 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+	jobsStopTimeout        = 10 * time.Second
+	tracingFlushTimeout    = 5 * time.Second
+)
+
+var servePort string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "启动 HTTP 服务",
+	Long: "启动 HTTP 服务。收到 SIGINT / SIGTERM 后 /readyz 改为返回 503，等待 server.drain_delay 后停止接收新连接，等待进行中的请求完成（最长 server.shutdown_timeout），\n" +
+		"然后停止后台任务，依次关闭 Redis（store.driver 为 memory 时没有）、数据库连接，导出剩余的 trace 并刷新日志。等待期间再次收到信号会立即退出。",
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVarP(&servePort, "port", "p", "", "监听端口，覆盖配置中的 server.port")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if servePort != "" {
+		config.AppConfig.Server.Port = servePort
+	}
+	// 配置有问题时在连接任何依赖之前退出，一次列出全部问题
+	if err := validateConfig(); err != nil {
+		return err
+	}
+	cfg := config.AppConfig.Server
+
+	// 最先初始化、最后关闭：退出过程中产生的 span 也能导出
+	shutdownTracing, err := tracing.Init(cmd.Context(), config.AppConfig.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.L.Error("flush traces failed", zap.Error(err))
+		}
+	}()
+
+	// 依赖由 App 持有，退出时 a.Close 依次关闭 Redis（store.driver 为 memory 时是内存存储）和数据库
+	a, err := app.New(app.WithConfig(config.Current()), app.WithLogger(logger.L))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := a.Close(); err != nil {
+			logger.L.Error("close app failed", zap.Error(err))
+		}
+	}()
+	if err := prepareSchema(a.DB, a.Config().Database.AutoMigrate); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	consumers, err := events.NewConsumers(a.Config().Events.Sinks, a.Redis)
+	if err != nil {
+		return err
+	}
+	// 内置消费者：把事件分发给 /admin/webhooks 中的订阅，由 webhook worker 发送
+	consumers = append(consumers, webhooks.NewDispatcher(replica.Primary(a.DB)).Consumer())
+	var notifyWorker *notify.Worker
+	var notifyScheduler *notify.Scheduler
+	if cfg := a.Config().Notify; cfg.Enabled {
+		transport, err := mail.New(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to init mail transport: %w", err)
+		}
+		// 内置消费者：学生创建后发送账户消息
+		consumers = append(consumers, a.Notifier.Consumer())
+		notifyWorker = notify.NewWorker(replica.Primary(a.DB), transport, cfg)
+		notifyScheduler = notify.NewScheduler(a.Notifier, cfg, a.Config().Loan)
+	}
+	relay := events.NewRelay(replica.Primary(a.DB), consumers, a.Config().Events)
+	webhookWorker := webhooks.NewWorker(replica.Primary(a.DB), a.Config().Webhooks)
+
+	runner := jobs.NewRunner(a.Logger)
+	runner.Go("purge-soft-deleted", func(ctx context.Context) {
+		jobs.StartPurgeJob(ctx, replica.Primary(a.DB), a.Config().SoftDelete)
+	})
+	runner.Go("events-relay", relay.Run)
+	runner.Go("webhooks", webhookWorker.Run)
+	if notifyWorker != nil {
+		runner.Go("notify-scheduler", notifyScheduler.Run)
+		runner.Go("notify-worker", notifyWorker.Run)
+	}
+	defer func() {
+		if err := runner.Stop(jobsStopTimeout); err != nil {
+			logger.L.Error("stop background jobs failed", zap.Error(err))
+		}
+	}()
+
+	srv := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      router.SetupRouter(a),
+		ReadTimeout:  config.Duration(cfg.ReadTimeout, defaultReadTimeout),
+		WriteTimeout: config.Duration(cfg.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:  config.Duration(cfg.IdleTimeout, defaultIdleTimeout),
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 限流和 CORS 中间件每次请求读取 a.Config()；logger 是全局的 L，级别单独同步
+	config.OnReload(a.SetConfig)
+	config.OnReload(func(c *config.Config) { logger.SetLevel(c.Log.Level) })
+	if err := config.Watch(ctx, logReload); err != nil {
+		logger.L.Warn("config hot reload disabled", zap.Error(err))
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.L.Info("http server listening", zap.String("addr", srv.Addr))
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// 没收到信号服务就退出了，通常是端口被占用
+		return fmt.Errorf("failed to run server: %w", err)
+	case <-ctx.Done():
+	}
+	// 恢复默认的信号处理，等待期间再按一次 Ctrl+C 直接退出
+	stop()
+
+	// 先让 /readyz 返回 503，等负载均衡器摘除实例后再停止接收连接
+	a.Lifecycle.StartDraining()
+	// drain_delay 可以为 0（不等待），未配置时同样不等待
+	if delay := config.Duration(cfg.DrainDelay, 0); delay > 0 {
+		logger.L.Info("draining, waiting for load balancer", zap.Duration("drain_delay", delay))
+		time.Sleep(delay)
+	}
+
+	timeout := config.Duration(cfg.ShutdownTimeout, defaultShutdownTimeout)
+	logger.L.Info("shutting down, draining in-flight requests", zap.Duration("timeout", timeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.L.Error("graceful shutdown timed out, closing remaining connections", zap.Error(err))
+		srv.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.L.Error("http server error", zap.Error(err))
+	}
+	logger.L.Info("http server stopped")
+	return nil
+}
+
+// logReload 记录配置热更新的结果；新配置不合法时保持原配置
+func logReload(res config.ReloadResult, err error) {
+	if err != nil {
+		logger.L.Error("config reload failed, keeping previous config", zap.Error(err))
+		return
+	}
+	if len(res.Applied) > 0 {
+		logger.L.Info("config reloaded", zap.Strings("applied", res.Applied))
+	}
+	if len(res.RestartRequired) > 0 {
+		logger.L.Warn("config changes require restart", zap.Strings("sections", res.RestartRequired))
+	}
+}
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,19 +16,39 @@ type Config struct {
 	Server    ServerConfig    `mapstructure:"server"`
 	Database  DatabaseConfig  `mapstructure:"database"`
 	Redis     RedisConfig     `mapstructure:"redis"`
+	Store     StoreConfig     `mapstructure:"store"`
+	Cache     CacheConfig     `mapstructure:"cache"`
 	Auth      AuthConfig      `mapstructure:"auth"`
 	Cors      CorsConfig      `mapstructure:"cors"`
 	RateLimit RateLimitConfig `mapstructure:"ratelimit"`
 	Log       LogConfig       `mapstructure:"log"`
+
+	SoftDelete SoftDeleteConfig `mapstructure:"softdelete"`
+	Loan       LoanConfig       `mapstructure:"loan"`
+	Tracing    TracingConfig    `mapstructure:"tracing"`
+	Events     EventsConfig     `mapstructure:"events"`
+	Webhooks   WebhooksConfig   `mapstructure:"webhooks"`
+	Notify     NotifyConfig     `mapstructure:"notify"`
 }
 
 type ServerConfig struct {
 	Port string `mapstructure:"port"`
 	Mode string `mapstructure:"mode"`
+
+	// 以下均为时长字符串，如 "15s"
+	ReadTimeout     string `mapstructure:"read_timeout"`     // 读取整个请求（含请求体）的超时
+	WriteTimeout    string `mapstructure:"write_timeout"`    // 从读完请求头到写完响应的超时
+	IdleTimeout     string `mapstructure:"idle_timeout"`     // keep-alive 连接的空闲超时
+	ShutdownTimeout string `mapstructure:"shutdown_timeout"` // 收到退出信号后等待进行中请求完成的最长时间
+	DrainDelay      string `mapstructure:"drain_delay"`      // 收到退出信号后 /readyz 先返回 503 多久再停止接收连接，留给负载均衡器摘除实例
+
+	// 反向代理的 IP 或 CIDR，只有连接来自这些地址时才用 X-Forwarded-For / X-Real-IP 确定客户端 IP。
+	// 默认为空，一律使用连接的对端地址，客户端伪造这些请求头不能绕过按 IP 的限流和 allow_ips
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
-	Driver   string `mapstructure:"driver"`
+	Driver   string `mapstructure:"driver"` // sqlite、postgres 或 mysql（MariaDB 同样使用 mysql）
 	Host     string `mapstructure:"host"`
 	Port     string `mapstructure:"port"`
 	User     string `mapstructure:"user"`
@@ -30,11 +56,25 @@ type DatabaseConfig struct {
 	DBName   string `mapstructure:"dbname"`
 	SSLMode  string `mapstructure:"sslmode"`
 	TimeZone string `mapstructure:"timezone"`
-	DSN      string `mapstructure:"dsn"` // 兼容 SQLite 或直接提供 DSN 的情况
+	DSN      string `mapstructure:"dsn"` // 兼容 SQLite 或直接提供 DSN 的情况（mysql 设置后忽略 host 等字段）
 
 	MaxIdleConns    int64  `mapstructure:"MaxIdleConns"`
 	MaxOpenConns    int64  `mapstructure:"MaxOpenConns"`
 	ConnMaxLifetime string `mapstructure:"ConnMaxLifetime"`
+
+	AutoMigrate bool `mapstructure:"auto_migrate"` // 启动服务时自动执行未执行的迁移，默认 true
+
+	// 只读副本的连接串，格式与所用驱动的 dsn 相同。配置后查询走健康的副本，写入和事务走主库
+	Replicas             []string `mapstructure:"replicas"`
+	ReplicaCheckInterval string   `mapstructure:"replica_check_interval"` // 副本健康检查间隔，默认 5s
+}
+
+// DefaultConnMaxLifetime 未配置连接最长存活时间时使用 1 小时
+const DefaultConnMaxLifetime = time.Hour
+
+// ConnLifetime 解析连接最长存活时间，主库和副本的连接池共用，未配置或不合法时返回 DefaultConnMaxLifetime
+func (c DatabaseConfig) ConnLifetime() time.Duration {
+	return Duration(c.ConnMaxLifetime, DefaultConnMaxLifetime)
 }
 
 type RedisConfig struct {
@@ -43,8 +83,44 @@ type RedisConfig struct {
 	DB       int    `mapstructure:"db"`
 }
 
+// StoreConfig 登录会话和限流计数的存储
+type StoreConfig struct {
+	Driver string `mapstructure:"driver"` // redis（默认）或 memory；memory 只适合单实例部署，不需要 Redis
+}
+
+// CacheConfig 图书目录的 Redis 缓存，store.driver 为 memory 时不启用
+type CacheConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 默认 true
+	TTL     string `mapstructure:"ttl"`     // 缓存有效期，时长字符串，默认 "5m"
+}
+
+// Duration 解析配置中的时长字符串，为空、不合法或不是正数时返回 def
+func Duration(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// DefaultCacheTTL 未配置缓存有效期时使用 5 分钟
+const DefaultCacheTTL = 5 * time.Minute
+
+// TTLDuration 解析缓存有效期，未配置或不合法时返回 DefaultCacheTTL
+func (c CacheConfig) TTLDuration() time.Duration {
+	return Duration(c.TTL, DefaultCacheTTL)
+}
+
 type AuthConfig struct {
-	TokenExpireHours string `mapstructure:"token_expire_hours"`
+	TokenExpireHours string `mapstructure:"token_expire_hours"` // token 有效期，时长字符串，如 "24h"
+}
+
+// DefaultTokenTTL 未配置 token 有效期时使用 24 小时
+const DefaultTokenTTL = 24 * time.Hour
+
+// TokenTTL 解析 token 有效期，未配置或不合法时返回 DefaultTokenTTL
+func (c AuthConfig) TokenTTL() time.Duration {
+	return Duration(c.TokenExpireHours, DefaultTokenTTL)
 }
 
 type CorsConfig struct {
@@ -52,9 +128,209 @@ type CorsConfig struct {
 }
 
 type RateLimitConfig struct {
-	GlobalLimit int64 `mapstructure:"global_limit"`
-	IPLimit     int64 `mapstructure:"ip_limit"`
+	GlobalLimit int64 `mapstructure:"global_limit"` // 兼容旧配置，未设置 global.limit 时使用
+	IPLimit     int64 `mapstructure:"ip_limit"`     // 兼容旧配置，未设置 ip.limit 时使用
+
+	Global RateLimitPolicy `mapstructure:"global"` // 所有请求共用的额度
+	IP     RateLimitPolicy `mapstructure:"ip"`     // 每个客户端 IP 的额度
+
+	// Policies 具名策略，通过 groups 挂到路由组上，在 global / ip 之后检查
+	Policies map[string]RateLimitPolicy `mapstructure:"policies"`
+}
+
+// RateLimitPolicy 一条限流策略。global / ip 只使用算法、额度和放行名单，key、groups、roles 只对具名策略有效
+type RateLimitPolicy struct {
+	Algorithm string `mapstructure:"algorithm"` // sliding_window（默认）或 token_bucket
+	Limit     int64  `mapstructure:"limit"`     // 滑动窗口：任意 window 时长内最多的请求数；令牌桶：桶容量
+	Window    string `mapstructure:"window"`    // 默认 "1m"；令牌桶每 window 补充 limit 个令牌
+
+	Key       string                       `mapstructure:"key"`        // 按什么计数：ip（默认）、user、api_key 或 header:<请求头>，取不到时退回 ip
+	Groups    []string                     `mapstructure:"groups"`     // 挂到哪些路由组：auth、user、admin、books、students
+	AllowIPs  []string                     `mapstructure:"allow_ips"`  // 不受此策略限制的 IP 或网段，如 10.0.0.0/8
+	Keys      []string                     `mapstructure:"keys"`       // api_key / header 策略认可的值，其他值按 ip 计数，避免客户端换值绕过限流
+	AllowKeys []string                     `mapstructure:"allow_keys"` // 不受此策略限制的计数 key，如用户 ID、API key
+	Roles     map[string]RateLimitOverride `mapstructure:"roles"`      // 按登录用户身份覆盖额度，如 admin
+}
+
+// RateLimitOverride 某个身份的额度覆盖，未设置的字段沿用策略本身的值
+type RateLimitOverride struct {
+	Algorithm string `mapstructure:"algorithm"`
+	Limit     int64  `mapstructure:"limit"`
+	Window    string `mapstructure:"window"`
+	Exempt    bool   `mapstructure:"exempt"` // 该身份不受此策略限制
+}
+
+// DefaultRateLimitWindow 未配置 window 时的限流窗口
+const DefaultRateLimitWindow = time.Minute
+
+// GlobalPolicy 全局限流策略，补齐默认值
+func (c RateLimitConfig) GlobalPolicy() RateLimitPolicy {
+	return c.Global.withDefaults(c.GlobalLimit)
+}
+
+// IPPolicy 单个 IP 的限流策略，补齐默认值
+func (c RateLimitConfig) IPPolicy() RateLimitPolicy {
+	return c.IP.withDefaults(c.IPLimit)
+}
+
+// NamedPolicy 具名策略，补齐默认值
+func (c RateLimitConfig) NamedPolicy(name string) (RateLimitPolicy, bool) {
+	p, ok := c.Policies[name]
+	if !ok {
+		return RateLimitPolicy{}, false
+	}
+	p = p.withDefaults(0)
+	if p.Key == "" {
+		p.Key = "ip"
+	}
+	return p, true
+}
+
+// ForRole 应用 role 的额度覆盖，返回覆盖后的策略和该身份是否免于限流。
+// role 按原样匹配，与 IsAdmin 判断管理员的方式一致
+func (p RateLimitPolicy) ForRole(role string) (RateLimitPolicy, bool) {
+	o, ok := p.Roles[role]
+	if !ok || role == "" {
+		return p, false
+	}
+	if o.Exempt {
+		return p, true
+	}
+	if o.Algorithm != "" {
+		p.Algorithm = o.Algorithm
+	}
+	if o.Limit > 0 {
+		p.Limit = o.Limit
+	}
+	if o.Window != "" {
+		p.Window = o.Window
+	}
+	return p, false
+}
+
+// KnownKey v 是否是 keys 或 allow_keys 中配置过的 API key / 请求头的值
+func (p RateLimitPolicy) KnownKey(v string) bool {
+	return slices.Contains(p.Keys, v) || slices.Contains(p.AllowKeys, v)
+}
+
+func (p RateLimitPolicy) withDefaults(limit int64) RateLimitPolicy {
+	if p.Algorithm == "" {
+		p.Algorithm = "sliding_window"
+	}
+	if p.Limit == 0 {
+		p.Limit = limit
+	}
+	if p.Window == "" {
+		p.Window = DefaultRateLimitWindow.String()
+	}
+	return p
+}
+
+// WindowDuration 解析窗口长度，不合法时返回 DefaultRateLimitWindow
+func (p RateLimitPolicy) WindowDuration() time.Duration {
+	return Duration(p.Window, DefaultRateLimitWindow)
+}
+
+// SoftDeleteConfig 软删除记录的保留策略
+type SoftDeleteConfig struct {
+	Retention     string `mapstructure:"retention"`      // 软删除后保留多久再物理删除，如 "720h"
+	PurgeInterval string `mapstructure:"purge_interval"` // 清理任务执行间隔，如 "24h"
+}
+
+// LoanConfig 借阅规则
+type LoanConfig struct {
+	Period string `mapstructure:"period"` // 借阅期限，超过即为逾期，如 "336h"（14 天）
+}
+
+// DefaultLoanPeriod 未配置借阅期限时使用 14 天
+const DefaultLoanPeriod = 14 * 24 * time.Hour
+
+// PeriodDuration 解析借阅期限，未配置或不合法时返回 DefaultLoanPeriod
+func (c LoanConfig) PeriodDuration() time.Duration {
+	return Duration(c.Period, DefaultLoanPeriod)
+}
+
+// EventsConfig 领域事件从 outbox 投递到 sink
+type EventsConfig struct {
+	PollInterval string            `mapstructure:"poll_interval"` // 没有新事件时多久查询一次 outbox，默认 "1s"
+	BatchSize    int               `mapstructure:"batch_size"`    // 每次读取的事件数，默认 100
+	Retention    string            `mapstructure:"retention"`     // 所有 sink 都已投递的事件保留多久后删除，默认 "168h"
+	GapRetention string            `mapstructure:"gap_retention"` // 越过的 ID 区间持续查询多久，仍没有事件才视为已回滚，默认 "1h"
+	Sinks        []EventSinkConfig `mapstructure:"sinks"`
+}
+
+// EventSinkConfig 一个事件 sink，同时也是一个消费者：投递进度按 name 记录在 outbox_offsets 中，
+// 改名相当于新的消费者，会从仍保留的最早事件开始投递
+type EventSinkConfig struct {
+	Name  string   `mapstructure:"name"`
+	Type  string   `mapstructure:"type"`  // log、redis_stream 或 webhook
+	Types []string `mapstructure:"types"` // 只投递这些事件类型，为空时投递全部
+
+	Stream string `mapstructure:"stream"`  // redis_stream：stream 名，默认 library:events
+	MaxLen int64  `mapstructure:"max_len"` // redis_stream：stream 保留的大致条数，0 表示不限制
+
+	URL     string            `mapstructure:"url"`     // webhook：接收事件的地址
+	Headers map[string]string `mapstructure:"headers"` // webhook：附加的请求头，如鉴权 token
+	Timeout string            `mapstructure:"timeout"` // webhook：单次请求超时，默认 "5s"
+}
+
+// WebhooksConfig 通过 /admin/webhooks 管理的 webhook 订阅的投递：
+// 失败后按 backoff、2×backoff、4×backoff……（不超过 max_backoff）重试，共尝试 max_attempts 次后进入死信
+type WebhooksConfig struct {
+	Timeout      string `mapstructure:"timeout"`       // 单次请求超时，默认 "10s"
+	MaxAttempts  int    `mapstructure:"max_attempts"`  // 最多尝试次数，默认 8
+	Backoff      string `mapstructure:"backoff"`       // 第一次重试前的等待时间，默认 "10s"
+	MaxBackoff   string `mapstructure:"max_backoff"`   // 重试间隔的上限，默认 "1h"
+	PollInterval string `mapstructure:"poll_interval"` // 多久查询一次到期的投递，默认 "1s"
+	Concurrency  int    `mapstructure:"concurrency"`   // 同时进行的请求数，默认 4
+	Retention    string `mapstructure:"retention"`     // 已结束（成功或死信）的投递记录保留多久，默认 "720h"
 }
+
+// NotifyConfig 学生邮件通知：到期提醒、逾期通知、预约到馆和账户消息
+type NotifyConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`        // 是否扫描借阅、处理账户事件并发送邮件，默认 true
+	Transport     string `mapstructure:"transport"`      // smtp 或 maildir（写入本地目录，便于开发测试），默认 maildir
+	From          string `mapstructure:"from"`           // 发件人，如 "图书馆 <library@example.com>"
+	DefaultLocale string `mapstructure:"default_locale"` // 学生没有设置语言时使用，zh-CN 或 en，默认 zh-CN
+
+	DueSoon        string `mapstructure:"due_soon"`        // 到期前多久发送到期提醒，默认 "48h"
+	OverdueRepeat  string `mapstructure:"overdue_repeat"`  // 逾期后每隔多久再发一次逾期通知，默认 "168h"
+	OverdueNotices int    `mapstructure:"overdue_notices"` // 每笔借阅最多发送的逾期通知数，默认 3
+	ScanInterval   string `mapstructure:"scan_interval"`   // 多久扫描一次借阅，默认 "15m"
+
+	PollInterval string `mapstructure:"poll_interval"` // 多久查询一次待发送的邮件，默认 "5s"
+	MaxAttempts  int    `mapstructure:"max_attempts"`  // 最多尝试次数，之后标记为发送失败，默认 5
+	Backoff      string `mapstructure:"backoff"`       // 第一次重试前的等待时间，之后每次翻倍，默认 "1m"
+	MaxBackoff   string `mapstructure:"max_backoff"`   // 重试间隔上限，默认 "6h"
+	Retention    string `mapstructure:"retention"`     // 已发送和发送失败的邮件保留多久，默认 "2160h"（90 天）
+
+	SMTP    SMTPConfig `mapstructure:"smtp"`
+	Maildir string     `mapstructure:"maildir"` // transport 为 maildir 时写入的目录，默认 "mail"
+}
+
+// SMTPConfig transport 为 smtp 时的邮件服务器
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`     // 默认 587
+	Username string `mapstructure:"username"` // 为空时不认证
+	Password string `mapstructure:"password"` // 可以用 APP_NOTIFY_SMTP_PASSWORD_FILE 从文件读取
+	TLS      string `mapstructure:"tls"`      // starttls（默认）、tls（如 465 端口）或 none（仅限本机中继）
+	Timeout  string `mapstructure:"timeout"`  // 连接和发送一封邮件的超时，默认 "30s"
+}
+
+// TracingConfig OpenTelemetry 链路追踪
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	ServiceName string            `mapstructure:"service_name"`
+	Exporter    string            `mapstructure:"exporter"` // otlp、stdout 或 file
+	Endpoint    string            `mapstructure:"endpoint"` // OTLP 接收端地址，如 localhost:4317（grpc）或 localhost:4318（http）
+	Protocol    string            `mapstructure:"protocol"` // OTLP 协议：grpc 或 http
+	Insecure    bool              `mapstructure:"insecure"` // OTLP 不使用 TLS
+	Headers     map[string]string `mapstructure:"headers"`  // OTLP 请求头，如鉴权 token
+	File        string            `mapstructure:"file"`     // exporter 为 file 时写入的文件
+	SampleRatio float64           `mapstructure:"sample_ratio"`
+}
+
 type LogConfig struct {
 	Level      string `mapstructure:"level"`
 	Filename   string `mapstructure:"filename"`
@@ -63,18 +339,120 @@ type LogConfig struct {
 	MaxBackups int    `mapstructure:"max_backups"`
 }
 
+// AppConfig 启动时加载的配置。运行中可热更新的部分（限流、CORS、日志级别）请通过 Current 读取
 var AppConfig Config
 
-func InitConfig() {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
+// EnvPrefix 环境变量前缀：database.password 对应 APP_DATABASE_PASSWORD，
+// APP_DATABASE_PASSWORD_FILE 则从文件读取（适合 Docker / Kubernetes secret）
+const EnvPrefix = "APP"
+
+// 未通过命令行参数指定时，从这两个环境变量读取配置文件路径和运行环境
+const (
+	ConfigPathEnv = EnvPrefix + "_CONFIG"
+	ProfileEnv    = EnvPrefix + "_ENV"
+)
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %s", err)
+// InitConfig 读取配置到 AppConfig。
+// path 为空时依次取 APP_CONFIG、当前目录的 config.yaml；profile（dev、test、prod 等）为空时取 APP_ENV，
+// 不为空时再叠加同目录下的 config.<profile>.yaml。环境变量的优先级最高。
+func InitConfig(path, profile string) error {
+	if path == "" {
+		path = os.Getenv(ConfigPathEnv)
+	}
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
 	}
-	if err := viper.Unmarshal(&AppConfig); err != nil {
-		log.Fatalf("Unable to decode into struct: %v", err)
+	cfg, files, err := load(path, profile)
+	if err != nil {
+		return err
 	}
+	AppConfig = *cfg
+	current.Store(cfg)
+	source = configSource{path: path, profile: profile, files: files}
 	log.Println("Configuration loaded successfully")
+	return nil
+}
+
+// Files 实际读取的配置文件，基础配置在前
+func Files() []string {
+	return source.files
+}
+
+// load 读取配置文件和环境变量，不修改全局状态，热更新时也用它重新加载
+func load(path, profile string) (*Config, []string, error) {
+	v := viper.New()
+	setDefaults(v)
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	files := []string{v.ConfigFileUsed()}
+	if profile != "" {
+		base := v.ConfigFileUsed()
+		ext := filepath.Ext(base)
+		overlay := strings.TrimSuffix(base, ext) + "." + profile + ext
+		v.SetConfigFile(overlay)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("error reading config file for profile %q: %w", profile, err)
+		}
+		files = append(files, overlay)
+	}
+	if err := bindEnv(v); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := new(Config)
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, nil, fmt.Errorf("unable to decode into struct: %w", err)
+	}
+	return cfg, files, nil
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.read_timeout", "15s")
+	v.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("server.idle_timeout", "60s")
+	v.SetDefault("server.shutdown_timeout", "30s")
+	v.SetDefault("database.auto_migrate", true)
+	v.SetDefault("store.driver", "redis")
+	v.SetDefault("cache.enabled", true)
+	v.SetDefault("cache.ttl", "5m")
+	v.SetDefault("events.poll_interval", "1s")
+	v.SetDefault("events.batch_size", 100)
+	v.SetDefault("events.retention", "168h")
+	v.SetDefault("events.gap_retention", "1h")
+	v.SetDefault("webhooks.timeout", "10s")
+	v.SetDefault("webhooks.max_attempts", 8)
+	v.SetDefault("webhooks.backoff", "10s")
+	v.SetDefault("webhooks.max_backoff", "1h")
+	v.SetDefault("webhooks.poll_interval", "1s")
+	v.SetDefault("webhooks.concurrency", 4)
+	v.SetDefault("webhooks.retention", "720h")
+	v.SetDefault("notify.enabled", true)
+	v.SetDefault("notify.transport", "maildir")
+	v.SetDefault("notify.from", "Library <library@localhost>")
+	v.SetDefault("notify.default_locale", "zh-CN")
+	v.SetDefault("notify.due_soon", "48h")
+	v.SetDefault("notify.overdue_repeat", "168h")
+	v.SetDefault("notify.overdue_notices", 3)
+	v.SetDefault("notify.scan_interval", "15m")
+	v.SetDefault("notify.poll_interval", "5s")
+	v.SetDefault("notify.max_attempts", 5)
+	v.SetDefault("notify.backoff", "1m")
+	v.SetDefault("notify.max_backoff", "6h")
+	v.SetDefault("notify.retention", "2160h")
+	v.SetDefault("notify.maildir", "mail")
+	v.SetDefault("notify.smtp.port", 587)
+	v.SetDefault("notify.smtp.tls", "starttls")
+	v.SetDefault("notify.smtp.timeout", "30s")
+	v.SetDefault("tracing.service_name", "trae-go")
+	v.SetDefault("tracing.exporter", "stdout")
+	v.SetDefault("tracing.protocol", "grpc")
+	v.SetDefault("tracing.sample_ratio", 1.0)
 }
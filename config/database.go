@@ -3,69 +3,147 @@ package config
 import (
 	"context"
 	"fmt"
-	"log"
+	"net"
+	"strings"
 	"time"
 
-	"trae-go/models"
-
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
+// InitDatabase 按 AppConfig 打开数据库
 func InitDatabase() (*gorm.DB, error) {
+	return OpenDatabase(AppConfig.Database, AppConfig.Tracing.Enabled)
+}
+
+// OpenDatabase 按 cfg 打开数据库并设置连接池，tracing 为 true 时每条 SQL 记录为子 span
+func OpenDatabase(cfg DatabaseConfig, tracing bool) (*gorm.DB, error) {
 	//适配
-	var dialector gorm.Dialector
-	switch AppConfig.Database.Driver {
+	var dsn string
+	switch cfg.Driver {
 	case "postgres":
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
-			AppConfig.Database.Host,
-			AppConfig.Database.User,
-			AppConfig.Database.Password,
-			AppConfig.Database.DBName,
-			AppConfig.Database.Port,
-			AppConfig.Database.SSLMode,
-			AppConfig.Database.TimeZone,
+		dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+			cfg.Host,
+			cfg.User,
+			cfg.Password,
+			cfg.DBName,
+			cfg.Port,
+			cfg.SSLMode,
+			cfg.TimeZone,
 		)
-		dialector = postgres.Open(dsn)
+	case "mysql":
+		var err error
+		if dsn, err = mysqlDSN(cfg); err != nil {
+			return nil, err
+		}
 	case "sqlite":
-		dialector = sqlite.Open(AppConfig.Database.DSN)
-	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", AppConfig.Database.Driver)
+		dsn = cfg.DSN
+	}
+	dialector, err := Dialector(cfg.Driver, dsn)
+	if err != nil {
+		return nil, err
 	}
 
 	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
+	if tracing {
+		// 每条 SQL 记录为子 span；不记录参数值，避免密码哈希等数据进入 trace
+		if err := db.Use(otelgorm.NewPlugin(otelgorm.WithoutMetrics(), otelgorm.WithoutQueryVariables())); err != nil {
+			return nil, err
+		}
+	}
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
-	d, err := time.ParseDuration(AppConfig.Database.ConnMaxLifetime)
-	if err != nil {
-		log.Fatalf("MaxLifetime parse failed,use default setting")
-		d = time.Hour
+	sqlDB.SetMaxIdleConns(int(cfg.MaxIdleConns))
+	sqlDB.SetMaxOpenConns(int(cfg.MaxOpenConns))
+	sqlDB.SetConnMaxLifetime(cfg.ConnLifetime())
+	// 表结构由 migrations 包中的版本化迁移维护，见 `go run . migrate`
+	return db, nil
+}
+
+// Dialector 按驱动和连接串创建 GORM dialector，只读副本的 dsn 也通过它打开
+func Dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "mysql":
+		return mysql.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(sqliteDSN(dsn)), nil
 	}
-	sqlDB.SetMaxIdleConns(int(AppConfig.Database.MaxIdleConns))
-	sqlDB.SetMaxOpenConns(int(AppConfig.Database.MaxOpenConns))
-	sqlDB.SetConnMaxLifetime(d)
-	if err := db.AutoMigrate(&models.Book{}, &models.Student{}, &models.Book_Student{}, &models.User{}); err != nil {
-		return nil, err
+	return nil, fmt.Errorf("unsupported database driver: %s", driver)
+}
+
+// sqliteDSN SQLite 默认不检查外键，需要在连接参数里打开
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_foreign_keys") || strings.Contains(dsn, "_fk") {
+		return dsn
 	}
-	return db, nil
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_foreign_keys=on"
+	}
+	return dsn + "?_foreign_keys=on"
 }
 
+// mysqlDSN MySQL / MariaDB 的连接参数。设置了 dsn 时直接使用，否则由 host、port 等字段拼出；
+// 时间字段需要 parseTime 才能读回 time.Time，loc 取 timezone（默认 UTC）
+func mysqlDSN(cfg DatabaseConfig) (string, error) {
+	if cfg.DSN != "" {
+		return cfg.DSN, nil
+	}
+	c := mysqldriver.NewConfig()
+	c.User = cfg.User
+	c.Passwd = cfg.Password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(cfg.Host, cfg.Port)
+	c.DBName = cfg.DBName
+	c.ParseTime = true
+	c.Params = map[string]string{"charset": "utf8mb4"}
+	if cfg.TimeZone != "" {
+		loc, err := time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return "", fmt.Errorf("database.timezone: %w", err)
+		}
+		c.Loc = loc
+	}
+	// 与 PostgreSQL 的 sslmode 取值对应：require 只加密不校验证书，verify-ca / verify-full 校验证书
+	switch cfg.SSLMode {
+	case "", "disable":
+	case "require":
+		c.TLSConfig = "skip-verify"
+	case "verify-ca", "verify-full":
+		c.TLSConfig = "true"
+	default:
+		return "", fmt.Errorf("database.sslmode: %q is not supported for mysql", cfg.SSLMode)
+	}
+	return c.FormatDSN(), nil
+}
+
+// InitRedis 按 AppConfig 连接 Redis
 func InitRedis() (*redis.Client, error) {
+	return OpenRedis(AppConfig.Redis)
+}
+
+// OpenRedis 按 cfg 连接 Redis，连不上时返回错误
+func OpenRedis(cfg RedisConfig) (*redis.Client, error) {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     AppConfig.Redis.Addr,
-		Password: AppConfig.Redis.Password,
-		DB:       AppConfig.Redis.DB,
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
 		return nil, err
 	}
 	return rdb, nil
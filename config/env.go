@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// bindEnv 把 Config 中的每个配置项绑定到对应的环境变量，并处理 _FILE 形式的密钥文件。
+// viper 的 AutomaticEnv 只对配置文件里出现过的 key 生效，这里按结构体逐项绑定，
+// 配置文件中没写的项也能用环境变量设置
+func bindEnv(v *viper.Viper) error {
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		name := envName(key)
+		if err := v.BindEnv(key, name); err != nil {
+			return err
+		}
+		file := os.Getenv(name + "_FILE")
+		if file == "" {
+			continue
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read %s_FILE: %w", name, err)
+		}
+		// 密钥文件末尾通常带换行
+		v.Set(key, strings.TrimRight(string(b), "\r\n"))
+	}
+	return nil
+}
+
+// envName 配置项对应的环境变量名，如 database.MaxOpenConns 对应 APP_DATABASE_MAXOPENCONNS
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// configKeys 列出结构体中所有配置项的 key，map 和切片整体作为一项
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + tag
+		if f.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(f.Type, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
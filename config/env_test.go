@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// baseYAML 能通过 Validate 的最小配置
+const baseYAML = `
+server:
+  port: "8080"
+database:
+  driver: sqlite
+  dsn: library.db
+  ConnMaxLifetime: 1h
+store:
+  driver: memory
+ratelimit:
+  global_limit: 100
+  ip_limit: 10
+log:
+  level: info
+  filename: logs/app.log
+`
+
+// writeConfig 在临时目录中写入配置文件，返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvName(t *testing.T) {
+	for key, want := range map[string]string{
+		"server.port":           "APP_SERVER_PORT",
+		"database.MaxOpenConns": "APP_DATABASE_MAXOPENCONNS",
+		"notify.smtp.password":  "APP_NOTIFY_SMTP_PASSWORD",
+		"ratelimit.ip.limit":    "APP_RATELIMIT_IP_LIMIT",
+	} {
+		if got := envName(key); got != want {
+			t.Errorf("envName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestConfigKeys(t *testing.T) {
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"server.port", true},
+		{"database.ConnMaxLifetime", true},
+		{"notify.smtp.host", true},   // 嵌套结构体展开
+		{"ratelimit.ip.limit", true}, // 结构体类型的策略同样展开
+		{"database.replicas", true},  // 切片整体作为一项
+		{"ratelimit.policies", true}, // map 整体作为一项
+		{"notify.smtp", false},
+		{"ratelimit.ip", false},
+		{"ratelimit.policies.login", false},
+	}
+	for _, tt := range tests {
+		if got := slices.Contains(keys, tt.key); got != tt.want {
+			t.Errorf("configKeys contains %q = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	path := writeConfig(t, baseYAML)
+	// 配置文件中没有出现的项也能用环境变量设置
+	t.Setenv("APP_SERVER_PORT", "9090")
+	t.Setenv("APP_REDIS_ADDR", "redis:6379")
+	cfg, _, err := load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "9090" || cfg.Redis.Addr != "redis:6379" {
+		t.Fatalf("server.port = %q, redis.addr = %q", cfg.Server.Port, cfg.Redis.Addr)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no newline", "s3cret", "s3cret"},
+		{"trailing newline", "s3cret\n", "s3cret"},
+		{"crlf", "s3cret\r\n", "s3cret"},
+		{"blank lines", "s3cret\n\n", "s3cret"},
+		// 只去掉末尾的换行，空格和中间的换行属于密钥本身
+		{"spaces kept", " s3cret \n", " s3cret "},
+		{"inner newline kept", "line1\nline2\n", "line1\nline2"},
+	}
+	path := writeConfig(t, baseYAML)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := filepath.Join(t.TempDir(), "secret")
+			if err := os.WriteFile(secret, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("APP_DATABASE_PASSWORD_FILE", secret)
+			cfg, _, err := load(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Database.Password != tt.want {
+				t.Fatalf("database.password = %q, want %q", cfg.Database.Password, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("APP_DATABASE_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+		if _, _, err := load(path, ""); err == nil {
+			t.Fatal("load succeeded with a missing secret file")
+		}
+	})
+}
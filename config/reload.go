@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce 编辑器保存文件时常连续触发多个事件，合并为一次重新加载
+const reloadDebounce = 300 * time.Millisecond
+
+// configSource 启动时的加载参数，热更新时按同样的参数重新加载
+type configSource struct {
+	path    string
+	profile string
+	files   []string
+}
+
+var (
+	current atomic.Pointer[Config]
+	source  configSource
+
+	subscribersMu sync.Mutex
+	subscribers   []func(*Config)
+)
+
+// Current 返回当前生效的配置，热更新后立即可见。返回值只读，不要修改。
+// 没有调用过 InitConfig（如测试中直接设置 AppConfig）时返回 &AppConfig
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return &AppConfig
+}
+
+// OnReload 注册热更新回调，在新配置生效后调用
+func OnReload(fn func(*Config)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// ReloadResult 一次热更新的结果
+type ReloadResult struct {
+	Applied         []string // 已生效的配置段
+	RestartRequired []string // 有改动但需要重启才能生效的配置段
+}
+
+// Reload 重新读取配置文件和环境变量。新配置校验通过后只替换可热更新的部分：
+// ratelimit、cors 和 log.level，其余改动记在 RestartRequired 中，需重启生效。
+// 需要重启的改动不会写入 Current，Current 始终是进程实际使用的配置，因此重启前每次重新加载都会再次报告它们；
+// 没有可热更新的改动时也不会通知 OnReload 的订阅者
+func Reload() (ReloadResult, error) {
+	var res ReloadResult
+	cfg, _, err := load(source.path, source.profile)
+	if err != nil {
+		return res, err
+	}
+	if errs := cfg.Validate(); len(errs) > 0 {
+		return res, errors.Join(errs...)
+	}
+
+	old := Current()
+	next := *old
+	next.RateLimit = cfg.RateLimit
+	next.Cors = cfg.Cors
+	next.Log.Level = cfg.Log.Level
+
+	ov, nv := reflect.ValueOf(*old), reflect.ValueOf(*cfg)
+	for i := 0; i < ov.NumField(); i++ {
+		name := ov.Type().Field(i).Tag.Get("mapstructure")
+		o, n := ov.Field(i).Interface(), nv.Field(i).Interface()
+		switch name {
+		case "ratelimit", "cors":
+			if !reflect.DeepEqual(o, n) {
+				res.Applied = append(res.Applied, name)
+			}
+		case "log":
+			ol, nl := o.(LogConfig), n.(LogConfig)
+			if ol.Level != nl.Level {
+				res.Applied = append(res.Applied, "log.level")
+			}
+			ol.Level = nl.Level
+			if ol != nl {
+				res.RestartRequired = append(res.RestartRequired, name)
+			}
+		default:
+			if !reflect.DeepEqual(o, n) {
+				res.RestartRequired = append(res.RestartRequired, name)
+			}
+		}
+	}
+	if len(res.Applied) == 0 {
+		return res, nil
+	}
+
+	current.Store(&next)
+	subscribersMu.Lock()
+	fns := slices.Clone(subscribers)
+	subscribersMu.Unlock()
+	for _, fn := range fns {
+		fn(&next)
+	}
+	return res, nil
+}
+
+// Watch 监听配置文件（含 profile 叠加文件），变化时调用 Reload，结果交给 report。ctx 结束后停止监听。
+// 监听的是文件所在目录，编辑器先写临时文件再改名、Kubernetes ConfigMap 替换符号链接时也能收到事件
+func Watch(ctx context.Context, report func(ReloadResult, error)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, f := range source.files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			w.Close()
+			return err
+		}
+		watched[abs] = true
+		dirs[filepath.Dir(abs)] = true
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer w.Close()
+		var timer *time.Timer
+		fire := make(chan struct{}, 1)
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				// ..data 是 ConfigMap 挂载目录中指向当前版本的符号链接
+				if !watched[filepath.Clean(ev.Name)] && filepath.Base(ev.Name) != "..data" {
+					continue
+				}
+				if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, func() {
+					select {
+					case fire <- struct{}{}:
+					default:
+					}
+				})
+			case <-fire:
+				report(Reload())
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				report(ReloadResult{}, err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// initTestConfig 以 baseYAML 和空的 test profile 叠加文件作为当前配置，返回叠加文件的路径。
+// 测试结束后恢复全局状态
+func initTestConfig(t *testing.T) string {
+	t.Helper()
+	oldApp, oldSource := AppConfig, source
+	subscribersMu.Lock()
+	oldSubscribers := subscribers
+	subscribers = nil
+	subscribersMu.Unlock()
+	t.Cleanup(func() {
+		AppConfig, source = oldApp, oldSource
+		current.Store(nil)
+		subscribersMu.Lock()
+		subscribers = oldSubscribers
+		subscribersMu.Unlock()
+	})
+
+	path := writeConfig(t, baseYAML)
+	overlay := strings.TrimSuffix(path, ".yaml") + ".test.yaml"
+	if err := os.WriteFile(overlay, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitConfig(path, "test"); err != nil {
+		t.Fatal(err)
+	}
+	return overlay
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name            string
+		overlay         string // 写入 profile 叠加文件，覆盖 baseYAML 中的值
+		applied         []string
+		restartRequired []string
+	}{
+		{name: "unchanged"},
+		{name: "ratelimit", overlay: "ratelimit:\n  ip_limit: 5\n", applied: []string{"ratelimit"}},
+		{name: "cors", overlay: "cors:\n  allow_origins: [\"https://example.com\"]\n", applied: []string{"cors"}},
+		{name: "log level", overlay: "log:\n  level: debug\n  filename: logs/app.log\n", applied: []string{"log.level"}},
+		{name: "log filename", overlay: "log:\n  level: info\n  filename: logs/other.log\n", restartRequired: []string{"log"}},
+		{
+			name:            "log level and filename",
+			overlay:         "log:\n  level: debug\n  filename: logs/other.log\n",
+			applied:         []string{"log.level"},
+			restartRequired: []string{"log"},
+		},
+		{
+			name:            "mixed",
+			overlay:         "server:\n  port: \"9090\"\ncors:\n  allow_origins: [\"https://example.com\"]\n",
+			applied:         []string{"cors"},
+			restartRequired: []string{"server"},
+		},
+		{name: "restart only", overlay: "server:\n  port: \"9090\"\n", restartRequired: []string{"server"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overlay := initTestConfig(t)
+			var notified []*Config
+			OnReload(func(c *Config) { notified = append(notified, c) })
+
+			if err := os.WriteFile(overlay, []byte(tt.overlay), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			res, err := Reload()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.Applied, tt.applied) || !slices.Equal(res.RestartRequired, tt.restartRequired) {
+				t.Fatalf("result = %+v, want applied %v, restart required %v", res, tt.applied, tt.restartRequired)
+			}
+			// 只有可热更新的部分有变化时才替换当前配置并通知订阅者
+			if want := min(len(tt.applied), 1); len(notified) != want {
+				t.Fatalf("subscribers notified %d times, want %d", len(notified), want)
+			}
+
+			// 需要重启的改动不写入当前配置，Current 始终反映进程实际使用的值
+			cur := Current()
+			if cur.Server.Port != "8080" || cur.Log.Filename != "logs/app.log" {
+				t.Fatalf("restart-only change stored: server.port = %q, log.filename = %q", cur.Server.Port, cur.Log.Filename)
+			}
+			if slices.Contains(tt.applied, "ratelimit") && cur.RateLimit.IPLimit != 5 {
+				t.Fatalf("ratelimit not applied: %+v", cur.RateLimit)
+			}
+			if slices.Contains(tt.applied, "log.level") && cur.Log.Level != "debug" {
+				t.Fatalf("log.level = %q", cur.Log.Level)
+			}
+
+			// 重启前每次重新加载都会再次报告这些改动
+			res, err = Reload()
+			if err != nil || !slices.Equal(res.RestartRequired, tt.restartRequired) || len(res.Applied) != 0 {
+				t.Fatalf("second reload = %+v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	overlay := initTestConfig(t)
+	if err := os.WriteFile(overlay, []byte("ratelimit:\n  ip_limit: 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reload(); err == nil {
+		t.Fatal("reload accepted an invalid config")
+	}
+	if Current().RateLimit.IPLimit != 10 {
+		t.Fatalf("invalid config applied: %+v", Current().RateLimit)
+	}
+}
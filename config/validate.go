@@ -0,0 +1,312 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate 检查配置是否完整、取值是否合法，返回发现的全部问题
+func (c *Config) Validate() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	duration := func(key, value string, required bool) {
+		if value == "" {
+			if required {
+				add("%s is required", key)
+			}
+			return
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			add("%s: %q is not a positive duration", key, value)
+		}
+	}
+
+	if p, err := strconv.Atoi(c.Server.Port); err != nil || p <= 0 || p > 65535 {
+		add("server.port: %q is not a valid port", c.Server.Port)
+	}
+	duration("server.read_timeout", c.Server.ReadTimeout, false)
+	duration("server.write_timeout", c.Server.WriteTimeout, false)
+	duration("server.idle_timeout", c.Server.IdleTimeout, false)
+	duration("server.shutdown_timeout", c.Server.ShutdownTimeout, false)
+	if c.Server.DrainDelay != "" {
+		if d, err := time.ParseDuration(c.Server.DrainDelay); err != nil || d < 0 {
+			add("server.drain_delay: %q is not a valid duration", c.Server.DrainDelay)
+		}
+	}
+	for _, p := range c.Server.TrustedProxies {
+		if _, err := ParseIPOrCIDR(p); err != nil {
+			add("server.trusted_proxies: %v", err)
+		}
+	}
+	switch c.Server.Mode {
+	case "", "debug", "release", "test":
+	default:
+		add("server.mode: %q must be debug, release or test", c.Server.Mode)
+	}
+
+	switch c.Database.Driver {
+	case "sqlite":
+		if c.Database.DSN == "" {
+			add("database.dsn is required for sqlite")
+		}
+	case "postgres", "mysql":
+		// mysql 也可以直接提供完整的 dsn
+		if c.Database.Driver == "mysql" && c.Database.DSN != "" {
+			break
+		}
+		for _, f := range [][2]string{
+			{"database.host", c.Database.Host},
+			{"database.port", c.Database.Port},
+			{"database.user", c.Database.User},
+			{"database.dbname", c.Database.DBName},
+		} {
+			if f[1] == "" {
+				add("%s is required for %s", f[0], c.Database.Driver)
+			}
+		}
+	case "":
+		add("database.driver is required")
+	default:
+		add("database.driver: unsupported driver %q", c.Database.Driver)
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		add("database.MaxOpenConns / MaxIdleConns must not be negative")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.MaxIdleConns must not exceed MaxOpenConns")
+	}
+	duration("database.ConnMaxLifetime", c.Database.ConnMaxLifetime, true)
+	duration("database.replica_check_interval", c.Database.ReplicaCheckInterval, false)
+	for i, dsn := range c.Database.Replicas {
+		if strings.TrimSpace(dsn) == "" {
+			add("database.replicas[%d] must not be empty", i)
+		}
+	}
+
+	switch c.Store.Driver {
+	case "redis":
+		if c.Redis.Addr == "" {
+			add("redis.addr is required when store.driver is redis")
+		}
+	case "memory":
+	default:
+		add("store.driver: %q must be redis or memory", c.Store.Driver)
+	}
+	duration("cache.ttl", c.Cache.TTL, false)
+	duration("auth.token_expire_hours", c.Auth.TokenExpireHours, false)
+	algorithm := func(key, value string) {
+		if value != "" && value != "sliding_window" && value != "token_bucket" {
+			add("%s: %q must be sliding_window or token_bucket", key, value)
+		}
+	}
+	policy := func(prefix string, p RateLimitPolicy) {
+		algorithm(prefix+".algorithm", p.Algorithm)
+		duration(prefix+".window", p.Window, false)
+		for _, ip := range p.AllowIPs {
+			if _, err := ParseIPOrCIDR(ip); err != nil {
+				add("%s.allow_ips: %v", prefix, err)
+			}
+		}
+	}
+	if c.RateLimit.GlobalPolicy().Limit <= 0 {
+		add("ratelimit.global.limit (or ratelimit.global_limit) must be positive")
+	}
+	policy("ratelimit.global", c.RateLimit.GlobalPolicy())
+	if c.RateLimit.IPPolicy().Limit <= 0 {
+		add("ratelimit.ip.limit (or ratelimit.ip_limit) must be positive")
+	}
+	policy("ratelimit.ip", c.RateLimit.IPPolicy())
+	names := make([]string, 0, len(c.RateLimit.Policies))
+	for name := range c.RateLimit.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prefix := "ratelimit.policies." + name
+		if name == "global" || name == "ip" {
+			add("%s: global and ip are reserved policy names", prefix)
+		}
+		p, _ := c.RateLimit.NamedPolicy(name)
+		if p.Limit <= 0 {
+			add("%s.limit must be positive", prefix)
+		}
+		policy(prefix, p)
+		switch {
+		case p.Key == "ip", p.Key == "user":
+		case p.Key == "api_key", strings.HasPrefix(p.Key, "header:") && len(p.Key) > len("header:"):
+			if len(p.Keys) == 0 && len(p.AllowKeys) == 0 {
+				add("%s.keys must not be empty when key is %s", prefix, p.Key)
+			}
+		default:
+			add("%s.key: %q must be ip, user, api_key or header:<name>", prefix, p.Key)
+		}
+		if len(p.Groups) == 0 {
+			add("%s.groups must not be empty", prefix)
+		}
+		for role, o := range p.Roles {
+			algorithm(prefix+".roles."+role+".algorithm", o.Algorithm)
+			duration(prefix+".roles."+role+".window", o.Window, false)
+		}
+	}
+
+	switch c.Log.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		add("log.level: %q must be debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.Filename == "" {
+		add("log.filename is required")
+	}
+
+	duration("loan.period", c.Loan.Period, false)
+	if c.Tracing.Enabled {
+		switch c.Tracing.Exporter {
+		case "otlp":
+			if c.Tracing.Endpoint == "" {
+				add("tracing.endpoint is required for otlp exporter")
+			}
+			if c.Tracing.Protocol != "grpc" && c.Tracing.Protocol != "http" {
+				add("tracing.protocol: %q must be grpc or http", c.Tracing.Protocol)
+			}
+		case "file":
+			if c.Tracing.File == "" {
+				add("tracing.file is required for file exporter")
+			}
+		case "stdout":
+		default:
+			add("tracing.exporter: %q must be otlp, stdout or file", c.Tracing.Exporter)
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			add("tracing.sample_ratio must be between 0 and 1")
+		}
+	}
+	duration("softdelete.retention", c.SoftDelete.Retention, false)
+	duration("softdelete.purge_interval", c.SoftDelete.PurgeInterval, false)
+
+	duration("events.poll_interval", c.Events.PollInterval, false)
+	duration("events.retention", c.Events.Retention, false)
+	duration("events.gap_retention", c.Events.GapRetention, false)
+	if c.Events.BatchSize < 0 {
+		add("events.batch_size must not be negative")
+	}
+	sinks := make(map[string]bool)
+	for i, s := range c.Events.Sinks {
+		prefix := fmt.Sprintf("events.sinks[%d]", i)
+		switch {
+		case s.Name == "":
+			add("%s.name is required", prefix)
+		case sinks[s.Name]:
+			add("%s.name: duplicate sink %q", prefix, s.Name)
+		case s.Name == "webhooks", s.Name == "notifications":
+			// 内置消费者：分发给 /admin/webhooks 中的订阅、发送账户通知邮件
+			add("%s.name: %s is a reserved sink name", prefix, s.Name)
+		}
+		sinks[s.Name] = true
+		switch s.Type {
+		case "log":
+		case "redis_stream":
+			if c.Store.Driver != "redis" {
+				add("%s: redis_stream requires store.driver redis", prefix)
+			}
+			if s.MaxLen < 0 {
+				add("%s.max_len must not be negative", prefix)
+			}
+		case "webhook":
+			if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("%s.url: %q must be an http(s) URL", prefix, s.URL)
+			}
+			duration(prefix+".timeout", s.Timeout, false)
+		default:
+			add("%s.type: %q must be log, redis_stream or webhook", prefix, s.Type)
+		}
+	}
+
+	duration("webhooks.timeout", c.Webhooks.Timeout, false)
+	duration("webhooks.backoff", c.Webhooks.Backoff, false)
+	duration("webhooks.max_backoff", c.Webhooks.MaxBackoff, false)
+	duration("webhooks.poll_interval", c.Webhooks.PollInterval, false)
+	duration("webhooks.retention", c.Webhooks.Retention, false)
+	if c.Webhooks.MaxAttempts < 0 {
+		add("webhooks.max_attempts must not be negative")
+	}
+	if c.Webhooks.Concurrency < 0 {
+		add("webhooks.concurrency must not be negative")
+	}
+
+	if c.Notify.Enabled {
+		switch c.Notify.Transport {
+		case "maildir":
+			if c.Notify.Maildir == "" {
+				add("notify.maildir is required for maildir transport")
+			}
+		case "smtp":
+			if c.Notify.SMTP.Host == "" {
+				add("notify.smtp.host is required for smtp transport")
+			}
+			if c.Notify.SMTP.Port <= 0 || c.Notify.SMTP.Port > 65535 {
+				add("notify.smtp.port: %d is not a valid port", c.Notify.SMTP.Port)
+			}
+			switch c.Notify.SMTP.TLS {
+			case "", "starttls", "tls", "none":
+			default:
+				add("notify.smtp.tls: %q must be starttls, tls or none", c.Notify.SMTP.TLS)
+			}
+			duration("notify.smtp.timeout", c.Notify.SMTP.Timeout, false)
+		default:
+			add("notify.transport: %q must be smtp or maildir", c.Notify.Transport)
+		}
+		if _, err := mail.ParseAddress(c.Notify.From); err != nil {
+			add("notify.from: %q is not a valid address", c.Notify.From)
+		}
+	}
+	switch c.Notify.DefaultLocale {
+	case "", "zh-CN", "en":
+	default:
+		add("notify.default_locale: %q must be zh-CN or en", c.Notify.DefaultLocale)
+	}
+	duration("notify.due_soon", c.Notify.DueSoon, false)
+	duration("notify.overdue_repeat", c.Notify.OverdueRepeat, false)
+	duration("notify.scan_interval", c.Notify.ScanInterval, false)
+	duration("notify.poll_interval", c.Notify.PollInterval, false)
+	duration("notify.backoff", c.Notify.Backoff, false)
+	duration("notify.max_backoff", c.Notify.MaxBackoff, false)
+	duration("notify.retention", c.Notify.Retention, false)
+	// 清理通知时去重键随之删除，保留时间不够长时同一提醒会再次发送
+	if retention, err := time.ParseDuration(c.Notify.Retention); err == nil {
+		for _, f := range [][2]string{
+			{"notify.due_soon", c.Notify.DueSoon},
+			{"notify.overdue_repeat", c.Notify.OverdueRepeat},
+		} {
+			if d, err := time.ParseDuration(f[1]); err == nil && retention <= d {
+				add("notify.retention must be longer than %s", f[0])
+			}
+		}
+	}
+	if c.Notify.OverdueNotices < 0 {
+		add("notify.overdue_notices must not be negative")
+	}
+	if c.Notify.MaxAttempts < 0 {
+		add("notify.max_attempts must not be negative")
+	}
+	return errs
+}
+
+// ParseIPOrCIDR 解析单个 IP 或网段，单个 IP 视为只包含它自己的网段
+func ParseIPOrCIDR(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
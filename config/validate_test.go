@@ -0,0 +1,101 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig 能通过 Validate 的最小配置
+func validConfig() *Config {
+	return &Config{
+		Server:    ServerConfig{Port: "8080"},
+		Database:  DatabaseConfig{Driver: "sqlite", DSN: "library.db", ConnMaxLifetime: "1h"},
+		Store:     StoreConfig{Driver: "memory"},
+		RateLimit: RateLimitConfig{GlobalLimit: 100, IPLimit: 10},
+		Log:       LogConfig{Level: "info", Filename: "logs/app.log"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errs := validConfig().Validate(); len(errs) != 0 {
+		t.Fatalf("valid config: %v", errs)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // 错误信息中应包含的内容
+	}{
+		{"port", func(c *Config) { c.Server.Port = "http" }, `server.port: "http" is not a valid port`},
+		{"timeout", func(c *Config) { c.Server.ReadTimeout = "-1s" }, `server.read_timeout: "-1s" is not a positive duration`},
+		{"trusted proxy", func(c *Config) { c.Server.TrustedProxies = []string{"proxy.local"} }, "server.trusted_proxies"},
+		{"mode", func(c *Config) { c.Server.Mode = "prod" }, `server.mode: "prod" must be debug, release or test`},
+		{"no driver", func(c *Config) { c.Database.Driver = "" }, "database.driver is required"},
+		{"sqlite dsn", func(c *Config) { c.Database.DSN = "" }, "database.dsn is required for sqlite"},
+		{"postgres host", func(c *Config) { c.Database.Driver = "postgres" }, "database.host is required for postgres"},
+		{"idle conns", func(c *Config) { c.Database.MaxOpenConns, c.Database.MaxIdleConns = 2, 5 }, "must not exceed MaxOpenConns"},
+		{"lifetime", func(c *Config) { c.Database.ConnMaxLifetime = "" }, "database.ConnMaxLifetime is required"},
+		{"redis addr", func(c *Config) { c.Store.Driver = "redis" }, "redis.addr is required"},
+		{"global limit", func(c *Config) { c.RateLimit.GlobalLimit = 0 }, "ratelimit.global.limit"},
+		{"allow ips", func(c *Config) { c.RateLimit.IP.AllowIPs = []string{"10.0.0.0/33"} }, "ratelimit.ip.allow_ips"},
+		{"reserved policy", func(c *Config) {
+			c.RateLimit.Policies = map[string]RateLimitPolicy{"ip": {Limit: 1, Groups: []string{"auth"}}}
+		}, "reserved policy names"},
+		{"policy key", func(c *Config) {
+			c.RateLimit.Policies = map[string]RateLimitPolicy{"p": {Limit: 1, Key: "cookie", Groups: []string{"auth"}}}
+		}, `ratelimit.policies.p.key: "cookie"`},
+		{"api key without keys", func(c *Config) {
+			c.RateLimit.Policies = map[string]RateLimitPolicy{"p": {Limit: 1, Key: "api_key", Groups: []string{"books"}}}
+		}, "ratelimit.policies.p.keys must not be empty"},
+		{"policy groups", func(c *Config) {
+			c.RateLimit.Policies = map[string]RateLimitPolicy{"p": {Limit: 1}}
+		}, "ratelimit.policies.p.groups must not be empty"},
+		{"role algorithm", func(c *Config) {
+			c.RateLimit.Policies = map[string]RateLimitPolicy{"p": {
+				Limit: 1, Groups: []string{"auth"}, Roles: map[string]RateLimitOverride{"admin": {Algorithm: "fixed"}},
+			}}
+		}, "ratelimit.policies.p.roles.admin.algorithm"},
+		{"purge retention", func(c *Config) { c.SoftDelete.Retention = "0s" }, `softdelete.retention: "0s" is not a positive duration`},
+		{"purge interval", func(c *Config) { c.SoftDelete.PurgeInterval = "-1h" }, `softdelete.purge_interval: "-1h" is not a positive duration`},
+		{"log level", func(c *Config) { c.Log.Level = "trace" }, `log.level: "trace"`},
+		{"log filename", func(c *Config) { c.Log.Filename = "" }, "log.filename is required"},
+		{"tracing exporter", func(c *Config) { c.Tracing = TracingConfig{Enabled: true, Exporter: "jaeger"} }, "tracing.exporter"},
+		{"gap retention", func(c *Config) { c.Events.GapRetention = "0s" }, `events.gap_retention: "0s" is not a positive duration`},
+		{"sink name", func(c *Config) {
+			c.Events.Sinks = []EventSinkConfig{{Name: "webhooks", Type: "log"}}
+		}, "webhooks is a reserved sink name"},
+		{"sink url", func(c *Config) {
+			c.Events.Sinks = []EventSinkConfig{{Name: "audit", Type: "webhook", URL: "ftp://example.com"}}
+		}, "events.sinks[0].url"},
+		{"smtp port", func(c *Config) {
+			c.Notify = NotifyConfig{Enabled: true, Transport: "smtp", From: "library@example.com", SMTP: SMTPConfig{Host: "smtp.example.com"}}
+		}, "notify.smtp.port: 0"},
+		{"notify retention", func(c *Config) {
+			c.Notify.Retention, c.Notify.OverdueRepeat = "24h", "168h"
+		}, "notify.retention must be longer than notify.overdue_repeat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.modify(c)
+			errs := c.Validate()
+			var msgs []string
+			for _, err := range errs {
+				msgs = append(msgs, err.Error())
+			}
+			if !strings.Contains(strings.Join(msgs, "\n"), tt.want) {
+				t.Fatalf("errors %q do not mention %q", msgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	c := validConfig()
+	c.Server.Port = ""
+	c.Database.Driver = ""
+	c.Log.Filename = ""
+	if errs := c.Validate(); len(errs) != 3 {
+		t.Fatalf("got %d errors, want 3: %v", len(errs), errs)
+	}
+}
@@ -24,49 +24,75 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/books": {
+        "/admin/notifications": {
             "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "获取所有书籍信息",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "按 ID 倒序，含待发送、已发送和发送失败的通知；用上一页最后一条的 ID 作为 before_id 翻页（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "notifications"
+                ],
+                "summary": "获取通知发送历史",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "只看该学生的通知",
+                        "name": "student_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending、sent 或 failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "due_soon、overdue、hold_ready 或 account_created",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "只返回 ID 小于它的通知",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "条数，默认 50，最多 200",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "获取书籍列表",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Book"
+                                "$ref": "#/definitions/models.Notification"
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "查询参数无效",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            },
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "添加一本新书",
+                ]
+            }
+        },
+        "/admin/notifications/hold-ready": {
+            "post": {
+                "description": "通知学生预约的图书已到馆、需在 pickup_by 之前领取。系统目前没有预约功能，由馆员或外部系统调用；\n同一学生、图书和领取期限只发送一次（仅管理员）",
                 "consumes": [
                     "application/json"
                 ],
@@ -74,58 +100,73 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "notifications"
                 ],
-                "summary": "创建书籍",
+                "summary": "发送预约到馆通知",
                 "parameters": [
                     {
-                        "description": "书籍信息",
+                        "description": "预约信息",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Book"
+                            "$ref": "#/definitions/handlers.HoldReadyRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/models.Book"
+                            "$ref": "#/definitions/models.Notification"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "字段校验失败或 pickup_by 已过",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "学生或图书未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "已发送过（NOTIFICATION_DUPLICATE）或学生关闭了这类通知（NOTIFICATION_OPTED_OUT）",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "学生没有邮箱",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/books/{id}": {
-            "get": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据 ID 获取书籍详情",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/notifications/{id}": {
+            "get": {
+                "description": "含收件人、渲染后的主题和正文、尝试次数和最后一次错误（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "notifications"
                 ],
-                "summary": "获取单本书籍",
+                "summary": "获取通知详情",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "书籍 ID",
+                        "description": "通知 ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -135,167 +176,141 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Book"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/models.Notification"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "通知未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            },
-            "put": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据 ID 更新书籍信息",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/notifications/{id}/resend": {
+            "post": {
+                "description": "把已发送或发送失败的通知重新排入队列，发送相同的内容并重新开始计算尝试次数（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "notifications"
                 ],
-                "summary": "更新书籍",
+                "summary": "重新发送通知",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "书籍 ID",
+                        "description": "通知 ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "更新信息",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Book"
-                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/models.Book"
+                            "$ref": "#/definitions/models.Notification"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "通知未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "通知仍在等待发送",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据 ID 删除书籍",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/status": {
+            "get": {
+                "description": "各依赖的检查结果和延迟、数据库 / Redis 连接池统计、迁移情况、版本和运行时长（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "books"
-                ],
-                "summary": "删除书籍",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "书籍 ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "获取实例运行状态",
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/students": {
-            "get": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "获取所有学生信息",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "description": "全部 webhook 订阅，不含签名密钥（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "webhooks"
                 ],
-                "summary": "获取学生列表",
+                "summary": "获取 webhook 订阅列表",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Student"
+                                "$ref": "#/definitions/models.Webhook"
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            },
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "创建一个新学生",
+                ]
+            },
+            "post": {
+                "description": "订阅的事件发生后向 url 发送签名的 POST 请求。响应中的 secret 只返回这一次（仅管理员）",
                 "consumes": [
                     "application/json"
                 ],
@@ -303,17 +318,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "webhooks"
                 ],
-                "summary": "创建学生",
+                "summary": "创建 webhook 订阅",
                 "parameters": [
                     {
-                        "description": "学生信息",
+                        "description": "订阅信息",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/handlers.WebhookRequest"
                         }
                     }
                 ],
@@ -321,195 +336,182 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/handlers.WebhookCreated"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "字段校验失败、地址不是 http(s) 或事件类型未定义",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/students/{id}": {
-            "get": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据 ID 获取学生详情",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/webhooks/deliveries": {
+            "get": {
+                "description": "按 ID 倒序。status=dead 即死信列表；用上一页最后一条的 ID 作为 before_id 翻页（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "webhooks"
                 ],
-                "summary": "获取单个学生",
+                "summary": "获取 webhook 投递记录",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "学生 ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "只看该订阅的投递",
+                        "name": "webhook_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending、succeeded 或 dead",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "只返回 ID 小于它的投递",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "条数，默认 50，最多 200",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDelivery"
+                            }
                         }
                     },
                     "400": {
-                        "description": "ID 无效",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
-                        }
-                    },
-                    "404": {
-                        "description": "学生未找到",
+                        "description": "查询参数无效",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            },
-            "put": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据 ID 更新学生信息",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/webhooks/deliveries/{id}": {
+            "get": {
+                "description": "投递记录（含请求体）和每次请求的结果：状态码、错误、响应体开头和耗时（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "webhooks"
                 ],
-                "summary": "更新学生",
+                "summary": "获取 webhook 投递详情",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "学生 ID",
+                        "description": "投递 ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "更新信息",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Student"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/handlers.WebhookDeliveryDetail"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "投递未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据 ID 删除学生",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/webhooks/deliveries/{id}/redeliver": {
+            "post": {
+                "description": "把已送达或死信中的投递重新排入队列并立即发送，重新开始计算尝试次数（仅管理员）",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "students"
+                    "webhooks"
                 ],
-                "summary": "删除学生",
+                "summary": "重新投递",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "学生 ID",
+                        "description": "投递 ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "{\"msg\": \"student deleted\"}",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.WebhookDelivery"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "投递未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "投递仍在等待发送（DELIVERY_PENDING）或订阅已停用 / 删除（WEBHOOK_INACTIVE）",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/students/{id}/books": {
-            "get": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "获取指定学生的所有借阅记录（包含书籍信息）",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/admin/webhooks/{id}": {
+            "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "borrow"
+                    "webhooks"
                 ],
-                "summary": "获取学生借书记录",
+                "summary": "获取单个 webhook 订阅",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "学生 ID",
+                        "description": "订阅 ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -519,32 +521,30 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/models.Webhook"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "ID 无效",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "订阅未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/students/{student_id}/books/{book_id}/return": {
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "学生归还书籍",
+                ]
+            },
+            "put": {
+                "description": "不传 secret 时保留原密钥；停用（active 为 false）时尚未送达的投递转入死信（仅管理员）",
                 "consumes": [
                     "application/json"
                 ],
@@ -552,108 +552,89 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "borrow"
+                    "webhooks"
                 ],
-                "summary": "归还书籍",
+                "summary": "更新 webhook 订阅",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "学生 ID",
-                        "name": "student_id",
+                        "description": "订阅 ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "书籍 ID",
-                        "name": "book_id",
-                        "in": "path",
-                        "required": true
+                        "description": "订阅信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Book_Student"
+                            "$ref": "#/definitions/models.Webhook"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
+                        "description": "订阅未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/user/login": {
-            "post": {
-                "description": "使用用户名和密码登录，获取 Token",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "尚未送达的投递转入死信，投递记录保留到 webhooks.retention 后清理（仅管理员）",
                 "tags": [
-                    "user"
+                    "webhooks"
                 ],
-                "summary": "用户登录",
+                "summary": "删除 webhook 订阅",
                 "parameters": [
                     {
-                        "description": "登录请求参数",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.UserLoginRequest"
-                        }
+                        "type": "integer",
+                        "description": "订阅 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "{\"token\": \"xxx\", \"user\": {...}}",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "订阅未找到",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/user/profile": {
-            "put": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "更新当前登录用户的个人信息（需要认证）",
+                ]
+            }
+        },
+        "/books": {
+            "get": {
+                "description": "获取所有书籍信息",
                 "consumes": [
                     "application/json"
                 ],
@@ -661,51 +642,48 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "books"
                 ],
-                "summary": "更新个人资料",
+                "summary": "获取书籍列表",
                 "parameters": [
                     {
-                        "description": "更新信息",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.UserUpdateRequest"
-                        }
+                        "type": "boolean",
+                        "description": "包含已删除的书籍（仅管理员）",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
-                        }
-                    },
-                    "400": {
-                        "description": "无效的 JSON",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Book"
+                            }
                         }
                     },
-                    "401": {
-                        "description": "未授权",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
-                    "404": {
-                        "description": "用户未找到",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/user/register": {
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
             "post": {
-                "description": "创建新用户账号",
+                "description": "添加一本新书",
                 "consumes": [
                     "application/json"
                 ],
@@ -713,17 +691,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "books"
                 ],
-                "summary": "用户注册",
+                "summary": "创建书籍",
                 "parameters": [
                     {
-                        "description": "注册信息",
+                        "description": "书籍信息",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.UserRegisterRequest"
+                            "$ref": "#/definitions/handlers.BookCreateRequest"
                         }
                     }
                 ],
@@ -731,79 +709,94 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/models.Book"
                         }
                     },
                     "400": {
-                        "description": "无效的 JSON 或用户已存在",
-                        "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
-                        }
-                    },
-                    "500": {
-                        "description": "服务器内部错误",
+                        "description": "JSON 格式错误或字段校验失败（errors 中列出字段）",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/user/uploadAvatar": {
-            "post": {
-                "description": "上传用户头像文件，返回头像 URL",
+        "/books/{id}": {
+            "get": {
+                "description": "根据 ID 获取书籍详情",
                 "consumes": [
-                    "multipart/form-data"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "books"
                 ],
-                "summary": "上传头像",
+                "summary": "获取单本书籍",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "头像文件",
-                        "name": "avatar",
-                        "in": "formData",
+                        "type": "integer",
+                        "description": "书籍 ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "包含已删除的书籍（仅管理员）",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "上次获取的 ETag，未变化时返回 304",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "{\"avatar_url\": \"http://...\"}",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Book"
                         }
                     },
+                    "304": {
+                        "description": "Not Modified"
+                    },
                     "400": {
-                        "description": "文件获取失败",
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
-                    "500": {
-                        "description": "保存文件失败",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
-                }
-            }
-        },
-        "/user/{user_name}": {
-            "delete": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "根据用户名删除用户",
+                ]
+            },
+            "put": {
+                "description": "根据 ID 更新书籍信息，需要携带 GET 时返回的 ETag 作为 If-Match。库存由借还书维护，stock 与当前值不同时返回 422",
                 "consumes": [
                     "application/json"
                 ],
@@ -811,115 +804,2016 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "books"
                 ],
-                "summary": "删除用户",
+                "summary": "更新书籍",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "用户名",
-                        "name": "user_name",
+                        "type": "integer",
+                        "description": "书籍 ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    }
-                ],
-                "responses": {
+                    },
+                    {
+                        "type": "string",
+                        "description": "当前 ETag",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "更新信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BookUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        }
+                    },
+                    "400": {
+                        "description": "JSON 格式错误或字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ETag 不匹配，书籍已被修改",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "修改库存",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "缺少 If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "根据 ID 软删除书籍，管理员可通过恢复接口找回。存在未归还的借阅时拒绝删除（409）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "books"
+                ],
+                "summary": "删除书籍",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "书籍 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "archive",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "description": "已结束借阅的处理方式，archive 和 cascade 仅限管理员",
+                        "name": "history",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
                     "204": {
                         "description": "No Content"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "非管理员使用 archive 或 cascade",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
                     "404": {
-                        "description": "用户未找到",
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "存在未归还的借阅",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     },
                     "500": {
-                        "description": "删除失败",
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "patch": {
+                "description": "使用 JSON Merge Patch（RFC 7396）或 JSON Patch（RFC 6902）更新书籍，只允许修改 title、author、isbn",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "books"
+                ],
+                "summary": "部分更新书籍",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "书籍 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "当前 ETag",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "merge patch 对象或 JSON Patch 操作数组",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        }
+                    },
+                    "400": {
+                        "description": "补丁格式错误、包含未知字段或字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "JSON Patch test 操作失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ETag 不匹配",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "不支持的 Content-Type",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "修改只读字段",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "缺少 If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/books/{id}/restore": {
+            "post": {
+                "description": "恢复一本已软删除的书籍（仅管理员）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "books"
+                ],
+                "summary": "恢复书籍",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "书籍 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/students": {
+            "get": {
+                "description": "获取所有学生信息",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "获取学生列表",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "包含已删除的学生（仅管理员）",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Student"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/middleware.AppError"
+                            "$ref": "#/definitions/middleware.Problem"
                         }
                     }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "创建一个新学生",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "创建学生",
+                "parameters": [
+                    {
+                        "description": "学生信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StudentCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "JSON 格式错误或字段校验失败（errors 中列出字段）",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/students/{id}": {
+            "get": {
+                "description": "根据 ID 获取学生详情",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "获取单个学生",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "包含已删除的学生（仅管理员）",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "上次获取的 ETag，未变化时返回 304",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "ID 无效",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "非管理员请求已删除记录",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "学生未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "根据 ID 更新学生信息，需要携带 GET 时返回的 ETag 作为 If-Match",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "更新学生",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "当前 ETag",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "更新信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StudentUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "JSON 格式错误或字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ETag 不匹配，学生已被修改",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "缺少 If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "根据 ID 软删除学生，管理员可通过恢复接口找回。存在未归还的借阅时拒绝删除（409）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "删除学生",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "archive",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "description": "已结束借阅的处理方式，archive 和 cascade 仅限管理员",
+                        "name": "history",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "{\"msg\": \"student deleted\"}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "非管理员使用 archive 或 cascade",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "存在未归还的借阅",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "patch": {
+                "description": "使用 JSON Merge Patch（RFC 7396）或 JSON Patch（RFC 6902）更新学生，只允许修改 name、email",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "部分更新学生",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "当前 ETag",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "merge patch 对象或 JSON Patch 操作数组",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "补丁格式错误、包含未知字段或字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "JSON Patch test 操作失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ETag 不匹配",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "不支持的 Content-Type",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "修改只读字段",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "缺少 If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/students/{id}/books": {
+            "get": {
+                "description": "获取指定学生的所有借阅记录（包含书籍信息）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "borrow"
+                ],
+                "summary": "获取学生借书记录",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "包含已删除的学生（仅管理员）",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/students/{id}/notification-preferences": {
+            "get": {
+                "description": "学生没有设置过时返回默认值：全部开启，locale 为空（使用 notify.default_locale）",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "获取通知偏好",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NotificationPreference"
+                        }
+                    },
+                    "400": {
+                        "description": "ID 无效",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "学生未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "全量设置邮件语言和各类通知的开关。关闭后不再入队该类通知，已入队的仍会发送",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "设置通知偏好",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "通知偏好",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.NotificationPreferencesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NotificationPreference"
+                        }
+                    },
+                    "400": {
+                        "description": "字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "学生未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/students/{id}/restore": {
+            "post": {
+                "description": "恢复一个已软删除的学生（仅管理员）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "students"
+                ],
+                "summary": "恢复学生",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/students/{student_id}/books/{book_id}/return": {
+            "post": {
+                "description": "学生归还书籍",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "borrow"
+                ],
+                "summary": "归还书籍",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "学生 ID",
+                        "name": "student_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "书籍 ID",
+                        "name": "book_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book_Student"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "并发归还时已被另一个请求归还",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/user/login": {
+            "post": {
+                "description": "使用用户名和密码登录，获取 Token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "用户登录",
+                "parameters": [
+                    {
+                        "description": "登录请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "{\"token\": \"xxx\", \"user\": {...}}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/profile": {
+            "get": {
+                "description": "获取当前登录用户的信息，响应头中的 ETag 用于后续更新时的 If-Match",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "获取个人资料",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上次获取的 ETag，未变化时返回 304",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "401": {
+                        "description": "未授权",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "用户未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "更新当前登录用户的个人信息（需要认证），需要携带 GET 时返回的 ETag 作为 If-Match",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "更新个人资料",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "当前 ETag",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "更新信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "无效的 JSON 或字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "用户未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ETag 不匹配",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "缺少 If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "patch": {
+                "description": "使用 JSON Merge Patch（RFC 7396）或 JSON Patch（RFC 6902）更新当前用户，只允许修改 user_name、sex、born_date、avatar_url",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "部分更新个人资料",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "当前 ETag",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "merge patch 对象或 JSON Patch 操作数组",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "补丁格式错误、包含未知字段或字段校验失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "JSON Patch test 操作失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "ETag 不匹配",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "不支持的 Content-Type",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "修改只读字段",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "缺少 If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/user/register": {
+            "post": {
+                "description": "创建新用户账号",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "用户注册",
+                "parameters": [
+                    {
+                        "description": "注册信息",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UserRegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "无效的 JSON、字段校验失败或用户已存在",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "不能注册为管理员",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/uploadAvatar": {
+            "post": {
+                "description": "上传用户头像文件，返回头像 URL",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "上传头像",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "头像文件",
+                        "name": "avatar",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "{\"avatar_url\": \"http://...\"}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "文件获取失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "保存文件失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{user_name}": {
+            "delete": {
+                "description": "根据用户名软删除用户，已登录的 token 随即失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "删除用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户名",
+                        "name": "user_name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "用户未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "删除失败",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/user/{user_name}/restore": {
+            "post": {
+                "description": "恢复一个已软删除的用户（仅管理员）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "恢复用户",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户名",
+                        "name": "user_name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "403": {
+                        "description": "非管理员",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "用户未找到",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "用户未被删除",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "期间被并发修改",
+                        "schema": {
+                            "$ref": "#/definitions/middleware.Problem"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        }
+    },
+    "definitions": {
+        "handlers.BookCreateRequest": {
+            "type": "object",
+            "required": [
+                "title"
+            ],
+            "properties": {
+                "author": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Alan A. A. Donovan"
+                },
+                "isbn": {
+                    "type": "string",
+                    "example": "9780134190440"
+                },
+                "stock": {
+                    "type": "integer",
+                    "maximum": 100000,
+                    "example": 10
+                },
+                "title": {
+                    "type": "string",
+                    "maxLength": 200,
+                    "example": "The Go Programming Language"
+                }
+            }
+        },
+        "handlers.BookUpdateRequest": {
+            "type": "object",
+            "required": [
+                "title"
+            ],
+            "properties": {
+                "author": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "isbn": {
+                    "type": "string"
+                },
+                "stock": {
+                    "description": "只读",
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string",
+                    "maxLength": 200
+                }
+            }
+        },
+        "handlers.DBPoolStats": {
+            "type": "object",
+            "properties": {
+                "idle": {
+                    "type": "integer"
+                },
+                "in_use": {
+                    "type": "integer"
+                },
+                "max_idle_closed": {
+                    "type": "integer"
+                },
+                "max_idle_time_closed": {
+                    "type": "integer"
+                },
+                "max_lifetime_closed": {
+                    "type": "integer"
+                },
+                "max_open_connections": {
+                    "type": "integer"
+                },
+                "open_connections": {
+                    "type": "integer"
+                },
+                "wait_count": {
+                    "type": "integer"
+                },
+                "wait_duration": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DependencyStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number",
+                    "example": 0.42
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "handlers.HoldReadyRequest": {
+            "type": "object",
+            "required": [
+                "book_id",
+                "pickup_by",
+                "student_id"
+            ],
+            "properties": {
+                "book_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "pickup_by": {
+                    "description": "预约保留到何时，须晚于当前时间",
+                    "type": "string",
+                    "example": "2026-10-25T18:00:00+08:00"
+                },
+                "student_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "handlers.MigrationStatus": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "handlers.NotificationPreferencesRequest": {
+            "type": "object",
+            "required": [
+                "account",
+                "due_soon",
+                "hold_ready",
+                "overdue"
+            ],
+            "properties": {
+                "account": {
+                    "description": "账户消息",
+                    "type": "boolean"
+                },
+                "due_soon": {
+                    "description": "到期提醒",
+                    "type": "boolean"
+                },
+                "hold_ready": {
+                    "description": "预约到馆",
+                    "type": "boolean"
+                },
+                "locale": {
+                    "description": "为空时使用 notify.default_locale",
+                    "type": "string",
+                    "enum": [
+                        "zh-CN",
+                        "en"
+                    ],
+                    "example": "en"
+                },
+                "overdue": {
+                    "description": "逾期通知",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.RedisPoolStats": {
+            "type": "object",
+            "properties": {
+                "hits": {
+                    "type": "integer"
+                },
+                "idle_conns": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "stale_conns": {
+                    "type": "integer"
+                },
+                "timeouts": {
+                    "type": "integer"
+                },
+                "total_conns": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "db_pool": {
+                    "$ref": "#/definitions/handlers.DBPoolStats"
+                },
+                "dependencies": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handlers.DependencyStatus"
+                    }
+                },
+                "draining": {
+                    "type": "boolean"
+                },
+                "migrations": {
+                    "$ref": "#/definitions/handlers.MigrationStatus"
+                },
+                "redis_pool": {
+                    "description": "不使用 Redis 时省略",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.RedisPoolStats"
+                        }
+                    ]
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "uptime": {
+                    "type": "string",
+                    "example": "3h25m10s"
+                },
+                "uptime_seconds": {
+                    "type": "integer"
+                },
+                "version": {
+                    "$ref": "#/definitions/version.Info"
+                }
+            }
+        },
+        "handlers.StudentCreateRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "tom@example.com"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "example": "Tom"
+                }
+            }
+        },
+        "handlers.StudentUpdateRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 50
+                }
+            }
+        },
+        "handlers.UserLoginRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "user_name"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "user_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.UserRegisterRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "user_name"
+            ],
+            "properties": {
+                "avatar_url": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "born_date": {
+                    "description": "添加 example 提示格式",
+                    "type": "string",
+                    "example": "2006-01-02"
+                },
+                "ide": {
+                    "type": "string",
+                    "maxLength": 20,
+                    "example": "student"
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 6,
+                    "example": "123456"
+                },
+                "sex": {
+                    "type": "string",
+                    "maxLength": 10,
+                    "example": "male"
+                },
+                "user_name": {
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
+                    "example": "zhangsan"
+                }
+            }
+        },
+        "handlers.UserUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "born_date": {
+                    "type": "string"
+                },
+                "sex": {
+                    "type": "string",
+                    "maxLength": 10
+                },
+                "user_name": {
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3
+                }
+            }
+        },
+        "handlers.WebhookCreated": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "停用后不再产生新的投递",
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "description": "订阅的事件类型，\"*\" 表示全部",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "whsec_3f1c..."
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.UserLoginRequest": {
+        },
+        "handlers.WebhookDeliveryDetail": {
             "type": "object",
-            "required": [
-                "password",
-                "user_name"
-            ],
             "properties": {
-                "password": {
+                "attempt_log": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WebhookAttempt"
+                    }
+                },
+                "attempts": {
+                    "description": "本轮已尝试次数",
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "user_name": {
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "integer"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
                     "type": "string"
+                },
+                "last_status_code": {
+                    "description": "0 表示没有收到响应",
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "description": "仅 pending 时有值",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "请求体（JSON），每次尝试都发送相同内容",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "integer"
                 }
             }
         },
-        "handlers.UserRegisterRequest": {
+        "handlers.WebhookRequest": {
             "type": "object",
             "required": [
-                "password",
-                "user_name"
+                "event_types",
+                "url"
             ],
             "properties": {
-                "avatar_url": {
-                    "type": "string"
-                },
-                "born_date": {
-                    "description": "添加 example 提示格式",
-                    "type": "string",
-                    "example": "2006-01-02"
+                "active": {
+                    "description": "默认 true",
+                    "type": "boolean"
                 },
-                "ide": {
+                "description": {
                     "type": "string",
-                    "example": "student"
+                    "maxLength": 255,
+                    "example": "SIS 借阅同步"
                 },
-                "password": {
-                    "type": "string",
-                    "example": "123456"
+                "event_types": {
+                    "description": "\"*\" 表示全部事件",
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "book.borrowed",
+                        "book.returned"
+                    ]
                 },
-                "sex": {
+                "secret": {
+                    "description": "签名密钥，创建时不传则自动生成，更新时不传则保留原密钥",
                     "type": "string",
-                    "example": "male"
+                    "maxLength": 255,
+                    "minLength": 16
                 },
-                "user_name": {
+                "url": {
                     "type": "string",
-                    "example": "zhangsan"
+                    "maxLength": 2048,
+                    "example": "https://sis.example.com/hooks/library"
                 }
             }
         },
-        "handlers.UserUpdateRequest": {
+        "middleware.Problem": {
             "type": "object",
             "properties": {
-                "avatar_url": {
-                    "type": "string"
+                "code": {
+                    "type": "string",
+                    "example": "BOOK_NOT_FOUND"
                 },
-                "born_date": {
-                    "type": "string"
+                "detail": {
+                    "type": "string",
+                    "example": "book not found"
                 },
-                "sex": {
-                    "type": "string"
+                "details": {},
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/middleware.ProblemError"
+                    }
                 },
-                "user_name": {
+                "instance": {
+                    "type": "string",
+                    "example": "/api/v1/books/42"
+                },
+                "request_id": {
                     "type": "string"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Book not found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/book-not-found"
                 }
             }
         },
-        "middleware.AppError": {
+        "middleware.ProblemError": {
             "type": "object",
             "properties": {
                 "code": {
                     "type": "string"
                 },
+                "field": {
+                    "type": "string"
+                },
                 "message": {
                     "type": "string"
                 },
-                "statusCode": {
-                    "type": "integer"
+                "rule": {
+                    "type": "string"
                 }
             }
         },
@@ -930,6 +2824,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "book_students": {
+                    "description": "有借阅记录的书不能被物理删除；删除书时副本随之删除",
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/models.Book_Student"
@@ -944,9 +2839,15 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "isbn": {
+                    "type": "string"
+                },
                 "stock": {
                     "type": "integer"
                 },
@@ -955,6 +2856,10 @@ const docTemplate = `{
                 },
                 "updated_at": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "乐观锁版本号，每次更新加一",
+                    "type": "integer"
                 }
             }
         },
@@ -1031,6 +2936,87 @@ const docTemplate = `{
                 "BorrowStatusLost"
             ]
         },
+        "models.Notification": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "dedup_key": {
+                    "type": "string"
+                },
+                "html_body": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "locale": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "description": "仅 pending 时有值",
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "student_id": {
+                    "type": "integer"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "text_body": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.NotificationPreference": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "type": "boolean"
+                },
+                "due_soon": {
+                    "type": "boolean"
+                },
+                "hold_ready": {
+                    "type": "boolean"
+                },
+                "locale": {
+                    "description": "zh-CN 或 en，为空时使用 notify.default_locale",
+                    "type": "string"
+                },
+                "overdue": {
+                    "type": "boolean"
+                },
+                "student_id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
         "models.Student": {
             "type": "object",
             "properties": {
@@ -1043,6 +3029,9 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
@@ -1054,6 +3043,10 @@ const docTemplate = `{
                 },
                 "updated_at": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "乐观锁版本号，每次更新加一",
+                    "type": "integer"
                 }
             }
         },
@@ -1066,6 +3059,9 @@ const docTemplate = `{
                 "born_date": {
                     "type": "string"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -1080,6 +3076,139 @@ const docTemplate = `{
                 },
                 "user_name": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "乐观锁版本号，每次更新加一",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "停用后不再产生新的投递",
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "description": "订阅的事件类型，\"*\" 表示全部",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookAttempt": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "description": "本轮第几次尝试",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_id": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "网络错误或非 2xx 的说明",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "response": {
+                    "description": "响应体的开头部分",
+                    "type": "string"
+                },
+                "status_code": {
+                    "description": "0 表示没有收到响应",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "本轮已尝试次数",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "integer"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "description": "0 表示没有收到响应",
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "description": "仅 pending 时有值",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "请求体（JSON），每次尝试都发送相同内容",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "version.Info": {
+            "type": "object",
+            "properties": {
+                "build_time": {
+                    "type": "string"
+                },
+                "commit": {
+                    "type": "string"
+                },
+                "go_version": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
                 }
             }
         }
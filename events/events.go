@@ -0,0 +1,142 @@
+// Package events 领域事件：事件类型和数据、写入 outbox 的事件构造，以及把 outbox 投递到各个 sink 的 Relay。
+//
+// 事件与产生它的数据修改在同一事务中写入 outbox_events（见 service 包），事务回滚时事件也不存在；
+// Relay 按事件 ID 顺序投递，每个 sink 是一个独立的消费者，投递成功后才推进它的 offset，
+// 因此投递语义为至少一次（at-least-once），消费方应按事件 ID 去重。
+package events
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"trae-go/models"
+)
+
+// 事件类型，形如 <聚合>.<动作>
+const (
+	BookCreated  = "book.created"
+	BookUpdated  = "book.updated"
+	BookDeleted  = "book.deleted"
+	BookRestored = "book.restored"
+	BookBorrowed = "book.borrowed"
+	BookReturned = "book.returned"
+
+	StudentCreated  = "student.created"
+	StudentUpdated  = "student.updated"
+	StudentDeleted  = "student.deleted"
+	StudentRestored = "student.restored"
+
+	UserRegistered = "user.registered"
+)
+
+// Types 全部事件类型
+var Types = []string{
+	BookCreated, BookUpdated, BookDeleted, BookRestored, BookBorrowed, BookReturned,
+	StudentCreated, StudentUpdated, StudentDeleted, StudentRestored,
+	UserRegistered,
+}
+
+// Known 是否为已定义的事件类型
+func Known(typ string) bool {
+	return slices.Contains(Types, typ)
+}
+
+// Book book.created / updated / restored 的数据：修改后的图书
+type Book struct {
+	ID      uint   `json:"id"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	ISBN    string `json:"isbn"`
+	Stock   uint   `json:"stock"`
+	Version uint   `json:"version"`
+}
+
+func BookOf(b *models.Book) Book {
+	return Book{ID: b.ID, Title: b.Title, Author: b.Author, ISBN: b.ISBN, Stock: b.Stock, Version: b.Version}
+}
+
+// Student student.created / updated / restored 的数据
+type Student struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Version uint   `json:"version"`
+}
+
+func StudentOf(s *models.Student) Student {
+	return Student{ID: s.ID, Name: s.Name, Email: s.Email, Version: s.Version}
+}
+
+// Deleted book.deleted / student.deleted 的数据，History 为已结束借阅的处理方式（keep、archive、cascade）
+type Deleted struct {
+	ID      uint   `json:"id"`
+	History string `json:"history"`
+}
+
+// Loan book.borrowed / returned 的数据
+type Loan struct {
+	LoanID     uint       `json:"loan_id"`
+	BookID     uint       `json:"book_id"`
+	StudentID  uint       `json:"student_id"`
+	BorrowedAt time.Time  `json:"borrowed_at"`
+	ReturnedAt *time.Time `json:"returned_at,omitempty"`
+}
+
+func LoanOf(l *models.Book_Student) Loan {
+	loan := Loan{LoanID: l.ID, BookID: l.BookID, StudentID: l.StudentID, BorrowedAt: l.BorrowedAt}
+	if l.Status == models.BorrowStatusReturned {
+		t := l.ReturnedAt
+		loan.ReturnedAt = &t
+	}
+	return loan
+}
+
+// User user.registered 的数据，不含密码
+type User struct {
+	ID   int    `json:"id"`
+	Name string `json:"user_name"`
+	Role string `json:"role"`
+}
+
+func UserOf(u *models.User) User {
+	return User{ID: u.ID, Name: u.Name, Role: u.Identify}
+}
+
+// New 构造待写入 outbox 的事件，聚合类型取事件类型的前缀
+func New(typ string, aggregateID uint, data any) (*models.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	aggregate, _, _ := strings.Cut(typ, ".")
+	return &models.OutboxEvent{
+		Type:          typ,
+		AggregateType: aggregate,
+		AggregateID:   aggregateID,
+		Payload:       string(payload),
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// Event 投递给 sink 的事件
+type Event struct {
+	ID            uint            `json:"id"` // 全局递增，消费方用于去重
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uint            `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func eventOf(e models.OutboxEvent) Event {
+	return Event{
+		ID:            e.ID,
+		Type:          e.Type,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		OccurredAt:    e.CreatedAt,
+		Data:          json.RawMessage(e.Payload),
+	}
+}
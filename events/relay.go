@@ -0,0 +1,368 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"trae-go/config"
+	"trae-go/jobs"
+	"trae-go/models"
+	"trae-go/pkg/logger"
+	"trae-go/pkg/metrics"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultRetention    = 7 * 24 * time.Hour
+	defaultGapRetention = time.Hour
+
+	// leaseDuration 消费者租约的有效期，每投递一个事件续期一次
+	leaseDuration = 30 * time.Second
+	// gapTimeout 事件 ID 不连续时，为保持顺序等待更小 ID 的事务提交的最长时间，
+	// 超过后越过这些 ID 并记录到 outbox_gaps，之后提交的事件再补投递
+	gapTimeout = 10 * time.Second
+	// maxBackoff 投递失败后重试间隔的上限
+	maxBackoff = time.Minute
+	// cleanupInterval 清理已投递旧事件的间隔
+	cleanupInterval = time.Hour
+	// releaseTimeout 退出时释放租约的超时
+	releaseTimeout = 2 * time.Second
+)
+
+// ErrLeaseLost 投递期间租约被其他实例接管（通常是单个事件投递超过了租约有效期）
+var ErrLeaseLost = errors.New("consumer lease lost")
+
+// Relay 把 outbox 中的事件按 ID 顺序投递给各个消费者。
+// 多个实例可以同时运行：每个消费者同一时间只由持有租约的实例投递
+type Relay struct {
+	db        *gorm.DB
+	consumers []Consumer
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+	// gapRetention 越过的 ID 区间持续查询多久，仍没有事件才视为已回滚
+	gapRetention time.Duration
+	owner        string
+	now          func() time.Time
+}
+
+// NewRelay db 应使用主库（replica.Primary），避免副本延迟
+func NewRelay(db *gorm.DB, consumers []Consumer, cfg config.EventsConfig) *Relay {
+	host, _ := os.Hostname()
+	r := &Relay{
+		db:           db,
+		consumers:    consumers,
+		interval:     config.Duration(cfg.PollInterval, defaultPollInterval),
+		batchSize:    cfg.BatchSize,
+		retention:    config.Duration(cfg.Retention, defaultRetention),
+		gapRetention: config.Duration(cfg.GapRetention, defaultGapRetention),
+		owner:        fmt.Sprintf("%s-%d-%08x", host, os.Getpid(), rand.Uint32()),
+		now:          time.Now,
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatchSize
+	}
+	return r
+}
+
+// Run 为每个消费者启动投递循环并定期清理旧事件，直到 ctx 结束
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range r.consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.consume(ctx, c)
+		}()
+	}
+
+	log := logger.Ctx(ctx)
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := r.Cleanup(ctx); err != nil {
+			log.Error("clean up outbox events failed", zap.Error(err))
+		} else if n > 0 {
+			log.Info("clean up outbox events", zap.Int64("rows", n), zap.Duration("retention", r.retention))
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// consume 循环投递一个消费者的事件；失败时按指数退避重试同一事件，退出时释放租约
+func (r *Relay) consume(ctx context.Context, c Consumer) {
+	log := logger.Ctx(ctx).With(zap.String("sink", c.Name))
+	failures := 0
+	for {
+		n, err := r.Deliver(ctx, c)
+		wait := r.interval
+		switch {
+		case err != nil && ctx.Err() == nil:
+			failures++
+			wait = jobs.Backoff(time.Second, maxBackoff, failures)
+			log.Warn("deliver events failed, retrying", zap.Duration("retry_in", wait), zap.Error(err))
+		case n == r.batchSize:
+			// 可能还有积压，立即读取下一批
+			failures, wait = 0, 0
+		default:
+			failures = 0
+		}
+		select {
+		case <-ctx.Done():
+			r.release(context.WithoutCancel(ctx), c.Name)
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Deliver 为消费者 c 投递一批事件，返回处理的事件数（含按类型跳过的）。
+// 其他实例持有该消费者的租约时不投递，返回 0
+func (r *Relay) Deliver(ctx context.Context, c Consumer) (int, error) {
+	offset, ok, err := r.claim(ctx, c.Name)
+	if err != nil || !ok {
+		return 0, err
+	}
+	n, err := r.deliverGaps(ctx, c)
+	if err != nil {
+		return n, err
+	}
+
+	var batch []models.OutboxEvent
+	if err := r.db.WithContext(ctx).Where("id > ?", offset).Order("id").Limit(r.batchSize).Find(&batch).Error; err != nil {
+		return n, err
+	}
+	last := offset
+	for _, e := range batch {
+		if e.ID != last+1 {
+			// 更小的 ID 可能属于还没提交的事务，先等一会儿，多数情况下它很快提交，投递仍按 ID 顺序
+			if r.now().Sub(e.CreatedAt) < gapTimeout {
+				break
+			}
+			// 超过 gapTimeout 后越过这些 ID，但在推进 offset 的同一事务中记为缺口，之后提交的事件由 deliverGaps 补投递。
+			// 新消费者（offset 为 0）前面缺的是已清理的事件，不记录
+			if offset > 0 {
+				gap := models.OutboxGap{Consumer: c.Name, FirstID: last + 1, LastID: e.ID - 1, CreatedAt: r.now()}
+				err := r.update(ctx, c.Name, map[string]interface{}{"last_event_id": gap.LastID}, func(tx *gorm.DB) error {
+					return tx.Create(&gap).Error
+				})
+				if err != nil {
+					return n, err
+				}
+			}
+			last = e.ID - 1
+		}
+		if c.wants(e.Type) {
+			if err := c.Sink.Publish(ctx, eventOf(e)); err != nil {
+				metrics.EventsPublished.WithLabelValues(c.Name, "error").Inc()
+				if n > 0 {
+					err = errors.Join(err, r.advance(ctx, c.Name, last))
+				}
+				return n, fmt.Errorf("publish event %d: %w", e.ID, err)
+			}
+			metrics.EventsPublished.WithLabelValues(c.Name, "ok").Inc()
+			// 每送达一个事件就记录进度（同时续租），崩溃后最多重复投递一个事件
+			if err := r.advance(ctx, c.Name, e.ID); err != nil {
+				return n, err
+			}
+		}
+		n, last = n+1, e.ID
+	}
+	if last != offset {
+		if err := r.advance(ctx, c.Name, last); err != nil {
+			return n, err
+		}
+	}
+	r.recordLag(ctx, c.Name, last)
+	return n, nil
+}
+
+// claim 取得或续期消费者的租约，返回当前 offset 和是否持有租约。第一次投递时创建 offset 记录
+func (r *Relay) claim(ctx context.Context, name string) (uint, bool, error) {
+	db := r.db.WithContext(ctx)
+	now := r.now()
+	err := db.Model(&models.OutboxOffset{}).
+		Where("consumer = ? AND (owner = ? OR lease_until IS NULL OR lease_until < ?)", name, r.owner, now).
+		Updates(map[string]interface{}{"owner": r.owner, "lease_until": now.Add(leaseDuration), "updated_at": now}).Error
+	if err != nil {
+		return 0, false, err
+	}
+	var off models.OutboxOffset
+	err = db.Where("consumer = ?", name).Take(&off).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 多个实例同时创建时只有一个成功，其余的读到的 owner 不是自己
+		err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.OutboxOffset{
+			Consumer: name, Owner: r.owner, LeaseUntil: now.Add(leaseDuration), UpdatedAt: now,
+		}).Error
+		if err == nil {
+			err = db.Where("consumer = ?", name).Take(&off).Error
+		}
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return off.LastEventID, off.Owner == r.owner && off.LeaseUntil.After(now), nil
+}
+
+// deliverGaps 重新查询消费者越过的 ID 区间，投递其中后来提交的事件（不再按 ID 顺序），并把它们从区间中去掉。
+// 超过 gapRetention 仍没有事件的区间视为事务已回滚，删除
+func (r *Relay) deliverGaps(ctx context.Context, c Consumer) (int, error) {
+	db := r.db.WithContext(ctx)
+	var gaps []models.OutboxGap
+	if err := db.Where("consumer = ?", c.Name).Order("first_id").Find(&gaps).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, g := range gaps {
+		var found []models.OutboxEvent
+		if err := db.Where("id BETWEEN ? AND ?", g.FirstID, g.LastID).Order("id").Limit(r.batchSize).Find(&found).Error; err != nil {
+			return n, err
+		}
+		if len(found) == 0 {
+			if r.now().Sub(g.CreatedAt) < r.gapRetention {
+				continue
+			}
+			err := r.update(ctx, c.Name, map[string]interface{}{}, func(tx *gorm.DB) error {
+				return tx.Where("consumer = ? AND first_id = ?", g.Consumer, g.FirstID).Delete(&models.OutboxGap{}).Error
+			})
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		for _, e := range found {
+			if c.wants(e.Type) {
+				if err := c.Sink.Publish(ctx, eventOf(e)); err != nil {
+					metrics.EventsPublished.WithLabelValues(c.Name, "error").Inc()
+					return n, fmt.Errorf("publish event %d: %w", e.ID, err)
+				}
+				metrics.EventsPublished.WithLabelValues(c.Name, "ok").Inc()
+			}
+			if err := r.update(ctx, c.Name, map[string]interface{}{}, func(tx *gorm.DB) error { return splitGap(tx, g, e.ID) }); err != nil {
+				return n, err
+			}
+			g.FirstID = e.ID + 1
+			n++
+		}
+	}
+	return n, nil
+}
+
+// splitGap 从区间 g 中去掉已投递的 id：前半段缩短或删除，后半段作为新区间，保留发现缺口的时间
+func splitGap(tx *gorm.DB, g models.OutboxGap, id uint) error {
+	q := tx.Model(&models.OutboxGap{}).Where("consumer = ? AND first_id = ?", g.Consumer, g.FirstID)
+	var err error
+	if id > g.FirstID {
+		err = q.Update("last_id", id-1).Error
+	} else {
+		err = q.Delete(&models.OutboxGap{}).Error
+	}
+	if err != nil || id >= g.LastID {
+		return err
+	}
+	return tx.Create(&models.OutboxGap{Consumer: g.Consumer, FirstID: id + 1, LastID: g.LastID, CreatedAt: g.CreatedAt}).Error
+}
+
+// advance 把 offset 推进到 id 并续租；租约已被其他实例接管时返回 ErrLeaseLost
+func (r *Relay) advance(ctx context.Context, name string, id uint) error {
+	return r.update(ctx, name, map[string]interface{}{"last_event_id": id}, nil)
+}
+
+// update 续租并写入 offset 记录的 values；fn 不为 nil 时在同一事务中执行。
+// 租约已被其他实例接管时返回 ErrLeaseLost，fn 不会执行
+func (r *Relay) update(ctx context.Context, name string, values map[string]interface{}, fn func(tx *gorm.DB) error) error {
+	now := r.now()
+	values["lease_until"], values["updated_at"] = now.Add(leaseDuration), now
+	renew := func(tx *gorm.DB) error {
+		res := tx.Model(&models.OutboxOffset{}).Where("consumer = ? AND owner = ?", name, r.owner).Updates(values)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrLeaseLost
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(tx)
+	}
+	if fn == nil {
+		return renew(r.db.WithContext(ctx))
+	}
+	return r.db.WithContext(ctx).Transaction(renew)
+}
+
+// release 退出时让租约立即过期，其他实例不用等到租约到期就能接手
+func (r *Relay) release(ctx context.Context, name string) {
+	ctx, cancel := context.WithTimeout(ctx, releaseTimeout)
+	defer cancel()
+	err := r.db.WithContext(ctx).Model(&models.OutboxOffset{}).
+		Where("consumer = ? AND owner = ?", name, r.owner).
+		Update("lease_until", r.now()).Error
+	if err != nil {
+		logger.Ctx(ctx).Warn("release events consumer lease failed", zap.String("sink", name), zap.Error(err))
+	}
+}
+
+// recordLag 更新消费者尚未投递的事件数
+func (r *Relay) recordLag(ctx context.Context, name string, offset uint) {
+	var maxID *uint
+	if err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Select("MAX(id)").Scan(&maxID).Error; err != nil || maxID == nil {
+		return
+	}
+	lag := 0.0
+	if *maxID > offset {
+		lag = float64(*maxID - offset)
+	}
+	metrics.EventsLag.WithLabelValues(name).Set(lag)
+}
+
+// Cleanup 删除早于 retention、且所有消费者都已投递的事件，返回删除的行数。
+// 消费者越过的 ID 区间中的事件还没有投递，从最小的区间起都保留
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	db := r.db.WithContext(ctx)
+	q := db.Where("created_at < ?", r.now().Add(-r.retention))
+	if len(r.consumers) > 0 {
+		names := make([]string, len(r.consumers))
+		for i, c := range r.consumers {
+			names[i] = c.Name
+		}
+		var offsets []models.OutboxOffset
+		if err := db.Where("consumer IN ?", names).Find(&offsets).Error; err != nil {
+			return 0, err
+		}
+		// 还没开始投递的消费者没有 offset 记录，这时不删除任何事件
+		var upTo uint
+		if len(offsets) == len(names) {
+			upTo = offsets[0].LastEventID
+			for _, o := range offsets[1:] {
+				upTo = min(upTo, o.LastEventID)
+			}
+		}
+		var firstGap *uint
+		if err := db.Model(&models.OutboxGap{}).Where("consumer IN ?", names).Select("MIN(first_id)").Scan(&firstGap).Error; err != nil {
+			return 0, err
+		}
+		if firstGap != nil {
+			upTo = min(upTo, *firstGap-1)
+		}
+		q = q.Where("id <= ?", upTo)
+	}
+	res := q.Delete(&models.OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,357 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"trae-go/config"
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/repository"
+	"trae-go/testutil"
+)
+
+// capture 记录收到的事件，fail 返回非 nil 时本次投递失败
+type capture struct {
+	got  []events.Event
+	fail func(e events.Event) error
+}
+
+func (c *capture) Publish(ctx context.Context, e events.Event) error {
+	if c.fail != nil {
+		if err := c.fail(e); err != nil {
+			return err
+		}
+	}
+	c.got = append(c.got, e)
+	return nil
+}
+
+func (c *capture) types() []string {
+	out := make([]string, len(c.got))
+	for i, e := range c.got {
+		out[i] = e.Type
+	}
+	return out
+}
+
+func newRelay(e *testutil.Env, consumers ...events.Consumer) *events.Relay {
+	return events.NewRelay(e.DB, consumers, config.EventsConfig{BatchSize: 10, Retention: "1h"})
+}
+
+func deliver(t *testing.T, r *events.Relay, c events.Consumer) int {
+	t.Helper()
+	n, err := r.Deliver(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// appendEvents 直接写入 n 个 book.created 事件，createdAt 为写入时间
+func appendEvents(t *testing.T, e *testutil.Env, n int, createdAt time.Time) []uint {
+	t.Helper()
+	ids := make([]uint, n)
+	for i := range ids {
+		ev, err := events.New(events.BookCreated, uint(i+1), events.Book{ID: uint(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev.CreatedAt = createdAt
+		if err := repository.NewGorm(e.DB).Outbox().Append(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = ev.ID
+	}
+	return ids
+}
+
+func offset(t *testing.T, e *testutil.Env, consumer string) uint {
+	t.Helper()
+	var off models.OutboxOffset
+	if err := e.DB.Where("consumer = ?", consumer).Take(&off).Error; err != nil {
+		t.Fatal(err)
+	}
+	return off.LastEventID
+}
+
+func TestDomainEvents(t *testing.T) {
+	e := testutil.New(t)
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	student := e.CreateStudent()
+
+	res := e.Do("POST", "/api/v1/user/register", map[string]interface{}{"user_name": "newbie", "password": testutil.Password}, reader)
+	testutil.RequireStatus(t, res, http.StatusCreated)
+	res = e.Do("POST", "/api/v1/books", map[string]interface{}{"title": "Go", "stock": 1}, reader)
+	testutil.RequireStatus(t, res, http.StatusCreated)
+	var book models.Book
+	testutil.Decode(t, res, &book)
+	borrow := fmt.Sprintf("/api/v1/students/%d/books/%d/borrow", student.ID, book.ID)
+	testutil.RequireStatus(t, e.Do("POST", borrow, nil, reader), http.StatusOK)
+	// 库存不足，事务回滚，不产生事件
+	testutil.RequireProblem(t, e.Do("POST", borrow, nil, reader), http.StatusBadRequest, "BOOK_OUT_OF_STOCK")
+	ret := fmt.Sprintf("/api/v1/students/%d/books/%d/return", student.ID, book.ID)
+	testutil.RequireStatus(t, e.Do("POST", ret, nil, reader), http.StatusOK)
+	testutil.RequireStatus(t, e.Do("DELETE", fmt.Sprintf("/api/v1/books/%d?history=archive", book.ID), nil, admin), http.StatusNoContent)
+
+	sink := &capture{}
+	c := events.Consumer{Name: "capture", Sink: sink}
+	deliver(t, newRelay(e), c)
+	want := []string{events.UserRegistered, events.BookCreated, events.BookBorrowed, events.BookReturned, events.BookDeleted}
+	if got := sink.types(); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	var user events.User
+	if err := json.Unmarshal(sink.got[0].Data, &user); err != nil || user.Name != "newbie" || user.Role != "" {
+		t.Fatalf("user.registered data = %s", sink.got[0].Data)
+	}
+	var loan events.Loan
+	if err := json.Unmarshal(sink.got[3].Data, &loan); err != nil || loan.BookID != book.ID || loan.StudentID != student.ID || loan.ReturnedAt == nil {
+		t.Fatalf("book.returned data = %s", sink.got[3].Data)
+	}
+	if b := sink.got[2]; b.AggregateType != "book" || b.AggregateID != book.ID {
+		t.Fatalf("book.borrowed aggregate = %s/%d", b.AggregateType, b.AggregateID)
+	}
+	if got := offset(t, e, "capture"); got != sink.got[4].ID {
+		t.Fatalf("offset = %d, want %d", got, sink.got[4].ID)
+	}
+	if n := deliver(t, newRelay(e), c); n != 0 {
+		t.Fatalf("redelivered %d events", n)
+	}
+}
+
+func TestAtLeastOnce(t *testing.T) {
+	e := testutil.New(t)
+	ids := appendEvents(t, e, 3, time.Now())
+
+	// 第二个事件第一次投递失败
+	var failed atomic.Bool
+	sink := &capture{fail: func(ev events.Event) error {
+		if ev.ID == ids[1] && !failed.Swap(true) {
+			return errors.New("sink down")
+		}
+		return nil
+	}}
+	c := events.Consumer{Name: "flaky", Sink: sink}
+	r := newRelay(e)
+
+	n, err := r.Deliver(context.Background(), c)
+	if err == nil || n != 1 {
+		t.Fatalf("Deliver = %d, %v; want 1 and error", n, err)
+	}
+	if got := offset(t, e, "flaky"); got != ids[0] {
+		t.Fatalf("offset after failure = %d, want %d", got, ids[0])
+	}
+	if n := deliver(t, r, c); n != 2 {
+		t.Fatalf("retry delivered %d, want 2", n)
+	}
+	var got []uint
+	for _, ev := range sink.got {
+		got = append(got, ev.ID)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("delivered %v, want %v", got, ids)
+	}
+}
+
+func TestConsumersAreIndependent(t *testing.T) {
+	e := testutil.New(t)
+	appendEvents(t, e, 2, time.Now())
+	borrowed, err := events.New(events.BookBorrowed, 1, events.Loan{BookID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewGorm(e.DB).Outbox().Append(context.Background(), borrowed); err != nil {
+		t.Fatal(err)
+	}
+
+	all, loans := &capture{}, &capture{}
+	r := newRelay(e)
+	deliver(t, r, events.Consumer{Name: "all", Sink: all})
+	if n := deliver(t, r, events.Consumer{Name: "loans", Types: []string{events.BookBorrowed}, Sink: loans}); n != 3 {
+		t.Fatalf("filtered consumer processed %d, want 3", n)
+	}
+	if len(all.got) != 3 || len(loans.got) != 1 || loans.got[0].Type != events.BookBorrowed {
+		t.Fatalf("all = %v, loans = %v", all.types(), loans.types())
+	}
+	// 跳过的事件同样推进 offset
+	if got := offset(t, e, "loans"); got != borrowed.ID {
+		t.Fatalf("offset = %d, want %d", got, borrowed.ID)
+	}
+}
+
+func TestLease(t *testing.T) {
+	e := testutil.New(t)
+	appendEvents(t, e, 2, time.Now())
+
+	a, b := &capture{}, &capture{}
+	if n := deliver(t, newRelay(e), events.Consumer{Name: "shared", Sink: a}); n != 2 {
+		t.Fatalf("first relay delivered %d, want 2", n)
+	}
+	appendEvents(t, e, 1, time.Now())
+	// 另一个实例在租约有效期内不投递同一个消费者
+	if n := deliver(t, newRelay(e), events.Consumer{Name: "shared", Sink: b}); n != 0 || len(b.got) != 0 {
+		t.Fatalf("second relay delivered %d", n)
+	}
+	e.DB.Model(&models.OutboxOffset{}).Where("consumer = ?", "shared").Update("lease_until", time.Now().Add(-time.Second))
+	if n := deliver(t, newRelay(e), events.Consumer{Name: "shared", Sink: b}); n != 1 {
+		t.Fatalf("relay after lease expiry delivered %d, want 1", n)
+	}
+}
+
+func TestGap(t *testing.T) {
+	e := testutil.New(t)
+	ids := appendEvents(t, e, 4, time.Now())
+	// 第二个事件所在的事务还没提交
+	var late models.OutboxEvent
+	e.DB.Take(&late, ids[1])
+	e.DB.Delete(&models.OutboxEvent{}, ids[1])
+
+	sink := &capture{}
+	c := events.Consumer{Name: "gap", Sink: sink}
+	r := newRelay(e, c)
+	if n := deliver(t, r, c); n != 1 {
+		t.Fatalf("delivered %d before gap, want 1", n)
+	}
+	// 超过等待时间后越过缺口，缺口记录在 outbox_gaps 中
+	old := time.Now().Add(-2 * time.Hour)
+	e.DB.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("created_at", old)
+	if n := deliver(t, r, c); n != 2 || sink.got[1].ID != ids[2] {
+		t.Fatalf("delivered %d after gap timeout, events %v", n, sink.got)
+	}
+	requireGaps(t, e, 1)
+	// 缺口之后的事件都已投递，但缺口中的事件还没有，清理时保留
+	if n, err := r.Cleanup(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Cleanup = %d, %v; want only the event before the gap deleted", n, err)
+	}
+
+	// 事务提交后补投递，且只投递一次
+	if err := e.DB.Create(&late).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n := deliver(t, r, c); n != 1 || sink.got[3].ID != ids[1] {
+		t.Fatalf("delivered %d after late commit, events %v", n, sink.got)
+	}
+	requireGaps(t, e, 0)
+	if n := deliver(t, r, c); n != 0 {
+		t.Fatalf("delivered %d again", n)
+	}
+
+	// 超过 gap_retention 仍没有事件的缺口视为已回滚
+	more := appendEvents(t, e, 2, old)
+	e.DB.Delete(&models.OutboxEvent{}, more[0])
+	if n := deliver(t, r, c); n != 1 {
+		t.Fatalf("delivered %d after rollback, want 1", n)
+	}
+	requireGaps(t, e, 1)
+	e.DB.Model(&models.OutboxGap{}).Where("1 = 1").Update("created_at", old)
+	deliver(t, r, c)
+	requireGaps(t, e, 0)
+}
+
+func requireGaps(t *testing.T, e *testutil.Env, want int64) {
+	t.Helper()
+	var n int64
+	e.DB.Model(&models.OutboxGap{}).Count(&n)
+	if n != want {
+		t.Fatalf("%d gaps, want %d", n, want)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	e := testutil.New(t)
+	old := appendEvents(t, e, 2, time.Now().Add(-2*time.Hour))
+	appendEvents(t, e, 1, time.Now())
+
+	a, b := &capture{}, &capture{}
+	ca, cb := events.Consumer{Name: "a", Sink: a}, events.Consumer{Name: "b", Sink: b}
+	r := newRelay(e, ca, cb)
+
+	count := func() int64 {
+		var n int64
+		e.DB.Model(&models.OutboxEvent{}).Count(&n)
+		return n
+	}
+	// b 还没有开始投递
+	deliver(t, r, ca)
+	if _, err := r.Cleanup(context.Background()); err != nil || count() != 3 {
+		t.Fatalf("cleanup before all consumers delivered: %v, %d left", err, count())
+	}
+	// b 只投递了第一个事件
+	e.DB.Create(&models.OutboxOffset{Consumer: "b", LastEventID: old[0], UpdatedAt: time.Now()})
+	if n, err := r.Cleanup(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Cleanup = %d, %v; want 1", n, err)
+	}
+	deliver(t, r, cb)
+	if n, err := r.Cleanup(context.Background()); err != nil || n != 1 || count() != 1 {
+		t.Fatalf("Cleanup = %d, %v, %d left; want 1 deleted and the recent event kept", n, err, count())
+	}
+}
+
+func TestRedisStreamSink(t *testing.T) {
+	e := testutil.New(t)
+	ids := appendEvents(t, e, 2, time.Now())
+	consumers, err := events.NewConsumers([]config.EventSinkConfig{{Name: "stream", Type: "redis_stream"}}, e.App.Redis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliver(t, newRelay(e), consumers[0])
+
+	msgs, err := e.App.Redis.XRange(context.Background(), events.DefaultStream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Values["id"] != fmt.Sprint(ids[0]) || msgs[1].Values["type"] != events.BookCreated {
+		t.Fatalf("stream = %+v", msgs)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	e := testutil.New(t)
+	appendEvents(t, e, 1, time.Now())
+
+	var calls atomic.Int64
+	var got events.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Event-Type") != events.BookCreated || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	consumers, err := events.NewConsumers([]config.EventSinkConfig{{
+		Name: "hook", Type: "webhook", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRelay(e)
+	if _, err := r.Deliver(context.Background(), consumers[0]); err == nil {
+		t.Fatal("Deliver succeeded on 503")
+	}
+	if n := deliver(t, r, consumers[0]); n != 1 || got.Type != events.BookCreated {
+		t.Fatalf("delivered %d, got %+v", n, got)
+	}
+}
+
+func TestNewConsumersRejectsUnknownType(t *testing.T) {
+	_, err := events.NewConsumers([]config.EventSinkConfig{{Name: "log", Type: "log", Types: []string{"book.burned"}}}, nil)
+	if err == nil {
+		t.Fatal("unknown event type accepted")
+	}
+}
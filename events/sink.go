@@ -0,0 +1,148 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"trae-go/config"
+	"trae-go/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// DefaultStream redis_stream 未配置 stream 时使用的 stream 名
+const DefaultStream = "library:events"
+
+// defaultWebhookTimeout webhook 未配置 timeout 时单次请求的超时
+const defaultWebhookTimeout = 5 * time.Second
+
+// Sink 事件的投递目标。返回 nil 表示已送达；返回错误时 Relay 稍后重试同一事件，不会跳过
+type Sink interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Consumer 一个配置的 sink：Name 为消费者名（offset 按它记录），Types 为空时投递全部事件
+type Consumer struct {
+	Name  string
+	Types []string
+	Sink  Sink
+}
+
+// wants 事件是否需要投递给该消费者，不需要的事件直接推进 offset
+func (c Consumer) wants(typ string) bool {
+	return len(c.Types) == 0 || slices.Contains(c.Types, typ)
+}
+
+// NewConsumers 按配置创建各个 sink。rdb 为 nil（store.driver 为 memory）时不能使用 redis_stream
+func NewConsumers(cfgs []config.EventSinkConfig, rdb *redis.Client) ([]Consumer, error) {
+	consumers := make([]Consumer, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		for _, typ := range cfg.Types {
+			if !Known(typ) {
+				return nil, fmt.Errorf("events sink %s: unknown event type %q", cfg.Name, typ)
+			}
+		}
+		c := Consumer{Name: cfg.Name, Types: cfg.Types}
+		switch cfg.Type {
+		case "log":
+			c.Sink = LogSink{}
+		case "redis_stream":
+			if rdb == nil {
+				return nil, fmt.Errorf("events sink %s: redis_stream requires redis", cfg.Name)
+			}
+			stream := cfg.Stream
+			if stream == "" {
+				stream = DefaultStream
+			}
+			c.Sink = &RedisStreamSink{rdb: rdb, stream: stream, maxLen: cfg.MaxLen}
+		case "webhook":
+			timeout := config.Duration(cfg.Timeout, defaultWebhookTimeout)
+			c.Sink = &WebhookSink{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: timeout}}
+		default:
+			return nil, fmt.Errorf("events sink %s: unsupported type %q", cfg.Name, cfg.Type)
+		}
+		consumers = append(consumers, c)
+	}
+	return consumers, nil
+}
+
+// LogSink 把事件写到日志，便于排查或在没有其他下游时留痕
+type LogSink struct{}
+
+func (LogSink) Publish(ctx context.Context, e Event) error {
+	logger.Ctx(ctx).Info("domain event",
+		zap.Uint("event_id", e.ID),
+		zap.String("type", e.Type),
+		zap.String("aggregate_type", e.AggregateType),
+		zap.Uint("aggregate_id", e.AggregateID),
+		zap.Time("occurred_at", e.OccurredAt),
+		zap.ByteString("data", e.Data),
+	)
+	return nil
+}
+
+// RedisStreamSink 用 XADD 把事件追加到 Redis Stream，下游通过消费组（XREADGROUP）各自记录进度
+type RedisStreamSink struct {
+	rdb    *redis.Client
+	stream string
+	maxLen int64
+}
+
+func (s *RedisStreamSink) Publish(ctx context.Context, e Event) error {
+	return s.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: map[string]interface{}{
+			"id":             strconv.FormatUint(uint64(e.ID), 10),
+			"type":           e.Type,
+			"aggregate_type": e.AggregateType,
+			"aggregate_id":   strconv.FormatUint(uint64(e.AggregateID), 10),
+			"occurred_at":    e.OccurredAt.UTC().Format(time.RFC3339Nano),
+			"data":           string(e.Data),
+		},
+	}).Err()
+}
+
+// WebhookSink 把事件以 JSON POST 到固定地址，2xx 视为送达
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatUint(uint64(e.ID), 10))
+	req.Header.Set("X-Event-Type", e.Type)
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.New("webhook responded " + res.Status)
+	}
+	return nil
+}
@@ -1,26 +1,50 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"trae-go/middleware"
-	"trae-go/models"
+	"trae-go/service"
 )
 
+// BookHandler 图书和借还书接口，只负责解析请求和映射响应，业务规则在 service 中
 type BookHandler struct {
-	DB *gorm.DB
+	Books       service.BookService
+	Circulation service.CirculationService
 }
 
-func NewBookHandler(db *gorm.DB) *BookHandler {
-	return &BookHandler{DB: db}
+func NewBookHandler(books service.BookService, circulation service.CirculationService) *BookHandler {
+	return &BookHandler{Books: books, Circulation: circulation}
 }
 
+type BookCreateRequest struct {
+	Title  string `json:"title" binding:"required,max=200" example:"The Go Programming Language"`
+	Author string `json:"author" binding:"max=100" example:"Alan A. A. Donovan"`
+	ISBN   string `json:"isbn" binding:"omitempty,isbn" example:"9780134190440"`
+	Stock  uint   `json:"stock" binding:"lte=100000" example:"10"`
+}
+
+// BookUpdateRequest PUT 全量更新，未提供的字段会被置空。
+// 库存由借还书维护，与 PATCH 一样不能修改：可以不传，传入时必须等于当前库存
+type BookUpdateRequest struct {
+	Title  string `json:"title" binding:"required,max=200"`
+	Author string `json:"author" binding:"max=100"`
+	ISBN   string `json:"isbn" binding:"omitempty,isbn"`
+	Stock  *uint  `json:"stock"` // 只读
+}
+
+// BookPatchRequest PATCH 之后书籍可写字段的取值；库存由借还书维护，不能直接修改
+type BookPatchRequest struct {
+	Title  string `json:"title" binding:"required,max=200"`
+	Author string `json:"author" binding:"max=100"`
+	ISBN   string `json:"isbn" binding:"omitempty,isbn"`
+}
+
+// bookWritableFields 允许通过 PATCH 修改的字段
+var bookWritableFields = []string{"title", "author", "isbn"}
+
 // ListBooks 获取书籍列表
 // @Summary      获取书籍列表
 // @Description  获取所有书籍信息
@@ -28,13 +52,19 @@ func NewBookHandler(db *gorm.DB) *BookHandler {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        include_deleted  query     bool  false  "包含已删除的书籍（仅管理员）"
 // @Success      200  {array}   models.Book
-// @Failure      500  {object}  middleware.AppError
+// @Failure      403  {object}  middleware.Problem
+// @Failure      500  {object}  middleware.Problem
 // @Router       /books [get]
 func (h *BookHandler) ListBooks(c *gin.Context) {
-	var books []models.Book
-	if err := h.DB.Find(&books).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_LIST_BOOKS", "failed to list books"))
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	books, err := h.Books.List(c.Request.Context(), withDeleted)
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_LIST_BOOKS", "failed to list books"))
 		return
 	}
 	c.JSON(http.StatusOK, books)
@@ -47,25 +77,30 @@ func (h *BookHandler) ListBooks(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      int  true  "书籍 ID"
+// @Param        id               path      int     true   "书籍 ID"
+// @Param        include_deleted  query     bool    false  "包含已删除的书籍（仅管理员）"
+// @Param        If-None-Match    header    string  false  "上次获取的 ETag，未变化时返回 304"
 // @Success      200  {object}  models.Book
-// @Failure      400  {object}  middleware.AppError
-// @Failure      404  {object}  middleware.AppError
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem
+// @Failure      404  {object}  middleware.Problem
 // @Router       /books/{id} [get]
 func (h *BookHandler) GetBook(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	book, err := h.Books.Get(c.Request.Context(), id, withDeleted)
 	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+		c.Error(serviceError(err, "FAILED_GET_BOOK", "failed to get book"))
 		return
 	}
-	var book models.Book
-	if err := h.DB.First(&book, uint(id)).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "BOOK_NOT_FOUND", "book not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_GET_BOOK", "failed to get book"))
+	if notModified(c, book.Version) {
 		return
 	}
 	c.JSON(http.StatusOK, book)
@@ -78,22 +113,24 @@ func (h *BookHandler) GetBook(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request body models.Book true "书籍信息"
+// @Param        request body BookCreateRequest true "书籍信息"
 // @Success      201  {object}  models.Book
-// @Failure      400  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem "JSON 格式错误或字段校验失败（errors 中列出字段）"
 // @Router       /books [post]
 func (h *BookHandler) CreateBook(c *gin.Context) {
-	var input models.Book
+	var input BookCreateRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
 		return
 	}
-	book := models.Book{
+	book, err := h.Books.Create(c.Request.Context(), service.BookInput{
 		Title:  input.Title,
 		Author: input.Author,
-	}
-	if err := h.DB.Create(&book).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_CREATE_BOOK", "failed to create book"))
+		ISBN:   input.ISBN,
+		Stock:  input.Stock,
+	})
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_CREATE_BOOK", "failed to create book"))
 		return
 	}
 	c.JSON(http.StatusCreated, book)
@@ -101,148 +138,195 @@ func (h *BookHandler) CreateBook(c *gin.Context) {
 
 // UpdateBook 更新书籍
 // @Summary      更新书籍
-// @Description  根据 ID 更新书籍信息
+// @Description  根据 ID 更新书籍信息，需要携带 GET 时返回的 ETag 作为 If-Match。库存由借还书维护，stock 与当前值不同时返回 422
 // @Tags         books
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id      path      int          true  "书籍 ID"
-// @Param        request body      models.Book  true  "更新信息"
+// @Param        id        path      int          true  "书籍 ID"
+// @Param        If-Match  header    string       true  "当前 ETag"
+// @Param        request   body      BookUpdateRequest  true  "更新信息"
 // @Success      200     {object}  models.Book
-// @Failure      400     {object}  middleware.AppError
-// @Failure      404     {object}  middleware.AppError
+// @Failure      400     {object}  middleware.Problem "JSON 格式错误或字段校验失败"
+// @Failure      404     {object}  middleware.Problem
+// @Failure      412     {object}  middleware.Problem "ETag 不匹配，书籍已被修改"
+// @Failure      422     {object}  middleware.Problem "修改库存"
+// @Failure      428     {object}  middleware.Problem "缺少 If-Match"
 // @Router       /books/{id} [put]
 func (h *BookHandler) UpdateBook(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
 		return
 	}
-	var book models.Book
-	if err := h.DB.First(&book, uint(id)).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "BOOK_NOT_FOUND", "book not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_GET_BOOK", "failed to get book"))
+	book, err := h.Books.Get(c.Request.Context(), id, false)
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_GET_BOOK", "failed to get book"))
 		return
 	}
-	var input models.Book
+	var input BookUpdateRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
+		return
+	}
+	if !checkIfMatch(c, book.Version) {
+		return
+	}
+	if input.Stock != nil && *input.Stock != book.Stock {
+		c.Error(middleware.NewAppError(http.StatusUnprocessableEntity, "FIELD_READ_ONLY", "field is read-only").
+			WithDetails(gin.H{"fields": []string{"stock"}}))
+		return
+	}
+	book, err = h.Books.Update(c.Request.Context(), id, book.Version, service.BookInput{
+		Title:  input.Title,
+		Author: input.Author,
+		ISBN:   input.ISBN,
+		Stock:  book.Stock,
+	})
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_UPDATE_BOOK", "failed to update book"))
+		return
+	}
+	c.Header("ETag", etag(book.Version))
+	c.JSON(http.StatusOK, book)
+}
+
+// PatchBook 部分更新书籍
+// @Summary      部分更新书籍
+// @Description  使用 JSON Merge Patch（RFC 7396）或 JSON Patch（RFC 6902）更新书籍，只允许修改 title、author、isbn
+// @Tags         books
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int     true  "书籍 ID"
+// @Param        If-Match  header    string  true  "当前 ETag"
+// @Param        request   body      object  true  "merge patch 对象或 JSON Patch 操作数组"
+// @Success      200  {object}  models.Book
+// @Failure      400  {object}  middleware.Problem "补丁格式错误、包含未知字段或字段校验失败"
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem "JSON Patch test 操作失败"
+// @Failure      412  {object}  middleware.Problem "ETag 不匹配"
+// @Failure      415  {object}  middleware.Problem "不支持的 Content-Type"
+// @Failure      422  {object}  middleware.Problem "修改只读字段"
+// @Failure      428  {object}  middleware.Problem "缺少 If-Match"
+// @Router       /books/{id} [patch]
+func (h *BookHandler) PatchBook(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	book, err := h.Books.Get(c.Request.Context(), id, false)
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_GET_BOOK", "failed to get book"))
+		return
+	}
+	if !checkIfMatch(c, book.Version) {
+		return
+	}
+	doc, err := toDocument(book)
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
-	book.Title = input.Title
-	book.Author = input.Author
-	book.Stock = input.Stock
-	if err := h.DB.Save(&book).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_UPDATE_BOOK", "failed to update book"))
+	var input BookPatchRequest
+	if !applyPatch(c, doc, bookWritableFields, &input) {
 		return
 	}
+	// 库存不在可写字段中，沿用当前值；版本号不变保证期间没有被借还书修改
+	book, err = h.Books.Update(c.Request.Context(), id, book.Version, service.BookInput{
+		Title:  input.Title,
+		Author: input.Author,
+		ISBN:   input.ISBN,
+		Stock:  book.Stock,
+	})
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_UPDATE_BOOK", "failed to update book"))
+		return
+	}
+	c.Header("ETag", etag(book.Version))
 	c.JSON(http.StatusOK, book)
 }
 
 // DeleteBook 删除书籍
 // @Summary      删除书籍
-// @Description  根据 ID 删除书籍
+// @Description  根据 ID 软删除书籍，管理员可通过恢复接口找回。存在未归还的借阅时拒绝删除（409）
 // @Tags         books
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      int  true  "书籍 ID"
+// @Param        id       path      int     true   "书籍 ID"
+// @Param        history  query     string  false  "已结束借阅的处理方式，archive 和 cascade 仅限管理员" Enums(keep, archive, cascade)
 // @Success      204  "No Content"
-// @Failure      400  {object}  middleware.AppError
-// @Failure      500  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem "非管理员使用 archive 或 cascade"
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem "存在未归还的借阅"
+// @Failure      500  {object}  middleware.Problem
 // @Router       /books/{id} [delete]
 func (h *BookHandler) DeleteBook(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	mode, ok := historyMode(c)
+	if !ok {
 		return
 	}
-	if err := h.DB.Delete(&models.Book{}, uint(id)).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_DELETE_BOOK", "failed to delete book"))
+	if err := h.Books.Delete(c.Request.Context(), id, mode); err != nil {
+		c.Error(serviceError(err, "FAILED_DELETE_BOOK", "failed to delete book"))
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
 
-// @Tags         borrow
+// RestoreBook 恢复书籍
+// @Summary      恢复书籍
+// @Description  恢复一本已软删除的书籍（仅管理员）
+// @Tags         books
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        student_id  path      int  true  "学生 ID"
-// @Param        book_id     path      int  true  "书籍 ID"
-func (h *BookHandler) BookABook(c *gin.Context) {
-	stuidstr := c.Param("student_id")
-	bookidstr := c.Param("book_id")
-
-	stuid, err := strconv.ParseUint(stuidstr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_STUDENT_ID", "invalid student_id"))
+// @Param        id   path      int  true  "书籍 ID"
+// @Success      200  {object}  models.Book
+// @Failure      400  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem
+// @Router       /books/{id}/restore [post]
+func (h *BookHandler) RestoreBook(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
 		return
 	}
-	bookid, err := strconv.ParseUint(bookidstr, 10, 64)
+	book, err := h.Books.Restore(c.Request.Context(), id)
 	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_BOOK_ID", "invalid book_id"))
+		c.Error(serviceError(err, "FAILED_RESTORE_BOOK", "failed to restore book"))
 		return
 	}
+	c.Header("ETag", etag(book.Version))
+	c.JSON(http.StatusOK, book)
+}
 
-	var student models.Student
-	var book models.Book
-
-	if err := h.DB.First(&student, uint(stuid)).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
-		return
-	}
-	if err := h.DB.First(&book, uint(bookid)).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "BOOK_NOT_FOUND", "book not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
-		return
-	}
-	if book.Stock <= 0 {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "BOOK_OUT_OF_STOCK", "book out of stock"))
+// @Tags         borrow
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        student_id  path      int  true  "学生 ID"
+// @Param        book_id     path      int  true  "书籍 ID"
+func (h *BookHandler) BookABook(c *gin.Context) {
+	stuid, ok := pathID(c, "id", "INVALID_STUDENT_ID", "invalid student_id")
+	if !ok {
 		return
 	}
-	var book_student models.Book_Student
-	book_student.BookID = book.ID
-	book_student.StudentID = student.ID
-	book_student.BorrowedAt = time.Now()
-	book_student.Status = models.BorrowStatusBorrowed
-	if err := h.DB.Create(&book_student).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	bookid, ok := pathID(c, "book_id", "INVALID_BOOK_ID", "invalid book_id")
+	if !ok {
 		return
 	}
-	book.Stock -= 1
-	if err := h.DB.Save(&book).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	loan, err := h.Circulation.Borrow(c.Request.Context(), stuid, bookid)
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
-	c.JSON(http.StatusOK, book_student)
-	// if book.StudentID != 0 {
-	// 	var currentOwner models.Student
-	// 	if err := h.DB.First(&currentOwner, book.StudentID).Error; err != nil {
-	// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-	// 		return
-	// 	}
-	// 	c.JSON(http.StatusOK, gin.H{"msg": "this book is already booked", "student": currentOwner})
-	// 	return
-	// }
-	// book.StudentID = student.ID
-	// if err := h.DB.Save(&book).Error; err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-	// 	return
-	// }
-	// c.JSON(http.StatusOK, book)
+	c.JSON(http.StatusOK, loan)
 }
 
 // ReturnABook 归还书籍
@@ -255,57 +339,26 @@ func (h *BookHandler) BookABook(c *gin.Context) {
 // @Param        student_id  path      int  true  "学生 ID"
 // @Param        book_id     path      int  true  "书籍 ID"
 // @Success      200  {object}  models.Book_Student
-// @Failure      400  {object}  middleware.AppError
-// @Failure      404  {object}  middleware.AppError
-// @Failure      500  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem "并发归还时已被另一个请求归还"
+// @Failure      500  {object}  middleware.Problem
 // @Router       /students/{student_id}/books/{book_id}/return [post]
 func (h *BookHandler) ReturnABook(c *gin.Context) {
-	stuidstr := c.Param("student_id")
-	bookidstr := c.Param("book_id")
-
-	stuid, err := strconv.ParseUint(stuidstr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_STUDENT_ID", "invalid student_id"))
+	stuid, ok := pathID(c, "id", "INVALID_STUDENT_ID", "invalid student_id")
+	if !ok {
 		return
 	}
-	bookid, err := strconv.ParseUint(bookidstr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_BOOK_ID", "invalid book_id"))
-		return
-	}
-
-	var book_student models.Book_Student
-	if err := h.DB.
-		Where("student_id = ? AND book_id = ? AND status = ?", stuid, bookid, models.BorrowStatusBorrowed).
-		First(&book_student).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "BORROW_RECORD_NOT_FOUND", "borrow record not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	bookid, ok := pathID(c, "book_id", "INVALID_BOOK_ID", "invalid book_id")
+	if !ok {
 		return
 	}
-	book_student.Status = models.BorrowStatusReturned
-	book_student.ReturnedAt = time.Now()
-	if err := h.DB.Save(&book_student).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
-		return
-	}
-	var book models.Book
-	if err := h.DB.First(&book, uint(bookid)).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "BOOK_NOT_FOUND", "book not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
-		return
-	}
-	book.Stock += 1
-	if err := h.DB.Save(&book).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	loan, err := h.Circulation.Return(c.Request.Context(), stuid, bookid)
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
-	c.JSON(http.StatusOK, book_student)
+	c.JSON(http.StatusOK, loan)
 }
 
 // ListStudentBooks 获取学生借书记录
@@ -315,25 +368,25 @@ func (h *BookHandler) ReturnABook(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      int  true  "学生 ID"
+// @Param        id               path      int   true   "学生 ID"
+// @Param        include_deleted  query     bool  false  "包含已删除的学生（仅管理员）"
 // @Success      200  {object}  models.Student
-// @Failure      400  {object}  middleware.AppError
-// @Failure      404  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem
+// @Failure      404  {object}  middleware.Problem
 // @Router       /students/{id}/books [get]
 func (h *BookHandler) ListStudentBooks(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
 		return
 	}
-	var student models.Student
-	if err := h.DB.Preload("Book_Student").First(&student, uint(id)).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	student, err := h.Circulation.StudentLoans(c.Request.Context(), id, withDeleted)
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
 	c.JSON(http.StatusOK, student)
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"trae-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag 根据记录的版本号生成强 ETag
+func etag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// etagMatches 判断 If-Match / If-None-Match 头中是否包含 tag。
+// weak 为 true 时忽略 W/ 前缀（If-None-Match 使用弱比较）。
+func etagMatches(header, tag string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if weak {
+			t = strings.TrimPrefix(t, "W/")
+		}
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified 写入 ETag 响应头；If-None-Match 命中时直接返回 304
+func notModified(c *gin.Context, version uint) bool {
+	tag := etag(version)
+	c.Header("ETag", tag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, tag, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch 写操作必须带 If-Match：缺失返回 428，与当前版本不一致返回 412
+func checkIfMatch(c *gin.Context, version uint) bool {
+	im := c.GetHeader("If-Match")
+	if im == "" {
+		c.Error(middleware.NewAppError(http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "If-Match header required"))
+		return false
+	}
+	if !etagMatches(im, etag(version), false) {
+		c.Error(middleware.NewAppError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "resource has been modified"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"trae-go/migrations"
+	"trae-go/pkg/lifecycle"
+	"trae-go/pkg/replica"
+	"trae-go/pkg/version"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// checkTimeout 单个依赖检查的超时
+const checkTimeout = 2 * time.Second
+
+// 检查结果
+const (
+	checkOK       = "ok"
+	checkFailed   = "failed"
+	checkPending  = "pending"
+	checkDraining = "draining"
+)
+
+// HealthHandler 会话存储为 memory 时 RDB 为 nil，不检查 Redis；没有配置只读副本时 Replicas 为 nil
+type HealthHandler struct {
+	DB        *gorm.DB
+	RDB       *redis.Client
+	Replicas  *replica.Set
+	Lifecycle *lifecycle.State
+}
+
+func NewHealthHandler(db *gorm.DB, rdb *redis.Client, replicas *replica.Set, lc *lifecycle.State) *HealthHandler {
+	return &HealthHandler{DB: db, RDB: rdb, Replicas: replicas, Lifecycle: lc}
+}
+
+// DependencyStatus 单个依赖的检查结果
+type DependencyStatus struct {
+	Status    string  `json:"status" example:"ok"`
+	LatencyMS float64 `json:"latency_ms" example:"0.42"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// MigrationStatus 迁移执行情况
+type MigrationStatus struct {
+	Status  string `json:"status" example:"ok"`
+	Applied int    `json:"applied"`
+	Pending int    `json:"pending"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DBPoolStats sql.DBStats 的 JSON 形式
+type DBPoolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// RedisPoolStats redis.PoolStats 的 JSON 形式
+type RedisPoolStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+// ReadinessResponse /readyz 的响应
+type ReadinessResponse struct {
+	Status string            `json:"status" example:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// StatusResponse /admin/status 的响应
+type StatusResponse struct {
+	Status        string                      `json:"status" example:"ok"`
+	Version       version.Info                `json:"version"`
+	StartedAt     time.Time                   `json:"started_at"`
+	Uptime        string                      `json:"uptime" example:"3h25m10s"`
+	UptimeSeconds int64                       `json:"uptime_seconds"`
+	Draining      bool                        `json:"draining"`
+	Dependencies  map[string]DependencyStatus `json:"dependencies"`
+	Migrations    MigrationStatus             `json:"migrations"`
+	DBPool        DBPoolStats                 `json:"db_pool"`
+	RedisPool     *RedisPoolStats             `json:"redis_pool,omitempty"` // 不使用 Redis 时省略
+}
+
+// Healthz 存活检查：进程能处理请求就返回 200，不检查依赖
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": checkOK})
+}
+
+// Readyz 就绪检查：数据库、Redis（使用时）可用，迁移已全部执行，且实例没有在退出时返回 200，否则返回 503
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx := c.Request.Context()
+	checks := map[string]string{
+		"database":   h.pingDB(ctx).Status,
+		"migrations": h.migrations(ctx).Status,
+		"lifecycle":  checkOK,
+	}
+	if h.RDB != nil {
+		checks["redis"] = h.pingRedis(ctx).Status
+	}
+	if h.Lifecycle.Draining() {
+		checks["lifecycle"] = checkDraining
+	}
+
+	resp := ReadinessResponse{Status: "ready", Checks: checks}
+	code := http.StatusOK
+	for _, v := range checks {
+		if v != checkOK {
+			resp.Status = "not_ready"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	c.JSON(code, resp)
+}
+
+// AdminStatus 获取实例运行状态
+// @Summary      获取实例运行状态
+// @Description  各依赖的检查结果和延迟、数据库 / Redis 连接池统计、迁移情况、版本和运行时长（仅管理员）
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  StatusResponse
+// @Failure      401  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem
+// @Router       /admin/status [get]
+func (h *HealthHandler) AdminStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	resp := StatusResponse{
+		Status:    checkOK,
+		Version:   version.Get(),
+		StartedAt: h.Lifecycle.StartedAt(),
+		Draining:  h.Lifecycle.Draining(),
+		Dependencies: map[string]DependencyStatus{
+			"database": h.pingDB(ctx),
+		},
+		Migrations: h.migrations(ctx),
+	}
+	if h.RDB != nil {
+		resp.Dependencies["redis"] = h.pingRedis(ctx)
+	}
+	if h.Replicas != nil {
+		// 副本不可用时查询回到主库，服务仍可用，这里只反映为 degraded
+		for _, st := range h.Replicas.Status() {
+			d := DependencyStatus{Status: checkOK, LatencyMS: float64(st.Latency.Microseconds()) / 1000, Error: st.Error}
+			if !st.Healthy {
+				d.Status = checkFailed
+			}
+			resp.Dependencies["database_"+st.Name] = d
+		}
+	}
+	uptime := h.Lifecycle.Uptime()
+	resp.Uptime = uptime.Round(time.Second).String()
+	resp.UptimeSeconds = int64(uptime.Seconds())
+	for _, d := range resp.Dependencies {
+		if d.Status != checkOK {
+			resp.Status = "degraded"
+		}
+	}
+	if resp.Migrations.Status != checkOK {
+		resp.Status = "degraded"
+	}
+
+	if sqlDB, err := h.DB.DB(); err == nil {
+		s := sqlDB.Stats()
+		resp.DBPool = DBPoolStats{
+			MaxOpenConnections: s.MaxOpenConnections,
+			OpenConnections:    s.OpenConnections,
+			InUse:              s.InUse,
+			Idle:               s.Idle,
+			WaitCount:          s.WaitCount,
+			WaitDuration:       s.WaitDuration.String(),
+			MaxIdleClosed:      s.MaxIdleClosed,
+			MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  s.MaxLifetimeClosed,
+		}
+	}
+	if h.RDB != nil {
+		ps := h.RDB.PoolStats()
+		resp.RedisPool = &RedisPoolStats{
+			Hits:       ps.Hits,
+			Misses:     ps.Misses,
+			Timeouts:   ps.Timeouts,
+			TotalConns: ps.TotalConns,
+			IdleConns:  ps.IdleConns,
+			StaleConns: ps.StaleConns,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *HealthHandler) pingDB(ctx context.Context) DependencyStatus {
+	return timed(ctx, func(ctx context.Context) error {
+		sqlDB, err := h.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+func (h *HealthHandler) pingRedis(ctx context.Context) DependencyStatus {
+	return timed(ctx, func(ctx context.Context) error {
+		return h.RDB.Ping(ctx).Err()
+	})
+}
+
+func (h *HealthHandler) migrations(ctx context.Context) MigrationStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	fail := func(err error) MigrationStatus {
+		return MigrationStatus{Status: checkFailed, Error: err.Error()}
+	}
+	m, err := migrations.New(h.DB)
+	if err != nil {
+		return fail(err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	ms := MigrationStatus{Status: checkOK}
+	for _, s := range statuses {
+		if s.Applied {
+			ms.Applied++
+		} else {
+			ms.Pending++
+		}
+	}
+	if ms.Pending > 0 {
+		ms.Status = checkPending
+	}
+	return ms
+}
+
+// timed 带超时执行一次依赖检查并记录耗时
+func timed(ctx context.Context, check func(ctx context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	err := check(ctx)
+	ds := DependencyStatus{
+		Status:    checkOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		ds.Status = checkFailed
+		ds.Error = err.Error()
+	}
+	return ds
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"trae-go/middleware"
+	"trae-go/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyMode 解析 ?history= 参数，删除图书 / 学生时对已结束借阅历史的处理方式。
+// archive 和 cascade 会移走或永久删除借阅历史，只允许管理员使用
+func historyMode(c *gin.Context) (service.HistoryMode, bool) {
+	mode := service.HistoryMode(c.DefaultQuery("history", string(service.HistoryKeep)))
+	if !mode.Valid() {
+		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_HISTORY_MODE", "history must be keep, archive or cascade"))
+		return "", false
+	}
+	if mode != service.HistoryKeep && !middleware.IsAdmin(c) {
+		c.Error(middleware.NewAppError(http.StatusForbidden, "FORBIDDEN", "history mode requires admin"))
+		return "", false
+	}
+	return mode, true
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"trae-go/middleware"
+	"trae-go/models"
+	"trae-go/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler 学生的通知偏好，以及通知发送历史、重新发送和预约到馆通知（管理员）
+type NotificationHandler struct {
+	Notifier *notify.Notifier
+}
+
+func NewNotificationHandler(n *notify.Notifier) *NotificationHandler {
+	return &NotificationHandler{Notifier: n}
+}
+
+// NotificationPreferencesRequest 全量设置通知偏好
+type NotificationPreferencesRequest struct {
+	Locale    string `json:"locale" binding:"omitempty,oneof=zh-CN en" example:"en"` // 为空时使用 notify.default_locale
+	DueSoon   *bool  `json:"due_soon" binding:"required"`                            // 到期提醒
+	Overdue   *bool  `json:"overdue" binding:"required"`                             // 逾期通知
+	HoldReady *bool  `json:"hold_ready" binding:"required"`                          // 预约到馆
+	Account   *bool  `json:"account" binding:"required"`                             // 账户消息
+}
+
+// HoldReadyRequest 预约到馆通知
+type HoldReadyRequest struct {
+	StudentID uint      `json:"student_id" binding:"required" example:"1"`
+	BookID    uint      `json:"book_id" binding:"required" example:"1"`
+	PickupBy  time.Time `json:"pickup_by" binding:"required" example:"2026-10-25T18:00:00+08:00"` // 预约保留到何时，须晚于当前时间
+}
+
+// notifyError 把 notify 包的错误转换成对应的 AppError
+func notifyError(err error, code, msg string) *middleware.AppError {
+	switch {
+	case errors.Is(err, notify.ErrNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "NOTIFICATION_NOT_FOUND", "notification not found")
+	case errors.Is(err, notify.ErrStudentNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found")
+	case errors.Is(err, notify.ErrBookNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "BOOK_NOT_FOUND", "book not found")
+	case errors.Is(err, notify.ErrInvalidLocale):
+		return middleware.NewAppError(http.StatusBadRequest, "INVALID_LOCALE", err.Error())
+	case errors.Is(err, notify.ErrPickupPassed):
+		return middleware.NewAppError(http.StatusBadRequest, "INVALID_PICKUP_BY", err.Error())
+	case errors.Is(err, notify.ErrPending):
+		return middleware.NewAppError(http.StatusConflict, "NOTIFICATION_PENDING", err.Error())
+	case errors.Is(err, notify.ErrDuplicate):
+		return middleware.NewAppError(http.StatusConflict, "NOTIFICATION_DUPLICATE", err.Error())
+	case errors.Is(err, notify.ErrOptedOut):
+		return middleware.NewAppError(http.StatusConflict, "NOTIFICATION_OPTED_OUT", err.Error())
+	case errors.Is(err, notify.ErrNoEmail):
+		return middleware.NewAppError(http.StatusUnprocessableEntity, "STUDENT_NO_EMAIL", err.Error())
+	}
+	return middleware.NewAppError(http.StatusInternalServerError, code, msg)
+}
+
+// GetNotificationPreferences 获取学生的通知偏好
+// @Summary      获取通知偏好
+// @Description  学生没有设置过时返回默认值：全部开启，locale 为空（使用 notify.default_locale）
+// @Tags         notifications
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "学生 ID"
+// @Success      200  {object}  models.NotificationPreference
+// @Failure      400  {object}  middleware.Problem "ID 无效"
+// @Failure      404  {object}  middleware.Problem "学生未找到"
+// @Router       /students/{id}/notification-preferences [get]
+func (h *NotificationHandler) GetNotificationPreferences(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	p, err := h.Notifier.Preferences(c.Request.Context(), id)
+	if err != nil {
+		c.Error(notifyError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// UpdateNotificationPreferences 设置学生的通知偏好
+// @Summary      设置通知偏好
+// @Description  全量设置邮件语言和各类通知的开关。关闭后不再入队该类通知，已入队的仍会发送
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int                             true  "学生 ID"
+// @Param        request  body      NotificationPreferencesRequest  true  "通知偏好"
+// @Success      200  {object}  models.NotificationPreference
+// @Failure      400  {object}  middleware.Problem "字段校验失败"
+// @Failure      404  {object}  middleware.Problem "学生未找到"
+// @Router       /students/{id}/notification-preferences [put]
+func (h *NotificationHandler) UpdateNotificationPreferences(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	var req NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.NewBindError(err))
+		return
+	}
+	p, err := h.Notifier.SetPreferences(c.Request.Context(), models.NotificationPreference{
+		StudentID: id,
+		Locale:    req.Locale,
+		DueSoon:   *req.DueSoon,
+		Overdue:   *req.Overdue,
+		HoldReady: *req.HoldReady,
+		Account:   *req.Account,
+	})
+	if err != nil {
+		c.Error(notifyError(err, "FAILED_UPDATE_PREFERENCES", "failed to update notification preferences"))
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// ListNotifications 获取通知发送历史
+// @Summary      获取通知发送历史
+// @Description  按 ID 倒序，含待发送、已发送和发送失败的通知；用上一页最后一条的 ID 作为 before_id 翻页（仅管理员）
+// @Tags         notifications
+// @Produce      json
+// @Security     BearerAuth
+// @Param        student_id  query     int     false  "只看该学生的通知"
+// @Param        status      query     string  false  "pending、sent 或 failed"
+// @Param        kind        query     string  false  "due_soon、overdue、hold_ready 或 account_created"
+// @Param        before_id   query     int     false  "只返回 ID 小于它的通知"
+// @Param        limit       query     int     false  "条数，默认 50，最多 200"
+// @Success      200  {array}   models.Notification
+// @Failure      400  {object}  middleware.Problem "查询参数无效"
+// @Router       /admin/notifications [get]
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	var f notify.Filter
+	switch status := c.Query("status"); status {
+	case "", models.NotificationPending, models.NotificationSent, models.NotificationFailed:
+		f.Status = status
+	default:
+		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", "status must be pending, sent or failed"))
+		return
+	}
+	if kind := c.Query("kind"); kind != "" {
+		if !slices.Contains(notify.Kinds, kind) {
+			c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", "kind must be due_soon, overdue, hold_ready or account_created"))
+			return
+		}
+		f.Kind = kind
+	}
+	for _, q := range []struct {
+		name string
+		msg  string
+		dst  *uint
+	}{
+		{"student_id", "invalid student_id", &f.StudentID},
+		{"before_id", "invalid before_id", &f.BeforeID},
+	} {
+		if v := c.Query(q.name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", q.msg))
+				return
+			}
+			*q.dst = uint(n)
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", "invalid limit"))
+			return
+		}
+		f.Limit = n
+	}
+	list, err := h.Notifier.List(c.Request.Context(), f)
+	if err != nil {
+		c.Error(notifyError(err, "FAILED_LIST_NOTIFICATIONS", "failed to list notifications"))
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetNotification 获取单条通知
+// @Summary      获取通知详情
+// @Description  含收件人、渲染后的主题和正文、尝试次数和最后一次错误（仅管理员）
+// @Tags         notifications
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "通知 ID"
+// @Success      200  {object}  models.Notification
+// @Failure      404  {object}  middleware.Problem "通知未找到"
+// @Router       /admin/notifications/{id} [get]
+func (h *NotificationHandler) GetNotification(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	n, err := h.Notifier.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(notifyError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, n)
+}
+
+// ResendNotification 重新发送通知
+// @Summary      重新发送通知
+// @Description  把已发送或发送失败的通知重新排入队列，发送相同的内容并重新开始计算尝试次数（仅管理员）
+// @Tags         notifications
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "通知 ID"
+// @Success      202  {object}  models.Notification
+// @Failure      404  {object}  middleware.Problem "通知未找到"
+// @Failure      409  {object}  middleware.Problem "通知仍在等待发送"
+// @Router       /admin/notifications/{id}/resend [post]
+func (h *NotificationHandler) ResendNotification(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	n, err := h.Notifier.Resend(c.Request.Context(), id)
+	if err != nil {
+		c.Error(notifyError(err, "FAILED_RESEND_NOTIFICATION", "failed to resend notification"))
+		return
+	}
+	c.JSON(http.StatusAccepted, n)
+}
+
+// NotifyHoldReady 发送预约到馆通知
+// @Summary      发送预约到馆通知
+// @Description  通知学生预约的图书已到馆、需在 pickup_by 之前领取。系统目前没有预约功能，由馆员或外部系统调用；
+// @Description  同一学生、图书和领取期限只发送一次（仅管理员）
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      HoldReadyRequest  true  "预约信息"
+// @Success      202  {object}  models.Notification
+// @Failure      400  {object}  middleware.Problem "字段校验失败或 pickup_by 已过"
+// @Failure      404  {object}  middleware.Problem "学生或图书未找到"
+// @Failure      409  {object}  middleware.Problem "已发送过（NOTIFICATION_DUPLICATE）或学生关闭了这类通知（NOTIFICATION_OPTED_OUT）"
+// @Failure      422  {object}  middleware.Problem "学生没有邮箱"
+// @Router       /admin/notifications/hold-ready [post]
+func (h *NotificationHandler) NotifyHoldReady(c *gin.Context) {
+	var req HoldReadyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.NewBindError(err))
+		return
+	}
+	n, err := h.Notifier.HoldReady(c.Request.Context(), req.StudentID, req.BookID, req.PickupBy)
+	if err != nil {
+		c.Error(notifyError(err, "FAILED_QUEUE_NOTIFICATION", "failed to queue notification"))
+		return
+	}
+	c.JSON(http.StatusAccepted, n)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"trae-go/middleware"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// PATCH 支持的请求体格式
+const (
+	mergePatchContentType = "application/merge-patch+json" // RFC 7396
+	jsonPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+// acceptPatch 作为 Accept-Patch 响应头告诉客户端支持哪些格式
+const acceptPatch = mergePatchContentType + ", " + jsonPatchContentType
+
+// toDocument 把当前记录转换成 JSON 对象，作为补丁的原始文档
+func toDocument(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// applyPatch 按 Content-Type 把请求体作为 merge patch 或 JSON Patch 应用到 doc 上。
+// 只允许修改 writable 中的字段，结果解码到 out 并做字段校验。
+// application/json 按 merge patch 处理。
+func applyPatch(c *gin.Context, doc map[string]interface{}, writable []string, out interface{}) bool {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_PATCH", "invalid patch"))
+		return false
+	}
+	original, err := json.Marshal(doc)
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+		return false
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case mergePatchContentType, binding.MIMEJSON:
+		patched, err = jsonpatch.MergePatch(original, body)
+	case jsonPatchContentType:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			patched, err = patch.Apply(original)
+		}
+	default:
+		c.Header("Accept-Patch", acceptPatch)
+		c.Error(middleware.NewAppError(http.StatusUnsupportedMediaType, "UNSUPPORTED_PATCH_TYPE", "unsupported patch content type"))
+		return false
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		c.Error(middleware.NewAppError(http.StatusConflict, "PATCH_TEST_FAILED", err.Error()))
+		return false
+	}
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_PATCH", err.Error()))
+		return false
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(patched, &result); err != nil {
+		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_PATCH", "patch result must be a json object"))
+		return false
+	}
+	if appErr := checkWritable(doc, result, writable); appErr != nil {
+		c.Error(appErr)
+		return false
+	}
+
+	if err := json.Unmarshal(patched, out); err != nil {
+		c.Error(middleware.NewBindError(err))
+		return false
+	}
+	if err := binding.Validator.ValidateStruct(out); err != nil {
+		c.Error(middleware.NewBindError(err))
+		return false
+	}
+	return true
+}
+
+// checkWritable 比较打补丁前后的文档，拒绝修改只读字段或引入未知字段
+func checkWritable(before, after map[string]interface{}, writable []string) *middleware.AppError {
+	allowed := make(map[string]struct{}, len(writable))
+	for _, f := range writable {
+		allowed[f] = struct{}{}
+	}
+
+	var unknown, readOnly []string
+	for k, v := range after {
+		old, existed := before[k]
+		if existed && reflect.DeepEqual(old, v) {
+			continue
+		}
+		if _, ok := allowed[k]; ok {
+			continue
+		}
+		if !existed {
+			unknown = append(unknown, k)
+		} else {
+			readOnly = append(readOnly, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; ok {
+			continue
+		}
+		if _, ok := allowed[k]; !ok {
+			readOnly = append(readOnly, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return middleware.NewAppError(http.StatusBadRequest, "UNKNOWN_FIELD", "unknown field").
+			WithDetails(gin.H{"fields": unknown})
+	}
+	if len(readOnly) > 0 {
+		sort.Strings(readOnly)
+		return middleware.NewAppError(http.StatusUnprocessableEntity, "FIELD_READ_ONLY", "field is read-only").
+			WithDetails(gin.H{"fields": readOnly})
+	}
+	return nil
+}
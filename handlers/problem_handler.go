@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"trae-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemHandler 错误码文档，problem+json 中的 type 指向这里
+type ProblemHandler struct{}
+
+func NewProblemHandler() *ProblemHandler {
+	return &ProblemHandler{}
+}
+
+// ListProblemTypes 获取错误码列表：type URI、HTTP 状态码、中英文标题和说明。
+// 挂在根路径 /problems 下（不在 /api/v1 里），因此不出现在 swagger 文档中
+func (h *ProblemHandler) ListProblemTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.ErrorTypes())
+}
+
+// GetProblemType 获取单个错误码说明，type 可以是 book-not-found 这样的 URI 最后一段，也可以是错误码 BOOK_NOT_FOUND
+func (h *ProblemHandler) GetProblemType(c *gin.Context) {
+	t, ok := middleware.LookupErrorType(c.Param("type"))
+	if !ok {
+		c.Error(middleware.NewAppError(http.StatusNotFound, "PROBLEM_TYPE_NOT_FOUND", "problem type not found"))
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
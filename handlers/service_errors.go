@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"trae-go/middleware"
+	"trae-go/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceError 把 service 返回的业务错误转换成对应的 AppError；
+// 其他错误（数据库故障等）不暴露细节，使用调用方给出的 500 错误码
+func serviceError(err error, code, msg string) *middleware.AppError {
+	var active *service.ActiveLoansError
+	switch {
+	case errors.Is(err, service.ErrBookNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "BOOK_NOT_FOUND", "book not found")
+	case errors.Is(err, service.ErrStudentNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found")
+	case errors.Is(err, service.ErrLoanNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "BORROW_RECORD_NOT_FOUND", "borrow record not found")
+	case errors.Is(err, service.ErrLoanReturned):
+		return middleware.NewAppError(http.StatusConflict, "LOAN_ALREADY_RETURNED", "book already returned")
+	case errors.Is(err, service.ErrOutOfStock):
+		return middleware.NewAppError(http.StatusBadRequest, "BOOK_OUT_OF_STOCK", "book out of stock")
+	case errors.Is(err, service.ErrVersionConflict):
+		return middleware.NewAppError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "resource has been modified")
+	case errors.Is(err, service.ErrBookNotDeleted):
+		return middleware.NewAppError(http.StatusConflict, "BOOK_NOT_DELETED", "book is not deleted")
+	case errors.Is(err, service.ErrStudentNotDeleted):
+		return middleware.NewAppError(http.StatusConflict, "STUDENT_NOT_DELETED", "student is not deleted")
+	case errors.As(err, &active):
+		// 列出阻塞删除的借阅记录
+		return middleware.NewAppError(http.StatusConflict, "CONFLICT", active.Error()).
+			WithDetails(gin.H{"active_loans": active.Loans})
+	}
+	return middleware.NewAppError(http.StatusInternalServerError, code, msg)
+}
+
+// pathID 解析路径参数中的 ID，不合法时返回 400（code / msg）
+func pathID(c *gin.Context, name, code, msg string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusBadRequest, code, msg))
+		return 0, false
+	}
+	return uint(id), true
+}
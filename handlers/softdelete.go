@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"trae-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// includeDeleted 处理 ?include_deleted=true：管理员可查看已软删除的记录，其他人返回 403
+func includeDeleted(c *gin.Context) (withDeleted bool, ok bool) {
+	if c.Query("include_deleted") != "true" {
+		return false, true
+	}
+	if !middleware.IsAdmin(c) {
+		c.Error(middleware.NewAppError(http.StatusForbidden, "FORBIDDEN", "include_deleted requires admin"))
+		return false, false
+	}
+	return true, true
+}
@@ -1,25 +1,43 @@
 package handlers
 
 import (
-	"errors"
 	"net/http"
-	"strconv"
-	"trae-go/models"
 
 	"trae-go/middleware"
+	"trae-go/service"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+// StudentHandler 学生接口，只负责解析请求和映射响应，业务规则在 service 中
 type StudentHandler struct {
-	DB *gorm.DB
+	Students service.StudentService
 }
 
-func NewStudentHandler(db *gorm.DB) *StudentHandler {
-	return &StudentHandler{DB: db}
+func NewStudentHandler(students service.StudentService) *StudentHandler {
+	return &StudentHandler{Students: students}
 }
 
+type StudentCreateRequest struct {
+	Name  string `json:"name" binding:"required,max=50" example:"Tom"`
+	Email string `json:"email" binding:"required,email,max=100" example:"tom@example.com"`
+}
+
+// StudentUpdateRequest PUT 全量更新
+type StudentUpdateRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// StudentPatchRequest PATCH 之后学生可写字段的取值
+type StudentPatchRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// studentWritableFields 允许通过 PATCH 修改的字段
+var studentWritableFields = []string{"name", "email"}
+
 // ListStudents 获取学生列表
 // @Summary      获取学生列表
 // @Description  获取所有学生信息
@@ -27,13 +45,19 @@ func NewStudentHandler(db *gorm.DB) *StudentHandler {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        include_deleted  query     bool  false  "包含已删除的学生（仅管理员）"
 // @Success      200  {array}   models.Student
-// @Failure      500  {object}  middleware.AppError
+// @Failure      403  {object}  middleware.Problem
+// @Failure      500  {object}  middleware.Problem
 // @Router       /students [get]
 func (h *StudentHandler) ListStudents(c *gin.Context) {
-	var students []models.Student
-	if err := h.DB.Find(&students).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_LIST_STUDENTS", "failed to list students"))
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	students, err := h.Students.List(c.Request.Context(), withDeleted)
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_LIST_STUDENTS", "failed to list students"))
 		return
 	}
 	c.JSON(http.StatusOK, students)
@@ -46,25 +70,30 @@ func (h *StudentHandler) ListStudents(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      int  true  "学生 ID"
+// @Param        id               path      int     true   "学生 ID"
+// @Param        include_deleted  query     bool    false  "包含已删除的学生（仅管理员）"
+// @Param        If-None-Match    header    string  false  "上次获取的 ETag，未变化时返回 304"
 // @Success      200  {object}  models.Student
-// @Failure      400  {object}  middleware.AppError "ID 无效"
-// @Failure      404  {object}  middleware.AppError "学生未找到"
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  middleware.Problem "ID 无效"
+// @Failure      403  {object}  middleware.Problem "非管理员请求已删除记录"
+// @Failure      404  {object}  middleware.Problem "学生未找到"
 // @Router       /students/{id} [get]
 func (h *StudentHandler) GetStudent(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	student, err := h.Students.Get(c.Request.Context(), id, withDeleted)
 	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
-	var student models.Student
-	if err := h.DB.First(&student, uint(id)).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	if notModified(c, student.Version) {
 		return
 	}
 	c.JSON(http.StatusOK, student)
@@ -77,22 +106,23 @@ func (h *StudentHandler) GetStudent(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request body models.Student true "学生信息"
+// @Param        request body StudentCreateRequest true "学生信息"
 // @Success      201  {object}  models.Student
-// @Failure      400  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem "JSON 格式错误或字段校验失败（errors 中列出字段）"
+// @Failure      500  {object}  middleware.Problem
 // @Router       /students [post]
 func (h *StudentHandler) CreatStudent(c *gin.Context) {
-	var input models.Student
+	var input StudentCreateRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
 		return
 	}
-	student := models.Student{
+	student, err := h.Students.Create(c.Request.Context(), service.StudentInput{
 		Name:  input.Name,
 		Email: input.Email,
-	}
-	if err := h.DB.Create(&student).Error; err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "FAILED_CREATE_STUDENT", "failed to create student"))
+	})
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_CREATE_STUDENT", "failed to create student"))
 		return
 	}
 	c.JSON(http.StatusCreated, student)
@@ -100,77 +130,161 @@ func (h *StudentHandler) CreatStudent(c *gin.Context) {
 
 // UpdateStudent 更新学生
 // @Summary      更新学生
-// @Description  根据 ID 更新学生信息
+// @Description  根据 ID 更新学生信息，需要携带 GET 时返回的 ETag 作为 If-Match
 // @Tags         students
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id      path      int             true  "学生 ID"
-// @Param        request body      models.Student  true  "更新信息"
+// @Param        id        path      int             true  "学生 ID"
+// @Param        If-Match  header    string          true  "当前 ETag"
+// @Param        request   body      StudentUpdateRequest  true  "更新信息"
 // @Success      200     {object}  models.Student
-// @Failure      400     {object}  middleware.AppError
-// @Failure      404     {object}  middleware.AppError
+// @Failure      400     {object}  middleware.Problem "JSON 格式错误或字段校验失败"
+// @Failure      404     {object}  middleware.Problem
+// @Failure      412     {object}  middleware.Problem "ETag 不匹配，学生已被修改"
+// @Failure      428     {object}  middleware.Problem "缺少 If-Match"
 // @Router       /students/{id} [put]
 func (h *StudentHandler) UpdateStudent(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
 		return
 	}
-	var input models.Student
+	var input StudentUpdateRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
 		return
 	}
-	var student models.Student
-	if err := h.DB.First(&student, uint(id)).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.Error(middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found"))
-			return
-		}
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	student, err := h.Students.Get(c.Request.Context(), id, false)
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
-	student.Name = input.Name
-	student.Email = input.Email
-	if err := h.DB.Save(&student).Error; err != nil {
+	if !checkIfMatch(c, student.Version) {
+		return
+	}
+	student, err = h.Students.Update(c.Request.Context(), id, student.Version, service.StudentInput{
+		Name:  input.Name,
+		Email: input.Email,
+	})
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	c.Header("ETag", etag(student.Version))
+	c.JSON(http.StatusOK, student)
+}
+
+// PatchStudent 部分更新学生
+// @Summary      部分更新学生
+// @Description  使用 JSON Merge Patch（RFC 7396）或 JSON Patch（RFC 6902）更新学生，只允许修改 name、email
+// @Tags         students
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int     true  "学生 ID"
+// @Param        If-Match  header    string  true  "当前 ETag"
+// @Param        request   body      object  true  "merge patch 对象或 JSON Patch 操作数组"
+// @Success      200  {object}  models.Student
+// @Failure      400  {object}  middleware.Problem "补丁格式错误、包含未知字段或字段校验失败"
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem "JSON Patch test 操作失败"
+// @Failure      412  {object}  middleware.Problem "ETag 不匹配"
+// @Failure      415  {object}  middleware.Problem "不支持的 Content-Type"
+// @Failure      422  {object}  middleware.Problem "修改只读字段"
+// @Failure      428  {object}  middleware.Problem "缺少 If-Match"
+// @Router       /students/{id} [patch]
+func (h *StudentHandler) PatchStudent(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	student, err := h.Students.Get(c.Request.Context(), id, false)
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	if !checkIfMatch(c, student.Version) {
+		return
+	}
+	doc, err := toDocument(student)
+	if err != nil {
 		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
+	var input StudentPatchRequest
+	if !applyPatch(c, doc, studentWritableFields, &input) {
+		return
+	}
+	student, err = h.Students.Update(c.Request.Context(), id, student.Version, service.StudentInput{
+		Name:  input.Name,
+		Email: input.Email,
+	})
+	if err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	c.Header("ETag", etag(student.Version))
 	c.JSON(http.StatusOK, student)
 }
 
 // DeleteStudent 删除学生
 // @Summary      删除学生
-// @Description  根据 ID 删除学生
+// @Description  根据 ID 软删除学生，管理员可通过恢复接口找回。存在未归还的借阅时拒绝删除（409）
 // @Tags         students
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      int  true  "学生 ID"
+// @Param        id       path      int     true   "学生 ID"
+// @Param        history  query     string  false  "已结束借阅的处理方式，archive 和 cascade 仅限管理员" Enums(keep, archive, cascade)
 // @Success      200  {object}  map[string]string "{"msg": "student deleted"}"
-// @Failure      400  {object}  middleware.AppError
-// @Failure      404  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem "非管理员使用 archive 或 cascade"
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem "存在未归还的借阅"
 // @Router       /students/{id} [delete]
 func (h *StudentHandler) DeleteStudent(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_ID", "invalid id"))
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
 		return
 	}
-	result := h.DB.Delete(&models.Student{}, uint(id))
-	if result.Error != nil {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error"))
+	mode, ok := historyMode(c)
+	if !ok {
 		return
 	}
-	if result.RowsAffected == 0 {
-		c.Error(middleware.NewAppError(http.StatusNotFound, "STUDENT_NOT_FOUND", "student not found"))
+	if err := h.Students.Delete(c.Request.Context(), id, mode); err != nil {
+		c.Error(serviceError(err, "INTERNAL_ERROR", "internal server error"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"msg": "student deleted"})
 }
+
+// RestoreStudent 恢复学生
+// @Summary      恢复学生
+// @Description  恢复一个已软删除的学生（仅管理员）
+// @Tags         students
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "学生 ID"
+// @Success      200  {object}  models.Student
+// @Failure      400  {object}  middleware.Problem
+// @Failure      403  {object}  middleware.Problem
+// @Failure      404  {object}  middleware.Problem
+// @Failure      409  {object}  middleware.Problem
+// @Router       /students/{id}/restore [post]
+func (h *StudentHandler) RestoreStudent(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	student, err := h.Students.Restore(c.Request.Context(), id)
+	if err != nil {
+		c.Error(serviceError(err, "FAILED_RESTORE_STUDENT", "failed to restore student"))
+		return
+	}
+	c.Header("ETag", etag(student.Version))
+	c.JSON(http.StatusOK, student)
+}
 func (h *StudentHandler) PanicTest(c *gin.Context) {
 	panic("kkk")
 }
@@ -4,44 +4,63 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
-	"log"
 	"net/http"
 	"path/filepath"
-	"strconv"
+	"strings"
 	"time"
-	"trae-go/config"
+	"trae-go/events"
 	"trae-go/middleware"
 	"trae-go/models"
+	"trae-go/pkg/store"
+	"trae-go/repository"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	DB  *gorm.DB
-	RDB *redis.Client
+	DB       *gorm.DB
+	Users    repository.UserRepository // 带版本号检查的写入
+	Sessions store.SessionStore
+	TokenTTL time.Duration // 登录 token 的有效期
 }
 
-func NewUserHanlder(db *gorm.DB, rdb *redis.Client) UserHandler {
-	return UserHandler{db, rdb}
+func NewUserHanlder(db *gorm.DB, sessions store.SessionStore, tokenTTL time.Duration) UserHandler {
+	return UserHandler{db, repository.NewGormUsers(db), sessions, tokenTTL}
+}
+
+// db 返回绑定了请求 context 的连接：查询会记录为请求 trace 的子 span，客户端断开时查询也会被取消
+func (h UserHandler) db(c *gin.Context) *gorm.DB {
+	return h.DB.WithContext(c.Request.Context())
 }
 
 type UserRegisterRequest struct {
-	Name      string `json:"user_name" binding:"required" example:"zhangsan"`
-	Password  string `json:"password" binding:"required" example:"123456"`
-	Sex       string `json:"sex" example:"male"`
+	Name      string `json:"user_name" binding:"required,min=3,max=32" example:"zhangsan"`
+	Password  string `json:"password" binding:"required,min=6,max=72" example:"123456"`
+	Sex       string `json:"sex" binding:"max=10" example:"male"`
 	BornDate  string `json:"born_date" example:"2006-01-02"` // 添加 example 提示格式
-	Identify  string `json:"ide" example:"student"`
-	AvatarURL string `json:"avatar_url"`
+	Identify  string `json:"ide" binding:"max=20" example:"student"`
+	AvatarURL string `json:"avatar_url" binding:"omitempty,url,max=255"`
 }
 type UserUpdateRequest struct {
-	Name      *string `json:"user_name"`
-	Sex       *string `json:"sex"`
+	Name      *string `json:"user_name" binding:"omitempty,min=3,max=32"`
+	Sex       *string `json:"sex" binding:"omitempty,max=10"`
 	BornDate  *string `json:"born_date"`
-	AvatarURL *string `json:"avatar_url"`
+	AvatarURL *string `json:"avatar_url" binding:"omitempty,url,max=255"`
+}
+
+// UserPatchRequest PATCH 之后个人资料可写字段的取值，born_date 格式为 yyyy-mm-dd
+type UserPatchRequest struct {
+	Name      string `json:"user_name" binding:"required,min=3,max=32"`
+	Sex       string `json:"sex" binding:"max=10"`
+	BornDate  string `json:"born_date" binding:"omitempty,datetime=2006-01-02"`
+	AvatarURL string `json:"avatar_url" binding:"omitempty,url,max=255"`
 }
+
+// userWritableFields 允许通过 PATCH 修改的字段，密码和身份不在其中
+var userWritableFields = []string{"user_name", "sex", "born_date", "avatar_url"}
+
 type UserLoginRequest struct {
 	Name     string `json:"user_name" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -63,18 +82,25 @@ func generateToken(n int) (string, error) {
 // @Produce      json
 // @Param        request body UserRegisterRequest true "注册信息"
 // @Success      201  {object}  models.User
-// @Failure      400  {object}  middleware.AppError "无效的 JSON 或用户已存在"
-// @Failure      500  {object}  middleware.AppError "服务器内部错误"
+// @Failure      400  {object}  middleware.Problem "无效的 JSON、字段校验失败或用户已存在"
+// @Failure      403  {object}  middleware.Problem "不能注册为管理员"
+// @Failure      500  {object}  middleware.Problem "服务器内部错误"
 // @Router       /user/register [post]
 func (h *UserHandler) UserRegister(c *gin.Context) {
 	var req UserRegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
+		return
+	}
+	// 管理员身份不能通过注册获得，大小写变体（如 "Admin"）同样拒绝
+	if strings.EqualFold(strings.TrimSpace(req.Identify), models.UserRoleAdmin) {
+		c.Error(middleware.NewAppError(http.StatusForbidden, "FORBIDDEN", "cannot register as admin"))
 		return
 	}
 
+	// 软删除的用户仍占用用户名（唯一索引），需要一起检查
 	var existing models.User
-	if err := h.DB.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+	if err := h.db(c).Unscoped().Where("name = ?", req.Name).First(&existing).Error; err == nil {
 		c.Error(middleware.NewAppError(http.StatusBadRequest, "USER_ALREADY_EXISTS", "user already exists"))
 		return
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -96,7 +122,12 @@ func (h *UserHandler) UserRegister(c *gin.Context) {
 
 		if parseErr != nil {
 			// 如果所有格式都尝试失败，才报错
-			c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_BORN_DATE", "invalid born_date format, expected yyyy-mm-dd"))
+			c.Error(middleware.NewValidationError(middleware.FieldError{
+				Field:   "born_date",
+				Rule:    "datetime",
+				Message: "invalid born_date format, expected yyyy-mm-dd",
+				Param:   "yyyy-mm-dd",
+			}))
 			return
 		}
 	}
@@ -116,7 +147,18 @@ func (h *UserHandler) UserRegister(c *gin.Context) {
 		AvatarURL: req.AvatarURL,
 	}
 
-	if err := h.DB.Create(&user).Error; err != nil {
+	// 用户和 user.registered 事件在同一事务中写入
+	err = h.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		e, err := events.New(events.UserRegistered, uint(user.ID), events.UserOf(&user))
+		if err != nil {
+			return err
+		}
+		return tx.Create(e).Error
+	})
+	if err != nil {
 		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_CREATE_USER", "failed to create user"))
 		return
 	}
@@ -131,18 +173,18 @@ func (h *UserHandler) UserRegister(c *gin.Context) {
 // @Produce      json
 // @Param        request body UserLoginRequest true "登录请求参数"
 // @Success      200  {object}  map[string]interface{} "{"token": "xxx", "user": {...}}"
-// @Failure      400  {object}  middleware.AppError
-// @Failure      401  {object}  middleware.AppError
+// @Failure      400  {object}  middleware.Problem
+// @Failure      401  {object}  middleware.Problem
 // @Router       /user/login [post]
 func (h *UserHandler) UserLogin(c *gin.Context) {
 	var req UserLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
 		return
 	}
 
 	var user models.User
-	if err := h.DB.Where("name = ?", req.Name).First(&user).Error; err != nil {
+	if err := h.db(c).Where("name = ?", req.Name).First(&user).Error; err != nil {
 		c.Error(middleware.NewAppError(http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid credentials"))
 		return
 	}
@@ -158,14 +200,7 @@ func (h *UserHandler) UserLogin(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
-	key := "auth:token:" + token
-	d, err := time.ParseDuration(config.AppConfig.Auth.TokenExpireHours)
-	if err != nil {
-		log.Printf("TokenExpireHours parse failed,use default setting")
-		d = 24 * time.Hour
-	}
-	if err := h.RDB.Set(ctx, key, strconv.Itoa(user.ID), d).Err(); err != nil {
+	if err := h.Sessions.SetSession(c.Request.Context(), token, uint(user.ID), h.TokenTTL); err != nil {
 		c.Error(middleware.NewAppError(http.StatusInternalServerError, "TOKEN_STORE_FAILED", "token store failed"))
 		return
 	}
@@ -178,20 +213,20 @@ func (h *UserHandler) UserLogin(c *gin.Context) {
 
 // UserDelete 删除用户
 // @Summary      删除用户
-// @Description  根据用户名删除用户
+// @Description  根据用户名软删除用户，已登录的 token 随即失效
 // @Tags         user
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        user_name path string true "用户名"
 // @Success      204  "No Content"
-// @Failure      404  {object}  middleware.AppError "用户未找到"
-// @Failure      500  {object}  middleware.AppError "删除失败"
+// @Failure      404  {object}  middleware.Problem "用户未找到"
+// @Failure      500  {object}  middleware.Problem "删除失败"
 // @Router       /user/{user_name} [delete]
 func (h *UserHandler) UserDelte(c *gin.Context) {
 	userName := c.Param("user_name")
 
-	result := h.DB.Where("name = ?", userName).Delete(&models.User{})
+	result := h.db(c).Where("name = ?", userName).Delete(&models.User{})
 	if result.Error != nil {
 		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_DELETE_USER", "failed to delete user"))
 		return
@@ -204,40 +239,85 @@ func (h *UserHandler) UserDelte(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// UserRestore 恢复用户
+// @Summary      恢复用户
+// @Description  恢复一个已软删除的用户（仅管理员）
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_name path string true "用户名"
+// @Success      200  {object}  models.User
+// @Failure      403  {object}  middleware.Problem "非管理员"
+// @Failure      404  {object}  middleware.Problem "用户未找到"
+// @Failure      409  {object}  middleware.Problem "用户未被删除"
+// @Failure      412  {object}  middleware.Problem "期间被并发修改"
+// @Router       /user/{user_name}/restore [post]
+func (h *UserHandler) UserRestore(c *gin.Context) {
+	userName := c.Param("user_name")
+
+	var user models.User
+	if err := h.db(c).Unscoped().Where("name = ?", userName).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(middleware.NewAppError(http.StatusNotFound, "USER_NOT_FOUND", "user not found"))
+			return
+		}
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal error"))
+		return
+	}
+	if !user.DeletedAt.Valid {
+		c.Error(middleware.NewAppError(http.StatusConflict, "USER_NOT_DELETED", "user is not deleted"))
+		return
+	}
+	ok, err := h.Users.Restore(c.Request.Context(), uint(user.ID), user.Version)
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_RESTORE_USER", "failed to restore user"))
+		return
+	}
+	if !ok {
+		// 查询之后被并发恢复或修改过，没有写入
+		c.Error(middleware.NewAppError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "resource has been modified"))
+		return
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version++
+	c.JSON(http.StatusOK, user)
+}
+
 // UpdateUser 更新用户信息
 // @Summary      更新个人资料
-// @Description  更新当前登录用户的个人信息（需要认证）
+// @Description  更新当前登录用户的个人信息（需要认证），需要携带 GET 时返回的 ETag 作为 If-Match
 // @Tags         user
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request body UserUpdateRequest true "更新信息"
+// @Param        If-Match  header  string             true  "当前 ETag"
+// @Param        request   body    UserUpdateRequest  true  "更新信息"
 // @Success      200  {object}  models.User
-// @Failure      400  {object}  middleware.AppError "无效的 JSON"
-// @Failure      401  {object}  middleware.AppError "未授权"
-// @Failure      404  {object}  middleware.AppError "用户未找到"
+// @Failure      400  {object}  middleware.Problem "无效的 JSON 或字段校验失败"
+// @Failure      401  {object}  middleware.Problem "未授权"
+// @Failure      404  {object}  middleware.Problem "用户未找到"
+// @Failure      412  {object}  middleware.Problem "ETag 不匹配"
+// @Failure      428  {object}  middleware.Problem "缺少 If-Match"
 // @Router       /user/profile [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	var req UserUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json"))
+		c.Error(middleware.NewBindError(err))
 		return
 	}
-	userIDval, ok := c.Get("user_id")
+	userID, ok := currentUserID(c)
 	if !ok {
-		c.Error(middleware.NewAppError(http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized"))
-		return
-	}
-	userID, ok := userIDval.(uint)
-	if !ok {
-		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal error"))
 		return
 	}
 	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
+	if err := h.db(c).First(&user, userID).Error; err != nil {
 		c.Error(middleware.NewAppError(http.StatusNotFound, "USER_NOT_FOUND", "user not found"))
 		return
 	}
+	if !checkIfMatch(c, user.Version) {
+		return
+	}
 	if req.Name != nil {
 		user.Name = *req.Name
 	}
@@ -250,7 +330,12 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		} else {
 			t, err := time.Parse("2006-01-02", *req.BornDate)
 			if err != nil {
-				c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_BORN_DATE", "invalid born_date"))
+				c.Error(middleware.NewValidationError(middleware.FieldError{
+					Field:   "born_date",
+					Rule:    "datetime",
+					Message: "must be a date in format 2006-01-02",
+					Param:   "2006-01-02",
+				}))
 				return
 			}
 			user.BornDate = t
@@ -260,14 +345,144 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		user.AvatarURL = *req.AvatarURL
 	}
 
-	if err := h.DB.Save(&user).Error; err != nil {
+	ok, err := h.Users.UpdateProfile(c.Request.Context(), &user)
+	if err != nil {
 		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_UPDATE_USER", "failed to update user"))
 		return
 	}
+	if !ok {
+		c.Error(middleware.NewAppError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "resource has been modified"))
+		return
+	}
+	user.Version++
 
+	c.Header("ETag", etag(user.Version))
 	c.JSON(http.StatusOK, user)
 }
 
+// PatchUser 部分更新个人资料
+// @Summary      部分更新个人资料
+// @Description  使用 JSON Merge Patch（RFC 7396）或 JSON Patch（RFC 6902）更新当前用户，只允许修改 user_name、sex、born_date、avatar_url
+// @Tags         user
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        If-Match  header    string  true  "当前 ETag"
+// @Param        request   body      object  true  "merge patch 对象或 JSON Patch 操作数组"
+// @Success      200  {object}  models.User
+// @Failure      400  {object}  middleware.Problem "补丁格式错误、包含未知字段或字段校验失败"
+// @Failure      401  {object}  middleware.Problem "未授权"
+// @Failure      409  {object}  middleware.Problem "JSON Patch test 操作失败"
+// @Failure      412  {object}  middleware.Problem "ETag 不匹配"
+// @Failure      415  {object}  middleware.Problem "不支持的 Content-Type"
+// @Failure      422  {object}  middleware.Problem "修改只读字段"
+// @Failure      428  {object}  middleware.Problem "缺少 If-Match"
+// @Router       /user/profile [patch]
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var user models.User
+	if err := h.db(c).First(&user, userID).Error; err != nil {
+		c.Error(middleware.NewAppError(http.StatusNotFound, "USER_NOT_FOUND", "user not found"))
+		return
+	}
+	if !checkIfMatch(c, user.Version) {
+		return
+	}
+	doc, err := toDocument(user)
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal error"))
+		return
+	}
+	// 补丁里的生日使用和注册接口一致的日期格式
+	doc["born_date"] = ""
+	if !user.BornDate.IsZero() {
+		doc["born_date"] = user.BornDate.Format("2006-01-02")
+	}
+	var input UserPatchRequest
+	if !applyPatch(c, doc, userWritableFields, &input) {
+		return
+	}
+	var bornDate time.Time
+	if input.BornDate != "" {
+		t, err := time.Parse("2006-01-02", input.BornDate)
+		if err != nil {
+			c.Error(middleware.NewValidationError(middleware.FieldError{
+				Field:   "born_date",
+				Rule:    "datetime",
+				Message: "must be a date in format 2006-01-02",
+				Param:   "2006-01-02",
+			}))
+			return
+		}
+		bornDate = t
+	}
+
+	ok, err = h.Users.UpdateProfile(c.Request.Context(), &models.User{
+		ID: user.ID, Version: user.Version, Name: input.Name, Sex: input.Sex, BornDate: bornDate, AvatarURL: input.AvatarURL,
+	})
+	if err != nil {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "FAILED_UPDATE_USER", "failed to update user"))
+		return
+	}
+	if !ok {
+		c.Error(middleware.NewAppError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "resource has been modified"))
+		return
+	}
+	if err := h.db(c).First(&user, userID).Error; err != nil {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal error"))
+		return
+	}
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+// GetProfile 获取个人资料
+// @Summary      获取个人资料
+// @Description  获取当前登录用户的信息，响应头中的 ETag 用于后续更新时的 If-Match
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        If-None-Match  header    string  false  "上次获取的 ETag，未变化时返回 304"
+// @Success      200  {object}  models.User
+// @Success      304  "Not Modified"
+// @Failure      401  {object}  middleware.Problem "未授权"
+// @Failure      404  {object}  middleware.Problem "用户未找到"
+// @Router       /user/profile [get]
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var user models.User
+	if err := h.db(c).First(&user, userID).Error; err != nil {
+		c.Error(middleware.NewAppError(http.StatusNotFound, "USER_NOT_FOUND", "user not found"))
+		return
+	}
+	if notModified(c, user.Version) {
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// currentUserID 取出 AuthenticationMiddleware 写入的 user_id
+func currentUserID(c *gin.Context) (uint, bool) {
+	userIDval, ok := c.Get("user_id")
+	if !ok {
+		c.Error(middleware.NewAppError(http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized"))
+		return 0, false
+	}
+	userID, ok := userIDval.(uint)
+	if !ok {
+		c.Error(middleware.NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal error"))
+		return 0, false
+	}
+	return userID, true
+}
+
 // UploadAvatar 上传头像
 // @Summary      上传头像
 // @Description  上传用户头像文件，返回头像 URL
@@ -276,8 +491,8 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Produce      json
 // @Param        avatar formData file true "头像文件"
 // @Success      200  {object}  map[string]string "{"avatar_url": "http://..."}"
-// @Failure      400  {object}  middleware.AppError "文件获取失败"
-// @Failure      500  {object}  middleware.AppError "保存文件失败"
+// @Failure      400  {object}  middleware.Problem "文件获取失败"
+// @Failure      500  {object}  middleware.Problem "保存文件失败"
 // @Router       /user/uploadAvatar [post]
 func (h *UserHandler) UploadAvatar(c *gin.Context) {
 	path, err := SaveFile(c, "avatar", "static/avatars")
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"trae-go/middleware"
+	"trae-go/models"
+	"trae-go/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler webhook 订阅管理接口（仅管理员）
+type WebhookHandler struct {
+	Webhooks *webhooks.Manager
+}
+
+func NewWebhookHandler(m *webhooks.Manager) *WebhookHandler {
+	return &WebhookHandler{Webhooks: m}
+}
+
+// WebhookRequest 创建或全量更新订阅
+type WebhookRequest struct {
+	URL         string   `json:"url" binding:"required,max=2048" example:"https://sis.example.com/hooks/library"`
+	EventTypes  []string `json:"event_types" binding:"required,min=1" example:"book.borrowed,book.returned"` // "*" 表示全部事件
+	Secret      string   `json:"secret" binding:"omitempty,min=16,max=255"`                                  // 签名密钥，创建时不传则自动生成，更新时不传则保留原密钥
+	Description string   `json:"description" binding:"max=255" example:"SIS 借阅同步"`
+	Active      *bool    `json:"active"` // 默认 true
+}
+
+func (r *WebhookRequest) input() webhooks.Input {
+	active := r.Active == nil || *r.Active
+	return webhooks.Input{URL: r.URL, EventTypes: r.EventTypes, Secret: r.Secret, Description: r.Description, Active: active}
+}
+
+// WebhookCreated 创建订阅的响应，密钥只在创建时返回
+type WebhookCreated struct {
+	models.Webhook
+	Secret string `json:"secret" example:"whsec_3f1c..."`
+}
+
+// WebhookDeliveryDetail 投递记录和它的投递日志
+type WebhookDeliveryDetail struct {
+	models.WebhookDelivery
+	AttemptLog []models.WebhookAttempt `json:"attempt_log"`
+}
+
+// webhookError 把 webhooks 包的错误转换成对应的 AppError
+func webhookError(err error, code, msg string) *middleware.AppError {
+	var unknown *webhooks.UnknownEventTypeError
+	switch {
+	case errors.Is(err, webhooks.ErrNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "WEBHOOK_NOT_FOUND", "webhook not found")
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		return middleware.NewAppError(http.StatusNotFound, "DELIVERY_NOT_FOUND", "webhook delivery not found")
+	case errors.Is(err, webhooks.ErrInvalidURL):
+		return middleware.NewAppError(http.StatusBadRequest, "INVALID_WEBHOOK_URL", err.Error())
+	case errors.Is(err, webhooks.ErrNoEventTypes):
+		return middleware.NewAppError(http.StatusBadRequest, "INVALID_EVENT_TYPE", err.Error())
+	case errors.As(err, &unknown):
+		return middleware.NewAppError(http.StatusBadRequest, "INVALID_EVENT_TYPE", "unknown event type").
+			WithDetails(gin.H{"type": unknown.Type})
+	case errors.Is(err, webhooks.ErrDeliveryPending):
+		return middleware.NewAppError(http.StatusConflict, "DELIVERY_PENDING", err.Error())
+	case errors.Is(err, webhooks.ErrInactive):
+		return middleware.NewAppError(http.StatusConflict, "WEBHOOK_INACTIVE", err.Error())
+	}
+	return middleware.NewAppError(http.StatusInternalServerError, code, msg)
+}
+
+// ListWebhooks 获取 webhook 订阅列表
+// @Summary      获取 webhook 订阅列表
+// @Description  全部 webhook 订阅，不含签名密钥（仅管理员）
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Webhook
+// @Failure      403  {object}  middleware.Problem
+// @Router       /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	hooks, err := h.Webhooks.List(c.Request.Context())
+	if err != nil {
+		c.Error(webhookError(err, "FAILED_LIST_WEBHOOKS", "failed to list webhooks"))
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// GetWebhook 获取单个 webhook 订阅
+// @Summary      获取单个 webhook 订阅
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "订阅 ID"
+// @Success      200  {object}  models.Webhook
+// @Failure      400  {object}  middleware.Problem "ID 无效"
+// @Failure      404  {object}  middleware.Problem "订阅未找到"
+// @Router       /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	hook, err := h.Webhooks.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(webhookError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// CreateWebhook 创建 webhook 订阅
+// @Summary      创建 webhook 订阅
+// @Description  订阅的事件发生后向 url 发送签名的 POST 请求。响应中的 secret 只返回这一次（仅管理员）
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body WebhookRequest true "订阅信息"
+// @Success      201  {object}  WebhookCreated
+// @Failure      400  {object}  middleware.Problem "字段校验失败、地址不是 http(s) 或事件类型未定义"
+// @Router       /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.NewBindError(err))
+		return
+	}
+	hook, err := h.Webhooks.Create(c.Request.Context(), req.input())
+	if err != nil {
+		c.Error(webhookError(err, "FAILED_CREATE_WEBHOOK", "failed to create webhook"))
+		return
+	}
+	c.JSON(http.StatusCreated, WebhookCreated{Webhook: *hook, Secret: hook.Secret})
+}
+
+// UpdateWebhook 全量更新 webhook 订阅
+// @Summary      更新 webhook 订阅
+// @Description  不传 secret 时保留原密钥；停用（active 为 false）时尚未送达的投递转入死信（仅管理员）
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int             true  "订阅 ID"
+// @Param        request  body      WebhookRequest  true  "订阅信息"
+// @Success      200  {object}  models.Webhook
+// @Failure      400  {object}  middleware.Problem
+// @Failure      404  {object}  middleware.Problem "订阅未找到"
+// @Router       /admin/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.NewBindError(err))
+		return
+	}
+	hook, err := h.Webhooks.Update(c.Request.Context(), id, req.input())
+	if err != nil {
+		c.Error(webhookError(err, "FAILED_UPDATE_WEBHOOK", "failed to update webhook"))
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook 删除 webhook 订阅
+// @Summary      删除 webhook 订阅
+// @Description  尚未送达的投递转入死信，投递记录保留到 webhooks.retention 后清理（仅管理员）
+// @Tags         webhooks
+// @Security     BearerAuth
+// @Param        id   path  int  true  "订阅 ID"
+// @Success      204  "No Content"
+// @Failure      404  {object}  middleware.Problem "订阅未找到"
+// @Router       /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	if err := h.Webhooks.Delete(c.Request.Context(), id); err != nil {
+		c.Error(webhookError(err, "FAILED_DELETE_WEBHOOK", "failed to delete webhook"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries 获取投递记录
+// @Summary      获取 webhook 投递记录
+// @Description  按 ID 倒序。status=dead 即死信列表；用上一页最后一条的 ID 作为 before_id 翻页（仅管理员）
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        webhook_id  query     int     false  "只看该订阅的投递"
+// @Param        status      query     string  false  "pending、succeeded 或 dead"
+// @Param        before_id   query     int     false  "只返回 ID 小于它的投递"
+// @Param        limit       query     int     false  "条数，默认 50，最多 200"
+// @Success      200  {array}   models.WebhookDelivery
+// @Failure      400  {object}  middleware.Problem "查询参数无效"
+// @Router       /admin/webhooks/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var f webhooks.DeliveryFilter
+	switch status := c.Query("status"); status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+		f.Status = status
+	default:
+		c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", "status must be pending, succeeded or dead"))
+		return
+	}
+	for _, q := range []struct {
+		name string
+		msg  string
+		dst  *uint
+	}{
+		{"webhook_id", "invalid webhook_id", &f.WebhookID},
+		{"before_id", "invalid before_id", &f.BeforeID},
+	} {
+		if v := c.Query(q.name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", q.msg))
+				return
+			}
+			*q.dst = uint(n)
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.Error(middleware.NewAppError(http.StatusBadRequest, "INVALID_QUERY", "invalid limit"))
+			return
+		}
+		f.Limit = n
+	}
+	deliveries, err := h.Webhooks.Deliveries(c.Request.Context(), f)
+	if err != nil {
+		c.Error(webhookError(err, "FAILED_LIST_DELIVERIES", "failed to list webhook deliveries"))
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDelivery 获取单个投递记录和投递日志
+// @Summary      获取 webhook 投递详情
+// @Description  投递记录（含请求体）和每次请求的结果：状态码、错误、响应体开头和耗时（仅管理员）
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "投递 ID"
+// @Success      200  {object}  WebhookDeliveryDetail
+// @Failure      404  {object}  middleware.Problem "投递未找到"
+// @Router       /admin/webhooks/deliveries/{id} [get]
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	d, attempts, err := h.Webhooks.Delivery(c.Request.Context(), id)
+	if err != nil {
+		c.Error(webhookError(err, "INTERNAL_ERROR", "internal server error"))
+		return
+	}
+	c.JSON(http.StatusOK, WebhookDeliveryDetail{WebhookDelivery: *d, AttemptLog: attempts})
+}
+
+// RedeliverDelivery 手动重新投递
+// @Summary      重新投递
+// @Description  把已送达或死信中的投递重新排入队列并立即发送，重新开始计算尝试次数（仅管理员）
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "投递 ID"
+// @Success      202  {object}  models.WebhookDelivery
+// @Failure      404  {object}  middleware.Problem "投递未找到"
+// @Failure      409  {object}  middleware.Problem "投递仍在等待发送（DELIVERY_PENDING）或订阅已停用 / 删除（WEBHOOK_INACTIVE）"
+// @Router       /admin/webhooks/deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) RedeliverDelivery(c *gin.Context) {
+	id, ok := pathID(c, "id", "INVALID_ID", "invalid id")
+	if !ok {
+		return
+	}
+	d, err := h.Webhooks.Redeliver(c.Request.Context(), id)
+	if err != nil {
+		c.Error(webhookError(err, "FAILED_REDELIVER", "failed to redeliver webhook"))
+		return
+	}
+	c.JSON(http.StatusAccepted, d)
+}
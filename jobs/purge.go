@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"trae-go/config"
+	"trae-go/models"
+	"trae-go/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultRetention     = 30 * 24 * time.Hour
+	defaultPurgeInterval = 24 * time.Hour
+)
+
+// PurgeSoftDeleted 物理删除软删除时间早于 now-retention 的图书、学生和用户。
+// 图书 / 学生已结束的借阅历史先归档到 loan_archives；仍有未归还借阅的记录会被跳过。
+func PurgeSoftDeleted(db *gorm.DB, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	var total int64
+
+	n, err := purgeWithLoans(db, &models.Book{}, "books", "book_id", cutoff)
+	if err != nil {
+		return total, err
+	}
+	total += n
+
+	n, err = purgeWithLoans(db, &models.Student{}, "students", "student_id", cutoff)
+	if err != nil {
+		return total, err
+	}
+	total += n
+
+	result := db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Delete(&models.User{})
+	if result.Error != nil {
+		return total, result.Error
+	}
+	total += result.RowsAffected
+
+	return total, nil
+}
+
+// purgeWithLoans 归档 table 中待清理记录的借阅历史后物理删除它们。
+// 待清理的行在事务中查询并锁住：期间被恢复的记录不再匹配，不会被删除；恢复要等清理提交
+func purgeWithLoans(db *gorm.DB, model interface{}, table, column string, cutoff time.Time) (int64, error) {
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Unscoped().Model(model).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM book_students WHERE book_students."+column+" = "+table+".id AND book_students.status = ?)", models.BorrowStatusBorrowed).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := models.ArchiveClosedLoans(tx, column, ids); err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(model, ids)
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected, err
+}
+
+// StartPurgeJob 按 PurgeInterval 周期执行 PurgeSoftDeleted，直到 ctx 结束
+func StartPurgeJob(ctx context.Context, db *gorm.DB, cfg config.SoftDeleteConfig) {
+	log := logger.Ctx(ctx)
+	// 配置校验拒绝不是正数的时长，0 或负数的保留期会一次清理所有软删除的记录
+	retention := config.Duration(cfg.Retention, defaultRetention)
+	interval := config.Duration(cfg.PurgeInterval, defaultPurgeInterval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := PurgeSoftDeleted(db.WithContext(ctx), retention)
+			if err != nil {
+				log.Error("purge soft deleted rows failed", zap.Error(err))
+				continue
+			}
+			log.Info("purge soft deleted rows", zap.Int64("rows", n), zap.Duration("retention", retention))
+		}
+	}
+}
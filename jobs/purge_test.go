@@ -0,0 +1,52 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"trae-go/jobs"
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestPurgeSoftDeleted(t *testing.T) {
+	e := testutil.New(t)
+	s := e.CreateStudent()
+	old := time.Now().Add(-48 * time.Hour)
+	deleteAt := func(b *models.Book, at time.Time) {
+		e.SoftDelete(b)
+		e.DB.Unscoped().Model(b).Update("deleted_at", at)
+	}
+
+	purged := e.CreateBook()
+	e.CreateLoan(s, purged, testutil.Returned)
+	deleteAt(purged, old)
+	onLoan := e.CreateBook()
+	e.CreateLoan(s, onLoan)
+	deleteAt(onLoan, old)
+	recent := e.CreateBook()
+	deleteAt(recent, time.Now())
+	restored := e.CreateBook()
+	deleteAt(restored, old)
+	e.DB.Unscoped().Model(restored).Update("deleted_at", nil)
+
+	if _, err := jobs.PurgeSoftDeleted(e.DB, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		book *models.Book
+		want int64
+	}{{purged, 0}, {onLoan, 1}, {recent, 1}, {restored, 1}} {
+		var n int64
+		e.DB.Unscoped().Model(&models.Book{}).Where("id = ?", tt.book.ID).Count(&n)
+		if n != tt.want {
+			t.Errorf("book %d: %d rows left, want %d", tt.book.ID, n, tt.want)
+		}
+	}
+	// 已结束的借阅历史先归档
+	var archived int64
+	e.DB.Model(&models.LoanArchive{}).Where("book_id = ?", purged.ID).Count(&archived)
+	if archived != 1 {
+		t.Fatalf("%d loans archived, want 1", archived)
+	}
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"trae-go/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// cleanupInterval Poller 清理旧记录的间隔
+const cleanupInterval = time.Hour
+
+// Poller 循环处理到期的待发送记录（webhook 投递、通知），并定期清理已结束的旧记录
+type Poller struct {
+	Name      string        // 日志中的名称，如 "webhook deliveries"
+	Interval  time.Duration // 没有积压时两次处理的间隔
+	BatchSize int           // Process 一次最多处理的条数，处理满一批时立即处理下一批
+	Retention time.Duration // 已结束记录的保留时长，只用于日志
+	Process   func(ctx context.Context) (int, error)
+	Cleanup   func(ctx context.Context) (int64, error)
+}
+
+// Run 循环调用 Process，每隔 cleanupInterval 调用一次 Cleanup，直到 ctx 结束
+func (p Poller) Run(ctx context.Context) {
+	log := logger.Ctx(ctx)
+	lastCleanup := time.Time{}
+	for {
+		if time.Since(lastCleanup) >= cleanupInterval {
+			if n, err := p.Cleanup(ctx); err != nil {
+				log.Error("clean up "+p.Name+" failed", zap.Error(err))
+			} else if n > 0 {
+				log.Info("clean up "+p.Name, zap.Int64("rows", n), zap.Duration("retention", p.Retention))
+			}
+			lastCleanup = time.Now()
+		}
+		n, err := p.Process(ctx)
+		wait := p.Interval
+		if err != nil && ctx.Err() == nil {
+			log.Warn("process "+p.Name+" failed", zap.Error(err))
+		} else if n == p.BatchSize {
+			// 可能还有积压，立即处理下一批
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Claim 把一条到期的待发送记录的 next_attempt_at 推迟到 until 作为占用。
+// 其他实例查到同一记录时条件更新不生效，返回 false
+func Claim(db *gorm.DB, model interface{}, id uint, pending string, now, until time.Time) (bool, error) {
+	res := db.Model(model).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, pending, now).
+		Update("next_attempt_at", until)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Release 立即释放占用，退出时中断的发送不计入尝试次数，由下次启动或其他实例重试。
+// ctx 已结束时也会执行
+func Release(ctx context.Context, db *gorm.DB, model interface{}, now time.Time) error {
+	return db.WithContext(context.WithoutCancel(ctx)).Model(model).Update("next_attempt_at", now).Error
+}
+
+// Backoff 第 n 次尝试失败后到下次重试的等待时间：base × 2^(n-1)，不超过 max
+func Backoff(base, max time.Duration, n int) time.Duration {
+	d := base
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+// Truncate 截断到 n 字节以内，不截断多字节字符，并替换非法的 UTF-8（响应体可能是任意字节）
+func Truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "�")
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	for n, want := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		10: time.Hour, // 不超过上限
+	} {
+		if got := Backoff(time.Minute, time.Hour, n); got != want {
+			t.Errorf("Backoff(1m, 1h, %d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"short", "abc", "abc"},
+		{"cut", "abcdef", "abcd"},
+		{"multibyte", "ab中文", "ab"}, // 不截断多字节字符
+		{"invalid utf8", "a\xffb", "a�"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.in, 4); got != tt.want {
+			t.Errorf("%s: Truncate(%q, 4) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"trae-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Runner 管理后台任务的生命周期：Go 启动任务，Stop 通知所有任务退出并等待它们结束
+type Runner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunner 任务的 ctx 中带有 log，任务内通过 logger.Ctx(ctx) 记录日志
+func NewRunner(log *zap.Logger) *Runner {
+	ctx, cancel := context.WithCancel(logger.NewContext(context.Background(), log))
+	return &Runner{ctx: ctx, cancel: cancel}
+}
+
+// Go 在新的 goroutine 中运行 fn，fn 应在 ctx 结束后尽快返回
+func (r *Runner) Go(name string, fn func(ctx context.Context)) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() {
+			if p := recover(); p != nil {
+				logger.Ctx(r.ctx).Error("background job panic", zap.String("job", name), zap.Any("panic", p))
+			}
+		}()
+		logger.Ctx(r.ctx).Info("background job started", zap.String("job", name))
+		fn(r.ctx)
+		logger.Ctx(r.ctx).Info("background job stopped", zap.String("job", name))
+	}()
+}
+
+// Stop 取消所有任务并最多等待 timeout，超时返回错误
+func (r *Runner) Stop(timeout time.Duration) error {
+	r.cancel()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("background jobs did not stop within %s", timeout)
+	}
+}
@@ -18,36 +18,8 @@ package main
 // @in header
 // @name Authorization
 
-import (
-	"log"
-
-	"trae-go/config"
-	"trae-go/pkg/logger"
-	"trae-go/router"
-)
+import "trae-go/cmd"
 
 func main() {
-	config.InitConfig()
-	logger.InitLogger(config.AppConfig.Log, config.AppConfig.Server.Mode)
-	defer logger.L.Sync() // 退出前刷新缓冲
-
-	db, err := config.InitDatabase()
-	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
-	}
-	rdb, err := config.InitRedis()
-	if err != nil {
-		log.Fatalf("failed to connect redis: %v", err)
-	}
-	defer rdb.Close()
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Fatalf("failed to get sql db: %v", err)
-	}
-	defer sqlDB.Close()
-
-	r := router.SetupRouter(db, rdb)
-	if err := r.Run(":" + config.AppConfig.Server.Port); err != nil {
-		log.Fatalf("failed to run server: %v", err)
-	}
+	cmd.Execute()
 }
@@ -8,10 +8,15 @@ import (
 	"go.uber.org/zap"
 )
 
+// ProblemContentType RFC 7807 错误响应的 Content-Type
+const ProblemContentType = "application/problem+json"
+
 type AppError struct {
 	StatusCode int
 	Code       string
 	Message    string
+	Details    interface{}  // 可选的附加信息，例如阻止删除的借阅记录
+	Errors     []FieldError // 字段级校验错误
 }
 
 // 实现error接口，让AppError成为error
@@ -27,41 +32,122 @@ func NewAppError(statuscode int, code string, msg string) *AppError {
 	}
 }
 
+// WithDetails 附加错误详情，会出现在响应的 details 字段中
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	e.Details = details
+	return e
+}
+
+// Problem RFC 7807 错误响应体，code / request_id / errors / details 为扩展字段
+type Problem struct {
+	Type      string         `json:"type" example:"/problems/book-not-found"`
+	Title     string         `json:"title" example:"Book not found"`
+	Status    int            `json:"status" example:"404"`
+	Detail    string         `json:"detail,omitempty" example:"book not found"`
+	Instance  string         `json:"instance" example:"/api/v1/books/42"`
+	Code      string         `json:"code" example:"BOOK_NOT_FOUND"`
+	RequestID string         `json:"request_id"`
+	Errors    []ProblemError `json:"errors,omitempty"`
+	Details   interface{}    `json:"details,omitempty"`
+}
+
+// ProblemError errors[] 中的一项：字段校验错误，或同一请求中的其它错误
+type ProblemError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+var internalError = NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+
+// NewProblem 按 lang 把 AppError 转换成 Problem，未注册的错误码使用 about:blank
+func NewProblem(c *gin.Context, appErr *AppError, lang string) Problem {
+	p := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(appErr.StatusCode),
+		Status:    appErr.StatusCode,
+		Detail:    Translate(lang, appErr.Message),
+		Instance:  c.Request.URL.Path,
+		Code:      appErr.Code,
+		RequestID: c.GetString("request_id"),
+		Details:   appErr.Details,
+	}
+	if t, ok := LookupErrorType(appErr.Code); ok {
+		p.Type = t.Type
+		p.Title = t.Title[lang]
+	}
+	p.Errors = appendFieldErrors(p.Errors, appErr, lang)
+	return p
+}
+
+func appendFieldErrors(list []ProblemError, appErr *AppError, lang string) []ProblemError {
+	for _, fe := range appErr.Errors {
+		list = append(list, ProblemError{
+			Code:    appErr.Code,
+			Field:   fe.Field,
+			Rule:    fe.Rule,
+			Message: translateFieldError(lang, fe),
+		})
+	}
+	return list
+}
+
+// handleError 只有一个错误时的便捷写法
 func handleError(c *gin.Context, err error) {
+	handleErrors(c, []error{err})
+}
+
+// handleErrors 记录全部错误，并以第一个错误为主写出一个 problem+json 响应，
+// 其余错误汇总到 errors[] 中。非 AppError 的内部错误只记日志，对外统一为 INTERNAL_ERROR。
+func handleErrors(c *gin.Context, errs []error) {
 	rid := c.GetString("request_id")
+	log := logger.Ctx(c.Request.Context())
 
-	if appErr, ok := err.(*AppError); ok {
-		// 日志照常记录
-		logger.L.Warn("Business Error",
-			zap.String("rid", rid),
-			zap.String("code", appErr.Code),
-			zap.String("msg", appErr.Message))
-
-		// 响应只在没写过的时候写
-		if !c.Writer.Written() {
-			c.JSON(appErr.StatusCode, gin.H{
-				"code":       appErr.Code,
-				"message":    appErr.Message,
-				"request_id": rid,
-			})
+	appErrs := make([]*AppError, 0, len(errs))
+	for _, err := range errs {
+		if appErr, ok := err.(*AppError); ok {
+			// 日志照常记录
+			log.Warn("Business Error",
+				zap.String("rid", rid),
+				zap.String("code", appErr.Code),
+				zap.String("msg", appErr.Message))
+			appErrs = append(appErrs, appErr)
+			continue
 		}
+
+		log.Error("Internal Server Error",
+			zap.String("rid", rid),
+			zap.Error(err),
+		)
+		appErrs = append(appErrs, internalError)
+	}
+	if len(appErrs) == 0 {
 		return
 	}
 
-	// 日志照常记录
-	logger.L.Error("Internal Server Error",
-		zap.String("rid", rid),
-		zap.Error(err),
-	)
-
 	// 响应只在没写过的时候写
-	if !c.Writer.Written() {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":       "INTERNAL_ERROR",
-			"message":    "internal server error",
-			"request_id": rid,
+	if c.Writer.Written() {
+		return
+	}
+
+	lang := NegotiateLanguage(c.GetHeader("Accept-Language"))
+	problem := NewProblem(c, appErrs[0], lang)
+	for _, appErr := range appErrs[1:] {
+		if len(appErr.Errors) > 0 {
+			problem.Errors = appendFieldErrors(problem.Errors, appErr, lang)
+			continue
+		}
+		problem.Errors = append(problem.Errors, ProblemError{
+			Code:    appErr.Code,
+			Message: Translate(lang, appErr.Message),
 		})
 	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.Header("Content-Language", lang)
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	c.JSON(problem.Status, problem)
 }
 
 func ErrorHandlingMiddleware() gin.HandlerFunc {
@@ -72,7 +158,10 @@ func ErrorHandlingMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		err := c.Errors[0].Err
-		handleError(c, err)
+		errs := make([]error, 0, len(c.Errors))
+		for _, e := range c.Errors {
+			errs = append(errs, e.Err)
+		}
+		handleErrors(c, errs)
 	}
 }
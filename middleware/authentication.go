@@ -1,45 +1,71 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
+
+	"trae-go/models"
+	"trae-go/pkg/store"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func AuthenticationMiddleware(db *gorm.DB, rdb *redis.Client) gin.HandlerFunc {
+var errUnauthorized = NewAppError(http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+
+func AuthenticationMiddleware(db *gorm.DB, sessions store.SessionStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Request.Header.Get("Authorization")
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			handleError(c, errUnauthorized)
 			c.Abort()
 			return
 		}
 
-		ctx := c.Request.Context()
-		key := "auth:token:" + token
-		userIDStr, err := rdb.Get(ctx, key).Result()
-		if err == redis.Nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		id, err := sessions.GetSession(c.Request.Context(), token)
+		if errors.Is(err, store.ErrNotFound) {
+			handleError(c, errUnauthorized)
 			c.Abort()
 			return
 		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			handleError(c, err)
 			c.Abort()
 			return
 		}
 
-		id, err := strconv.ParseUint(userIDStr, 10, 64)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		// 已被（软）删除的用户，token 即使没过期也不再有效
+		var user models.User
+		if err := db.Select("id", "identify").First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				handleError(c, errUnauthorized)
+				c.Abort()
+				return
+			}
+			handleError(c, err)
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", uint(id))
+		c.Set("user_id", id)
+		c.Set("user_role", user.Identify)
+		c.Next()
+	}
+}
+
+// IsAdmin 判断当前登录用户是否为管理员
+func IsAdmin(c *gin.Context) bool {
+	return c.GetString("user_role") == models.UserRoleAdmin
+}
+
+// AdminRequired 只允许管理员访问，需挂在 AuthenticationMiddleware 之后
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			handleError(c, NewAppError(http.StatusForbidden, "FORBIDDEN", "admin only"))
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
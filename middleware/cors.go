@@ -2,16 +2,14 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CorsMiddleware(allowedOrigins []string) gin.HandlerFunc {
-	origins := make(map[string]struct{}, len(allowedOrigins))
-	for _, o := range allowedOrigins {
-		origins[o] = struct{}{}
-	} //range取出，赋value给o，再把o填进map
-
+// CorsMiddleware allowOrigins 每个请求调用一次，返回允许的来源，为空时允许所有来源；
+// 配置热更新后立即生效
+func CorsMiddleware(allowOrigins func() []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
 		if origin == "" {
@@ -19,18 +17,18 @@ func CorsMiddleware(allowedOrigins []string) gin.HandlerFunc {
 			return
 		}
 
-		if len(origins) > 0 {
-			if _, ok := origins[origin]; !ok {
-				c.AbortWithStatus(http.StatusForbidden)
-				return
-			}
+		allowed := allowOrigins()
+		if len(allowed) > 0 && !slices.Contains(allowed, origin) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
 		}
 
 		h := c.Writer.Header()
 		h.Set("Access-Control-Allow-Origin", origin)
 		h.Set("Access-Control-Allow-Credentials", "true")
-		h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, "+APIKeyHeader)
+		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		h.Set("Access-Control-Expose-Headers", "ETag, "+RequestIDHeader+", "+RateLimitLimitHeader+", "+RateLimitRemainingHeader+", "+RateLimitResetHeader+", "+RetryAfterHeader)
 
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrorType 错误码注册表中的一项，对应 problem+json 里的 type。
+// 通过 GET /problems/{type} 可以查到每个错误码的说明。
+type ErrorType struct {
+	Code        string            `json:"code"`
+	Type        string            `json:"type"`
+	Status      int               `json:"status"`
+	Title       map[string]string `json:"title"`       // 语言 -> 标题
+	Description string            `json:"description"` // 何时出现、客户端该怎么处理
+}
+
+var errorTypes = map[string]ErrorType{}
+
+func registerErrorType(code string, status int, en, zh, description string) {
+	errorTypes[code] = ErrorType{
+		Code:        code,
+		Type:        ProblemTypeURI(code),
+		Status:      status,
+		Title:       map[string]string{LangEN: en, LangZH: zh},
+		Description: description,
+	}
+}
+
+// ProblemTypeURI 错误码对应的 type URI，如 BOOK_NOT_FOUND -> /problems/book-not-found
+func ProblemTypeURI(code string) string {
+	return "/problems/" + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+// LookupErrorType 按错误码或 type URI 最后一段（book-not-found）查找注册表
+func LookupErrorType(codeOrSlug string) (ErrorType, bool) {
+	code := strings.ToUpper(strings.ReplaceAll(codeOrSlug, "-", "_"))
+	t, ok := errorTypes[code]
+	return t, ok
+}
+
+// ErrorTypes 按错误码排序返回全部注册项
+func ErrorTypes() []ErrorType {
+	list := make([]ErrorType, 0, len(errorTypes))
+	for _, t := range errorTypes {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+func init() {
+	// 通用
+	registerErrorType("INTERNAL_ERROR", http.StatusInternalServerError, "Internal server error", "服务器内部错误",
+		"服务端未预期的错误，详细原因只记录在日志中，可凭 request_id 排查。")
+	registerErrorType("UNAUTHORIZED", http.StatusUnauthorized, "Unauthorized", "未登录",
+		"缺少或无效的 Authorization token，需要重新登录。")
+	registerErrorType("FORBIDDEN", http.StatusForbidden, "Forbidden", "没有权限",
+		"当前用户没有执行该操作的权限，例如非管理员访问管理接口。")
+	registerErrorType("CONFLICT", http.StatusConflict, "Conflict", "资源冲突",
+		"操作与资源当前状态冲突，例如删除仍有未归还借阅的图书；details 中给出冲突的记录。")
+	registerErrorType("TOO_MANY_REQUEST", http.StatusTooManyRequests, "Too many requests", "请求过于频繁",
+		"触发限流，请稍后重试。")
+	registerErrorType("PROBLEM_TYPE_NOT_FOUND", http.StatusNotFound, "Problem type not found", "错误类型不存在",
+		"GET /problems/{type} 查询的错误类型没有注册。")
+	registerErrorType("RATE_LIMIT_STORAGE_ERROR", http.StatusInternalServerError, "Rate limit storage error", "限流存储异常",
+		"限流计数存储不可用。")
+
+	// 请求格式与校验
+	registerErrorType("INVALID_JSON", http.StatusBadRequest, "Invalid JSON", "JSON 格式错误",
+		"请求体不是合法的 JSON。")
+	registerErrorType("VALIDATION_FAILED", http.StatusBadRequest, "Validation failed", "参数校验失败",
+		"一个或多个字段未通过校验，errors 中按字段列出了规则和原因。")
+	registerErrorType("INVALID_ID", http.StatusBadRequest, "Invalid id", "ID 无效",
+		"路径中的 ID 不是正整数。")
+	registerErrorType("INVALID_BOOK_ID", http.StatusBadRequest, "Invalid book id", "图书 ID 无效",
+		"路径中的 book_id 不是正整数。")
+	registerErrorType("INVALID_STUDENT_ID", http.StatusBadRequest, "Invalid student id", "学生 ID 无效",
+		"路径中的学生 ID 不是正整数。")
+	registerErrorType("INVALID_HISTORY_MODE", http.StatusBadRequest, "Invalid history mode", "历史处理方式无效",
+		"删除接口的 history 参数只能是 keep、archive 或 cascade。")
+	registerErrorType("INVALID_QUERY", http.StatusBadRequest, "Invalid query parameter", "查询参数无效",
+		"查询参数的取值不合法，detail 中说明是哪个参数。")
+
+	// 条件请求与 PATCH
+	registerErrorType("PRECONDITION_REQUIRED", http.StatusPreconditionRequired, "Precondition required", "缺少 If-Match",
+		"更新接口必须携带 If-Match 请求头，值为 GET 时返回的 ETag。")
+	registerErrorType("PRECONDITION_FAILED", http.StatusPreconditionFailed, "Precondition failed", "资源已被修改",
+		"If-Match 与资源当前版本不一致，说明有人先修改了它，请重新获取后再提交。")
+	registerErrorType("INVALID_PATCH", http.StatusBadRequest, "Invalid patch", "补丁格式错误",
+		"merge patch 或 JSON Patch 文档无法解析或无法应用。")
+	registerErrorType("PATCH_TEST_FAILED", http.StatusConflict, "Patch test failed", "补丁 test 操作失败",
+		"JSON Patch 中的 test 操作与资源当前值不一致。")
+	registerErrorType("UNSUPPORTED_PATCH_TYPE", http.StatusUnsupportedMediaType, "Unsupported patch type", "不支持的补丁格式",
+		"PATCH 只接受 application/merge-patch+json 和 application/json-patch+json，见 Accept-Patch 响应头。")
+	registerErrorType("UNKNOWN_FIELD", http.StatusBadRequest, "Unknown field", "未知字段",
+		"补丁中出现了资源不存在的字段，details.fields 列出这些字段。")
+	registerErrorType("FIELD_READ_ONLY", http.StatusUnprocessableEntity, "Field is read-only", "字段只读",
+		"补丁试图修改服务端维护的字段（id、created_at、stock、version 等），details.fields 列出这些字段。")
+
+	// 图书
+	registerErrorType("BOOK_NOT_FOUND", http.StatusNotFound, "Book not found", "图书不存在", "图书不存在或已被删除。")
+	registerErrorType("BOOK_NOT_DELETED", http.StatusConflict, "Book is not deleted", "图书未被删除", "只能恢复已软删除的图书。")
+	registerErrorType("BOOK_OUT_OF_STOCK", http.StatusBadRequest, "Book out of stock", "图书库存不足", "图书当前没有可借的库存。")
+	registerErrorType("FAILED_LIST_BOOKS", http.StatusInternalServerError, "Failed to list books", "获取图书列表失败", "查询图书列表时数据库出错。")
+	registerErrorType("FAILED_GET_BOOK", http.StatusInternalServerError, "Failed to get book", "获取图书失败", "查询图书时数据库出错。")
+	registerErrorType("FAILED_CREATE_BOOK", http.StatusInternalServerError, "Failed to create book", "创建图书失败", "写入图书时数据库出错。")
+	registerErrorType("FAILED_UPDATE_BOOK", http.StatusInternalServerError, "Failed to update book", "更新图书失败", "更新图书时数据库出错。")
+	registerErrorType("FAILED_DELETE_BOOK", http.StatusInternalServerError, "Failed to delete book", "删除图书失败", "删除图书时数据库出错。")
+	registerErrorType("FAILED_RESTORE_BOOK", http.StatusInternalServerError, "Failed to restore book", "恢复图书失败", "恢复图书时数据库出错。")
+
+	// 学生与借阅
+	registerErrorType("STUDENT_NOT_FOUND", http.StatusNotFound, "Student not found", "学生不存在", "学生不存在或已被删除。")
+	registerErrorType("STUDENT_NOT_DELETED", http.StatusConflict, "Student is not deleted", "学生未被删除", "只能恢复已软删除的学生。")
+	registerErrorType("FAILED_LIST_STUDENTS", http.StatusInternalServerError, "Failed to list students", "获取学生列表失败", "查询学生列表时数据库出错。")
+	registerErrorType("FAILED_CREATE_STUDENT", http.StatusInternalServerError, "Failed to create student", "创建学生失败", "写入学生时数据库出错。")
+	registerErrorType("FAILED_RESTORE_STUDENT", http.StatusInternalServerError, "Failed to restore student", "恢复学生失败", "恢复学生时数据库出错。")
+	registerErrorType("BORROW_RECORD_NOT_FOUND", http.StatusNotFound, "Borrow record not found", "借阅记录不存在", "该学生没有未归还的这本书。")
+	registerErrorType("LOAN_ALREADY_RETURNED", http.StatusConflict, "Book already returned", "图书已归还",
+		"同一笔借阅被并发归还，另一个请求已经完成归还。")
+
+	// 用户
+	registerErrorType("USER_NOT_FOUND", http.StatusNotFound, "User not found", "用户不存在", "用户不存在或已被删除。")
+	registerErrorType("USER_NOT_DELETED", http.StatusConflict, "User is not deleted", "用户未被删除", "只能恢复已软删除的用户。")
+	registerErrorType("USER_ALREADY_EXISTS", http.StatusBadRequest, "User already exists", "用户已存在", "用户名已被占用（包括已删除的用户）。")
+	registerErrorType("INVALID_CREDENTIALS", http.StatusUnauthorized, "Invalid credentials", "用户名或密码错误", "登录失败。")
+	registerErrorType("FAILED_CREATE_USER", http.StatusInternalServerError, "Failed to create user", "创建用户失败", "写入用户时数据库出错。")
+	registerErrorType("FAILED_UPDATE_USER", http.StatusInternalServerError, "Failed to update user", "更新用户失败", "更新用户时数据库出错。")
+	registerErrorType("FAILED_DELETE_USER", http.StatusInternalServerError, "Failed to delete user", "删除用户失败", "删除用户时数据库出错。")
+	registerErrorType("FAILED_RESTORE_USER", http.StatusInternalServerError, "Failed to restore user", "恢复用户失败", "恢复用户时数据库出错。")
+	registerErrorType("PASSWORD_HASH_FAILED", http.StatusInternalServerError, "Password hash failed", "密码加密失败", "生成密码哈希失败。")
+	registerErrorType("TOKEN_GENERATE_FAILED", http.StatusInternalServerError, "Token generate failed", "生成 token 失败", "生成登录 token 失败。")
+	registerErrorType("TOKEN_STORE_FAILED", http.StatusInternalServerError, "Token store failed", "保存 token 失败", "登录 token 写入存储失败。")
+	registerErrorType("SAVE_FILE_FAILED", http.StatusInternalServerError, "Save file failed", "保存文件失败", "上传文件保存失败。")
+
+	// webhook
+	registerErrorType("WEBHOOK_NOT_FOUND", http.StatusNotFound, "Webhook not found", "webhook 订阅不存在", "订阅不存在或已被删除。")
+	registerErrorType("DELIVERY_NOT_FOUND", http.StatusNotFound, "Webhook delivery not found", "投递记录不存在",
+		"投递记录不存在，或已超过 webhooks.retention 被清理。")
+	registerErrorType("INVALID_WEBHOOK_URL", http.StatusBadRequest, "Invalid webhook url", "webhook 地址无效", "url 必须是完整的 http 或 https 地址。")
+	registerErrorType("INVALID_EVENT_TYPE", http.StatusBadRequest, "Invalid event type", "事件类型无效",
+		"event_types 为空或包含未定义的事件类型；\"*\" 表示订阅全部事件。")
+	registerErrorType("DELIVERY_PENDING", http.StatusConflict, "Delivery is pending", "投递仍在进行",
+		"投递仍在等待发送或重试，只有已送达或死信中的投递可以重新投递。")
+	registerErrorType("WEBHOOK_INACTIVE", http.StatusConflict, "Webhook is inactive", "webhook 订阅已停用",
+		"订阅已停用或删除，重新启用后才能重新投递。")
+	registerErrorType("FAILED_LIST_WEBHOOKS", http.StatusInternalServerError, "Failed to list webhooks", "获取订阅列表失败", "查询 webhook 订阅时数据库出错。")
+	registerErrorType("FAILED_CREATE_WEBHOOK", http.StatusInternalServerError, "Failed to create webhook", "创建订阅失败", "写入 webhook 订阅时数据库出错。")
+	registerErrorType("FAILED_UPDATE_WEBHOOK", http.StatusInternalServerError, "Failed to update webhook", "更新订阅失败", "更新 webhook 订阅时数据库出错。")
+	registerErrorType("FAILED_DELETE_WEBHOOK", http.StatusInternalServerError, "Failed to delete webhook", "删除订阅失败", "删除 webhook 订阅时数据库出错。")
+	registerErrorType("FAILED_LIST_DELIVERIES", http.StatusInternalServerError, "Failed to list deliveries", "获取投递记录失败", "查询 webhook 投递记录时数据库出错。")
+	registerErrorType("FAILED_REDELIVER", http.StatusInternalServerError, "Failed to redeliver", "重新投递失败", "重新投递时数据库出错。")
+
+	// 邮件通知
+	registerErrorType("NOTIFICATION_NOT_FOUND", http.StatusNotFound, "Notification not found", "通知不存在",
+		"通知不存在，或已超过 notify.retention 被清理。")
+	registerErrorType("INVALID_LOCALE", http.StatusBadRequest, "Invalid locale", "语言无效", "locale 只能是 zh-CN、en 或留空（使用默认语言）。")
+	registerErrorType("INVALID_PICKUP_BY", http.StatusBadRequest, "Invalid pickup time", "领取期限无效", "pickup_by 必须晚于当前时间。")
+	registerErrorType("NOTIFICATION_PENDING", http.StatusConflict, "Notification is pending", "通知仍在发送",
+		"通知仍在等待发送或重试，只有已发送或发送失败的通知可以重新发送。")
+	registerErrorType("NOTIFICATION_DUPLICATE", http.StatusConflict, "Notification already queued", "通知已发送过",
+		"同一学生、图书和领取期限的预约到馆通知已经入队，不会重复发送；需要再次发送时使用重新发送接口。")
+	registerErrorType("NOTIFICATION_OPTED_OUT", http.StatusConflict, "Notification opted out", "学生已关闭该类通知",
+		"学生在通知偏好中关闭了这类通知，没有入队。")
+	registerErrorType("STUDENT_NO_EMAIL", http.StatusUnprocessableEntity, "Student has no email", "学生没有邮箱",
+		"学生没有登记邮箱，无法发送通知，先更新学生的 email。")
+	registerErrorType("FAILED_UPDATE_PREFERENCES", http.StatusInternalServerError, "Failed to update preferences", "更新通知偏好失败", "写入通知偏好时数据库出错。")
+	registerErrorType("FAILED_LIST_NOTIFICATIONS", http.StatusInternalServerError, "Failed to list notifications", "获取通知失败", "查询通知时数据库出错。")
+	registerErrorType("FAILED_QUEUE_NOTIFICATION", http.StatusInternalServerError, "Failed to queue notification", "通知入队失败", "渲染或写入通知时出错。")
+	registerErrorType("FAILED_RESEND_NOTIFICATION", http.StatusInternalServerError, "Failed to resend notification", "重新发送通知失败", "重新发送通知时数据库出错。")
+}
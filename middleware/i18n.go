@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的响应语言
+const (
+	LangEN = "en"
+	LangZH = "zh-CN"
+)
+
+// DefaultLang 没有 Accept-Language 或都不支持时使用的语言
+const DefaultLang = LangEN
+
+// NegotiateLanguage 按 Accept-Language 中的 q 值选出支持的语言：zh* 对应 zh-CN，en* 对应 en
+func NegotiateLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range fields[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		var lang string
+		switch {
+		case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+			lang = LangZH
+		case tag == "en" || strings.HasPrefix(tag, "en-"):
+			lang = LangEN
+		case tag == "*":
+			lang = DefaultLang
+		default:
+			continue
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return DefaultLang
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// zhMessages 英文错误信息 -> 中文。错误信息以英文原文为 key，未收录的原样返回
+var zhMessages = map[string]string{
+	"internal error":                           "服务器内部错误",
+	"internal server error":                    "服务器内部错误",
+	"unauthorized":                             "未登录或登录已失效",
+	"admin only":                               "仅管理员可以访问",
+	"cannot register as admin":                 "不能注册为管理员",
+	"include_deleted requires admin":           "只有管理员可以查看已删除的记录",
+	"problem type not found":                   "错误类型不存在",
+	"too many request":                         "请求过于频繁，请稍后再试",
+	"rate limit storage error":                 "限流存储异常",
+	"invalid json":                             "请求体不是合法的 JSON",
+	"validation failed":                        "参数校验失败",
+	"invalid id":                               "ID 无效",
+	"invalid book_id":                          "图书 ID 无效",
+	"invalid student_id":                       "学生 ID 无效",
+	"history must be keep, archive or cascade": "history 只能是 keep、archive 或 cascade",
+	"history mode requires admin":              "只有管理员可以归档或删除借阅历史",
+	"If-Match header required":                 "缺少 If-Match 请求头",
+	"resource has been modified":               "资源已被修改，请重新获取后再提交",
+	"invalid patch":                            "补丁格式错误",
+	"patch result must be a json object":       "打补丁后的结果必须是 JSON 对象",
+	"unsupported patch content type":           "不支持的补丁格式",
+	"unknown field":                            "包含未知字段",
+	"field is read-only":                       "字段只读，不能修改",
+	"book not found":                           "图书不存在",
+	"book is not deleted":                      "图书未被删除",
+	"book out of stock":                        "图书库存不足",
+	"book has active loans":                    "图书还有未归还的借阅",
+	"failed to list books":                     "获取图书列表失败",
+	"failed to get book":                       "获取图书失败",
+	"failed to create book":                    "创建图书失败",
+	"failed to update book":                    "更新图书失败",
+	"failed to delete book":                    "删除图书失败",
+	"failed to restore book":                   "恢复图书失败",
+	"student not found":                        "学生不存在",
+	"student is not deleted":                   "学生未被删除",
+	"student has active loans":                 "学生还有未归还的借阅",
+	"failed to list students":                  "获取学生列表失败",
+	"failed to create student":                 "创建学生失败",
+	"failed to restore student":                "恢复学生失败",
+	"borrow record not found":                  "借阅记录不存在",
+	"book already returned":                    "图书已归还",
+	"user not found":                           "用户不存在",
+	"user is not deleted":                      "用户未被删除",
+	"user already exists":                      "用户已存在",
+	"invalid credentials":                      "用户名或密码错误",
+	"failed to create user":                    "创建用户失败",
+	"failed to update user":                    "更新用户失败",
+	"failed to delete user":                    "删除用户失败",
+	"failed to restore user":                   "恢复用户失败",
+	"password hash failed":                     "密码加密失败",
+	"token generate failed":                    "生成 token 失败",
+	"token store failed":                       "保存 token 失败",
+	"save file failed":                         "保存文件失败",
+	"invalid limit":                            "limit 无效",
+	"invalid before_id":                        "before_id 无效",
+
+	// webhook
+	"invalid webhook_id":                                "webhook 订阅 ID 无效",
+	"webhook not found":                                 "webhook 订阅不存在",
+	"webhook delivery not found":                        "投递记录不存在",
+	"webhook url must be an absolute http(s) URL":       "webhook 地址必须是完整的 http 或 https 地址",
+	"webhook must subscribe to at least one event type": "webhook 至少要订阅一种事件",
+	"unknown event type":                                "未知的事件类型",
+	"webhook delivery is still pending":                 "投递仍在进行，不能重新投递",
+	"webhook is not active":                             "webhook 订阅已停用",
+	"status must be pending, succeeded or dead":         "status 只能是 pending、succeeded 或 dead",
+	"failed to list webhooks":                           "获取 webhook 订阅列表失败",
+	"failed to create webhook":                          "创建 webhook 订阅失败",
+	"failed to update webhook":                          "更新 webhook 订阅失败",
+	"failed to delete webhook":                          "删除 webhook 订阅失败",
+	"failed to list webhook deliveries":                 "获取投递记录失败",
+	"failed to redeliver webhook":                       "重新投递失败",
+
+	// 通知
+	"notification not found":                                        "通知不存在",
+	"locale must be zh-CN or en":                                    "locale 只能是 zh-CN 或 en",
+	"pickup_by must be in the future":                               "pickup_by 必须晚于当前时间",
+	"notification is still pending":                                 "通知仍在发送，不能重新发送",
+	"notification already queued":                                   "通知已发送过，不会重复发送",
+	"student has turned off this kind of notification":              "学生已关闭该类通知",
+	"student has no email address":                                  "学生没有邮箱地址",
+	"status must be pending, sent or failed":                        "status 只能是 pending、sent 或 failed",
+	"kind must be due_soon, overdue, hold_ready or account_created": "kind 只能是 due_soon、overdue、hold_ready 或 account_created",
+	"failed to list notifications":                                  "获取通知失败",
+	"failed to queue notification":                                  "通知入队失败",
+	"failed to resend notification":                                 "重新发送通知失败",
+	"failed to update notification preferences":                     "更新通知偏好失败",
+}
+
+// Translate 把英文错误信息翻译成 lang
+func Translate(lang, msg string) string {
+	if lang == LangZH {
+		if zh, ok := zhMessages[msg]; ok {
+			return zh
+		}
+	}
+	return msg
+}
+
+// translateFieldError 按校验规则生成 lang 语言的字段错误说明
+func translateFieldError(lang string, fe FieldError) string {
+	if lang != LangZH {
+		return fe.Message
+	}
+	switch fe.Rule {
+	case "required":
+		return "不能为空"
+	case "email":
+		return "必须是合法的邮箱地址"
+	case "isbn", "isbn10", "isbn13":
+		return "必须是合法的 ISBN"
+	case "url":
+		return "必须是合法的 URL"
+	case "min":
+		if fe.kind == "string" {
+			return fmt.Sprintf("长度不能少于 %s 个字符", fe.Param)
+		}
+		return fmt.Sprintf("不能小于 %s", fe.Param)
+	case "max":
+		if fe.kind == "string" {
+			return fmt.Sprintf("长度不能超过 %s 个字符", fe.Param)
+		}
+		return fmt.Sprintf("不能大于 %s", fe.Param)
+	case "gte":
+		return fmt.Sprintf("必须大于或等于 %s", fe.Param)
+	case "lte":
+		return fmt.Sprintf("必须小于或等于 %s", fe.Param)
+	case "oneof":
+		return fmt.Sprintf("必须是 [%s] 之一", fe.Param)
+	case "datetime":
+		return fmt.Sprintf("必须是 %s 格式的日期", fe.Param)
+	case "type":
+		return fmt.Sprintf("类型必须是 %s", fe.Param)
+	}
+	return "格式不正确"
+}
@@ -8,6 +8,15 @@ import (
 	"go.uber.org/zap"
 )
 
+// LoggerMiddleware 把服务实例的 logger 放进请求 context，需挂在最前面：
+// 之后的中间件和 handler 通过 logger.Ctx(c.Request.Context()) 记录日志
+func LoggerMiddleware(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), log))
+		c.Next()
+	}
+}
+
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startAt := time.Now()
@@ -20,25 +29,12 @@ func LoggingMiddleware() gin.HandlerFunc {
 
 		statusCode := c.Writer.Status()
 		latency := time.Since(startAt)
-		rcTemp1, exist := c.Get("request_count")
-		var rc int64
-		if exist {
-			if rcTemp2, ok := rcTemp1.(int64); ok {
-				rc = rcTemp2
-			} else {
-				rc = 0
-				logger.L.Warn("request_count type assert to int64 failed")
-			}
-		} else {
-			rc = 0
-			logger.L.Debug("request_count haven't been set")
-		}
 		userID, ok := c.Get("user_id")
 		if !ok {
 			userID = 0
 		}
 
-		logger.L.Info("HTTP Request",
+		logger.Ctx(c.Request.Context()).Info("HTTP Request",
 			zap.String("rid", c.Writer.Header().Get(RequestIDHeader)),
 			zap.String("method", method),
 			zap.String("path", path),
@@ -46,7 +42,6 @@ func LoggingMiddleware() gin.HandlerFunc {
 			zap.Duration("latency", latency),
 			zap.String("ip", clientIP),
 			zap.String("ua", userAgent),
-			zap.Int64("rc", rc),
 			zap.Any("userID", userID),
 		)
 
@@ -1,129 +1,192 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
-	"sync"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 	"trae-go/config"
+	"trae-go/pkg/metrics"
+	"trae-go/pkg/store"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 )
 
-type limiter struct {
-	windowStart time.Time
-	count       int
-	limit       int
-	window      time.Duration
-	mu          sync.Mutex
-}
-
-var (
-	ipLimiters = make(map[string]*limiter) //map来制作局部限流器
-	ipMu       sync.RWMutex
+// 限流响应头，见 IETF RateLimit header fields 草案
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
 )
-var globleLimter = &limiter{
-	windowStart: time.Now(),
-	limit:       int(config.AppConfig.RateLimit.GlobalLimit),
-	window:      time.Minute,
-}
 
-func getLimiterForIP(ip string) *limiter {
-	ipMu.RLock()
-	l, ok := ipLimiters[ip]
-	ipMu.RUnlock()
-	if ok {
-		return l
-	}
-
-	ipMu.Lock()
-	defer ipMu.Unlock()
-
-	if l, ok = ipLimiters[ip]; ok {
-		return l
-	}
-	l = &limiter{
-		windowStart: time.Now(),
-		limit:       int(config.AppConfig.RateLimit.IPLimit),
-		window:      time.Minute,
-	}
-	ipLimiters[ip] = l
-	return l
+// APIKeyHeader 按 api_key 计数的策略从这个请求头取 key
+const APIKeyHeader = "X-API-Key"
 
+// rateLimitCheck 一次限流检查
+type rateLimitCheck struct {
+	name   string // 策略名，也是指标的 scope
+	key    string // 计数 key
+	policy config.RateLimitPolicy
 }
 
-func (l *limiter) Allow() bool {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	timenow := time.Now()
-	if timenow.After(l.windowStart.Add(l.window)) {
-		l.windowStart = time.Now()
-		l.count = 0
-	}
-	if l.count < l.limit {
-		l.count++
-		return true
-	}
-	return false
-}
+// RateLimitConfigFunc 返回当前的限流配置，每个请求调用一次，配置热更新后立即生效
+type RateLimitConfigFunc func() config.RateLimitConfig
 
-func RateLimiterMiddleware() gin.HandlerFunc {
+// StoreRateLimiterMiddleware 全局挂载，检查 global 和 ip 两条策略，计数保存在 store 中：
+// 使用 Redis 时多个实例共享同一份计数
+func StoreRateLimiterMiddleware(limiter store.RateLimitStore, rateLimitConfig RateLimitConfigFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		cfg := rateLimitConfig()
 		ip := c.ClientIP()
-		limiter := getLimiterForIP(ip)
-
-		if !globleLimter.Allow() || !limiter.Allow() {
-			err := NewAppError(http.StatusTooManyRequests, "TOO_MANY_REQUEST", "too many request")
-			handleError(c, err)
-			c.Abort()
-			return
+		var checks []rateLimitCheck
+		if p := cfg.GlobalPolicy(); !allowListed(p, ip, "") {
+			checks = append(checks, rateLimitCheck{"global", rateLimitKey(p, "global", "", ""), p})
 		}
-		c.Next()
-
+		if p := cfg.IPPolicy(); !allowListed(p, ip, ip) {
+			checks = append(checks, rateLimitCheck{"ip", rateLimitKey(p, "ip", "ip", ip), p})
+		}
+		enforceRateLimits(c, limiter, checks)
 	}
 }
 
-func RedisRateLimiterMiddleware(rdb *redis.Client) gin.HandlerFunc {
+// RateLimitGroupMiddleware 检查 groups 中包含 group 的具名策略。
+// 按用户计数和按身份覆盖额度依赖登录信息，需挂在 AuthenticationMiddleware 之后
+func RateLimitGroupMiddleware(limiter store.RateLimitStore, rateLimitConfig RateLimitConfigFunc, group string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx := c.Request.Context()
-		ip := c.ClientIP()
-
-		globalKey := "rate:global"
-		ipKey := fmt.Sprintf("rate:ip:%s", ip)
+		cfg := rateLimitConfig()
+		names := make([]string, 0, len(cfg.Policies))
+		for name := range cfg.Policies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
 
-		globalLimit := int64(config.AppConfig.RateLimit.GlobalLimit)
-		ipLimit := int64(config.AppConfig.RateLimit.IPLimit)
-		window := time.Minute
+		ip := c.ClientIP()
+		var checks []rateLimitCheck
+		for _, name := range names {
+			p, _ := cfg.NamedPolicy(name)
+			if !slices.Contains(p.Groups, group) {
+				continue
+			}
+			p, exempt := p.ForRole(c.GetString("user_role"))
+			if exempt {
+				continue
+			}
+			kind, id := rateLimitSubject(c, p)
+			if allowListed(p, ip, id) {
+				continue
+			}
+			checks = append(checks, rateLimitCheck{name, rateLimitKey(p, name, kind, id), p})
+		}
+		enforceRateLimits(c, limiter, checks)
+	}
+}
 
-		globalCount, err := rdb.Incr(ctx, globalKey).Result()
+// enforceRateLimits 依次执行检查，任一策略拒绝即返回 429。
+// 响应头中的 RateLimit-* 取剩余额度最少的策略，被拒绝时另外返回 Retry-After
+func enforceRateLimits(c *gin.Context, limiter store.RateLimitStore, checks []rateLimitCheck) {
+	ctx := c.Request.Context()
+	for _, chk := range checks {
+		res, err := limiter.Allow(ctx, chk.key, store.Limit{
+			Algorithm: chk.policy.Algorithm,
+			Limit:     chk.policy.Limit,
+			Window:    chk.policy.WindowDuration(),
+		})
 		if err != nil {
 			appErr := NewAppError(http.StatusInternalServerError, "RATE_LIMIT_STORAGE_ERROR", "rate limit storage error")
 			handleError(c, appErr)
 			c.Abort()
 			return
 		}
-		if globalCount == 1 {
-			rdb.Expire(ctx, globalKey, window)
-		}
-		ipCount, err := rdb.Incr(ctx, ipKey).Result()
-		if err != nil {
-			appErr := NewAppError(http.StatusInternalServerError, "RATE_LIMIT_STORAGE_ERROR", "rate limit storage error")
+		if !res.Allowed {
+			c.Header(RateLimitLimitHeader, strconv.FormatInt(res.Limit, 10))
+			c.Header(RateLimitRemainingHeader, "0")
+			c.Header(RateLimitResetHeader, strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+			c.Header(RetryAfterHeader, strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+			metrics.RateLimitRejections.WithLabelValues(chk.name).Inc()
+			appErr := NewAppError(http.StatusTooManyRequests, "TOO_MANY_REQUEST", "too many request")
 			handleError(c, appErr)
 			c.Abort()
 			return
 		}
-		if ipCount == 1 {
-			rdb.Expire(ctx, ipKey, window)
+		setRateLimitHeaders(c, res)
+	}
+
+	c.Next()
+}
+
+// rateLimitSubject 按策略的 key 取计数对象，取不到（未登录、没带请求头）时退回客户端 IP。
+// API key 和请求头只认配置过的值，否则客户端每次换一个值就能拿到新的额度
+func rateLimitSubject(c *gin.Context, p config.RateLimitPolicy) (kind, id string) {
+	switch {
+	case p.Key == "user":
+		if v, ok := c.Get("user_id"); ok {
+			return "user", fmt.Sprint(v)
+		}
+	case p.Key == "api_key":
+		if v := c.GetHeader(APIKeyHeader); v != "" && p.KnownKey(v) {
+			return "api_key", v
 		}
+	case strings.HasPrefix(p.Key, "header:"):
+		if v := c.GetHeader(strings.TrimPrefix(p.Key, "header:")); v != "" && p.KnownKey(v) {
+			return "header", v
+		}
+	}
+	return "ip", c.ClientIP()
+}
 
-		if globalCount > globalLimit || ipCount > ipLimit {
-			appErr := NewAppError(http.StatusTooManyRequests, "TOO_MANY_REQUEST", "too many request")
-			handleError(c, appErr)
-			c.Abort()
-			return
+// allowListed 客户端 IP 在 allow_ips 中，或计数 key 在 allow_keys 中
+func allowListed(p config.RateLimitPolicy, ip, id string) bool {
+	if id != "" && slices.Contains(p.AllowKeys, id) {
+		return true
+	}
+	if len(p.AllowIPs) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, s := range p.AllowIPs {
+		if prefix, err := config.ParseIPOrCIDR(s); err == nil && prefix.Contains(addr) {
+			return true
 		}
+	}
+	return false
+}
 
-		c.Next()
+// rateLimitKey 计数 key 带上算法名：两种算法在 Redis 中的数据类型不同，切换算法时不能沿用旧 key。
+// API key 和请求头的值取哈希，避免原文出现在 Redis 中
+func rateLimitKey(p config.RateLimitPolicy, name, kind, id string) string {
+	if kind == "" {
+		return fmt.Sprintf("rate:%s:%s", p.Algorithm, name)
 	}
+	if kind == "api_key" || kind == "header" {
+		sum := sha256.Sum256([]byte(id))
+		id = hex.EncodeToString(sum[:16])
+	}
+	return fmt.Sprintf("rate:%s:%s:%s:%s", p.Algorithm, name, kind, id)
+}
+
+// setRateLimitHeaders 全局和路由组的策略都会调用，只在剩余额度更少时覆盖已有的响应头
+func setRateLimitHeaders(c *gin.Context, res store.Result) {
+	if prev, err := strconv.ParseInt(c.Writer.Header().Get(RateLimitRemainingHeader), 10, 64); err == nil && prev <= res.Remaining {
+		return
+	}
+	c.Header(RateLimitLimitHeader, strconv.FormatInt(res.Limit, 10))
+	c.Header(RateLimitRemainingHeader, strconv.FormatInt(res.Remaining, 10))
+	c.Header(RateLimitResetHeader, strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+}
+
+// ceilSeconds 向上取整到秒，Retry-After 至少为 1 秒，避免客户端立即重试
+func ceilSeconds(d time.Duration) int64 {
+	s := int64((d + time.Second - 1) / time.Second)
+	return max(s, 1)
 }
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"trae-go/pkg/replica"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadYourWritesMiddleware 给请求 context 加上读写粘滞标记：配置了只读副本时，
+// 请求写过数据后，之后的查询都走主库。未配置副本时没有影响
+func ReadYourWritesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(replica.Sticky(c.Request.Context()))
+		c.Next()
+	}
+}
@@ -13,7 +13,7 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if r := recover(); r != nil {
-				logger.L.Error("Panic Recovered",
+				logger.Ctx(c.Request.Context()).Error("Panic Recovered",
 					zap.Any("error", r),
 					zap.String("stack", string(debug.Stack())), // 记录堆栈
 					zap.String("rid", c.GetString("request_id")),
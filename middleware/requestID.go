@@ -6,16 +6,26 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const RequestIDHeader = "X-Request-ID"
 
+// RequestIDMiddleware 沿用请求头中的 X-Request-ID；没有时优先使用 trace ID，
+// 这样日志、错误响应里的 request_id 可以直接在 trace 后端中搜索
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := trace.SpanFromContext(c.Request.Context())
 		rid := c.Request.Header.Get(RequestIDHeader)
 		if rid == "" {
-			rid = fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int63())
+			if sc := span.SpanContext(); sc.IsValid() {
+				rid = sc.TraceID().String()
+			} else {
+				rid = fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int63())
+			}
 		}
+		span.SetAttributes(attribute.String("request.id", rid))
 		c.Set("request_id", rid)
 		c.Writer.Header().Set(RequestIDHeader, rid)
 
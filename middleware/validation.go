@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError 单个字段的校验错误，前端据此定位出错的输入框
+type FieldError struct {
+	Field   string `json:"field"`   // JSON 字段名，嵌套字段用 . 连接
+	Rule    string `json:"rule"`    // 未通过的校验规则，如 required、email
+	Message string `json:"message"` // 给人看的说明
+
+	Param string `json:"-"` // 规则参数，如 max=100 中的 100，用于翻译
+	kind  string // string 或其它，min/max 的说明依此区分长度和数值
+}
+
+// SetupValidator 让 gin 的校验器在错误里使用 json tag 作为字段名，启动时调用一次
+func SetupValidator() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return fld.Name
+		}
+		return name
+	})
+}
+
+// NewBindError 把 ShouldBind / 校验返回的错误转换成 AppError：
+// 字段校验失败和字段类型错误返回 VALIDATION_FAILED 并列出字段错误，其它情况视为 JSON 格式错误
+func NewBindError(err error) *AppError {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			kind := "number"
+			if fe.Kind() == reflect.String {
+				kind = "string"
+			}
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Message: ruleMessage(fe),
+				Param:   fe.Param(),
+				kind:    kind,
+			})
+		}
+		return NewValidationError(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		typeName := jsonTypeName(typeErr.Type)
+		return NewValidationError(FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("must be of type %s", typeName),
+			Param:   typeName,
+		})
+	}
+
+	return NewAppError(http.StatusBadRequest, "INVALID_JSON", "invalid json")
+}
+
+// NewValidationError 生成带字段错误列表的 400 错误，handler 自己做的校验也用它返回
+func NewValidationError(fields ...FieldError) *AppError {
+	appErr := NewAppError(http.StatusBadRequest, "VALIDATION_FAILED", "validation failed")
+	appErr.Errors = fields
+	return appErr
+}
+
+// fieldPath 去掉命名空间中的结构体名，得到 title、items.0.name 这样的路径
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		ns = ns[i+1:]
+	}
+	ns = strings.NewReplacer("[", ".", "]", "").Replace(ns)
+	return ns
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "isbn", "isbn10", "isbn13":
+		return "must be a valid ISBN"
+	case "url":
+		return "must be a valid URL"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", fe.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "datetime":
+		return fmt.Sprintf("must be a date in format %s", fe.Param())
+	}
+	return "is invalid"
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"fmt"
+
+	"trae-go/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// initVersion 初始表结构 0001_init 的版本号
+const initVersion = 1
+
+// legacyColumn 引入版本化迁移之前由 AutoMigrate 建的表可能缺少的列
+type legacyColumn struct {
+	table, column string
+	defs          map[string]string // 方言 -> 列定义，与 0001_init 中一致
+	index         string            // MySQL 的索引写在 CREATE TABLE 中，表已存在时需要单独创建
+}
+
+var (
+	deletedAtDefs = map[string]string{"sqlite": "datetime", "postgres": "timestamptz", "mysql": "datetime(3) NULL"}
+	versionDefs   = map[string]string{
+		"sqlite":   "integer NOT NULL DEFAULT 1",
+		"postgres": "bigint NOT NULL DEFAULT 1",
+		"mysql":    "bigint unsigned NOT NULL DEFAULT 1",
+	}
+)
+
+var legacyColumns = []legacyColumn{
+	{table: "books", column: "isbn", defs: map[string]string{"sqlite": "text", "postgres": "varchar(17)", "mysql": "varchar(17)"}, index: "idx_books_isbn"},
+	{table: "books", column: "deleted_at", defs: deletedAtDefs, index: "idx_books_deleted_at"},
+	{table: "books", column: "version", defs: versionDefs},
+	{table: "students", column: "deleted_at", defs: deletedAtDefs, index: "idx_students_deleted_at"},
+	{table: "students", column: "version", defs: versionDefs},
+	{table: "users", column: "deleted_at", defs: deletedAtDefs, index: "idx_users_deleted_at"},
+	{table: "users", column: "version", defs: versionDefs},
+}
+
+// legacyForeignKeys 借阅记录上的外键，删除时的引用完整性依赖它们
+var legacyForeignKeys = []struct {
+	name, column, ref string
+}{
+	{"fk_books_book_students", "book_id", "books"},
+	{"fk_students_book_student", "student_id", "students"},
+}
+
+// adopt 在执行 0001_init 之前补齐 AutoMigrate 时期建好的表：0001_init 使用 CREATE TABLE IF NOT EXISTS，
+// 不会修改已存在的表。缺少的列直接添加；缺少借阅记录的外键时 PostgreSQL / MySQL 添加外键，
+// SQLite 不支持给已有的表加外键，返回错误，需要运维先按 README 重建 book_students 表
+func adopt(tx *gorm.DB, dialect string) error {
+	mig := tx.Migrator()
+	log := logger.Ctx(tx.Statement.Context)
+	for _, c := range legacyColumns {
+		if !mig.HasTable(c.table) || mig.HasColumn(c.table, c.column) {
+			continue
+		}
+		err := tx.Exec("ALTER TABLE ? ADD COLUMN ? "+c.defs[dialect], clause.Table{Name: c.table}, clause.Column{Name: c.column}).Error
+		if err != nil {
+			return fmt.Errorf("adopt %s.%s: %w", c.table, c.column, err)
+		}
+		if c.index != "" && dialect == "mysql" && !mig.HasIndex(c.table, c.index) {
+			err := tx.Exec("CREATE INDEX ? ON ? (?)", clause.Column{Name: c.index}, clause.Table{Name: c.table}, clause.Column{Name: c.column}).Error
+			if err != nil {
+				return fmt.Errorf("adopt index %s: %w", c.index, err)
+			}
+		}
+		log.Info("added missing column to existing table", zap.String("table", c.table), zap.String("column", c.column))
+	}
+
+	if !mig.HasTable("book_students") {
+		return nil
+	}
+	for _, fk := range legacyForeignKeys {
+		if mig.HasConstraint("book_students", fk.name) {
+			continue
+		}
+		if dialect == "sqlite" {
+			return fmt.Errorf("existing table book_students has no foreign key %s and SQLite cannot add one to an existing table; "+
+				"rebuild book_students as described in the README and run migrate again", fk.name)
+		}
+		err := tx.Exec("ALTER TABLE book_students ADD CONSTRAINT ? FOREIGN KEY (?) REFERENCES ?(id) ON DELETE RESTRICT ON UPDATE CASCADE",
+			clause.Column{Name: fk.name}, clause.Column{Name: fk.column}, clause.Table{Name: fk.ref}).Error
+		if err != nil {
+			return fmt.Errorf("adopt foreign key %s: %w", fk.name, err)
+		}
+		log.Info("added missing foreign key to existing table", zap.String("table", "book_students"), zap.String("constraint", fk.name))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// baselineSchema 引入版本化迁移之前 AutoMigrate 建出的表（SQLite）
+const baselineSchema = "CREATE TABLE `books` (`id` integer PRIMARY KEY AUTOINCREMENT,`title` text,`author` text,`created_at` datetime,`updated_at` datetime,`stock` integer);" +
+	"CREATE TABLE `students` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text,`email` text,`created_at` datetime,`updated_at` datetime);" +
+	"CREATE TABLE `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text,`password` text,`sex` text,`born_date` datetime,`identify` text,`avatar_url` text);" +
+	"CREATE UNIQUE INDEX `idx_users_name` ON `users`(`name`);"
+
+const baselineLoans = "CREATE TABLE `book_students` (`id` integer PRIMARY KEY AUTOINCREMENT,`book_id` integer,`student_id` integer," +
+	"`borrowed_at` datetime,`returned_at` datetime,`status` text," +
+	"CONSTRAINT `fk_students_book_student` FOREIGN KEY (`student_id`) REFERENCES `students`(`id`)," +
+	"CONSTRAINT `fk_books_book_students` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`));"
+
+func openSQLite(t *testing.T, schema string) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "library.db") + "?_foreign_keys=1"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if schema == "" {
+		return db
+	}
+	if err := db.Exec(schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestAdoptBaselineDatabase(t *testing.T) {
+	db := openSQLite(t, baselineSchema+baselineLoans+
+		"INSERT INTO books (title, stock) VALUES ('Go', 2);"+
+		"INSERT INTO students (name) VALUES ('Alice');"+
+		"INSERT INTO book_students (book_id, student_id, status) VALUES (1, 1, 'returned');")
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range legacyColumns {
+		if !db.Migrator().HasColumn(c.table, c.column) {
+			t.Errorf("%s.%s was not added", c.table, c.column)
+		}
+	}
+	var book struct {
+		Version int
+		ISBN    *string
+	}
+	if err := db.Raw("SELECT version, isbn FROM books WHERE id = 1 AND deleted_at IS NULL").Scan(&book).Error; err != nil || book.Version != 1 || book.ISBN != nil {
+		t.Fatalf("book = %+v, %v", book, err)
+	}
+	if !db.Migrator().HasIndex("books", "idx_books_deleted_at") || !db.Migrator().HasTable("loan_archives") {
+		t.Fatal("0001_init did not run after adopting the tables")
+	}
+	// 外键仍然生效
+	if err := db.Exec("DELETE FROM books WHERE id = 1").Error; err == nil {
+		t.Fatal("deleted a book that still has loans")
+	}
+}
+
+func TestAdoptWithoutForeignKeys(t *testing.T) {
+	db := openSQLite(t, baselineSchema+"CREATE TABLE `book_students` (`id` integer PRIMARY KEY AUTOINCREMENT,`book_id` integer,`student_id` integer);")
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rebuild book_students") {
+		t.Fatalf("up = %v", err)
+	}
+	// 整个 0001_init 回滚，修好之后可以重新执行
+	if db.Migrator().HasColumn("books", "version") || db.Migrator().HasTable("loan_archives") {
+		t.Fatal("failed adoption left partial changes")
+	}
+	var n int64
+	db.Table("schema_migrations").Count(&n)
+	if n != 0 {
+		t.Fatalf("schema_migrations has %d rows", n)
+	}
+}
@@ -0,0 +1,167 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey PostgreSQL advisory lock 的 key，所有实例必须一致
+const lockKey int64 = 7_209_113_450_032
+
+// lockName MySQL GET_LOCK 的锁名，所有实例必须一致
+const lockName = "trae-go:schema_migrations"
+
+// staleLockAfter 表锁超过这个时间仍未释放，视为持有者已崩溃
+const staleLockAfter = 15 * time.Minute
+
+// lockRetryInterval 等待锁时的重试间隔
+const lockRetryInterval = 500 * time.Millisecond
+
+// locker 迁移锁，保证同一时刻只有一个实例在执行迁移
+type locker interface {
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+}
+
+func newLocker(db *gorm.DB, dialect string) (locker, error) {
+	switch dialect {
+	case "postgres":
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		return &pgLocker{db: sqlDB}, nil
+	case "mysql":
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		return &mysqlLocker{db: sqlDB}, nil
+	case "sqlite":
+		host, _ := os.Hostname()
+		return &tableLocker{db: db, owner: host + ":" + strconv.Itoa(os.Getpid())}, nil
+	}
+	return nil, fmt.Errorf("migration lock not supported for dialect %q", dialect)
+}
+
+// pgLocker 使用会话级 advisory lock。锁属于连接，所以加锁和解锁必须在同一个连接上
+type pgLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func (l *pgLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		conn.Close()
+		return err
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *pgLocker) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	return err
+}
+
+// mysqlLocker 使用 GET_LOCK 命名锁，和 advisory lock 一样属于连接。
+// 每次最多等待几秒后重试，以便 ctx 取消时及时返回
+type mysqlLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func (l *mysqlLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		// 拿到锁返回 1，超时返回 0，出错返回 NULL
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, 5).Scan(&got); err != nil {
+			conn.Close()
+			return err
+		}
+		if got.Valid && got.Int64 == 1 {
+			l.conn = conn
+			return nil
+		}
+		if !got.Valid {
+			conn.Close()
+			return fmt.Errorf("GET_LOCK(%q) failed", lockName)
+		}
+		if err := ctx.Err(); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+}
+
+func (l *mysqlLocker) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+	_, err := l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+	return err
+}
+
+// tableLocker 用一张只允许一行的表做锁，适用于没有 advisory lock 的 SQLite
+type tableLocker struct {
+	db    *gorm.DB
+	owner string
+}
+
+func (l *tableLocker) Lock(ctx context.Context) error {
+	db := l.db.WithContext(ctx)
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id INTEGER PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL,
+    locked_at TIMESTAMP NOT NULL
+)`).Error; err != nil {
+		return err
+	}
+
+	for {
+		err := db.Exec("INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)",
+			l.owner, time.Now().UTC()).Error
+		if err == nil {
+			return nil
+		}
+		// 插入失败说明锁被别人持有，持有太久的锁直接清掉
+		if err := db.Exec("DELETE FROM schema_migrations_lock WHERE id = 1 AND locked_at < ?",
+			time.Now().UTC().Add(-staleLockAfter)).Error; err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func (l *tableLocker) Unlock(ctx context.Context) error {
+	return l.db.WithContext(ctx).Exec("DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?", l.owner).Error
+}
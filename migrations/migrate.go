@@ -0,0 +1,332 @@
+// Package migrations 版本化的数据库迁移。
+//
+// 每个数据库方言一个目录（sqlite/、postgres/、mysql/），文件名形如 0002_add_xxx.up.sql / 0002_add_xxx.down.sql，
+// 前面的数字是版本号。已执行的版本记录在 schema_migrations 表中；执行期间持有迁移锁，
+// 多个实例同时启动时只有一个会真正执行迁移。
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"trae-go/pkg/logger"
+	"trae-go/pkg/replica"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql mysql/*.sql
+var files embed.FS
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 某个版本的执行情况
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Missing   bool       `json:"missing,omitempty"` // 数据库里记录已执行，但当前代码中没有这个脚本
+}
+
+// schemaMigration schema_migrations 表中的一行
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`
+
+// Migrator 在一个数据库上执行迁移
+type Migrator struct {
+	db         *gorm.DB
+	dialect    string
+	migrations []Migration
+}
+
+// New 按 db 的方言加载对应目录下的迁移脚本
+func New(db *gorm.DB) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+	migrations, err := load(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+	// 配置了只读副本时也从主库读取迁移记录，避免副本延迟导致重复执行
+	return &Migrator{db: replica.Primary(db), dialect: dialect, migrations: migrations}, nil
+}
+
+// load 读取 dir 下的 *.up.sql / *.down.sql，按版本号排序
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dir, err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q, want <version>_<name>.%s.sql", name, direction)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, parts[1])
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up 按版本顺序执行所有未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, mig, true); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+			}
+			if err := m.run(ctx, mig, false); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 列出所有迁移及执行情况。只读：不建 schema_migrations 表，表不存在时视为一个迁移都没有执行，
+// 就绪检查等只有查询权限的账号也可以调用
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied := map[int64]schemaMigration{}
+	if m.db.WithContext(ctx).Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		if applied, err = m.applied(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]Status, 0, len(m.migrations))
+	known := map[int64]struct{}{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = struct{}{}
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			at := row.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		list = append(list, s)
+	}
+	for v, row := range applied {
+		if _, ok := known[v]; ok {
+			continue
+		}
+		at := row.AppliedAt
+		list = append(list, Status{Version: v, Name: row.Name, Applied: true, AppliedAt: &at, Missing: true})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Pending 返回还未执行的迁移
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if s.Applied {
+			continue
+		}
+		for _, mig := range m.migrations {
+			if mig.Version == s.Version {
+				pending = append(pending, mig)
+			}
+		}
+	}
+	return pending, nil
+}
+
+// run 在一个事务里执行脚本并更新 schema_migrations
+func (m *Migrator) run(ctx context.Context, mig Migration, up bool) error {
+	direction, script := "up", mig.Up
+	if !up {
+		direction, script = "down", mig.Down
+	}
+	start := time.Now()
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if up && mig.Version == initVersion {
+			if err := adopt(tx, m.dialect); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range statements(m.dialect, script) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now().UTC()}).Error
+		}
+		return tx.Delete(&schemaMigration{}, mig.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", mig.Version, mig.Name, direction, err)
+	}
+	logger.Ctx(ctx).Info("migration applied",
+		zap.Int64("version", mig.Version),
+		zap.String("name", mig.Name),
+		zap.String("direction", direction),
+		zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// statements MySQL 驱动默认不允许一次执行多条语句，按行尾的分号拆开；其他方言整段执行
+func statements(dialect, script string) []string {
+	if dialect != "mysql" {
+		return []string{script}
+	}
+	var stmts []string
+	for _, chunk := range strings.SplitAfter(script, ";\n") {
+		if !hasSQL(chunk) {
+			continue
+		}
+		stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(chunk), ";"))
+	}
+	return stmts
+}
+
+// hasSQL chunk 中除空行和 -- 注释外还有内容
+func hasSQL(chunk string) bool {
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	return m.db.WithContext(ctx).Exec(createSchemaMigrations).Error
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := m.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// withLock 持有迁移锁执行 fn
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	l, err := newLocker(m.db, m.dialect)
+	if err != nil {
+		return err
+	}
+	if err := l.Lock(ctx); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if err := l.Unlock(context.Background()); err != nil {
+			logger.Ctx(ctx).Error("release migration lock failed", zap.Error(err))
+		}
+	}()
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	return fn()
+}
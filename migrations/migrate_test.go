@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStatusIsReadOnly(t *testing.T) {
+	db := openSQLite(t, "")
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	statuses, err := m.Status(ctx)
+	if err != nil || len(statuses) != len(m.migrations) {
+		t.Fatalf("status = %+v, %v", statuses, err)
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Fatalf("%d_%s reported as applied", s.Version, s.Name)
+		}
+	}
+	if db.Migrator().HasTable("schema_migrations") {
+		t.Fatal("Status created schema_migrations")
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending = %+v, %v", pending, err)
+	}
+}
@@ -0,0 +1,83 @@
+-- 初始表结构，与 sqlite/、postgres/ 下的 0001_init 对应。
+-- MySQL 不能直接索引 longtext，需要索引的列使用 varchar；users.name 使用 utf8mb4_bin，
+-- 用户名与其他数据库一样区分大小写。MySQL 的 DDL 会隐式提交，脚本中途失败时已执行的语句不会回滚。
+-- 已存在的表不会被修改，缺少的列、索引和外键由 adopt.go 在执行前补上。
+CREATE TABLE IF NOT EXISTS `books` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `title` longtext,
+    `author` longtext,
+    `isbn` varchar(17),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `stock` bigint,
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    PRIMARY KEY (`id`),
+    INDEX `idx_books_deleted_at` (`deleted_at`),
+    INDEX `idx_books_isbn` (`isbn`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `book_copies` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `book_id` bigint unsigned NOT NULL,
+    `status` varchar(20),
+    PRIMARY KEY (`id`),
+    INDEX `idx_book_copies_book_id` (`book_id`),
+    CONSTRAINT `fk_books_copies` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `students` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `name` longtext,
+    `email` longtext,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    PRIMARY KEY (`id`),
+    INDEX `idx_students_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `book_students` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `book_id` bigint unsigned NOT NULL,
+    `student_id` bigint unsigned NOT NULL,
+    `borrowed_at` datetime(3) NULL,
+    `returned_at` datetime(3) NULL,
+    `status` varchar(20),
+    PRIMARY KEY (`id`),
+    INDEX `idx_book_students_book_id` (`book_id`),
+    INDEX `idx_book_students_student_id` (`student_id`),
+    CONSTRAINT `fk_books_book_students` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT `fk_students_book_student` FOREIGN KEY (`student_id`) REFERENCES `students`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `loan_archives` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `loan_id` bigint unsigned,
+    `book_id` bigint unsigned,
+    `student_id` bigint unsigned,
+    `borrowed_at` datetime(3) NULL,
+    `returned_at` datetime(3) NULL,
+    `status` varchar(20),
+    `archived_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_loan_archives_loan_id` (`loan_id`),
+    INDEX `idx_loan_archives_book_id` (`book_id`),
+    INDEX `idx_loan_archives_student_id` (`student_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `name` varchar(191) COLLATE utf8mb4_bin,
+    `password` longtext,
+    `sex` longtext,
+    `born_date` datetime(3) NULL,
+    `identify` longtext,
+    `avatar_url` longtext,
+    `deleted_at` datetime(3) NULL,
+    `version` bigint unsigned NOT NULL DEFAULT 1,
+    PRIMARY KEY (`id`),
+    INDEX `idx_users_deleted_at` (`deleted_at`),
+    UNIQUE INDEX `idx_users_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
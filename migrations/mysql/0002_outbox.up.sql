@@ -0,0 +1,20 @@
+-- 领域事件 outbox 和各消费者的投递进度
+CREATE TABLE IF NOT EXISTS `outbox_events` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `type` varchar(64) NOT NULL,
+    `aggregate_type` varchar(32) NOT NULL,
+    `aggregate_id` bigint unsigned NOT NULL,
+    `payload` longtext NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_outbox_events_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `outbox_offsets` (
+    `consumer` varchar(100) NOT NULL,
+    `last_event_id` bigint unsigned NOT NULL DEFAULT 0,
+    `owner` varchar(255) NOT NULL DEFAULT '',
+    `lease_until` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`consumer`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
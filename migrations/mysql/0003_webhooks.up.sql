@@ -0,0 +1,47 @@
+-- webhook 订阅、投递和投递日志
+CREATE TABLE IF NOT EXISTS `webhooks` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `url` varchar(2048) NOT NULL,
+    `event_types` text NOT NULL,
+    `secret` varchar(255) NOT NULL,
+    `description` varchar(255) NOT NULL DEFAULT '',
+    `active` tinyint(1) NOT NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_webhooks_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `webhook_id` bigint unsigned NOT NULL,
+    `event_id` bigint unsigned NOT NULL,
+    `event_type` varchar(64) NOT NULL,
+    `payload` longtext NOT NULL,
+    `status` varchar(16) NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime(3) NULL,
+    `last_status_code` bigint NOT NULL DEFAULT 0,
+    `last_error` varchar(1024) NOT NULL DEFAULT '',
+    `delivered_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_webhook_deliveries_event` (`webhook_id`, `event_id`),
+    INDEX `idx_webhook_deliveries_due` (`status`, `next_attempt_at`),
+    INDEX `idx_webhook_deliveries_updated_at` (`updated_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `webhook_attempts` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `delivery_id` bigint unsigned NOT NULL,
+    `attempt` bigint NOT NULL,
+    `status_code` bigint NOT NULL DEFAULT 0,
+    `error` varchar(1024) NOT NULL DEFAULT '',
+    `response` varchar(1024) NOT NULL DEFAULT '',
+    `duration_ms` bigint NOT NULL DEFAULT 0,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_webhook_attempts_delivery_id` (`delivery_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,35 @@
+-- 通知偏好和通知邮件
+CREATE TABLE IF NOT EXISTS `notification_preferences` (
+    `student_id` bigint unsigned NOT NULL,
+    `locale` varchar(16) NOT NULL DEFAULT '',
+    `due_soon` tinyint(1) NOT NULL,
+    `overdue` tinyint(1) NOT NULL,
+    `hold_ready` tinyint(1) NOT NULL,
+    `account` tinyint(1) NOT NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`student_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `notifications` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `student_id` bigint unsigned NOT NULL,
+    `kind` varchar(32) NOT NULL,
+    `locale` varchar(16) NOT NULL,
+    `recipient` varchar(255) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `text_body` longtext NOT NULL,
+    `html_body` longtext NOT NULL,
+    `dedup_key` varchar(191) NOT NULL,
+    `status` varchar(16) NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime(3) NULL,
+    `last_error` varchar(1024) NOT NULL DEFAULT '',
+    `sent_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_notifications_student_id` (`student_id`),
+    UNIQUE INDEX `idx_notifications_dedup_key` (`dedup_key`),
+    INDEX `idx_notifications_due` (`status`, `next_attempt_at`),
+    INDEX `idx_notifications_updated_at` (`updated_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
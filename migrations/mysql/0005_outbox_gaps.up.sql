@@ -0,0 +1,8 @@
+-- 消费者越过的、当时还没有事件的 ID 区间，事务提交后补投递
+CREATE TABLE IF NOT EXISTS `outbox_gaps` (
+    `consumer` varchar(100) NOT NULL,
+    `first_id` bigint unsigned NOT NULL,
+    `last_id` bigint unsigned NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`consumer`, `first_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
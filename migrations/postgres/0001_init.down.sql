@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "loan_archives";
+DROP TABLE IF EXISTS "book_students";
+DROP TABLE IF EXISTS "students";
+DROP TABLE IF EXISTS "book_copies";
+DROP TABLE IF EXISTS "books";
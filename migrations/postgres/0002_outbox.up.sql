@@ -0,0 +1,18 @@
+-- 领域事件 outbox 和各消费者的投递进度
+CREATE TABLE IF NOT EXISTS "outbox_events" (
+    "id" bigserial PRIMARY KEY,
+    "type" varchar(64) NOT NULL,
+    "aggregate_type" varchar(32) NOT NULL,
+    "aggregate_id" bigint NOT NULL,
+    "payload" text NOT NULL,
+    "created_at" timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_created_at" ON "outbox_events"("created_at");
+
+CREATE TABLE IF NOT EXISTS "outbox_offsets" (
+    "consumer" varchar(100) PRIMARY KEY,
+    "last_event_id" bigint NOT NULL DEFAULT 0,
+    "owner" varchar(255) NOT NULL DEFAULT '',
+    "lease_until" timestamptz,
+    "updated_at" timestamptz
+);
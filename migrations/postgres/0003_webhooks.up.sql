@@ -0,0 +1,44 @@
+-- webhook 订阅、投递和投递日志
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" bigserial PRIMARY KEY,
+    "url" varchar(2048) NOT NULL,
+    "event_types" text NOT NULL,
+    "secret" varchar(255) NOT NULL,
+    "description" varchar(255) NOT NULL DEFAULT '',
+    "active" boolean NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_deleted_at" ON "webhooks"("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" bigserial PRIMARY KEY,
+    "webhook_id" bigint NOT NULL,
+    "event_id" bigint NOT NULL,
+    "event_type" varchar(64) NOT NULL,
+    "payload" text NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz,
+    "last_status_code" bigint NOT NULL DEFAULT 0,
+    "last_error" varchar(1024) NOT NULL DEFAULT '',
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_webhook_deliveries_event" ON "webhook_deliveries"("webhook_id", "event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_due" ON "webhook_deliveries"("status", "next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_updated_at" ON "webhook_deliveries"("updated_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_attempts" (
+    "id" bigserial PRIMARY KEY,
+    "delivery_id" bigint NOT NULL,
+    "attempt" bigint NOT NULL,
+    "status_code" bigint NOT NULL DEFAULT 0,
+    "error" varchar(1024) NOT NULL DEFAULT '',
+    "response" varchar(1024) NOT NULL DEFAULT '',
+    "duration_ms" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_attempts_delivery_id" ON "webhook_attempts"("delivery_id");
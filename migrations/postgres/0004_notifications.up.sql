@@ -0,0 +1,33 @@
+-- 通知偏好和通知邮件
+CREATE TABLE IF NOT EXISTS "notification_preferences" (
+    "student_id" bigint PRIMARY KEY,
+    "locale" varchar(16) NOT NULL DEFAULT '',
+    "due_soon" boolean NOT NULL,
+    "overdue" boolean NOT NULL,
+    "hold_ready" boolean NOT NULL,
+    "account" boolean NOT NULL,
+    "updated_at" timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS "notifications" (
+    "id" bigserial PRIMARY KEY,
+    "student_id" bigint NOT NULL,
+    "kind" varchar(32) NOT NULL,
+    "locale" varchar(16) NOT NULL,
+    "recipient" varchar(255) NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "text_body" text NOT NULL,
+    "html_body" text NOT NULL,
+    "dedup_key" varchar(191) NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz,
+    "last_error" varchar(1024) NOT NULL DEFAULT '',
+    "sent_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz
+);
+CREATE INDEX IF NOT EXISTS "idx_notifications_student_id" ON "notifications"("student_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notifications_dedup_key" ON "notifications"("dedup_key");
+CREATE INDEX IF NOT EXISTS "idx_notifications_due" ON "notifications"("status", "next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_notifications_updated_at" ON "notifications"("updated_at");
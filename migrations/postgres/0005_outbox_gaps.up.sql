@@ -0,0 +1,8 @@
+-- 消费者越过的、当时还没有事件的 ID 区间，事务提交后补投递
+CREATE TABLE IF NOT EXISTS "outbox_gaps" (
+    "consumer" varchar(100) NOT NULL,
+    "first_id" bigint NOT NULL,
+    "last_id" bigint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("consumer", "first_id")
+);
@@ -0,0 +1,75 @@
+-- 初始表结构，与之前 AutoMigrate 生成的结构一致。
+-- 使用 IF NOT EXISTS，已经由 AutoMigrate 建好表的数据库可以直接接入，缺少的列由 adopt.go 在执行前补上。
+CREATE TABLE IF NOT EXISTS `books` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `title` text,
+    `author` text,
+    `isbn` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `stock` integer,
+    `version` integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS `idx_books_deleted_at` ON `books`(`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_books_isbn` ON `books`(`isbn`);
+
+CREATE TABLE IF NOT EXISTS `book_copies` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `book_id` integer NOT NULL,
+    `status` text,
+    CONSTRAINT `fk_books_copies` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_book_copies_book_id` ON `book_copies`(`book_id`);
+
+CREATE TABLE IF NOT EXISTS `students` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text,
+    `email` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `version` integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS `idx_students_deleted_at` ON `students`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `book_students` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `book_id` integer NOT NULL,
+    `student_id` integer NOT NULL,
+    `borrowed_at` datetime,
+    `returned_at` datetime,
+    `status` text,
+    CONSTRAINT `fk_books_book_students` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT `fk_students_book_student` FOREIGN KEY (`student_id`) REFERENCES `students`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS `idx_book_students_book_id` ON `book_students`(`book_id`);
+CREATE INDEX IF NOT EXISTS `idx_book_students_student_id` ON `book_students`(`student_id`);
+
+CREATE TABLE IF NOT EXISTS `loan_archives` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `loan_id` integer,
+    `book_id` integer,
+    `student_id` integer,
+    `borrowed_at` datetime,
+    `returned_at` datetime,
+    `status` text,
+    `archived_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_loan_archives_loan_id` ON `loan_archives`(`loan_id`);
+CREATE INDEX IF NOT EXISTS `idx_loan_archives_book_id` ON `loan_archives`(`book_id`);
+CREATE INDEX IF NOT EXISTS `idx_loan_archives_student_id` ON `loan_archives`(`student_id`);
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text,
+    `password` text,
+    `sex` text,
+    `born_date` datetime,
+    `identify` text,
+    `avatar_url` text,
+    `deleted_at` datetime,
+    `version` integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_name` ON `users`(`name`);
@@ -0,0 +1,18 @@
+-- 领域事件 outbox 和各消费者的投递进度
+CREATE TABLE IF NOT EXISTS `outbox_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `type` text NOT NULL,
+    `aggregate_type` text NOT NULL,
+    `aggregate_id` integer NOT NULL,
+    `payload` text NOT NULL,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_outbox_events_created_at` ON `outbox_events`(`created_at`);
+
+CREATE TABLE IF NOT EXISTS `outbox_offsets` (
+    `consumer` text PRIMARY KEY,
+    `last_event_id` integer NOT NULL DEFAULT 0,
+    `owner` text NOT NULL DEFAULT '',
+    `lease_until` datetime,
+    `updated_at` datetime
+);
@@ -0,0 +1,44 @@
+-- webhook 订阅、投递和投递日志
+CREATE TABLE IF NOT EXISTS `webhooks` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `url` text NOT NULL,
+    `event_types` text NOT NULL,
+    `secret` text NOT NULL,
+    `description` text NOT NULL DEFAULT '',
+    `active` numeric NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_webhooks_deleted_at` ON `webhooks`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `webhook_id` integer NOT NULL,
+    `event_id` integer NOT NULL,
+    `event_type` text NOT NULL,
+    `payload` text NOT NULL,
+    `status` text NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime,
+    `last_status_code` integer NOT NULL DEFAULT 0,
+    `last_error` text NOT NULL DEFAULT '',
+    `delivered_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_webhook_deliveries_event` ON `webhook_deliveries`(`webhook_id`, `event_id`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_due` ON `webhook_deliveries`(`status`, `next_attempt_at`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_updated_at` ON `webhook_deliveries`(`updated_at`);
+
+CREATE TABLE IF NOT EXISTS `webhook_attempts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `delivery_id` integer NOT NULL,
+    `attempt` integer NOT NULL,
+    `status_code` integer NOT NULL DEFAULT 0,
+    `error` text NOT NULL DEFAULT '',
+    `response` text NOT NULL DEFAULT '',
+    `duration_ms` integer NOT NULL DEFAULT 0,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_webhook_attempts_delivery_id` ON `webhook_attempts`(`delivery_id`);
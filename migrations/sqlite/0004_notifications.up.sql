@@ -0,0 +1,33 @@
+-- 通知偏好和通知邮件
+CREATE TABLE IF NOT EXISTS `notification_preferences` (
+    `student_id` integer PRIMARY KEY,
+    `locale` text NOT NULL DEFAULT '',
+    `due_soon` numeric NOT NULL,
+    `overdue` numeric NOT NULL,
+    `hold_ready` numeric NOT NULL,
+    `account` numeric NOT NULL,
+    `updated_at` datetime
+);
+
+CREATE TABLE IF NOT EXISTS `notifications` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `student_id` integer NOT NULL,
+    `kind` text NOT NULL,
+    `locale` text NOT NULL,
+    `recipient` text NOT NULL,
+    `subject` text NOT NULL,
+    `text_body` text NOT NULL,
+    `html_body` text NOT NULL,
+    `dedup_key` text NOT NULL,
+    `status` text NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime,
+    `last_error` text NOT NULL DEFAULT '',
+    `sent_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_notifications_student_id` ON `notifications`(`student_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_notifications_dedup_key` ON `notifications`(`dedup_key`);
+CREATE INDEX IF NOT EXISTS `idx_notifications_due` ON `notifications`(`status`, `next_attempt_at`);
+CREATE INDEX IF NOT EXISTS `idx_notifications_updated_at` ON `notifications`(`updated_at`);
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type BookStatus string
 
@@ -11,19 +15,23 @@ const (
 )
 
 type Book struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Title     string    `json:"title"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Stock     uint      `json:"stock"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Title     string         `json:"title"`
+	Author    string         `json:"author"`
+	ISBN      string         `gorm:"size:17;index" json:"isbn"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string"`
+	Stock     uint           `json:"stock"`
+	Version   uint           `gorm:"not null;default:1" json:"version"` // 乐观锁版本号，每次更新加一
 
-	Book_Students []Book_Student `json:"book_students"`
-	Copies        []BookCopy     `json:"copies"`
+	// 有借阅记录的书不能被物理删除；删除书时副本随之删除
+	Book_Students []Book_Student `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"book_students"`
+	Copies        []BookCopy     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"copies"`
 }
 
 type BookCopy struct {
 	ID     uint       `gorm:"primaryKey" json:"id"`
-	BookID uint       `json:"book_id"`
+	BookID uint       `gorm:"index;not null" json:"book_id"`
 	Status BookStatus `json:"status"`
 }
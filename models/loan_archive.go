@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoanArchive 归档后的借阅记录。已结束的借阅从 book_students 移到这里，
+// 不再受外键约束，图书 / 学生被物理删除后仍可追溯。
+type LoanArchive struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	LoanID     uint         `gorm:"index" json:"loan_id"` // 原 Book_Student.ID
+	BookID     uint         `gorm:"index" json:"book_id"`
+	StudentID  uint         `gorm:"index" json:"student_id"`
+	BorrowedAt time.Time    `json:"borrowed_time"`
+	ReturnedAt time.Time    `json:"return_time"`
+	Status     BorrowStatus `json:"status"`
+	ArchivedAt time.Time    `json:"archived_at"`
+}
+
+// ArchiveClosedLoans 把 column（book_id 或 student_id）属于 ids 的已结束借阅记录
+// 复制到 loan_archives 并从 book_students 删除，需在事务中调用
+func ArchiveClosedLoans(tx *gorm.DB, column string, ids []uint) error {
+	var loans []Book_Student
+	if err := tx.Where(column+" IN ? AND status <> ?", ids, BorrowStatusBorrowed).Find(&loans).Error; err != nil {
+		return err
+	}
+	if len(loans) == 0 {
+		return nil
+	}
+	now := time.Now()
+	archives := make([]LoanArchive, 0, len(loans))
+	loanIDs := make([]uint, 0, len(loans))
+	for _, l := range loans {
+		archives = append(archives, LoanArchive{
+			LoanID:     l.ID,
+			BookID:     l.BookID,
+			StudentID:  l.StudentID,
+			BorrowedAt: l.BorrowedAt,
+			ReturnedAt: l.ReturnedAt,
+			Status:     l.Status,
+			ArchivedAt: now,
+		})
+		loanIDs = append(loanIDs, l.ID)
+	}
+	if err := tx.Create(&archives).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&Book_Student{}, loanIDs).Error
+}
+
+// DeleteClosedLoans 直接删除 column 属于 ids 的已结束借阅记录
+func DeleteClosedLoans(tx *gorm.DB, column string, ids []uint) error {
+	return tx.Where(column+" IN ? AND status <> ?", ids, BorrowStatusBorrowed).Delete(&Book_Student{}).Error
+}
@@ -12,8 +12,8 @@ const (
 
 type Book_Student struct {
 	ID         uint         `gorm:"primaryKey" json:"id"`
-	BookID     uint         `json:"book_id"`
-	StudentID  uint         `json:"student_id"`
+	BookID     uint         `gorm:"index;not null" json:"book_id"`
+	StudentID  uint         `gorm:"index;not null" json:"student_id"`
 	BorrowedAt time.Time    `json:"borrowed_time"`
 	ReturnedAt time.Time    `json:"return_time"`
 	Status     BorrowStatus `json:"status"`
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// 通知类型
+const (
+	NotificationDueSoon        = "due_soon"        // 借阅即将到期
+	NotificationOverdue        = "overdue"         // 借阅已逾期
+	NotificationHoldReady      = "hold_ready"      // 预约的图书可以取了
+	NotificationAccountCreated = "account_created" // 学生账户已创建
+)
+
+// 通知状态
+const (
+	NotificationPending = "pending" // 等待发送或重试
+	NotificationSent    = "sent"    // 已交给邮件服务器（或写入 maildir）
+	NotificationFailed  = "failed"  // 重试次数用完，可手动重新发送
+)
+
+// NotificationPreference 学生的通知偏好，没有记录时使用默认值（全部开启、notify.default_locale）
+type NotificationPreference struct {
+	StudentID uint      `gorm:"primaryKey;autoIncrement:false" json:"student_id"`
+	Locale    string    `gorm:"size:16;not null;default:''" json:"locale"` // zh-CN 或 en，为空时使用 notify.default_locale
+	DueSoon   bool      `gorm:"not null" json:"due_soon"`
+	Overdue   bool      `gorm:"not null" json:"overdue"`
+	HoldReady bool      `gorm:"not null" json:"hold_ready"`
+	Account   bool      `gorm:"not null" json:"account"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification 一封通知邮件。入队时按学生的语言渲染好主题和正文，重试和重新发送都使用相同内容；
+// DedupKey 唯一，同一件事（如某笔借阅的第 n 次逾期通知）只会入队一次
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	StudentID     uint       `gorm:"not null;index" json:"student_id"`
+	Kind          string     `gorm:"size:32;not null" json:"kind"`
+	Locale        string     `gorm:"size:16;not null" json:"locale"`
+	Recipient     string     `gorm:"size:255;not null" json:"recipient"`
+	Subject       string     `gorm:"size:255;not null" json:"subject"`
+	TextBody      string     `gorm:"not null" json:"text_body"`
+	HTMLBody      string     `gorm:"not null" json:"html_body"`
+	DedupKey      string     `gorm:"size:191;not null;uniqueIndex" json:"dedup_key"`
+	Status        string     `gorm:"size:16;not null;index:idx_notifications_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index:idx_notifications_due,priority:2" json:"next_attempt_at"` // 仅 pending 时有值
+	LastError     string     `gorm:"size:1024;not null;default:''" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"index" json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// OutboxEvent 领域事件。与产生它的数据修改在同一事务中写入 outbox_events，
+// 事务回滚时事件也不存在；由 events.Relay 读取并投递到各个 sink
+type OutboxEvent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Type          string    `gorm:"size:64;not null" json:"type"`           // 如 book.borrowed
+	AggregateType string    `gorm:"size:32;not null" json:"aggregate_type"` // book、student 或 user
+	AggregateID   uint      `gorm:"not null" json:"aggregate_id"`
+	Payload       string    `gorm:"not null" json:"payload"` // JSON
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// OutboxOffset 一个消费者（sink）的投递进度。LastEventID 之前的事件都已投递；
+// Owner / LeaseUntil 为租约，多个实例中同一时间只有持有租约的实例投递该消费者
+type OutboxOffset struct {
+	Consumer    string    `gorm:"primaryKey;size:100" json:"consumer"`
+	LastEventID uint      `gorm:"not null;default:0" json:"last_event_id"`
+	Owner       string    `gorm:"size:255;not null;default:''" json:"owner"`
+	LeaseUntil  time.Time `json:"lease_until"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OutboxGap 消费者越过时还没有事件的 ID 区间 [FirstID, LastID]。这些 ID 可能属于尚未提交的事务，
+// events.Relay 会反复查询，事务提交后补投递；超过 events.gap_retention 仍没有事件的区间视为已回滚并删除
+type OutboxGap struct {
+	Consumer  string    `gorm:"primaryKey;size:100" json:"consumer"`
+	FirstID   uint      `gorm:"primaryKey;autoIncrement:false" json:"first_id"`
+	LastID    uint      `gorm:"not null" json:"last_id"`
+	CreatedAt time.Time `json:"created_at"` // 发现缺口的时间
+}
@@ -1,13 +1,19 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Student struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string"`
+	Version   uint           `gorm:"not null;default:1" json:"version"` // 乐观锁版本号，每次更新加一
 
-	Book_Student []Book_Student `json:"book_student"`
+	Book_Student []Book_Student `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"book_student"`
 }
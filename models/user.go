@@ -1,13 +1,25 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 用户身份（Identify 字段）
+const (
+	UserRoleAdmin   = "admin"
+	UserRoleStudent = "student"
+)
 
 type User struct {
-	ID        int       `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"uniqueIndex" json:"user_name"`
-	Password  string    `json:"password"`
-	Sex       string    `json:"sex"`
-	BornDate  time.Time `json:"born_date"`
-	Identify  string    `json:"ide"`
-	AvatarURL string    `json:"avatar_url"`
+	ID        int            `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"uniqueIndex" json:"user_name"`
+	Password  string         `json:"password"`
+	Sex       string         `json:"sex"`
+	BornDate  time.Time      `json:"born_date"`
+	Identify  string         `json:"ide"`
+	AvatarURL string         `json:"avatar_url"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string"`
+	Version   uint           `gorm:"not null;default:1" json:"version"` // 乐观锁版本号，每次更新加一
 }
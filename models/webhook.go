@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook 一个 webhook 订阅：EventTypes 中的领域事件发生后 POST 到 URL，请求用 Secret 做 HMAC-SHA256 签名
+type Webhook struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	URL         string         `gorm:"size:2048;not null" json:"url"`
+	EventTypes  []string       `gorm:"serializer:json;not null" json:"event_types"` // 订阅的事件类型，"*" 表示全部
+	Secret      string         `gorm:"size:255;not null" json:"-"`
+	Description string         `gorm:"size:255;not null;default:''" json:"description"`
+	Active      bool           `gorm:"not null" json:"active"` // 停用后不再产生新的投递
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"   // 等待（重试）发送
+	DeliverySucceeded = "succeeded" // 已送达（2xx）
+	DeliveryDead      = "dead"      // 重试次数用完或订阅已删除 / 停用，进入死信列表，可手动重新投递
+)
+
+// WebhookDelivery 一个事件到一个订阅的投递。同一事件对同一订阅只有一条记录（webhook_id + event_id 唯一），
+// 手动重新投递时复用该记录、重新开始计算尝试次数
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event" json:"webhook_id"`
+	EventID        uint       `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event" json:"event_id"`
+	EventType      string     `gorm:"size:64;not null" json:"event_type"`
+	Payload        string     `gorm:"not null" json:"payload"` // 请求体（JSON），每次尝试都发送相同内容
+	Status         string     `gorm:"size:16;not null;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`                                 // 本轮已尝试次数
+	NextAttemptAt  *time.Time `gorm:"index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"` // 仅 pending 时有值
+	LastStatusCode int        `gorm:"not null;default:0" json:"last_status_code"`                         // 0 表示没有收到响应
+	LastError      string     `gorm:"size:1024;not null;default:''" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"index" json:"updated_at"`
+}
+
+// WebhookAttempt 投递日志：一次 HTTP 请求的结果
+type WebhookAttempt struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	DeliveryID uint      `gorm:"not null;index" json:"delivery_id"`
+	Attempt    int       `gorm:"not null" json:"attempt"`                       // 本轮第几次尝试
+	StatusCode int       `gorm:"not null;default:0" json:"status_code"`         // 0 表示没有收到响应
+	Error      string    `gorm:"size:1024;not null;default:''" json:"error"`    // 网络错误或非 2xx 的说明
+	Response   string    `gorm:"size:1024;not null;default:''" json:"response"` // 响应体的开头部分
+	DurationMS int64     `gorm:"not null;default:0" json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"trae-go/events"
+	"trae-go/models"
+)
+
+// ConsumerName 账户消息在 events.Relay 中的消费者名，配置的 events.sinks 不能使用
+const ConsumerName = "notifications"
+
+// maxEventAge 超过这个时间的事件不再发送账户消息。
+// 消费者第一次启用时从 outbox 中最早的事件开始投递，避免给很久以前创建的学生补发欢迎邮件
+const maxEventAge = 24 * time.Hour
+
+// Consumer 作为 events.Relay 的消费者：student.created 时入队账户消息
+func (n *Notifier) Consumer() events.Consumer {
+	return events.Consumer{Name: ConsumerName, Types: []string{events.StudentCreated}, Sink: accountSink{n}}
+}
+
+type accountSink struct {
+	n *Notifier
+}
+
+func (s accountSink) Publish(ctx context.Context, e events.Event) error {
+	if e.Type != events.StudentCreated || s.n.now().Sub(e.OccurredAt) > maxEventAge {
+		return nil
+	}
+	var student events.Student
+	if err := json.Unmarshal(e.Data, &student); err != nil {
+		return fmt.Errorf("decode %s event %d: %w", e.Type, e.ID, err)
+	}
+	key := fmt.Sprintf("account_created:%d", student.ID)
+	if _, err := s.n.enqueue(ctx, student.ID, models.NotificationAccountCreated, key, Data{}); err != nil && !skipped(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+// Package notify 学生的邮件通知：借阅到期提醒、逾期通知、预约到馆和账户消息。
+//
+// 通知先入队（notifications 表），入队时按学生的语言渲染好主题和正文，再由 Worker 通过 mail.Transport 发送，
+// 失败按指数退避重试，次数用完标记为 failed，可以通过接口手动重新发送；已发送的通知保留为发送历史。
+// Scheduler 定期扫描未归还的借阅，入队到期提醒和逾期通知；账户消息由 events.Relay 的内置消费者
+// （名为 notifications）在 student.created 事件到达时入队。每条通知有唯一的去重键，重复扫描或事件重复投递不会重复发送。
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"trae-go/config"
+	"trae-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+var (
+	ErrNotFound        = errors.New("notification not found")
+	ErrStudentNotFound = errors.New("student not found")
+	ErrBookNotFound    = errors.New("book not found")
+	ErrInvalidLocale   = errors.New("locale must be zh-CN or en")
+	ErrPickupPassed    = errors.New("pickup_by must be in the future")
+	// ErrPending 通知仍在等待发送或重试，不需要重新发送
+	ErrPending = errors.New("notification is still pending")
+
+	// 以下错误表示通知没有入队，Scheduler 和事件消费者直接跳过
+	ErrDuplicate = errors.New("notification already queued")
+	ErrOptedOut  = errors.New("student has turned off this kind of notification")
+	ErrNoEmail   = errors.New("student has no email address")
+)
+
+// skipped 是否为不需要入队（而不是出错）的情况
+func skipped(err error) bool {
+	return errors.Is(err, ErrDuplicate) || errors.Is(err, ErrOptedOut) ||
+		errors.Is(err, ErrNoEmail) || errors.Is(err, ErrStudentNotFound)
+}
+
+// Filter 通知列表的查询条件，零值表示不过滤
+type Filter struct {
+	StudentID uint
+	Status    string // pending、sent 或 failed
+	Kind      string
+	BeforeID  uint // 只返回 ID 小于它的通知，用于翻页
+	Limit     int  // 默认 50，最多 200
+}
+
+// Notifier 入队通知，管理通知偏好和发送历史
+type Notifier struct {
+	db            *gorm.DB
+	defaultLocale string
+	now           func() time.Time
+}
+
+// NewNotifier db 应使用主库（replica.Primary）
+func NewNotifier(db *gorm.DB, cfg config.NotifyConfig) *Notifier {
+	locale := cfg.DefaultLocale
+	if !slices.Contains(Locales, locale) {
+		locale = LocaleZH
+	}
+	return &Notifier{db: db, defaultLocale: locale, now: time.Now}
+}
+
+// DefaultPreference 学生没有设置过偏好时使用：全部开启，语言为 notify.default_locale
+func DefaultPreference(studentID uint) models.NotificationPreference {
+	return models.NotificationPreference{StudentID: studentID, DueSoon: true, Overdue: true, HoldReady: true, Account: true}
+}
+
+// allows 偏好是否允许发送 kind 类型的通知
+func allows(p *models.NotificationPreference, kind string) bool {
+	switch kind {
+	case models.NotificationDueSoon:
+		return p.DueSoon
+	case models.NotificationOverdue:
+		return p.Overdue
+	case models.NotificationHoldReady:
+		return p.HoldReady
+	case models.NotificationAccountCreated:
+		return p.Account
+	}
+	return false
+}
+
+// Preferences 学生的通知偏好，没有设置过时返回默认值
+func (n *Notifier) Preferences(ctx context.Context, studentID uint) (*models.NotificationPreference, error) {
+	db := n.db.WithContext(ctx)
+	if err := studentExists(db, studentID); err != nil {
+		return nil, err
+	}
+	return preference(db, studentID)
+}
+
+func preference(db *gorm.DB, studentID uint) (*models.NotificationPreference, error) {
+	p := DefaultPreference(studentID)
+	if err := db.Where("student_id = ?", studentID).Take(&p).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetPreferences 全量设置学生的通知偏好，Locale 为空表示使用 notify.default_locale
+func (n *Notifier) SetPreferences(ctx context.Context, p models.NotificationPreference) (*models.NotificationPreference, error) {
+	if p.Locale != "" && !slices.Contains(Locales, p.Locale) {
+		return nil, ErrInvalidLocale
+	}
+	db := n.db.WithContext(ctx)
+	if err := studentExists(db, p.StudentID); err != nil {
+		return nil, err
+	}
+	p.UpdatedAt = n.now()
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func studentExists(db *gorm.DB, id uint) error {
+	var s models.Student
+	if err := db.Select("id").Take(&s, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrStudentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// HoldReady 通知学生预约的图书已到馆，需在 pickupBy 之前领取。
+// 同一学生、图书和领取期限只入队一次；学生关闭了这类通知时返回 ErrOptedOut
+func (n *Notifier) HoldReady(ctx context.Context, studentID, bookID uint, pickupBy time.Time) (*models.Notification, error) {
+	if !pickupBy.After(n.now()) {
+		return nil, ErrPickupPassed
+	}
+	var book models.Book
+	if err := n.db.WithContext(ctx).Take(&book, bookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookNotFound
+		}
+		return nil, err
+	}
+	key := fmt.Sprintf("hold_ready:%d:%d:%d", studentID, bookID, pickupBy.Unix())
+	return n.enqueue(ctx, studentID, models.NotificationHoldReady, key, Data{
+		BookTitle: book.Title, BookAuthor: book.Author, PickupBy: pickupBy,
+	})
+}
+
+// enqueue 按学生的偏好和语言渲染并入队一条通知。key 已存在时返回 ErrDuplicate，
+// 学生已删除、没有邮箱或关闭了这类通知时分别返回 ErrStudentNotFound、ErrNoEmail、ErrOptedOut
+func (n *Notifier) enqueue(ctx context.Context, studentID uint, kind, key string, data Data) (*models.Notification, error) {
+	db := n.db.WithContext(ctx)
+	var exists int64
+	if err := db.Model(&models.Notification{}).Where("dedup_key = ?", key).Count(&exists).Error; err != nil {
+		return nil, err
+	}
+	if exists > 0 {
+		return nil, ErrDuplicate
+	}
+	var student models.Student
+	if err := db.Take(&student, studentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudentNotFound
+		}
+		return nil, err
+	}
+	if student.Email == "" {
+		return nil, ErrNoEmail
+	}
+	pref, err := preference(db, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if !allows(pref, kind) {
+		return nil, ErrOptedOut
+	}
+	locale := pref.Locale
+	if locale == "" {
+		locale = n.defaultLocale
+	}
+
+	data.StudentName = student.Name
+	subject, text, html, err := render(locale, kind, data)
+	if err != nil {
+		return nil, err
+	}
+	// MySQL 的 datetime(3) 会把更细的精度四舍五入，截断到毫秒，避免存入的时间晚于当前时间而没有立即到期
+	now := n.now().Truncate(time.Millisecond)
+	notification := &models.Notification{
+		StudentID:     studentID,
+		Kind:          kind,
+		Locale:        locale,
+		Recipient:     student.Email,
+		Subject:       subject,
+		TextBody:      text,
+		HTMLBody:      html,
+		DedupKey:      key,
+		Status:        models.NotificationPending,
+		NextAttemptAt: &now,
+	}
+	// 并发入队同一 key 时只有一条生效
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrDuplicate
+	}
+	return notification, nil
+}
+
+// List 按 ID 倒序列出通知（发送历史）
+func (n *Notifier) List(ctx context.Context, f Filter) ([]models.Notification, error) {
+	q := n.db.WithContext(ctx).Order("id DESC")
+	if f.StudentID != 0 {
+		q = q.Where("student_id = ?", f.StudentID)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.Kind != "" {
+		q = q.Where("kind = ?", f.Kind)
+	}
+	if f.BeforeID != 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	var list []models.Notification
+	err := q.Limit(min(limit, maxListLimit)).Find(&list).Error
+	return list, err
+}
+
+func (n *Notifier) Get(ctx context.Context, id uint) (*models.Notification, error) {
+	var notification models.Notification
+	if err := n.db.WithContext(ctx).Take(&notification, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// Resend 把已发送或发送失败的通知重新排入队列，立即发送相同的内容并重新开始计算尝试次数
+func (n *Notifier) Resend(ctx context.Context, id uint) (*models.Notification, error) {
+	var notification models.Notification
+	err := n.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Take(&notification, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if notification.Status == models.NotificationPending {
+			return ErrPending
+		}
+		now := n.now().Truncate(time.Millisecond)
+		// 带上状态条件，并发的重新发送只有一个生效
+		res := tx.Model(&notification).Where("status = ?", notification.Status).
+			Updates(map[string]interface{}{"status": models.NotificationPending, "attempts": 0, "next_attempt_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrPending
+		}
+		notification.Status, notification.Attempts, notification.NextAttemptAt = models.NotificationPending, 0, &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
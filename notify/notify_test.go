@@ -0,0 +1,255 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trae-go/config"
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/notify"
+	"trae-go/pkg/mail"
+	"trae-go/testutil"
+)
+
+const day = 24 * time.Hour
+
+// fakeTransport 记录发送的邮件，fail 返回每次发送的错误
+type fakeTransport struct {
+	mu   sync.Mutex
+	sent []*mail.Message
+	fail func(n int) error
+}
+
+func (f *fakeTransport) Send(ctx context.Context, m *mail.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, m)
+	if f.fail != nil {
+		return f.fail(len(f.sent))
+	}
+	return nil
+}
+
+var notifyConfig = config.NotifyConfig{
+	From: "Library <library@example.com>", DueSoon: "48h", OverdueRepeat: "168h", OverdueNotices: 3,
+	MaxAttempts: 2, Backoff: "1m", MaxBackoff: "10m",
+}
+
+func notifications(t *testing.T, e *testutil.Env) []models.Notification {
+	t.Helper()
+	var list []models.Notification
+	if err := e.DB.Order("id").Find(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestScanQueuesReminders(t *testing.T) {
+	e := testutil.New(t)
+	period := config.DefaultLoanPeriod
+	ago := func(d time.Duration) testutil.LoanOption { return testutil.BorrowedAt(time.Now().Add(-d)) }
+	student := e.CreateStudent()
+	english := e.CreateStudent()
+	optedOut := e.CreateStudent()
+	noEmail := e.CreateStudent(func(s *models.Student) { s.Email = "" })
+	book := e.CreateBook(testutil.Title("Go <语言>"))
+
+	dueSoon := e.CreateLoan(student, book, ago(period-day))
+	e.CreateLoan(student, book, ago(time.Hour))                     // 还没到提醒时间
+	e.CreateLoan(student, book, ago(period-day), testutil.Returned) // 已归还
+	overdue := e.CreateLoan(english, book, ago(period+8*day))       // 第二个逾期周期
+	e.CreateLoan(english, book, ago(period+30*day))                 // 逾期通知已发满三次
+	e.CreateLoan(optedOut, book, ago(period+day))
+	e.CreateLoan(noEmail, book, ago(period+day))
+
+	n := e.App.Notifier
+	ctx := context.Background()
+	if _, err := n.SetPreferences(ctx, models.NotificationPreference{StudentID: english.ID, Locale: notify.LocaleEN, DueSoon: true, Overdue: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.SetPreferences(ctx, models.NotificationPreference{StudentID: optedOut.ID, DueSoon: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := notify.NewScheduler(n, notifyConfig, config.LoanConfig{})
+	queued, err := s.Scan(ctx)
+	if err != nil || queued != 2 {
+		t.Fatalf("scan = %d, %v", queued, err)
+	}
+	// 再次扫描不会重复入队
+	if queued, err := s.Scan(ctx); err != nil || queued != 0 {
+		t.Fatalf("second scan = %d, %v", queued, err)
+	}
+
+	list := notifications(t, e)
+	if len(list) != 2 {
+		t.Fatalf("notifications = %+v", list)
+	}
+	soon, late := list[0], list[1]
+	if soon.Kind != models.NotificationDueSoon || soon.StudentID != student.ID || soon.Locale != notify.LocaleZH ||
+		soon.DedupKey != "due_soon:"+itoa(dueSoon.ID) || !strings.Contains(soon.Subject, "《Go <语言>》将于") {
+		t.Fatalf("due soon = %+v", soon)
+	}
+	// HTML 正文转义书名，纯文本保持原样
+	if !strings.Contains(soon.HTMLBody, "Go &lt;语言&gt;") || !strings.Contains(soon.TextBody, "Go <语言>") {
+		t.Fatalf("due soon bodies = %q / %q", soon.TextBody, soon.HTMLBody)
+	}
+	if late.Kind != models.NotificationOverdue || late.Locale != notify.LocaleEN || late.DedupKey != "overdue:"+itoa(overdue.ID)+":1" ||
+		late.Subject != `Overdue: "Go <语言>" is 8 days overdue` || !strings.Contains(late.TextBody, "overdue notice #2") {
+		t.Fatalf("overdue = %+v", late)
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func TestWorkerSendsAndRetries(t *testing.T) {
+	e := testutil.New(t)
+	ok, flaky := e.CreateStudent(), e.CreateStudent()
+	book := e.CreateBook()
+	n := e.App.Notifier
+	ctx := context.Background()
+	for _, s := range []*models.Student{ok, flaky} {
+		if _, err := n.HoldReady(ctx, s.ID, book.ID, time.Now().Add(day)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tr := &fakeTransport{}
+	tr.fail = func(int) error {
+		if tr.sent[len(tr.sent)-1].To == flaky.Email {
+			return errors.New("550 mailbox unavailable")
+		}
+		return nil
+	}
+	w := notify.NewWorker(e.DB, tr, notifyConfig)
+	if _, err := w.Process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	list := notifications(t, e)
+	if len(tr.sent) != 2 || tr.sent[0].Headers["X-Notification-ID"] != itoa(list[0].ID) || tr.sent[0].HTML == "" || tr.sent[0].From != notifyConfig.From {
+		t.Fatalf("sent = %+v", tr.sent)
+	}
+	sent, retry := list[0], list[1]
+	if sent.Status != models.NotificationSent || sent.Attempts != 1 || sent.SentAt == nil || sent.NextAttemptAt != nil {
+		t.Fatalf("sent = %+v", sent)
+	}
+	if retry.Status != models.NotificationPending || retry.Attempts != 1 || retry.LastError != "550 mailbox unavailable" ||
+		retry.NextAttemptAt == nil || time.Until(*retry.NextAttemptAt) < 50*time.Second {
+		t.Fatalf("retry = %+v", retry)
+	}
+
+	// 还没到重试时间
+	if _, err := w.Process(ctx); err != nil || len(tr.sent) != 2 {
+		t.Fatalf("sent before retry was due: %d, %v", len(tr.sent), err)
+	}
+	e.DB.Model(&retry).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if _, err := w.Process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	retry = notifications(t, e)[1]
+	if len(tr.sent) != 3 || retry.Status != models.NotificationFailed || retry.Attempts != 2 || retry.NextAttemptAt != nil {
+		t.Fatalf("failed = %+v", retry)
+	}
+
+	// 重新发送后成功
+	tr.fail = nil
+	if _, err := n.Resend(ctx, retry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	retry = notifications(t, e)[1]
+	if len(tr.sent) != 4 || retry.Status != models.NotificationSent || retry.Attempts != 1 {
+		t.Fatalf("resent = %+v", retry)
+	}
+}
+
+func TestWorkerMaildir(t *testing.T) {
+	e := testutil.New(t)
+	s := e.CreateStudent()
+	book := e.CreateBook()
+	ctx := context.Background()
+	if _, err := e.App.Notifier.HoldReady(ctx, s.ID, book.ID, time.Now().Add(day)); err != nil {
+		t.Fatal(err)
+	}
+	cfg := notifyConfig
+	cfg.Transport, cfg.Maildir = "maildir", filepath.Join(t.TempDir(), "mail")
+	tr, err := mail.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notify.NewWorker(e.DB, tr, cfg).Process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(filepath.Join(cfg.Maildir, "new"))
+	if len(files) != 1 || notifications(t, e)[0].Status != models.NotificationSent {
+		t.Fatalf("maildir files = %d", len(files))
+	}
+}
+
+func TestAccountCreatedConsumer(t *testing.T) {
+	e := testutil.New(t)
+	s := e.CreateStudent()
+	old := e.CreateStudent()
+	c := e.App.Notifier.Consumer()
+	if c.Name != notify.ConsumerName || len(c.Types) != 1 || c.Types[0] != events.StudentCreated {
+		t.Fatalf("consumer = %+v", c)
+	}
+	publish := func(id uint, s *models.Student, at time.Time) {
+		t.Helper()
+		data, _ := json.Marshal(events.StudentOf(s))
+		ev := events.Event{ID: id, Type: events.StudentCreated, AggregateType: "student", AggregateID: s.ID, OccurredAt: at, Data: data}
+		if err := c.Sink.Publish(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publish(1, s, time.Now())
+	publish(1, s, time.Now()) // 重复投递
+	publish(2, old, time.Now().Add(-7*day))
+
+	list := notifications(t, e)
+	if len(list) != 1 || list[0].Kind != models.NotificationAccountCreated || list[0].StudentID != s.ID || list[0].Subject != "欢迎使用图书馆" {
+		t.Fatalf("notifications = %+v", list)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	e := testutil.New(t)
+	old := time.Now().Add(-100 * day)
+	now := time.Now()
+	rows := []*models.Notification{
+		{DedupKey: "old-sent", Status: models.NotificationSent, UpdatedAt: old},
+		{DedupKey: "old-failed", Status: models.NotificationFailed, UpdatedAt: old},
+		{DedupKey: "old-pending", Status: models.NotificationPending, NextAttemptAt: &now, UpdatedAt: old},
+		{DedupKey: "recent", Status: models.NotificationSent},
+	}
+	for _, r := range rows {
+		r.Kind, r.Locale, r.Recipient = models.NotificationDueSoon, notify.LocaleZH, "a@example.com"
+		if err := e.DB.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.DB.Model(&models.Notification{}).Where("dedup_key LIKE ?", "old-%").UpdateColumn("updated_at", old)
+
+	n, err := notify.NewWorker(e.DB, &fakeTransport{}, notifyConfig).Cleanup(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("cleanup = %d, %v", n, err)
+	}
+	var keys []string
+	e.DB.Model(&models.Notification{}).Order("id").Pluck("dedup_key", &keys)
+	if strings.Join(keys, ",") != "old-pending,recent" {
+		t.Fatalf("remaining = %v", keys)
+	}
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"trae-go/config"
+	"trae-go/models"
+	"trae-go/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDueSoon        = 48 * time.Hour
+	defaultOverdueRepeat  = 7 * 24 * time.Hour
+	defaultOverdueNotices = 3
+	defaultScanInterval   = 15 * time.Minute
+
+	// scanBatchSize 每次查询的借阅数
+	scanBatchSize = 500
+)
+
+// Scheduler 扫描未归还的借阅，入队到期提醒和逾期通知。
+// 到期时间为借出时间加 loan.period；到期前 due_soon 以内发一次到期提醒，
+// 逾期后每隔 overdue_repeat 发一次逾期通知，每笔借阅最多 overdue_notices 次。
+// 去重键保证同一提醒只入队一次，多个实例同时扫描也不会重复
+type Scheduler struct {
+	notifier *Notifier
+	period   time.Duration
+	dueSoon  time.Duration
+	repeat   time.Duration
+	notices  int
+	interval time.Duration
+}
+
+func NewScheduler(n *Notifier, cfg config.NotifyConfig, loan config.LoanConfig) *Scheduler {
+	s := &Scheduler{
+		notifier: n,
+		period:   loan.PeriodDuration(),
+		dueSoon:  config.Duration(cfg.DueSoon, defaultDueSoon),
+		repeat:   config.Duration(cfg.OverdueRepeat, defaultOverdueRepeat),
+		notices:  cfg.OverdueNotices,
+		interval: config.Duration(cfg.ScanInterval, defaultScanInterval),
+	}
+	if s.notices <= 0 {
+		s.notices = defaultOverdueNotices
+	}
+	return s
+}
+
+// Run 每隔 scan_interval 扫描一次，直到 ctx 结束
+func (s *Scheduler) Run(ctx context.Context) {
+	log := logger.Ctx(ctx)
+	for {
+		if n, err := s.Scan(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("scan loans for notifications failed", zap.Error(err))
+		} else if n > 0 {
+			log.Info("queued loan notifications", zap.Int("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Scan 扫描一遍未归还的借阅，返回入队的通知数。单条借阅入队失败不影响其他借阅
+func (s *Scheduler) Scan(ctx context.Context) (int, error) {
+	db := s.notifier.db.WithContext(ctx)
+	now := s.notifier.now()
+	// 只需要到期时间在 now + due_soon 之前的借阅
+	borrowedBefore := now.Add(s.dueSoon - s.period)
+	queued := 0
+	var errs []error
+	var lastID uint
+	for {
+		var loans []models.Book_Student
+		err := db.Where("id > ? AND status = ? AND borrowed_at <= ?", lastID, models.BorrowStatusBorrowed, borrowedBefore).
+			Order("id").Limit(scanBatchSize).Find(&loans).Error
+		if err != nil {
+			return queued, errors.Join(append(errs, err)...)
+		}
+		if len(loans) == 0 {
+			break
+		}
+		lastID = loans[len(loans)-1].ID
+
+		bookIDs := make([]uint, 0, len(loans))
+		for _, l := range loans {
+			bookIDs = append(bookIDs, l.BookID)
+		}
+		var books []models.Book
+		if err := db.Unscoped().Where("id IN ?", bookIDs).Find(&books).Error; err != nil {
+			return queued, errors.Join(append(errs, err)...)
+		}
+		byID := make(map[uint]*models.Book, len(books))
+		for i := range books {
+			byID[books[i].ID] = &books[i]
+		}
+
+		for _, l := range loans {
+			kind, key, data, ok := s.reminder(&l, now)
+			if !ok {
+				continue
+			}
+			if b := byID[l.BookID]; b != nil {
+				data.BookTitle, data.BookAuthor = b.Title, b.Author
+			}
+			if _, err := s.notifier.enqueue(ctx, l.StudentID, kind, key, data); err == nil {
+				queued++
+			} else if !skipped(err) {
+				errs = append(errs, fmt.Errorf("loan %d: %w", l.ID, err))
+				if ctx.Err() != nil {
+					return queued, errors.Join(errs...)
+				}
+			}
+		}
+		if len(loans) < scanBatchSize {
+			break
+		}
+	}
+	return queued, errors.Join(errs...)
+}
+
+// reminder 借阅在 now 时应有的提醒：类型、去重键和模板数据；不需要提醒时 ok 为 false
+func (s *Scheduler) reminder(l *models.Book_Student, now time.Time) (kind, key string, data Data, ok bool) {
+	due := l.BorrowedAt.Add(s.period)
+	data.DueAt = due
+	if now.Before(due) {
+		if due.Sub(now) > s.dueSoon {
+			return "", "", data, false
+		}
+		return models.NotificationDueSoon, fmt.Sprintf("due_soon:%d", l.ID), data, true
+	}
+	// 第 n 个 overdue_repeat 周期发第 n+1 次逾期通知；停机错过的周期不补发
+	n := int(now.Sub(due) / s.repeat)
+	if n >= s.notices {
+		return "", "", data, false
+	}
+	data.Notice = n + 1
+	data.DaysOverdue = max(1, int(now.Sub(due)/(24*time.Hour)))
+	return models.NotificationOverdue, fmt.Sprintf("overdue:%d:%d", l.ID, n), data, true
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"trae-go/models"
+)
+
+// 支持的语言
+const (
+	LocaleZH = "zh-CN"
+	LocaleEN = "en"
+)
+
+// Locales 全部支持的语言
+var Locales = []string{LocaleZH, LocaleEN}
+
+// Kinds 全部通知类型
+var Kinds = []string{
+	models.NotificationDueSoon, models.NotificationOverdue,
+	models.NotificationHoldReady, models.NotificationAccountCreated,
+}
+
+// templates/<语言>/<类型>.txt 定义 subject 和纯文本正文（content），<类型>.html 定义 HTML 正文，
+// 正文套在同目录的 layout.txt / layout.html 中
+//
+//go:embed templates
+var templateFS embed.FS
+
+// Data 模板数据，各类型只用到其中一部分
+type Data struct {
+	StudentName string
+	BookTitle   string
+	BookAuthor  string
+	DueAt       time.Time // 借阅到期时间
+	DaysOverdue int       // 已逾期的整天数，至少为 1
+	Notice      int       // 第几次逾期通知，从 1 开始
+	PickupBy    time.Time // 预约保留到何时
+}
+
+type localeTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates 语言 -> 类型 -> 模板，启动时解析，模板有错误时 panic
+var templates = parseTemplates()
+
+// dateLayouts 模板函数 date 的格式
+var dateLayouts = map[string]string{
+	LocaleZH: "2006年1月2日 15:04",
+	LocaleEN: "Jan 2, 2006 15:04",
+}
+
+func parseTemplates() map[string]map[string]localeTemplates {
+	all := make(map[string]map[string]localeTemplates, len(Locales))
+	for _, locale := range Locales {
+		layout := dateLayouts[locale]
+		funcs := map[string]any{"date": func(t time.Time) string { return t.Local().Format(layout) }}
+		all[locale] = make(map[string]localeTemplates, len(Kinds))
+		for _, kind := range Kinds {
+			dir := "templates/" + locale + "/"
+			all[locale][kind] = localeTemplates{
+				text: texttemplate.Must(texttemplate.New(kind).Funcs(funcs).
+					ParseFS(templateFS, dir+"layout.txt", dir+kind+".txt")),
+				html: htmltemplate.Must(htmltemplate.New(kind).Funcs(funcs).
+					ParseFS(templateFS, dir+"layout.html", dir+kind+".html")),
+			}
+		}
+	}
+	return all
+}
+
+// render 按语言渲染通知的主题、纯文本和 HTML 正文
+func render(locale, kind string, data Data) (subject, text, html string, err error) {
+	t, ok := templates[locale][kind]
+	if !ok {
+		return "", "", "", fmt.Errorf("no template for %s/%s", locale, kind)
+	}
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", "", "", err
+	}
+	text = strings.TrimSpace(buf.String()) + "\n"
+	buf.Reset()
+	if err := t.html.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", "", "", err
+	}
+	return subject, text, buf.String(), nil
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"trae-go/config"
+	"trae-go/jobs"
+	"trae-go/models"
+	"trae-go/pkg/logger"
+	"trae-go/pkg/mail"
+	"trae-go/pkg/metrics"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultMaxAttempts  = 5
+	defaultBackoff      = time.Minute
+	defaultMaxBackoff   = 6 * time.Hour
+	defaultRetention    = 90 * 24 * time.Hour
+
+	// batchSize 每次查询的待发送通知数
+	batchSize = 100
+	// lease 发送期间通知的 next_attempt_at 推迟这么久作为占用，
+	// 实例在发送中途退出时，其他实例在这之后重试。应长于 notify.smtp.timeout
+	lease = 2 * time.Minute
+	// maxErrorText last_error 保留的最大字节数
+	maxErrorText = 1024
+)
+
+// Worker 发送到期的通知。多个实例可以同时运行：每条通知发送前先用条件更新占用，只有一个实例发送。
+// 邮件服务器通常限制连接频率，同一实例内逐条发送
+type Worker struct {
+	db          *gorm.DB
+	transport   mail.Transport
+	from        string
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	interval    time.Duration
+	retention   time.Duration
+	now         func() time.Time
+}
+
+// NewWorker db 应使用主库（replica.Primary），transport 通常由 mail.New(cfg) 创建
+func NewWorker(db *gorm.DB, transport mail.Transport, cfg config.NotifyConfig) *Worker {
+	w := &Worker{
+		db:          db,
+		transport:   transport,
+		from:        cfg.From,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     config.Duration(cfg.Backoff, defaultBackoff),
+		maxBackoff:  config.Duration(cfg.MaxBackoff, defaultMaxBackoff),
+		interval:    config.Duration(cfg.PollInterval, defaultPollInterval),
+		retention:   config.Duration(cfg.Retention, defaultRetention),
+		now:         time.Now,
+	}
+	if w.maxAttempts <= 0 {
+		w.maxAttempts = defaultMaxAttempts
+	}
+	return w
+}
+
+// Run 循环发送到期的通知并定期清理旧记录，直到 ctx 结束
+func (w *Worker) Run(ctx context.Context) {
+	jobs.Poller{
+		Name:      "notifications",
+		Interval:  w.interval,
+		BatchSize: batchSize,
+		Retention: w.retention,
+		Process:   w.Process,
+		Cleanup:   w.Cleanup,
+	}.Run(ctx)
+}
+
+// Process 逐条发送一批到期的通知，返回本次查询到的通知数
+func (w *Worker) Process(ctx context.Context) (int, error) {
+	var due []models.Notification
+	err := w.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.NotificationPending, w.now()).
+		Order("next_attempt_at").Limit(batchSize).Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if err := w.send(ctx, &due[i]); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// send 占用并发送一条通知，更新状态
+func (w *Worker) send(ctx context.Context, n *models.Notification) error {
+	db := w.db.WithContext(ctx)
+	now := w.now()
+	claimed, err := jobs.Claim(db, &models.Notification{}, n.ID, models.NotificationPending, now, now.Add(lease))
+	if err != nil || !claimed {
+		return err
+	}
+
+	sendErr := w.transport.Send(ctx, &mail.Message{
+		From:    w.from,
+		To:      n.Recipient,
+		Subject: n.Subject,
+		Text:    n.TextBody,
+		HTML:    n.HTMLBody,
+		Headers: map[string]string{"X-Notification-ID": strconv.FormatUint(uint64(n.ID), 10)},
+	})
+	if ctx.Err() != nil {
+		return jobs.Release(ctx, db, n, now)
+	}
+
+	attempt := n.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempt}
+	result := "retry"
+	switch {
+	case sendErr == nil:
+		result = models.NotificationSent
+		updates["status"], updates["next_attempt_at"], updates["sent_at"], updates["last_error"] =
+			models.NotificationSent, nil, w.now(), ""
+	case attempt >= w.maxAttempts:
+		result = models.NotificationFailed
+		updates["status"], updates["next_attempt_at"], updates["last_error"] = models.NotificationFailed, nil, jobs.Truncate(sendErr.Error(), maxErrorText)
+	default:
+		updates["next_attempt_at"], updates["last_error"] = w.now().Add(jobs.Backoff(w.backoff, w.maxBackoff, attempt)), jobs.Truncate(sendErr.Error(), maxErrorText)
+	}
+	if err := db.Model(n).Where("status = ?", models.NotificationPending).Updates(updates).Error; err != nil {
+		return err
+	}
+	metrics.NotificationsSent.WithLabelValues(n.Kind, result).Inc()
+	if sendErr != nil {
+		logger.Ctx(ctx).Warn("send notification failed",
+			zap.Uint("notification_id", n.ID),
+			zap.String("kind", n.Kind),
+			zap.Int("attempt", attempt),
+			zap.String("result", result),
+			zap.Error(sendErr),
+		)
+	}
+	return nil
+}
+
+// Cleanup 删除更新时间早于 retention 的已发送和发送失败的通知，返回删除的条数。
+// 去重键随之删除，因此 retention 必须长于 due_soon 和 overdue_repeat（配置校验保证），否则同一提醒可能再次入队
+func (w *Worker) Cleanup(ctx context.Context) (int64, error) {
+	res := w.db.WithContext(ctx).
+		Where("status <> ? AND updated_at < ?", models.NotificationPending, w.now().Add(-w.retention)).
+		Delete(&models.Notification{})
+	return res.RowsAffected, res.Error
+}
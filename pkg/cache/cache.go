@@ -0,0 +1,138 @@
+// Package cache Redis 上的 cache-aside 缓存，按标签失效。
+//
+// 每个标签在 Redis 中有一个版本号，缓存 key 带上所属标签的当前版本；Invalidate 把版本号加一，
+// 旧 key 不再被读到，等 TTL 到期自然淘汰。读请求在失效之前从数据库读到的旧数据即使晚于失效才写入缓存，
+// 也是写在旧版本的 key 下，不会被之后的请求读到。同一 key 的并发未命中通过 singleflight 合并为一次加载。
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"trae-go/pkg/logger"
+	"trae-go/pkg/metrics"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// keyPrefix 缓存在 Redis 中的 key 前缀，标签版本号为 cache:tag:<标签>
+const keyPrefix = "cache:"
+
+// lookupScript 读取标签版本号并拼出带版本的 key，再读取缓存，一次往返完成。
+// 读取的 key 不全在 KEYS 中，不适用于 Redis Cluster
+//
+//	KEYS  标签版本号的 key
+//	ARGV  缓存 key（不含版本）
+//	返回  {带版本的 key, 缓存内容或 nil}
+var lookupScript = redis.NewScript(`
+local key = ARGV[1]
+for _, tag in ipairs(KEYS) do
+	key = key .. ':' .. (redis.call('GET', tag) or '0')
+end
+return {key, redis.call('GET', key)}
+`)
+
+// Cache 一组共用 Redis 连接和有效期的缓存
+type Cache struct {
+	rdb   *redis.Client
+	ttl   time.Duration
+	group singleflight.Group
+}
+
+func New(rdb *redis.Client, ttl time.Duration) *Cache {
+	return &Cache{rdb: rdb, ttl: ttl}
+}
+
+// Fetch 读取 key 的缓存，未命中时调用 load 并把结果以 JSON 写入缓存。
+// name 为指标中的缓存名；tags 中任一标签被 Invalidate 后缓存失效。
+// Redis 不可用时直接调用 load，不影响请求；load 返回的错误不缓存
+func Fetch[T any](ctx context.Context, c *Cache, name, key string, tags []string, load func(context.Context) (T, error)) (T, error) {
+	var zero T
+	versioned, data, err := c.lookup(ctx, key, tags)
+	if err != nil {
+		metrics.CacheRequests.WithLabelValues(name, "error").Inc()
+		logger.Ctx(ctx).Warn("cache lookup failed, loading from source", zap.String("cache", name), zap.Error(err))
+		return load(ctx)
+	}
+	if data != nil {
+		var v T
+		// 解析失败（如模型字段变化后的旧数据）按未命中处理，重新加载时覆盖
+		if err := json.Unmarshal(data, &v); err == nil {
+			metrics.CacheRequests.WithLabelValues(name, "hit").Inc()
+			return v, nil
+		}
+	}
+	metrics.CacheRequests.WithLabelValues(name, "miss").Inc()
+
+	// 发起加载的请求被取消时，等待同一结果的其他请求不应一起失败
+	shared, err, _ := c.group.Do(versioned, func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+		v, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.rdb.Set(ctx, versioned, b, c.ttl).Err(); err != nil {
+			logger.Ctx(ctx).Warn("cache write failed", zap.String("cache", name), zap.Error(err))
+		}
+		return b, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+	// 每个调用方解析出自己的副本，互不影响
+	var v T
+	if err := json.Unmarshal(shared.([]byte), &v); err != nil {
+		return zero, err
+	}
+	return v, nil
+}
+
+// lookup 返回带版本的 key 和缓存内容，未命中时内容为 nil
+func (c *Cache) lookup(ctx context.Context, key string, tags []string) (string, []byte, error) {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = tagKey(tag)
+	}
+	res, err := lookupScript.Run(ctx, c.rdb, keys, keyPrefix+key).Slice()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(res) != 2 {
+		return "", nil, fmt.Errorf("unexpected lookup result %v", res)
+	}
+	versioned, ok := res[0].(string)
+	if !ok {
+		return "", nil, errors.New("unexpected lookup key")
+	}
+	if s, ok := res[1].(string); ok {
+		return versioned, []byte(s), nil
+	}
+	return versioned, nil, nil
+}
+
+// Invalidate 使带有 tags 中任一标签的缓存失效
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := c.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, tag := range tags {
+			p.Incr(ctx, tagKey(tag))
+		}
+		return nil
+	})
+	return err
+}
+
+func tagKey(tag string) string {
+	return keyPrefix + "tag:" + tag
+}
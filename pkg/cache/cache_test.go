@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"trae-go/pkg/metrics"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func newCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return New(rdb, time.Minute), mr
+}
+
+// counter 返回的 load 每次调用计数加一并返回调用次数
+func counter(calls *atomic.Int64) func(context.Context) (int64, error) {
+	return func(context.Context) (int64, error) {
+		return calls.Add(1), nil
+	}
+}
+
+func fetch(t *testing.T, c *Cache, load func(context.Context) (int64, error)) int64 {
+	t.Helper()
+	v, err := Fetch(context.Background(), c, "test", "item:1", []string{"item:1", "items"}, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestFetch(t *testing.T) {
+	c, mr := newCache(t)
+	var calls atomic.Int64
+	hits := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "hit"))
+
+	if v := fetch(t, c, counter(&calls)); v != 1 {
+		t.Fatalf("first fetch = %d, want 1", v)
+	}
+	if v := fetch(t, c, counter(&calls)); v != 1 {
+		t.Fatalf("second fetch = %d, want cached 1", v)
+	}
+	if got := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "hit")) - hits; got != 1 {
+		t.Fatalf("hits = %v, want 1", got)
+	}
+	if ttl := mr.TTL("cache:item:1:0:0"); ttl != time.Minute {
+		t.Fatalf("ttl = %v, want 1m", ttl)
+	}
+
+	// 任一标签失效都会重新加载
+	for i, tag := range []string{"items", "item:1"} {
+		if err := c.Invalidate(context.Background(), tag); err != nil {
+			t.Fatal(err)
+		}
+		if v := fetch(t, c, counter(&calls)); v != int64(i+2) {
+			t.Fatalf("fetch after invalidating %s = %d, want %d", tag, v, i+2)
+		}
+	}
+}
+
+func TestLoadErrorNotCached(t *testing.T) {
+	c, _ := newCache(t)
+	boom := errors.New("boom")
+	_, err := Fetch(context.Background(), c, "test", "item:1", nil, func(context.Context) (int64, error) { return 0, boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	var calls atomic.Int64
+	if v := fetch(t, c, counter(&calls)); v != 1 {
+		t.Fatalf("fetch after error = %d, want 1", v)
+	}
+}
+
+// 加载期间发生失效（数据库读到的是旧数据），写入的缓存之后不会被读到
+func TestInvalidateDuringLoad(t *testing.T) {
+	c, _ := newCache(t)
+	var calls atomic.Int64
+	fetch(t, c, func(ctx context.Context) (int64, error) {
+		if err := c.Invalidate(ctx, "items"); err != nil {
+			t.Fatal(err)
+		}
+		return calls.Add(1), nil
+	})
+	if v := fetch(t, c, counter(&calls)); v != 2 {
+		t.Fatalf("fetch = %d, want reloaded 2", v)
+	}
+}
+
+func TestSingleflight(t *testing.T) {
+	c, _ := newCache(t)
+	var calls atomic.Int64
+	release := make(chan struct{})
+	load := func(context.Context) (int64, error) {
+		<-release
+		return calls.Add(1), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int64, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = fetch(t, c, load)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("load called %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != 1 {
+			t.Fatalf("results[%d] = %d, want 1", i, v)
+		}
+	}
+}
+
+func TestRedisDown(t *testing.T) {
+	c, mr := newCache(t)
+	mr.Close()
+	errs := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "error"))
+
+	var calls atomic.Int64
+	for want := int64(1); want <= 2; want++ {
+		if v := fetch(t, c, counter(&calls)); v != want {
+			t.Fatalf("fetch = %d, want %d from source", v, want)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "error")) - errs; got != 2 {
+		t.Fatalf("errors = %v, want 2", got)
+	}
+	if err := c.Invalidate(context.Background(), "items"); err == nil {
+		t.Fatal("Invalidate succeeded with redis down")
+	}
+}
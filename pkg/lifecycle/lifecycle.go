@@ -0,0 +1,38 @@
+// Package lifecycle 服务实例的运行状态：启动时间，以及是否正在退出（draining）
+package lifecycle
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// State 一个服务实例的运行状态，由 app.App 持有
+type State struct {
+	startedAt time.Time
+	draining  atomic.Bool
+}
+
+// New 以当前时间为启动时间
+func New() *State {
+	return &State{startedAt: time.Now()}
+}
+
+// StartedAt 实例启动时间
+func (s *State) StartedAt() time.Time {
+	return s.startedAt
+}
+
+// Uptime 已运行时长
+func (s *State) Uptime() time.Duration {
+	return time.Since(s.startedAt)
+}
+
+// StartDraining 标记实例正在退出，/readyz 随后返回 503，负载均衡器不再转发新请求
+func (s *State) StartDraining() {
+	s.draining.Store(true)
+}
+
+// Draining 是否正在退出
+func (s *State) Draining() bool {
+	return s.draining.Load()
+}
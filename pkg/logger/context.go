@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// NewContext 把 l 放进 ctx，之后 Ctx(ctx) 会使用它而不是全局 L。
+// 每个服务实例在请求入口放入自己的 logger，同一进程中的多个实例日志互不干扰
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// Ctx 返回 ctx 中的 logger（没有时为 L），并带上 trace_id / span_id 字段，便于从日志跳转到对应的 trace
+func Ctx(ctx context.Context) *zap.Logger {
+	l, _ := ctx.Value(ctxKey{}).(*zap.Logger)
+	if l == nil {
+		l = L
+	}
+	if l == nil {
+		// 测试等场景没有初始化日志
+		return zap.NewNop()
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.With(
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	)
+}
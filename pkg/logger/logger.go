@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"trae-go/config"
 
@@ -11,23 +12,31 @@ import (
 
 var L *zap.Logger
 
+// level 日志级别，可在运行中通过 SetLevel 修改
+var level = zap.NewAtomicLevel()
+
+// ConsoleOutput 日志在控制台的输出位置。命令行工具会把它改成 os.Stderr，
+// 避免日志和 export 等命令写到标准输出的数据混在一起
+var ConsoleOutput io.Writer = os.Stdout
+
+// InitLogger 初始化全局 logger L，命令行和 serve 使用
 func InitLogger(cfg config.LogConfig, mode string) {
-	// 1. 设置日志级别
-	var level zapcore.Level
-	switch cfg.Level {
-	case "debug":
-		level = zap.DebugLevel
-	case "info":
-		level = zap.InfoLevel
-	case "warn":
-		level = zap.WarnLevel
-	case "error":
-		level = zap.ErrorLevel
-	default:
-		level = zap.InfoLevel
-	}
+	SetLevel(cfg.Level)
+	L = build(cfg, mode, level)
+
+	// 替换全局的 logger，这样你也可以在其他地方用 zap.L() 直接调用
+	zap.ReplaceGlobals(L)
+}
 
-	// 2. 配置 Encoder (日志格式)
+// New 创建独立的 logger，不修改全局 L；返回的 AtomicLevel 用于运行中调整级别。
+// 同一进程中运行多个服务实例（如集成测试）时使用
+func New(cfg config.LogConfig, mode string) (*zap.Logger, zap.AtomicLevel) {
+	lvl := zap.NewAtomicLevelAt(ParseLevel(cfg.Level))
+	return build(cfg, mode, lvl), lvl
+}
+
+func build(cfg config.LogConfig, mode string, lvl zap.AtomicLevel) *zap.Logger {
+	// 1. 配置 Encoder (日志格式)
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder   // 时间格式: 2024-01-01T12:00:00.000Z
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder // 级别格式: INFO, ERROR
@@ -39,7 +48,7 @@ func InitLogger(cfg config.LogConfig, mode string) {
 		encoder = zapcore.NewJSONEncoder(encoderConfig) // 生产模式用 JSON 格式
 	}
 
-	// 3. 配置日志输出 (同时输出到文件和控制台)
+	// 2. 配置日志输出 (同时输出到文件和控制台)
 	writeSyncer := zapcore.AddSync(&lumberjack.Logger{
 		Filename:   cfg.Filename,
 		MaxSize:    cfg.MaxSize,
@@ -51,14 +60,30 @@ func InitLogger(cfg config.LogConfig, mode string) {
 	// 同时输出到控制台和文件
 	core := zapcore.NewCore(
 		encoder,
-		zapcore.NewMultiWriteSyncer(writeSyncer, zapcore.AddSync(os.Stdout)),
-		level,
+		zapcore.NewMultiWriteSyncer(writeSyncer, zapcore.AddSync(ConsoleOutput)),
+		lvl,
 	)
 
-	// 4. 创建 Logger
+	// 3. 创建 Logger
 	// AddCaller: 添加调用者信息 (文件名:行号)
-	L = zap.New(core, zap.AddCaller())
+	return zap.New(core, zap.AddCaller())
+}
 
-	// 替换全局的 logger，这样你也可以在其他地方用 zap.L() 直接调用
-	zap.ReplaceGlobals(L)
+// SetLevel 修改全局 logger 的级别，配置热更新时调用；不认识的级别按 info 处理
+func SetLevel(l string) {
+	level.SetLevel(ParseLevel(l))
+}
+
+// ParseLevel 解析配置中的日志级别，不认识的级别按 info 处理
+func ParseLevel(l string) zapcore.Level {
+	switch l {
+	case "debug":
+		return zap.DebugLevel
+	case "warn":
+		return zap.WarnLevel
+	case "error":
+		return zap.ErrorLevel
+	default:
+		return zap.InfoLevel
+	}
 }
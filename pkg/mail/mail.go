@@ -0,0 +1,174 @@
+// Package mail 发送邮件：把 Message 编码为 MIME（multipart/alternative，纯文本 + HTML），
+// 通过 Transport 投递。Transport 有 SMTP 和写入本地 maildir 两种实现，按 notify.transport 选择。
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"trae-go/config"
+)
+
+// Message 一封邮件。Text 和 HTML 至少有一个，同时存在时客户端优先显示 HTML
+type Message struct {
+	From    string // 如 "图书馆 <library@example.com>"
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string // 附加的邮件头，如 X-Notification-ID
+}
+
+// Transport 邮件的投递方式。返回错误时调用方稍后重试
+type Transport interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// New 按配置创建 Transport
+func New(cfg config.NotifyConfig) (Transport, error) {
+	switch cfg.Transport {
+	case "smtp":
+		return NewSMTP(cfg.SMTP), nil
+	case "maildir":
+		return NewMaildir(cfg.Maildir)
+	}
+	return nil, fmt.Errorf("unsupported mail transport %q", cfg.Transport)
+}
+
+// Bytes 编码为 RFC 5322 邮件，now 为 Date 头的时间
+func (m *Message) Bytes(now time.Time) ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", m.From, err)
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to address %q: %w", m.To, err)
+	}
+	if m.Text == "" && m.HTML == "" {
+		return nil, errors.New("message has no body")
+	}
+
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+	keys := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header(textproto.CanonicalMIMEHeaderKey(k), mime.QEncoding.Encode("utf-8", m.Headers[k]))
+	}
+
+	if m.Text == "" || m.HTML == "" {
+		body, typ := m.Text, "text/plain"
+		if body == "" {
+			body, typ = m.HTML, "text/html"
+		}
+		header("Content-Type", typ+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQP(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+w.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ typ, body string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQP(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID 生成全局唯一的 Message-ID，域名取发件人地址的域名
+func messageID(from string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	domain := "localhost"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		domain = from[i+1:]
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
+
+// Maildir 把邮件写入本地 maildir 目录（new/ 下每封一个文件），用于开发和测试，
+// 可以直接用邮件客户端（如 mutt -f）打开，不会真正发出
+type Maildir struct {
+	dir string
+}
+
+// NewMaildir 创建 maildir 的 tmp、new、cur 子目录
+func NewMaildir(dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Maildir{dir: dir}, nil
+}
+
+// Send 先写入 tmp/ 再改名到 new/，读取方不会看到写了一半的文件
+func (d *Maildir) Send(ctx context.Context, m *Message) error {
+	now := time.Now()
+	b, err := m.Bytes(now)
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	r := make([]byte, 6)
+	rand.Read(r)
+	name := fmt.Sprintf("%d.%d_%s.%s", now.Unix(), os.Getpid(), hex.EncodeToString(r), strings.ReplaceAll(host, "/", "_"))
+	tmp := filepath.Join(d.dir, "tmp", name)
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(d.dir, "new", name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
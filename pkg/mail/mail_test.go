@@ -0,0 +1,209 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"trae-go/config"
+)
+
+var testMessage = &Message{
+	From:    "图书馆 <library@example.com>",
+	To:      "Tom <tom@example.com>",
+	Subject: "《Go 语言》即将到期",
+	Text:    "你好 Tom，\n请在 2026-10-21 前归还。",
+	HTML:    "<p>你好 Tom，</p><p>请在 <b>2026-10-21</b> 前归还。</p>",
+	Headers: map[string]string{"X-Notification-ID": "42"},
+}
+
+// parse 解析邮件，返回邮件头和各部分的 Content-Type -> 正文
+func parse(t *testing.T, raw []byte) (mail.Header, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || typ != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// NextPart 自动解码 quoted-printable
+		b, _ := io.ReadAll(p)
+		parts[strings.Split(p.Header.Get("Content-Type"), ";")[0]] = strings.ReplaceAll(string(b), "\r\n", "\n")
+	}
+	return msg.Header, parts
+}
+
+func TestMessageBytes(t *testing.T) {
+	raw, err := testMessage.Bytes(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, parts := parse(t, raw)
+	subject, _ := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if subject != testMessage.Subject || header.Get("X-Notification-Id") != "42" || header.Get("Message-Id") == "" {
+		t.Fatalf("header = %v", header)
+	}
+	if from, err := header.AddressList("From"); err != nil || from[0].Name != "图书馆" {
+		t.Fatalf("from = %v, %v", from, err)
+	}
+	if parts["text/plain"] != testMessage.Text || parts["text/html"] != testMessage.HTML {
+		t.Fatalf("parts = %q", parts)
+	}
+
+	if _, err := (&Message{From: "library@example.com", To: "not an address", Text: "x"}).Bytes(time.Now()); err == nil {
+		t.Fatal("invalid recipient accepted")
+	}
+}
+
+func TestMaildir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	tr, err := New(config.NotifyConfig{Transport: "maildir", Maildir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(filepath.Join(dir, "new"))
+	tmp, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	if len(files) != 1 || len(tmp) != 0 {
+		t.Fatalf("new = %d, tmp = %d", len(files), len(tmp))
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, "new", files[0].Name()))
+	if _, parts := parse(t, raw); parts["text/plain"] != testMessage.Text {
+		t.Fatalf("parts = %q", parts)
+	}
+}
+
+// fakeSMTP 最小的 SMTP 服务器，记录收到的信封和邮件；reject 非空时拒绝 RCPT
+type fakeSMTP struct {
+	addr   string
+	from   string
+	to     string
+	data   string
+	reject string
+	done   chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer close(s.done)
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.Fields(cmd)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 fake")
+			case "MAIL":
+				s.from = cmd
+				reply("250 ok")
+			case "RCPT":
+				if s.reject != "" {
+					reply(s.reject)
+					continue
+				}
+				s.to = cmd
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					b.WriteString(strings.TrimPrefix(l, "."))
+				}
+				s.data = b.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return s
+}
+
+func newTestSMTP(addr string) *SMTP {
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	return NewSMTP(config.SMTPConfig{Host: host, Port: p, TLS: "none", Timeout: "5s"})
+}
+
+func TestSMTP(t *testing.T) {
+	srv := newFakeSMTP(t)
+	if err := newTestSMTP(srv.addr).Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	<-srv.done
+	if !strings.HasPrefix(srv.from, "MAIL FROM:<library@example.com>") {
+		t.Fatalf("MAIL = %q", srv.from)
+	}
+	if srv.to != "RCPT TO:<tom@example.com>" {
+		t.Fatalf("RCPT = %q", srv.to)
+	}
+	if _, parts := parse(t, []byte(srv.data)); parts["text/html"] != testMessage.HTML {
+		t.Fatalf("parts = %q", parts)
+	}
+}
+
+func TestSMTPRejected(t *testing.T) {
+	srv := newFakeSMTP(t)
+	srv.reject = "550 no such user"
+	err := newTestSMTP(srv.addr).Send(context.Background(), testMessage)
+	if err == nil || !strings.Contains(err.Error(), "no such user") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSMTPRequiresSTARTTLS(t *testing.T) {
+	srv := newFakeSMTP(t)
+	s := newTestSMTP(srv.addr)
+	s.tls = "starttls"
+	// 服务器不支持 STARTTLS 时不以明文发送
+	if err := s.Send(context.Background(), testMessage); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"trae-go/config"
+)
+
+const (
+	defaultSMTPPort    = 587
+	defaultSMTPTimeout = 30 * time.Second
+)
+
+// SMTP 通过 SMTP 服务器发送，每封邮件一个连接
+type SMTP struct {
+	host      string
+	addr      string
+	username  string
+	password  string
+	tls       string // starttls、tls 或 none
+	timeout   time.Duration
+	tlsConfig *tls.Config // tls / starttls 使用，校验服务器证书
+}
+
+func NewSMTP(cfg config.SMTPConfig) *SMTP {
+	port := cfg.Port
+	if port <= 0 {
+		port = defaultSMTPPort
+	}
+	timeout := config.Duration(cfg.Timeout, defaultSMTPTimeout)
+	mode := cfg.TLS
+	if mode == "" {
+		mode = "starttls"
+	}
+	return &SMTP{
+		host:      cfg.Host,
+		addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		username:  cfg.Username,
+		password:  cfg.Password,
+		tls:       mode,
+		timeout:   timeout,
+		tlsConfig: &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12},
+	}
+}
+
+func (s *SMTP) Send(ctx context.Context, m *Message) error {
+	b, err := m.Bytes(time.Now())
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(m.From)
+	to, _ := mail.ParseAddress(m.To)
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	if s.tls == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	// 整个会话共用一个截止时间，服务器无响应时不会一直阻塞
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if s.tls == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", s.addr)
+		}
+		if err := c.StartTLS(s.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
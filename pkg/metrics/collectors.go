@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"trae-go/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// scrapeTimeout 抓取时查询数据库的超时
+const scrapeTimeout = 3 * time.Second
+
+// DBCollector 一个服务实例的数据库连接池统计和借阅业务指标，抓取时查询该实例的数据库。
+// 由 app.App 创建，通过 Handler 与进程级的 Registry 一起暴露，同一进程中的多个实例互不影响
+type DBCollector struct {
+	pool   dbPoolCollector
+	domain domainCollector
+}
+
+// NewDBCollector loanPeriod 为判断逾期的借阅期限
+func NewDBCollector(db *gorm.DB, loanPeriod time.Duration) *DBCollector {
+	return &DBCollector{
+		pool: dbPoolCollector{
+			db:           db,
+			maxOpen:      prometheus.NewDesc(namespace+"_db_max_open_connections", "连接池最大连接数。", nil, nil),
+			open:         prometheus.NewDesc(namespace+"_db_open_connections", "当前打开的连接数。", nil, nil),
+			inUse:        prometheus.NewDesc(namespace+"_db_in_use_connections", "正在使用的连接数。", nil, nil),
+			idle:         prometheus.NewDesc(namespace+"_db_idle_connections", "空闲连接数。", nil, nil),
+			waitCount:    prometheus.NewDesc(namespace+"_db_wait_count_total", "等待空闲连接的总次数。", nil, nil),
+			waitDuration: prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total", "等待空闲连接的总时长。", nil, nil),
+		},
+		domain: domainCollector{
+			db:                db,
+			loanPeriod:        loanPeriod,
+			activeLoans:       prometheus.NewDesc(namespace+"_active_loans", "未归还的借阅数。", nil, nil),
+			overdueLoans:      prometheus.NewDesc(namespace+"_overdue_loans", "超过借阅期限仍未归还的借阅数。", nil, nil),
+			checkoutsLastHour: prometheus.NewDesc(namespace+"_checkouts_last_hour", "最近一小时的借书数（按数据库统计，包含所有实例）。", nil, nil),
+			scrapeErrors:      prometheus.NewDesc(namespace+"_domain_scrape_errors", "本次抓取业务指标时查询失败的次数。", nil, nil),
+		},
+	}
+}
+
+func (c *DBCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.pool.Describe(ch)
+	c.domain.Describe(ch)
+}
+
+func (c *DBCollector) Collect(ch chan<- prometheus.Metric) {
+	c.pool.Collect(ch)
+	c.domain.Collect(ch)
+}
+
+// dbPoolCollector 抓取时读取 sql.DB 的连接池统计
+type dbPoolCollector struct {
+	db                                                  *gorm.DB
+	maxOpen, open, inUse, idle, waitCount, waitDuration *prometheus.Desc
+}
+
+func (c *dbPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *dbPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return
+	}
+	s := sqlDB.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+}
+
+// domainCollector 抓取时从数据库统计借阅相关的业务指标
+type domainCollector struct {
+	db                                                         *gorm.DB
+	loanPeriod                                                 time.Duration
+	activeLoans, overdueLoans, checkoutsLastHour, scrapeErrors *prometheus.Desc
+}
+
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.activeLoans
+	ch <- c.overdueLoans
+	ch <- c.checkoutsLastHour
+	ch <- c.scrapeErrors
+}
+
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	loans := c.db.WithContext(ctx).Model(&models.Book_Student{})
+	now := time.Now()
+
+	var errs float64
+	count := func(desc *prometheus.Desc, query string, args ...interface{}) {
+		var n int64
+		if err := loans.Session(&gorm.Session{}).Where(query, args...).Count(&n).Error; err != nil {
+			errs++
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n))
+	}
+	count(c.activeLoans, "status = ?", models.BorrowStatusBorrowed)
+	count(c.overdueLoans, "status = ? AND borrowed_at < ?", models.BorrowStatusBorrowed,
+		now.Add(-c.loanPeriod))
+	count(c.checkoutsLastHour, "borrowed_at >= ?", now.Add(-time.Hour))
+	ch <- prometheus.MustNewConstMetric(c.scrapeErrors, prometheus.GaugeValue, errs)
+}
@@ -0,0 +1,131 @@
+// Package metrics Prometheus 指标。进程级的计数器注册在 Registry 中，各服务实例的数据库指标由 DBCollector 提供，
+// 两者一起由 Handler 暴露。
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "library"
+
+// Registry 本服务的指标注册表，除业务指标外还包含 Go 运行时和进程指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数，route 为路由模板（如 /api/v1/books/:id）。",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求处理耗时。",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "正在处理的 HTTP 请求数。",
+	})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "被限流拒绝的请求数，scope 为触发的限流策略（global、ip 或具名策略）。",
+	}, []string{"scope"})
+
+	RedisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Redis 命令出错次数（不含 key 不存在）。",
+	}, []string{"command"})
+
+	Checkouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkouts_total",
+		Help:      "本实例处理的借书次数，每小时借书量可用 increase(library_checkouts_total[1h]) 计算。",
+	})
+
+	Returns = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "returns_total",
+		Help:      "本实例处理的还书次数。",
+	})
+
+	DBReplicaUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_up",
+		Help:      "只读副本最近一次健康检查是否通过（1 / 0），replica 为副本名（replica-0、replica-1 ...）。",
+	}, []string{"replica"})
+
+	DBReadRouting = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_read_routing_total",
+		Help:      "配置了只读副本时查询的去向：replica 为副本，sticky 为请求写过数据或要求读主库（如缓存加载）时回到主库，fallback 为没有健康副本时回到主库。",
+	}, []string{"target"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "缓存读取次数，cache 为缓存名（book、books），result 为 hit、miss 或 error（Redis 不可用，直接读数据库）。",
+	}, []string{"cache", "result"})
+
+	EventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_published_total",
+		Help:      "领域事件投递次数，sink 为消费者名，result 为 ok 或 error（稍后重试）。",
+	}, []string{"sink", "result"})
+
+	EventsLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "events_lag",
+		Help:      "各消费者尚未投递的事件数（outbox 最大事件 ID 与消费者 offset 之差）。",
+	}, []string{"sink"})
+
+	WebhookAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_attempts_total",
+		Help:      "webhook 订阅的投递请求次数，result 为 succeeded、retry（稍后重试）或 dead（重试次数用完或订阅已停用，进入死信）。",
+	}, []string{"result"})
+
+	NotificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "通知邮件的发送次数，kind 为通知类型，result 为 sent、retry（稍后重试）或 failed（重试次数用完）。",
+	}, []string{"kind", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		HTTPInFlight,
+		RateLimitRejections,
+		RedisErrors,
+		Checkouts,
+		Returns,
+		DBReplicaUp,
+		DBReadRouting,
+		CacheRequests,
+		EventsPublished,
+		EventsLag,
+		WebhookAttempts,
+		NotificationsSent,
+	)
+}
+
+// Handler /metrics 的处理函数，输出 Registry 和 collectors（服务实例自己的指标，如 DBCollector）
+func Handler(collectors ...prometheus.Collector) http.Handler {
+	own := prometheus.NewRegistry()
+	own.MustRegister(collectors...)
+	return promhttp.HandlerFor(prometheus.Gatherers{Registry, own}, promhttp.HandlerOpts{Registry: own})
+}
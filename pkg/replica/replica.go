@@ -0,0 +1,242 @@
+// Package replica 只读副本路由：基于 GORM dbresolver，查询走健康的副本，写入和事务走主库。
+// 后台定期 ping 每个副本，全部不可用时查询回到主库；请求写过数据后，同一请求之后的查询也走主库（见 Sticky）。
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"trae-go/config"
+	"trae-go/pkg/metrics"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// DefaultCheckInterval 未配置 replica_check_interval 时的健康检查间隔
+const DefaultCheckInterval = 5 * time.Second
+
+// checkTimeout 单次 ping 的超时
+const checkTimeout = 2 * time.Second
+
+// Status 一个副本最近一次健康检查的结果
+type Status struct {
+	Name      string
+	Healthy   bool
+	Latency   time.Duration
+	Error     string
+	CheckedAt time.Time
+}
+
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// conn 一个副本的连接池和健康状态
+type conn struct {
+	name    string
+	pool    gorm.ConnPool
+	healthy atomic.Bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Set 注册在主库 *gorm.DB 上的一组副本，用完需要 Close
+type Set struct {
+	primary  gorm.ConnPool
+	conns    []*conn
+	byPool   map[gorm.ConnPool]*conn
+	next     atomic.Uint64
+	interval time.Duration
+	log      *zap.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// Register 在 db 上注册 cfg.Replicas 中的副本并启动健康检查。
+// 副本在启动时连不上不会报错，只会被标记为不可用，查询暂时走主库
+func Register(db *gorm.DB, cfg config.DatabaseConfig, log *zap.Logger) (*Set, error) {
+	if len(cfg.Replicas) == 0 {
+		return nil, errors.New("no replicas configured")
+	}
+	dialectors := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for i, dsn := range cfg.Replicas {
+		d, err := config.Dialector(cfg.Driver, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("replica-%d: %w", i, err)
+		}
+		dialectors = append(dialectors, d)
+	}
+	interval := config.Duration(cfg.ReplicaCheckInterval, DefaultCheckInterval)
+
+	s := &Set{
+		primary:  db.Config.ConnPool,
+		byPool:   map[gorm.ConnPool]*conn{},
+		interval: interval,
+		log:      log,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: s})
+	// dbresolver 复制主库的 gorm.Config 打开副本，主库已经连上，关掉自动 ping 以免副本故障时服务无法启动
+	db.Config.DisableAutomaticPing = true
+	if err := db.Use(resolver); err != nil {
+		return nil, err
+	}
+	resolver.Call(func(pool gorm.ConnPool) error {
+		if pool == s.primary {
+			return nil
+		}
+		c := &conn{name: fmt.Sprintf("replica-%d", len(s.conns)), pool: pool}
+		s.conns = append(s.conns, c)
+		s.byPool[pool] = c
+		return nil
+	})
+	if len(s.conns) != len(dialectors) {
+		return nil, fmt.Errorf("expected %d replica pools, got %d", len(dialectors), len(s.conns))
+	}
+	setPoolLimits(s.conns, cfg)
+
+	if err := s.registerCallbacks(db); err != nil {
+		s.closePools()
+		return nil, err
+	}
+	s.checkAll()
+	go s.loop()
+	return s, nil
+}
+
+// setPoolLimits 副本的连接池与主库使用同样的配置
+func setPoolLimits(conns []*conn, cfg config.DatabaseConfig) {
+	lifetime := cfg.ConnLifetime()
+	for _, c := range conns {
+		if p, ok := c.pool.(interface {
+			SetMaxIdleConns(int)
+			SetMaxOpenConns(int)
+			SetConnMaxLifetime(time.Duration)
+		}); ok {
+			p.SetMaxIdleConns(int(cfg.MaxIdleConns))
+			p.SetMaxOpenConns(int(cfg.MaxOpenConns))
+			p.SetConnMaxLifetime(lifetime)
+		}
+	}
+}
+
+// Resolve 实现 dbresolver.Policy：在健康的副本间轮询，没有健康副本时返回主库。
+// 只有一个副本时 dbresolver 不调用 Policy，回到主库由 route 回调处理
+func (s *Set) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	n := len(pools)
+	start := s.next.Add(1)
+	for i := range n {
+		p := pools[(start+uint64(i))%uint64(n)]
+		if c, ok := s.byPool[p]; !ok || c.healthy.Load() {
+			return p
+		}
+	}
+	return s.primary
+}
+
+// healthy 是否还有可用的副本
+func (s *Set) healthy() bool {
+	for _, c := range s.conns {
+		if c.healthy.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// Status 各副本最近一次健康检查的结果
+func (s *Set) Status() []Status {
+	out := make([]Status, 0, len(s.conns))
+	for _, c := range s.conns {
+		c.mu.Lock()
+		out = append(out, c.status)
+		c.mu.Unlock()
+	}
+	return out
+}
+
+func (s *Set) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.checkAll()
+		}
+	}
+}
+
+func (s *Set) checkAll() {
+	var wg sync.WaitGroup
+	for _, c := range s.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.check(c)
+		}()
+	}
+	wg.Wait()
+}
+
+// check ping 一个副本并更新状态，状态变化时记录日志
+func (s *Set) check(c *conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	start := time.Now()
+	var err error
+	if p, ok := c.pool.(pinger); ok {
+		err = p.PingContext(ctx)
+	}
+	st := Status{Name: c.name, Healthy: err == nil, Latency: time.Since(start), CheckedAt: time.Now()}
+	if err != nil {
+		st.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	c.status = st
+	c.mu.Unlock()
+	was := c.healthy.Swap(st.Healthy)
+	switch {
+	case was && !st.Healthy:
+		s.log.Warn("database replica unhealthy, reads fall back", zap.String("replica", c.name), zap.Error(err))
+	case !was && st.Healthy:
+		s.log.Info("database replica healthy", zap.String("replica", c.name))
+	}
+	up := 0.0
+	if st.Healthy {
+		up = 1
+	}
+	metrics.DBReplicaUp.WithLabelValues(c.name).Set(up)
+}
+
+// Close 停止健康检查并关闭副本的连接池，主库由调用方关闭
+func (s *Set) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+	return s.closePools()
+}
+
+func (s *Set) closePools() error {
+	var errs []error
+	for _, c := range s.conns {
+		if p, ok := c.pool.(interface{ Close() error }); ok {
+			errs = append(errs, p.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,154 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"trae-go/config"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type item struct {
+	ID   uint
+	Name string
+}
+
+// openCluster 主库和每个副本是各自独立的 SQLite 文件，各放一行自己名字的数据，查询结果能看出走了哪个库
+func openCluster(t *testing.T, replicas ...string) (*gorm.DB, *Set) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{Driver: "sqlite", MaxIdleConns: 2, MaxOpenConns: 2, ConnMaxLifetime: "1h"}
+	for _, name := range append([]string{"primary"}, replicas...) {
+		dsn := filepath.Join(dir, name+".db")
+		db, err := config.OpenDatabase(config.DatabaseConfig{Driver: "sqlite", DSN: dsn, ConnMaxLifetime: "1h"}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Logger = gormlogger.Discard
+		if err := db.AutoMigrate(&item{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&item{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+		if name == "primary" {
+			cfg.DSN = dsn
+		} else {
+			cfg.Replicas = append(cfg.Replicas, dsn)
+		}
+	}
+
+	db, err := config.OpenDatabase(cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = gormlogger.Discard
+	s, err := Register(db, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db, s
+}
+
+// source 查询第一行的名字，即数据来自哪个库
+func source(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var it item
+	if err := db.Order("id").First(&it).Error; err != nil {
+		t.Fatal(err)
+	}
+	return it.Name
+}
+
+func TestRouting(t *testing.T) {
+	db, _ := openCluster(t, "replica-0")
+
+	if got := source(t, db); got != "replica-0" {
+		t.Fatalf("read went to %s, want replica-0", got)
+	}
+	if got := source(t, Primary(db)); got != "primary" {
+		t.Fatalf("Primary read went to %s", got)
+	}
+	db.Transaction(func(tx *gorm.DB) error {
+		if got := source(t, tx); got != "primary" {
+			t.Fatalf("read in transaction went to %s", got)
+		}
+		return nil
+	})
+}
+
+func TestReadYourWrites(t *testing.T) {
+	db, _ := openCluster(t, "replica-0")
+
+	// 没有 Sticky 标记的 context 写入后仍读副本
+	if err := db.Create(&item{Name: "other"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := source(t, db); got != "replica-0" {
+		t.Fatalf("read went to %s, want replica-0", got)
+	}
+
+	ctx := Sticky(context.Background())
+	if got := source(t, db.WithContext(ctx)); got != "replica-0" {
+		t.Fatalf("read before write went to %s, want replica-0", got)
+	}
+	if err := db.WithContext(ctx).Create(&item{Name: "mine"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !Written(ctx) {
+		t.Fatal("context not marked after write")
+	}
+	var n int64
+	db.WithContext(ctx).Model(&item{}).Where("name = ?", "mine").Count(&n)
+	if n != 1 {
+		t.Fatalf("read after write found %d rows, want 1", n)
+	}
+	// 其他请求不受影响
+	if got := source(t, db.WithContext(Sticky(context.Background()))); got != "replica-0" {
+		t.Fatalf("other request went to %s, want replica-0", got)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	db, s := openCluster(t, "replica-0", "replica-1")
+
+	s.conns[0].pool.(*sql.DB).Close()
+	s.checkAll()
+	st := s.Status()
+	if st[0].Healthy || st[0].Error == "" || !st[1].Healthy {
+		t.Fatalf("status = %+v", st)
+	}
+	for range 4 {
+		if got := source(t, db); got != "replica-1" {
+			t.Fatalf("read went to %s, want replica-1", got)
+		}
+	}
+
+	s.conns[1].pool.(*sql.DB).Close()
+	s.checkAll()
+	if got := source(t, db); got != "primary" {
+		t.Fatalf("read went to %s, want primary when no replica is healthy", got)
+	}
+}
+
+func TestSingleReplicaFallback(t *testing.T) {
+	db, s := openCluster(t, "replica-0")
+
+	s.conns[0].pool.(*sql.DB).Close()
+	s.checkAll()
+	if got := source(t, db); got != "primary" {
+		t.Fatalf("read went to %s, want primary", got)
+	}
+}
@@ -0,0 +1,87 @@
+package replica
+
+import (
+	"context"
+	"sync/atomic"
+
+	"trae-go/pkg/metrics"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type stickyKey struct{}
+
+// Sticky 返回带读写粘滞标记的 context。通过它写过数据之后，同一 context 上的查询都走主库，
+// 请求不会因为副本延迟读不到自己刚写入的数据。每个请求一个，由 middleware.ReadYourWritesMiddleware 设置
+func Sticky(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyKey{}, new(atomic.Bool))
+}
+
+// MarkWritten 之后 ctx 上的查询都走主库，ctx 不是由 Sticky 创建时不起作用
+func MarkWritten(ctx context.Context) {
+	if flag, ok := ctx.Value(stickyKey{}).(*atomic.Bool); ok {
+		flag.Store(true)
+	}
+}
+
+// Written ctx 上是否写过数据
+func Written(ctx context.Context) bool {
+	flag, ok := ctx.Value(stickyKey{}).(*atomic.Bool)
+	return ok && flag.Load()
+}
+
+// UsePrimary 返回查询都走主库的 context，用于结果会被缓存、不能读到副本旧数据的加载
+func UsePrimary(ctx context.Context) context.Context {
+	ctx = Sticky(ctx)
+	MarkWritten(ctx)
+	return ctx
+}
+
+// registerCallbacks 写入成功后标记 context；查询在 dbresolver 选好连接之后、执行之前再检查一次，
+// 已写过数据或没有健康副本时改走主库
+func (s *Set) registerCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().After("gorm:create").Register("replica:mark_written", markWritten),
+		cb.Update().After("gorm:update").Register("replica:mark_written", markWritten),
+		cb.Delete().After("gorm:delete").Register("replica:mark_written", markWritten),
+		cb.Query().After("gorm:db_resolver").Before("gorm:query").Register("replica:route", s.route),
+		cb.Row().After("gorm:db_resolver").Before("gorm:row").Register("replica:route", s.route),
+		cb.Raw().After("gorm:db_resolver").Before("gorm:raw").Register("replica:route", s.route),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markWritten(db *gorm.DB) {
+	if db.Error == nil && db.Statement.Context != nil {
+		MarkWritten(db.Statement.Context)
+	}
+}
+
+// route 只处理 dbresolver 分到副本的语句，事务和写入本来就在主库上
+func (s *Set) route(db *gorm.DB) {
+	if _, ok := s.byPool[db.Statement.ConnPool]; !ok {
+		return
+	}
+	switch {
+	case db.Statement.Context != nil && Written(db.Statement.Context):
+		metrics.DBReadRouting.WithLabelValues("sticky").Inc()
+	case !s.healthy():
+		metrics.DBReadRouting.WithLabelValues("fallback").Inc()
+	default:
+		metrics.DBReadRouting.WithLabelValues("replica").Inc()
+		return
+	}
+	dbresolver.Write.ModifyStatement(db.Statement)
+}
+
+// Primary 返回总是使用主库的 db，用于不能容忍副本延迟的后台任务（迁移、清理等）。
+// 没有注册副本时与 db 相同
+func Primary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval 清理过期条目的间隔
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	userID    uint
+	expiresAt time.Time
+}
+
+// MemoryStore 进程内实现：只适合单实例部署，重启后所有登录失效、限流计数清零
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	windows  map[string]*slidingWindow
+	buckets  map[string]*tokenBucket
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryStore 创建内存存储并启动后台清理，用完需要 Close
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		sessions: make(map[string]memoryEntry),
+		windows:  make(map[string]*slidingWindow),
+		buckets:  make(map[string]*tokenBucket),
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+	go s.sweepLoop()
+	return s
+}
+
+func (s *MemoryStore) SetSession(_ context.Context, token string, userID uint, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[token] = memoryEntry{userID: userID, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) GetSession(_ context.Context, token string) (uint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[token]
+	if !ok || !s.now().Before(e.expiresAt) {
+		delete(s.sessions, token)
+		return 0, ErrNotFound
+	}
+	return e.userID, nil
+}
+
+func (s *MemoryStore) Driver() string { return DriverMemory }
+
+// Close 停止后台清理
+func (s *MemoryStore) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *MemoryStore) sweepLoop() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+// sweep 删除已过期的会话和限流状态，避免长期运行时 map 无限增长
+func (s *MemoryStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for k, e := range s.sessions {
+		if !now.Before(e.expiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+	for k, w := range s.windows {
+		if !now.Before(w.expiresAt) {
+			delete(s.windows, k)
+		}
+	}
+	for k, b := range s.buckets {
+		if !now.Before(b.expiresAt) {
+			delete(s.buckets, k)
+		}
+	}
+}
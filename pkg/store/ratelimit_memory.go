@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// slidingWindow 窗口内放行的请求时间，按时间先后排列
+type slidingWindow struct {
+	hits      []time.Time
+	expiresAt time.Time
+}
+
+// tokenBucket 剩余令牌数和上次补充的时间
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// Allow 与 RedisStore 的 Lua 脚本算法相同，在进程内加锁执行
+func (s *MemoryStore) Allow(_ context.Context, key string, l Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	switch l.Algorithm {
+	case AlgorithmSlidingWindow:
+		return s.allowSlidingWindow(now, key, l), nil
+	case AlgorithmTokenBucket:
+		return s.allowTokenBucket(now, key, l), nil
+	default:
+		return Result{}, fmt.Errorf("store: unknown rate limit algorithm %q", l.Algorithm)
+	}
+}
+
+func (s *MemoryStore) allowSlidingWindow(now time.Time, key string, l Limit) Result {
+	w, ok := s.windows[key]
+	if !ok {
+		w = &slidingWindow{}
+		s.windows[key] = w
+	}
+	// 丢弃已滑出窗口的请求
+	cutoff := now.Add(-l.Window)
+	i := 0
+	for i < len(w.hits) && !w.hits[i].After(cutoff) {
+		i++
+	}
+	w.hits = w.hits[i:]
+
+	res := Result{Limit: l.Limit}
+	if int64(len(w.hits)) < l.Limit {
+		w.hits = append(w.hits, now)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = w.hits[0].Add(l.Window).Sub(now)
+	}
+	res.Remaining = l.Limit - int64(len(w.hits))
+	if n := len(w.hits); n > 0 {
+		res.ResetAfter = w.hits[n-1].Add(l.Window).Sub(now)
+	}
+	w.expiresAt = now.Add(l.Window)
+	return res
+}
+
+func (s *MemoryStore) allowTokenBucket(now time.Time, key string, l Limit) Result {
+	capacity := float64(l.Limit)
+	rate := capacity / float64(l.Window) // 每纳秒补充的令牌数
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updatedAt: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)*rate)
+	}
+	b.updatedAt = now
+
+	res := Result{Limit: l.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+	res.Remaining = int64(math.Floor(b.tokens))
+	res.ResetAfter = time.Duration(math.Ceil((capacity - b.tokens) / rate))
+	b.expiresAt = now.Add(res.ResetAfter)
+	return res
+}
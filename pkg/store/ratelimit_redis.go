@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 脚本中的时间均为毫秒：Lua 5.1 的数字转字符串只保留 14 位有效数字，微秒时间戳会丢失精度。
+// 时间由调用方传入而不是在脚本里调用 TIME，多个实例之间的时钟偏差远小于限流窗口。
+
+// slidingWindowScript 滑动窗口日志，窗口内每个放行的请求在 ZSET 中占一个成员
+//
+//	KEYS[1] 计数 key
+//	ARGV    当前时间、窗口长度、限额、本次请求的成员名
+//	返回    {是否放行, 剩余额度, 完全恢复的毫秒数, 需等待的毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+local retry = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+else
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + window - now
+end
+
+local reset = 0
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+end
+redis.call('PEXPIRE', key, window)
+return {allowed, limit - count, reset, retry}
+`)
+
+// tokenBucketScript 令牌桶，HASH 中保存剩余令牌数和上次补充的时间
+//
+//	KEYS[1] 令牌桶 key
+//	ARGV    当前时间、补满所需时间（窗口长度）、容量
+//	返回    {是否放行, 剩余令牌, 补满的毫秒数, 需等待的毫秒数}
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local capacity = tonumber(ARGV[3])
+local rate = capacity / window
+
+local data = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', key, reset + 1000)
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+func (s *RedisStore) Allow(ctx context.Context, key string, l Limit) (Result, error) {
+	now := time.Now().UnixMilli()
+	window := l.Window.Milliseconds()
+	var (
+		vals []int64
+		err  error
+	)
+	switch l.Algorithm {
+	case AlgorithmSlidingWindow:
+		member := fmt.Sprintf("%d-%d", now, rand.Int64())
+		vals, err = slidingWindowScript.Run(ctx, s.rdb, []string{key}, now, window, l.Limit, member).Int64Slice()
+	case AlgorithmTokenBucket:
+		vals, err = tokenBucketScript.Run(ctx, s.rdb, []string{key}, now, window, l.Limit).Int64Slice()
+	default:
+		return Result{}, fmt.Errorf("store: unknown rate limit algorithm %q", l.Algorithm)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 4 {
+		return Result{}, fmt.Errorf("store: unexpected rate limit script result %v", vals)
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      l.Limit,
+		Remaining:  max(vals[1], 0),
+		ResetAfter: time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
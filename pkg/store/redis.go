@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sessionKeyPrefix 会话在 Redis 中的 key 前缀
+const sessionKeyPrefix = "auth:token:"
+
+// RedisStore 基于 Redis 的实现，多个实例共享会话和限流计数
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) SetSession(ctx context.Context, token string, userID uint, ttl time.Duration) error {
+	return s.rdb.Set(ctx, sessionKeyPrefix+token, strconv.FormatUint(uint64(userID), 10), ttl).Err()
+}
+
+func (s *RedisStore) GetSession(ctx context.Context, token string) (uint, error) {
+	v, err := s.rdb.Get(ctx, sessionKeyPrefix+token).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+func (s *RedisStore) Driver() string { return DriverRedis }
+
+func (s *RedisStore) Close() error { return s.rdb.Close() }
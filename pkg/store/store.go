@@ -0,0 +1,68 @@
+// Package store 登录会话和限流计数的存储。多实例部署使用 Redis；
+// 单机部署（如只用 SQLite 的学校机房）可以选内存实现，不再依赖 Redis。
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 存储驱动
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+)
+
+// ErrNotFound 会话不存在或已过期
+var ErrNotFound = errors.New("store: not found")
+
+// SessionStore 登录 token 到用户 ID 的映射
+type SessionStore interface {
+	// SetSession 保存 token，ttl 后自动失效
+	SetSession(ctx context.Context, token string, userID uint, ttl time.Duration) error
+	// GetSession 返回 token 对应的用户 ID，不存在或已过期时返回 ErrNotFound
+	GetSession(ctx context.Context, token string) (uint, error)
+}
+
+// 限流算法
+const (
+	// AlgorithmSlidingWindow 滑动窗口日志：记录窗口内每个请求的时间，任意 Window 时长内最多 Limit 个请求，
+	// 没有固定窗口在边界处允许两倍突发的问题
+	AlgorithmSlidingWindow = "sliding_window"
+	// AlgorithmTokenBucket 令牌桶：容量为 Limit，每 Window 匀速补充 Limit 个令牌，允许短时突发
+	AlgorithmTokenBucket = "token_bucket"
+)
+
+// Limit 一条限流规则
+type Limit struct {
+	Algorithm string
+	Limit     int64
+	Window    time.Duration
+}
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// ResetAfter 额度完全恢复所需的时间
+	ResetAfter time.Duration
+	// RetryAfter 被拒绝时至少需要等待多久才能再次请求，允许时为 0
+	RetryAfter time.Duration
+}
+
+// RateLimitStore 限流计数。判断和计数是原子的，多个实例并发请求也不会超出限额
+type RateLimitStore interface {
+	// Allow 按 l 判断 key 的这次请求是否放行，放行时计入额度
+	Allow(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// Store 服务使用的全部存储
+type Store interface {
+	SessionStore
+	RateLimitStore
+	// Driver 返回 DriverRedis 或 DriverMemory
+	Driver() string
+	Close() error
+}
@@ -0,0 +1,39 @@
+// Package version 构建信息，发布时通过 -ldflags 注入：
+//
+//	go build -ldflags "-X trae-go/pkg/version.Version=v1.2.0 -X trae-go/pkg/version.Commit=$(git rev-parse --short HEAD) -X trae-go/pkg/version.BuildTime=$(date -u +%FT%TZ)"
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info 构建信息
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get 返回构建信息，没有注入 Commit / BuildTime 时尝试从 go build 记录的 VCS 信息中读取
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"trae-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormRepos struct {
+	db *gorm.DB
+}
+
+// NewGorm 基于 GORM 的仓储，查询都绑定调用方的 context（记录到请求 trace，客户端断开时取消）
+func NewGorm(db *gorm.DB) Repositories {
+	return &gormRepos{db: db}
+}
+
+func (r *gormRepos) Books() BookRepository       { return gormBooks{r.db} }
+func (r *gormRepos) Students() StudentRepository { return gormStudents{r.db} }
+func (r *gormRepos) Loans() LoanRepository       { return gormLoans{r.db} }
+func (r *gormRepos) Outbox() OutboxRepository    { return gormOutbox{r.db} }
+
+func (r *gormRepos) Transaction(ctx context.Context, fn func(r Repositories) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormRepos{db: tx})
+	})
+}
+
+// scoped withDeleted 为 true 时去掉软删除条件
+func scoped(db *gorm.DB, withDeleted bool) *gorm.DB {
+	if withDeleted {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// first 按主键查询，把 gorm.ErrRecordNotFound 转换成 ErrNotFound
+func first(db *gorm.DB, dest interface{}, id uint) error {
+	err := db.First(dest, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// findOne 按条件取 ID 最小的一条，没有时返回 ErrNotFound。用 Find 而不是 First，
+// 导入时找不到已有记录是正常情况，不记录 record not found 日志
+func findOne(db *gorm.DB, dest interface{}) error {
+	result := db.Order("id").Limit(1).Find(dest)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// lockRow 按主键 SELECT ... FOR UPDATE，只匹配未软删除的记录。SQLite 不支持行锁，
+// 驱动会去掉 FOR UPDATE，写事务本身已经串行执行
+func lockRow(db *gorm.DB, model interface{}, id uint) error {
+	return first(db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id"), model, id)
+}
+
+// updateVersioned 只有当记录版本号仍为 version 时才写入 values，并把版本号加一
+func updateVersioned(db *gorm.DB, model interface{}, id, version uint, values map[string]interface{}) (bool, error) {
+	values["version"] = gorm.Expr("version + 1")
+	result := db.Model(model).Where("id = ? AND version = ?", id, version).Updates(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// softDelete 软删除，返回是否删除了记录
+func softDelete(db *gorm.DB, model interface{}, id uint) (bool, error) {
+	result := db.Delete(model, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// restore 版本号仍为 version 时清除 deleted_at
+func restore(db *gorm.DB, model interface{}, id, version uint) (bool, error) {
+	return updateVersioned(db.Unscoped().Where("deleted_at IS NOT NULL"), model, id, version,
+		map[string]interface{}{"deleted_at": nil})
+}
+
+type gormBooks struct{ db *gorm.DB }
+
+func (r gormBooks) List(ctx context.Context, withDeleted bool) ([]models.Book, error) {
+	var books []models.Book
+	err := scoped(r.db.WithContext(ctx), withDeleted).Find(&books).Error
+	return books, err
+}
+
+func (r gormBooks) Get(ctx context.Context, id uint, withDeleted bool) (*models.Book, error) {
+	var book models.Book
+	if err := first(scoped(r.db.WithContext(ctx), withDeleted), &book, id); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (r gormBooks) FindByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	var book models.Book
+	if err := findOne(r.db.WithContext(ctx).Where("isbn = ?", isbn), &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (r gormBooks) Create(ctx context.Context, b *models.Book) error {
+	return r.db.WithContext(ctx).Create(b).Error
+}
+
+func (r gormBooks) Update(ctx context.Context, b *models.Book) (bool, error) {
+	return updateVersioned(r.db.WithContext(ctx), &models.Book{}, b.ID, b.Version, map[string]interface{}{
+		"title":  b.Title,
+		"author": b.Author,
+		"isbn":   b.ISBN,
+		"stock":  b.Stock,
+	})
+}
+
+func (r gormBooks) Delete(ctx context.Context, id uint) (bool, error) {
+	return softDelete(r.db.WithContext(ctx), &models.Book{}, id)
+}
+
+func (r gormBooks) Restore(ctx context.Context, id, version uint) (bool, error) {
+	return restore(r.db.WithContext(ctx), &models.Book{}, id, version)
+}
+
+func (r gormBooks) AdjustStock(ctx context.Context, id uint, delta int) (bool, error) {
+	q := r.db.WithContext(ctx).Unscoped().Model(&models.Book{}).Where("id = ?", id)
+	if delta < 0 {
+		// 条件更新，并发借书时不会把库存减成负数
+		q = q.Where("stock >= ?", -delta)
+	}
+	result := q.Updates(map[string]interface{}{
+		"stock":   gorm.Expr("stock + ?", delta),
+		"version": gorm.Expr("version + 1"),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormBooks) Lock(ctx context.Context, id uint) error {
+	return lockRow(r.db.WithContext(ctx), &models.Book{}, id)
+}
+
+type gormStudents struct{ db *gorm.DB }
+
+func (r gormStudents) List(ctx context.Context, withDeleted bool) ([]models.Student, error) {
+	var students []models.Student
+	err := scoped(r.db.WithContext(ctx), withDeleted).Find(&students).Error
+	return students, err
+}
+
+func (r gormStudents) Get(ctx context.Context, id uint, withDeleted bool) (*models.Student, error) {
+	var student models.Student
+	if err := first(scoped(r.db.WithContext(ctx), withDeleted), &student, id); err != nil {
+		return nil, err
+	}
+	return &student, nil
+}
+
+func (r gormStudents) GetWithLoans(ctx context.Context, id uint, withDeleted bool) (*models.Student, error) {
+	var student models.Student
+	if err := first(scoped(r.db.WithContext(ctx), withDeleted).Preload("Book_Student"), &student, id); err != nil {
+		return nil, err
+	}
+	return &student, nil
+}
+
+func (r gormStudents) FindByEmail(ctx context.Context, email string) (*models.Student, error) {
+	var student models.Student
+	if err := findOne(r.db.WithContext(ctx).Where("email = ?", email), &student); err != nil {
+		return nil, err
+	}
+	return &student, nil
+}
+
+func (r gormStudents) Create(ctx context.Context, s *models.Student) error {
+	return r.db.WithContext(ctx).Create(s).Error
+}
+
+func (r gormStudents) Update(ctx context.Context, s *models.Student) (bool, error) {
+	return updateVersioned(r.db.WithContext(ctx), &models.Student{}, s.ID, s.Version, map[string]interface{}{
+		"name":  s.Name,
+		"email": s.Email,
+	})
+}
+
+func (r gormStudents) Delete(ctx context.Context, id uint) (bool, error) {
+	return softDelete(r.db.WithContext(ctx), &models.Student{}, id)
+}
+
+func (r gormStudents) Restore(ctx context.Context, id, version uint) (bool, error) {
+	return restore(r.db.WithContext(ctx), &models.Student{}, id, version)
+}
+
+func (r gormStudents) Lock(ctx context.Context, id uint) error {
+	return lockRow(r.db.WithContext(ctx), &models.Student{}, id)
+}
+
+type gormLoans struct{ db *gorm.DB }
+
+// where 把筛选条件转换成查询条件
+func (f LoanFilter) where(db *gorm.DB) *gorm.DB {
+	if f.BookID != 0 {
+		db = db.Where("book_id = ?", f.BookID)
+	}
+	if f.StudentID != 0 {
+		db = db.Where("student_id = ?", f.StudentID)
+	}
+	return db
+}
+
+// column 归档 / 删除已结束借阅时的筛选列，只支持按图书或按学生之一
+func (f LoanFilter) column() (string, uint, error) {
+	switch {
+	case f.BookID != 0 && f.StudentID == 0:
+		return "book_id", f.BookID, nil
+	case f.StudentID != 0 && f.BookID == 0:
+		return "student_id", f.StudentID, nil
+	}
+	return "", 0, errors.New("loan filter needs exactly one of BookID and StudentID")
+}
+
+func (r gormLoans) Create(ctx context.Context, l *models.Book_Student) error {
+	return r.db.WithContext(ctx).Create(l).Error
+}
+
+func (r gormLoans) FindActive(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error) {
+	var loan models.Book_Student
+	err := r.db.WithContext(ctx).
+		Where("student_id = ? AND book_id = ? AND status = ?", studentID, bookID, models.BorrowStatusBorrowed).
+		First(&loan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+func (r gormLoans) ListActive(ctx context.Context, f LoanFilter) ([]models.Book_Student, error) {
+	var loans []models.Book_Student
+	err := f.where(r.db.WithContext(ctx)).Where("status = ?", models.BorrowStatusBorrowed).Find(&loans).Error
+	return loans, err
+}
+
+func (r gormLoans) MarkReturned(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Book_Student{}).
+		Where("id = ? AND status = ?", id, models.BorrowStatusBorrowed).
+		Updates(map[string]interface{}{"status": models.BorrowStatusReturned, "returned_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormLoans) Lock(ctx context.Context, id uint) error {
+	return lockRow(r.db.WithContext(ctx), &models.Book_Student{}, id)
+}
+
+func (r gormLoans) ArchiveClosed(ctx context.Context, f LoanFilter) error {
+	column, id, err := f.column()
+	if err != nil {
+		return err
+	}
+	return models.ArchiveClosedLoans(r.db.WithContext(ctx), column, []uint{id})
+}
+
+func (r gormLoans) DeleteClosed(ctx context.Context, f LoanFilter) error {
+	column, id, err := f.column()
+	if err != nil {
+		return err
+	}
+	return models.DeleteClosedLoans(r.db.WithContext(ctx), column, []uint{id})
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+// NewGormUsers 基于 GORM 的用户仓储
+func NewGormUsers(db *gorm.DB) UserRepository {
+	return gormUsers{db}
+}
+
+func (r gormUsers) UpdateProfile(ctx context.Context, u *models.User) (bool, error) {
+	return updateVersioned(r.db.WithContext(ctx), &models.User{}, uint(u.ID), u.Version, map[string]interface{}{
+		"name": u.Name, "sex": u.Sex, "born_date": u.BornDate, "avatar_url": u.AvatarURL,
+	})
+}
+
+func (r gormUsers) Restore(ctx context.Context, id, version uint) (bool, error) {
+	return restore(r.db.WithContext(ctx), &models.User{}, id, version)
+}
+
+type gormOutbox struct{ db *gorm.DB }
+
+func (r gormOutbox) Append(ctx context.Context, e *models.OutboxEvent) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"trae-go/models"
+
+	"gorm.io/gorm"
+)
+
+// Memory 内存仓储，行为与 GORM 实现一致（软删除、版本号、条件扣库存），用于测试 service 和 handler。
+// 事务期间持有全局锁，fn 返回错误时恢复到事务开始前的快照
+type Memory struct {
+	memView
+}
+
+// NewMemory 创建空的内存仓储
+func NewMemory() *Memory {
+	return &Memory{memView{db: &memDB{memTables: memTables{
+		books:    map[uint]models.Book{},
+		students: map[uint]models.Student{},
+		loans:    map[uint]models.Book_Student{},
+	}}}}
+}
+
+// Archives 已归档的借阅记录
+func (m *Memory) Archives() []models.LoanArchive {
+	defer m.lock()()
+	return slices.Clone(m.db.archives)
+}
+
+// Events 已写入 outbox 的领域事件
+func (m *Memory) Events() []models.OutboxEvent {
+	defer m.lock()()
+	return slices.Clone(m.db.events)
+}
+
+// memDB 内存中的表和保护它们的锁
+type memDB struct {
+	mu sync.Mutex
+	memTables
+}
+
+type memTables struct {
+	books    map[uint]models.Book
+	students map[uint]models.Student
+	loans    map[uint]models.Book_Student
+	archives []models.LoanArchive
+	events   []models.OutboxEvent
+	lastID   struct{ book, student, loan, archive, event uint }
+}
+
+// clone 事务开始前的快照，回滚时整体换回
+func (t memTables) clone() memTables {
+	t.books, t.students, t.loans = maps.Clone(t.books), maps.Clone(t.students), maps.Clone(t.loans)
+	t.archives, t.events = slices.Clone(t.archives), slices.Clone(t.events)
+	return t
+}
+
+// memView 事务外的视图每次操作加锁；事务内的视图由 Transaction 统一持有锁
+type memView struct {
+	db   *memDB
+	inTx bool
+}
+
+func (v *memView) lock() func() {
+	if v.inTx {
+		return func() {}
+	}
+	v.db.mu.Lock()
+	return v.db.mu.Unlock
+}
+
+func (v *memView) Books() BookRepository       { return memBooks{v} }
+func (v *memView) Students() StudentRepository { return memStudents{v} }
+func (v *memView) Loans() LoanRepository       { return memLoans{v} }
+func (v *memView) Outbox() OutboxRepository    { return memOutbox{v} }
+
+func (v *memView) Transaction(ctx context.Context, fn func(r Repositories) error) error {
+	if v.inTx {
+		return fn(v)
+	}
+	v.db.mu.Lock()
+	defer v.db.mu.Unlock()
+	snap := v.db.memTables.clone()
+	if err := fn(&memView{db: v.db, inTx: true}); err != nil {
+		v.db.memTables = snap
+		return err
+	}
+	return nil
+}
+
+// sortedValues 按 ID 排序返回 map 中满足 keep 的记录
+func sortedValues[T any](m map[uint]T, keep func(T) bool) []T {
+	ids := slices.Sorted(maps.Keys(m))
+	out := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if keep(m[id]) {
+			out = append(out, m[id])
+		}
+	}
+	return out
+}
+
+func deletedAt(now time.Time) gorm.DeletedAt {
+	return gorm.DeletedAt{Time: now, Valid: true}
+}
+
+type memBooks struct{ v *memView }
+
+func (r memBooks) List(ctx context.Context, withDeleted bool) ([]models.Book, error) {
+	defer r.v.lock()()
+	return sortedValues(r.v.db.books, func(b models.Book) bool { return withDeleted || !b.DeletedAt.Valid }), nil
+}
+
+func (r memBooks) Get(ctx context.Context, id uint, withDeleted bool) (*models.Book, error) {
+	defer r.v.lock()()
+	b, ok := r.v.db.books[id]
+	if !ok || (b.DeletedAt.Valid && !withDeleted) {
+		return nil, ErrNotFound
+	}
+	return &b, nil
+}
+
+func (r memBooks) Create(ctx context.Context, b *models.Book) error {
+	defer r.v.lock()()
+	db := r.v.db
+	db.lastID.book++
+	now := time.Now()
+	b.ID, b.CreatedAt, b.UpdatedAt = db.lastID.book, now, now
+	if b.Version == 0 {
+		b.Version = 1
+	}
+	stored := *b
+	stored.Book_Students, stored.Copies = nil, nil
+	db.books[b.ID] = stored
+	return nil
+}
+
+func (r memBooks) Update(ctx context.Context, b *models.Book) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.books[b.ID]
+	if !ok || cur.DeletedAt.Valid || cur.Version != b.Version {
+		return false, nil
+	}
+	cur.Title, cur.Author, cur.ISBN, cur.Stock = b.Title, b.Author, b.ISBN, b.Stock
+	cur.Version++
+	cur.UpdatedAt = time.Now()
+	r.v.db.books[b.ID] = cur
+	return true, nil
+}
+
+func (r memBooks) Delete(ctx context.Context, id uint) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.books[id]
+	if !ok || cur.DeletedAt.Valid {
+		return false, nil
+	}
+	cur.DeletedAt = deletedAt(time.Now())
+	r.v.db.books[id] = cur
+	return true, nil
+}
+
+func (r memBooks) Restore(ctx context.Context, id, version uint) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.books[id]
+	if !ok || !cur.DeletedAt.Valid || cur.Version != version {
+		return false, nil
+	}
+	cur.DeletedAt = gorm.DeletedAt{}
+	cur.Version++
+	cur.UpdatedAt = time.Now()
+	r.v.db.books[id] = cur
+	return true, nil
+}
+
+func (r memBooks) AdjustStock(ctx context.Context, id uint, delta int) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.books[id]
+	if !ok || (delta < 0 && cur.Stock < uint(-delta)) {
+		return false, nil
+	}
+	cur.Stock = uint(int(cur.Stock) + delta)
+	cur.Version++
+	cur.UpdatedAt = time.Now()
+	r.v.db.books[id] = cur
+	return true, nil
+}
+
+type memStudents struct{ v *memView }
+
+func (r memStudents) List(ctx context.Context, withDeleted bool) ([]models.Student, error) {
+	defer r.v.lock()()
+	return sortedValues(r.v.db.students, func(s models.Student) bool { return withDeleted || !s.DeletedAt.Valid }), nil
+}
+
+func (r memBooks) FindByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	defer r.v.lock()()
+	found := sortedValues(r.v.db.books, func(b models.Book) bool { return !b.DeletedAt.Valid && b.ISBN == isbn })
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+	return &found[0], nil
+}
+
+// Lock 内存仓储的事务持有整个库的锁，这里只检查记录是否存在
+func (r memBooks) Lock(ctx context.Context, id uint) error {
+	_, err := r.Get(ctx, id, false)
+	return err
+}
+
+func (r memStudents) get(id uint, withDeleted bool) (*models.Student, error) {
+	s, ok := r.v.db.students[id]
+	if !ok || (s.DeletedAt.Valid && !withDeleted) {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (r memStudents) Get(ctx context.Context, id uint, withDeleted bool) (*models.Student, error) {
+	defer r.v.lock()()
+	return r.get(id, withDeleted)
+}
+
+func (r memStudents) FindByEmail(ctx context.Context, email string) (*models.Student, error) {
+	defer r.v.lock()()
+	found := sortedValues(r.v.db.students, func(s models.Student) bool { return !s.DeletedAt.Valid && s.Email == email })
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r memStudents) GetWithLoans(ctx context.Context, id uint, withDeleted bool) (*models.Student, error) {
+	defer r.v.lock()()
+	s, err := r.get(id, withDeleted)
+	if err != nil {
+		return nil, err
+	}
+	s.Book_Student = sortedValues(r.v.db.loans, func(l models.Book_Student) bool { return l.StudentID == id })
+	return s, nil
+}
+
+func (r memStudents) Create(ctx context.Context, s *models.Student) error {
+	defer r.v.lock()()
+	db := r.v.db
+	db.lastID.student++
+	now := time.Now()
+	s.ID, s.CreatedAt, s.UpdatedAt = db.lastID.student, now, now
+	if s.Version == 0 {
+		s.Version = 1
+	}
+	stored := *s
+	stored.Book_Student = nil
+	db.students[s.ID] = stored
+	return nil
+}
+
+func (r memStudents) Update(ctx context.Context, s *models.Student) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.students[s.ID]
+	if !ok || cur.DeletedAt.Valid || cur.Version != s.Version {
+		return false, nil
+	}
+	cur.Name, cur.Email = s.Name, s.Email
+	cur.Version++
+	cur.UpdatedAt = time.Now()
+	r.v.db.students[s.ID] = cur
+	return true, nil
+}
+
+func (r memStudents) Delete(ctx context.Context, id uint) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.students[id]
+	if !ok || cur.DeletedAt.Valid {
+		return false, nil
+	}
+	cur.DeletedAt = deletedAt(time.Now())
+	r.v.db.students[id] = cur
+	return true, nil
+}
+
+func (r memStudents) Restore(ctx context.Context, id, version uint) (bool, error) {
+	defer r.v.lock()()
+	cur, ok := r.v.db.students[id]
+	if !ok || !cur.DeletedAt.Valid || cur.Version != version {
+		return false, nil
+	}
+	cur.DeletedAt = gorm.DeletedAt{}
+	cur.Version++
+	cur.UpdatedAt = time.Now()
+	r.v.db.students[id] = cur
+	return true, nil
+}
+
+func (r memStudents) Lock(ctx context.Context, id uint) error {
+	_, err := r.Get(ctx, id, false)
+	return err
+}
+
+type memLoans struct{ v *memView }
+
+// match 借阅记录是否满足筛选条件
+func (f LoanFilter) match(l models.Book_Student) bool {
+	return (f.BookID == 0 || l.BookID == f.BookID) && (f.StudentID == 0 || l.StudentID == f.StudentID)
+}
+
+func (r memLoans) Create(ctx context.Context, l *models.Book_Student) error {
+	defer r.v.lock()()
+	db := r.v.db
+	db.lastID.loan++
+	l.ID = db.lastID.loan
+	db.loans[l.ID] = *l
+	return nil
+}
+
+func (r memLoans) FindActive(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error) {
+	defer r.v.lock()()
+	active := sortedValues(r.v.db.loans, func(l models.Book_Student) bool {
+		return l.StudentID == studentID && l.BookID == bookID && l.Status == models.BorrowStatusBorrowed
+	})
+	if len(active) == 0 {
+		return nil, ErrNotFound
+	}
+	return &active[0], nil
+}
+
+func (r memLoans) ListActive(ctx context.Context, f LoanFilter) ([]models.Book_Student, error) {
+	defer r.v.lock()()
+	return sortedValues(r.v.db.loans, func(l models.Book_Student) bool {
+		return f.match(l) && l.Status == models.BorrowStatusBorrowed
+	}), nil
+}
+
+func (r memLoans) MarkReturned(ctx context.Context, id uint, at time.Time) (bool, error) {
+	defer r.v.lock()()
+	l, ok := r.v.db.loans[id]
+	if !ok || l.Status != models.BorrowStatusBorrowed {
+		return false, nil
+	}
+	l.Status, l.ReturnedAt = models.BorrowStatusReturned, at
+	r.v.db.loans[id] = l
+	return true, nil
+}
+
+func (r memLoans) Lock(ctx context.Context, id uint) error {
+	defer r.v.lock()()
+	if _, ok := r.v.db.loans[id]; !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// closed 满足筛选条件的已结束借阅，与 GORM 实现一样只支持按图书或按学生之一
+func (r memLoans) closed(f LoanFilter) ([]models.Book_Student, error) {
+	if _, _, err := f.column(); err != nil {
+		return nil, err
+	}
+	return sortedValues(r.v.db.loans, func(l models.Book_Student) bool {
+		return f.match(l) && l.Status != models.BorrowStatusBorrowed
+	}), nil
+}
+
+func (r memLoans) ArchiveClosed(ctx context.Context, f LoanFilter) error {
+	defer r.v.lock()()
+	loans, err := r.closed(f)
+	if err != nil {
+		return err
+	}
+	db := r.v.db
+	now := time.Now()
+	for _, l := range loans {
+		db.lastID.archive++
+		db.archives = append(db.archives, models.LoanArchive{
+			ID:         db.lastID.archive,
+			LoanID:     l.ID,
+			BookID:     l.BookID,
+			StudentID:  l.StudentID,
+			BorrowedAt: l.BorrowedAt,
+			ReturnedAt: l.ReturnedAt,
+			Status:     l.Status,
+			ArchivedAt: now,
+		})
+		delete(db.loans, l.ID)
+	}
+	return nil
+}
+
+func (r memLoans) DeleteClosed(ctx context.Context, f LoanFilter) error {
+	defer r.v.lock()()
+	loans, err := r.closed(f)
+	if err != nil {
+		return err
+	}
+	for _, l := range loans {
+		delete(r.v.db.loans, l.ID)
+	}
+	return nil
+}
+
+type memOutbox struct{ v *memView }
+
+func (r memOutbox) Append(ctx context.Context, e *models.OutboxEvent) error {
+	defer r.v.lock()()
+	db := r.v.db
+	db.lastID.event++
+	e.ID = db.lastID.event
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	db.events = append(db.events, *e)
+	return nil
+}
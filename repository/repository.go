@@ -0,0 +1,102 @@
+// Package repository 图书、学生和借阅记录的持久化接口。
+// GORM 实现（NewGorm）用于服务运行，内存实现（NewMemory）用于测试；业务规则在 service 包中。
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"trae-go/models"
+)
+
+// ErrNotFound 记录不存在（或已软删除且没有要求包含已删除记录）
+var ErrNotFound = errors.New("record not found")
+
+// Repositories 一组共享同一个连接（或事务）的仓储
+type Repositories interface {
+	Books() BookRepository
+	Students() StudentRepository
+	Loans() LoanRepository
+	Outbox() OutboxRepository
+	// Transaction 在一个事务中执行 fn，fn 内必须通过参数 r 访问数据；fn 返回错误时回滚
+	Transaction(ctx context.Context, fn func(r Repositories) error) error
+}
+
+// BookRepository 图书
+type BookRepository interface {
+	// List 返回全部图书，withDeleted 为 true 时包含已软删除的
+	List(ctx context.Context, withDeleted bool) ([]models.Book, error)
+	// Get 按 ID 查询，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id uint, withDeleted bool) (*models.Book, error)
+	// FindByISBN 按 ISBN 查询未删除的图书，有多本时返回 ID 最小的，不存在时返回 ErrNotFound
+	FindByISBN(ctx context.Context, isbn string) (*models.Book, error)
+	Create(ctx context.Context, b *models.Book) error
+	// Update 版本号仍为 b.Version 时写入 title、author、isbn、stock 并把版本号加一，
+	// 返回 false 表示记录不存在或期间被修改过
+	Update(ctx context.Context, b *models.Book) (bool, error)
+	// Delete 软删除，返回 false 表示记录不存在
+	Delete(ctx context.Context, id uint) (bool, error)
+	// Restore 版本号仍为 version 时恢复已软删除的图书
+	Restore(ctx context.Context, id, version uint) (bool, error)
+	// AdjustStock 库存加 delta 并把版本号加一，已软删除的书也会调整（归还时需要）。
+	// 返回 false 表示图书不存在，或 delta 为负时库存不足
+	AdjustStock(ctx context.Context, id uint, delta int) (bool, error)
+	// Lock 在事务中锁住未删除的图书直到事务结束，并发的借书和删除因此串行执行。
+	// 不存在或已软删除时返回 ErrNotFound
+	Lock(ctx context.Context, id uint) error
+}
+
+// StudentRepository 学生
+type StudentRepository interface {
+	List(ctx context.Context, withDeleted bool) ([]models.Student, error)
+	Get(ctx context.Context, id uint, withDeleted bool) (*models.Student, error)
+	// GetWithLoans 同 Get，同时加载该学生的全部借阅记录
+	GetWithLoans(ctx context.Context, id uint, withDeleted bool) (*models.Student, error)
+	// FindByEmail 按邮箱查询未删除的学生，同 BookRepository.FindByISBN
+	FindByEmail(ctx context.Context, email string) (*models.Student, error)
+	Create(ctx context.Context, s *models.Student) error
+	// Update 版本号仍为 s.Version 时写入 name、email 并把版本号加一
+	Update(ctx context.Context, s *models.Student) (bool, error)
+	Delete(ctx context.Context, id uint) (bool, error)
+	Restore(ctx context.Context, id, version uint) (bool, error)
+	// Lock 同 BookRepository.Lock
+	Lock(ctx context.Context, id uint) error
+}
+
+// LoanFilter 按图书或学生筛选借阅记录，为 0 的字段不作为条件
+type LoanFilter struct {
+	BookID    uint
+	StudentID uint
+}
+
+// LoanRepository 借阅记录（book_students）
+type LoanRepository interface {
+	Create(ctx context.Context, l *models.Book_Student) error
+	// FindActive 查询学生对某本书未归还的借阅，不存在时返回 ErrNotFound
+	FindActive(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error)
+	// ListActive 未归还的借阅
+	ListActive(ctx context.Context, f LoanFilter) ([]models.Book_Student, error)
+	// MarkReturned 借阅仍未归还时标记为已归还，返回 false 表示不存在或已经归还
+	MarkReturned(ctx context.Context, id uint, at time.Time) (bool, error)
+	// Lock 同 BookRepository.Lock，并发归还同一笔借阅因此串行执行
+	Lock(ctx context.Context, id uint) error
+	// ArchiveClosed 把已结束的借阅移到 loan_archives
+	ArchiveClosed(ctx context.Context, f LoanFilter) error
+	// DeleteClosed 直接删除已结束的借阅
+	DeleteClosed(ctx context.Context, f LoanFilter) error
+}
+
+// UserRepository 用户的写入，只有 GORM 实现（NewGormUsers），不在 Repositories 中，
+// 也不受内存仓储影响；注册和登录的查询仍在 handler 中
+type UserRepository interface {
+	// UpdateProfile 版本号仍为 u.Version 时写入 name、sex、born_date、avatar_url 并把版本号加一
+	UpdateProfile(ctx context.Context, u *models.User) (bool, error)
+	// Restore 同 BookRepository.Restore
+	Restore(ctx context.Context, id, version uint) (bool, error)
+}
+
+// OutboxRepository 领域事件 outbox，应在与数据修改相同的事务中调用
+type OutboxRepository interface {
+	Append(ctx context.Context, e *models.OutboxEvent) error
+}
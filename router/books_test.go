@@ -0,0 +1,182 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestBooks(t *testing.T) {
+	e := testutil.New(t)
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+
+	book := e.CreateBook(testutil.Title("Go"), testutil.Stock(3))
+	deleted := e.CreateBook()
+	e.SoftDelete(deleted)
+	toUpdate, toPatch, toDelete, toRestore := e.CreateBook(), e.CreateBook(), e.CreateBook(), e.CreateBook()
+	e.SoftDelete(toRestore)
+
+	borrowed, withHistory, withCascade := e.CreateBook(), e.CreateBook(), e.CreateBook()
+	student := e.CreateStudent()
+	e.CreateLoan(student, borrowed)
+	e.CreateLoan(student, withHistory, testutil.Returned)
+	e.CreateLoan(student, withCascade, testutil.Returned)
+
+	path := func(b *models.Book) string { return fmt.Sprintf("/api/v1/books/%d", b.ID) }
+	mergePatch := testutil.Header("Content-Type", "application/merge-patch+json")
+	listLen := func(want int) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, res *httptest.ResponseRecorder) {
+			var books []models.Book
+			testutil.Decode(t, res, &books)
+			if len(books) != want {
+				t.Fatalf("len = %d, want %d", len(books), want)
+			}
+		}
+	}
+
+	runCases(t, e, []apiCase{
+		{name: "list requires login", method: "GET", path: "/api/v1/books", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "list hides deleted", method: "GET", path: "/api/v1/books", opts: opts(reader), status: http.StatusOK, check: listLen(7)},
+		{name: "list include_deleted as student", method: "GET", path: "/api/v1/books?include_deleted=true", opts: opts(reader),
+			status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "list include_deleted as admin", method: "GET", path: "/api/v1/books?include_deleted=true", opts: opts(admin),
+			status: http.StatusOK, check: listLen(9)},
+
+		{name: "get", method: "GET", path: path(book), opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var b models.Book
+				testutil.Decode(t, res, &b)
+				if b.Title != "Go" || b.Stock != 3 {
+					t.Fatalf("got %+v", b)
+				}
+				if res.Header().Get("ETag") != `"1"` {
+					t.Fatalf("ETag = %q", res.Header().Get("ETag"))
+				}
+			}},
+		{name: "get not modified", method: "GET", path: path(book), opts: opts(reader, testutil.Header("If-None-Match", `W/"1"`)),
+			status: http.StatusNotModified},
+		{name: "get invalid id", method: "GET", path: "/api/v1/books/abc", opts: opts(reader), status: http.StatusBadRequest, code: "INVALID_ID"},
+		{name: "get missing", method: "GET", path: "/api/v1/books/9999", opts: opts(reader), status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "get deleted", method: "GET", path: path(deleted), opts: opts(reader), status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "get deleted as admin", method: "GET", path: path(deleted) + "?include_deleted=true", opts: opts(admin), status: http.StatusOK},
+
+		{name: "create", method: "POST", path: "/api/v1/books", opts: opts(reader),
+			body: map[string]interface{}{"title": "Rust", "author": "Klabnik", "isbn": "9781718500440", "stock": 2}, status: http.StatusCreated,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var b models.Book
+				testutil.Decode(t, res, &b)
+				if b.ID == 0 || b.Title != "Rust" || b.Version != 1 {
+					t.Fatalf("got %+v", b)
+				}
+			}},
+		{name: "create missing title", method: "POST", path: "/api/v1/books", opts: opts(reader),
+			body: map[string]interface{}{"author": "A"}, status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "create invalid isbn", method: "POST", path: "/api/v1/books", opts: opts(reader),
+			body: map[string]interface{}{"title": "T", "isbn": "123"}, status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "create invalid json", method: "POST", path: "/api/v1/books", opts: opts(reader),
+			body: "{", status: http.StatusBadRequest, code: "INVALID_JSON"},
+
+		{name: "put requires If-Match", method: "PUT", path: path(toUpdate), opts: opts(reader),
+			body: map[string]interface{}{"title": "New"}, status: http.StatusPreconditionRequired, code: "PRECONDITION_REQUIRED"},
+		{name: "put stale ETag", method: "PUT", path: path(toUpdate), opts: opts(reader, testutil.Header("If-Match", `"7"`)),
+			body: map[string]interface{}{"title": "New"}, status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED"},
+		{name: "put missing", method: "PUT", path: "/api/v1/books/9999", opts: opts(reader, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"title": "New"}, status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "put read-only stock", method: "PUT", path: path(toUpdate), opts: opts(reader, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"title": "New", "stock": 5}, status: http.StatusUnprocessableEntity, code: "FIELD_READ_ONLY"},
+		{name: "put", method: "PUT", path: path(toUpdate), opts: opts(reader, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"title": "New", "stock": 1}, status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var b models.Book
+				testutil.Decode(t, res, &b)
+				if b.Title != "New" || b.Stock != 1 || b.Author != "" || res.Header().Get("ETag") != `"2"` {
+					t.Fatalf("got %+v, ETag %q", b, res.Header().Get("ETag"))
+				}
+			}},
+
+		{name: "patch", method: "PATCH", path: path(toPatch), opts: opts(reader, mergePatch, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"title": "Patched"}, status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var b models.Book
+				testutil.Decode(t, res, &b)
+				if b.Title != "Patched" || b.Author != "Author" || b.Stock != 1 {
+					t.Fatalf("got %+v", b)
+				}
+			}},
+		{name: "patch read-only stock", method: "PATCH", path: path(toPatch), opts: opts(reader, mergePatch, testutil.Header("If-Match", `"2"`)),
+			body: map[string]interface{}{"stock": 99}, status: http.StatusUnprocessableEntity, code: "FIELD_READ_ONLY"},
+		{name: "patch unknown field", method: "PATCH", path: path(toPatch), opts: opts(reader, mergePatch, testutil.Header("If-Match", `"2"`)),
+			body: map[string]interface{}{"color": "red"}, status: http.StatusBadRequest, code: "UNKNOWN_FIELD"},
+		{name: "patch json-patch test fails", method: "PATCH", path: path(toPatch),
+			opts: opts(reader, testutil.Header("Content-Type", "application/json-patch+json"), testutil.Header("If-Match", `"2"`)),
+			body: `[{"op":"test","path":"/title","value":"Other"}]`, status: http.StatusConflict, code: "PATCH_TEST_FAILED"},
+		{name: "patch unsupported content type", method: "PATCH", path: path(toPatch),
+			opts: opts(reader, testutil.Header("Content-Type", "text/plain"), testutil.Header("If-Match", `"2"`)),
+			body: "title", status: http.StatusUnsupportedMediaType, code: "UNSUPPORTED_PATCH_TYPE"},
+
+		{name: "delete invalid history", method: "DELETE", path: path(toDelete) + "?history=burn", opts: opts(reader),
+			status: http.StatusBadRequest, code: "INVALID_HISTORY_MODE"},
+		{name: "delete", method: "DELETE", path: path(toDelete), opts: opts(reader), status: http.StatusNoContent},
+		{name: "delete twice", method: "DELETE", path: path(toDelete), opts: opts(reader), status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "delete with active loan", method: "DELETE", path: path(borrowed), opts: opts(reader), status: http.StatusConflict, code: "CONFLICT",
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var p struct {
+					Details struct {
+						ActiveLoans []models.Book_Student `json:"active_loans"`
+					} `json:"details"`
+				}
+				testutil.Decode(t, res, &p)
+				if len(p.Details.ActiveLoans) != 1 || p.Details.ActiveLoans[0].StudentID != student.ID {
+					t.Fatalf("active_loans = %+v", p.Details.ActiveLoans)
+				}
+			}},
+		{name: "archive history requires admin", method: "DELETE", path: path(withHistory) + "?history=archive", opts: opts(reader),
+			status: http.StatusForbidden, code: "FORBIDDEN", check: func(t *testing.T, _ *httptest.ResponseRecorder) {
+				requireCount(t, e, &models.Book_Student{}, "book_id = ?", withHistory.ID, 1)
+			}},
+		{name: "cascade history requires admin", method: "DELETE", path: path(withCascade) + "?history=cascade", opts: opts(reader),
+			status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "delete archives history", method: "DELETE", path: path(withHistory) + "?history=archive", opts: opts(admin),
+			status: http.StatusNoContent, check: func(t *testing.T, _ *httptest.ResponseRecorder) {
+				requireCount(t, e, &models.LoanArchive{}, "book_id = ?", withHistory.ID, 1)
+				requireCount(t, e, &models.Book_Student{}, "book_id = ?", withHistory.ID, 0)
+			}},
+		{name: "delete cascades history", method: "DELETE", path: path(withCascade) + "?history=cascade", opts: opts(admin),
+			status: http.StatusNoContent, check: func(t *testing.T, _ *httptest.ResponseRecorder) {
+				requireCount(t, e, &models.LoanArchive{}, "book_id = ?", withCascade.ID, 0)
+				requireCount(t, e, &models.Book_Student{}, "book_id = ?", withCascade.ID, 0)
+			}},
+
+		{name: "restore as student", method: "POST", path: path(toRestore) + "/restore", opts: opts(reader),
+			status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "restore", method: "POST", path: path(toRestore) + "/restore", opts: opts(admin), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var b models.Book
+				testutil.Decode(t, res, &b)
+				if b.DeletedAt.Valid || res.Header().Get("ETag") != `"2"` {
+					t.Fatalf("got %+v, ETag %q", b, res.Header().Get("ETag"))
+				}
+			}},
+		{name: "restore not deleted", method: "POST", path: path(toRestore) + "/restore", opts: opts(admin),
+			status: http.StatusConflict, code: "BOOK_NOT_DELETED"},
+		{name: "restore missing", method: "POST", path: "/api/v1/books/9999/restore", opts: opts(admin),
+			status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+	})
+}
+
+// requireCount 断言 model 表中满足条件的行数（包括软删除的）
+func requireCount(t *testing.T, e *testutil.Env, model interface{}, query string, arg interface{}, want int64) {
+	t.Helper()
+	var n int64
+	if err := e.DB.Unscoped().Model(model).Where(query, arg).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Fatalf("count %T where %s = %d, want %d", model, query, n, want)
+	}
+}
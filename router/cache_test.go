@@ -0,0 +1,131 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"trae-go/models"
+	"trae-go/service"
+	"trae-go/testutil"
+)
+
+func TestBookCache(t *testing.T) {
+	e := testutil.New(t, testutil.Cache)
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+	student := e.CreateStudent()
+	book := e.CreateBook(testutil.Title("Go"), testutil.Stock(2))
+	path := fmt.Sprintf("/api/v1/books/%d", book.ID)
+
+	get := func(t *testing.T) models.Book {
+		t.Helper()
+		res := e.Do("GET", path, nil, reader)
+		testutil.RequireStatus(t, res, http.StatusOK)
+		var b models.Book
+		testutil.Decode(t, res, &b)
+		return b
+	}
+	list := func(t *testing.T) []models.Book {
+		t.Helper()
+		res := e.Do("GET", "/api/v1/books", nil, reader)
+		testutil.RequireStatus(t, res, http.StatusOK)
+		var books []models.Book
+		testutil.Decode(t, res, &books)
+		return books
+	}
+	// bypass 绕过服务直接改数据库，缓存不会知道
+	bypass := func(t *testing.T, column string, value interface{}) {
+		t.Helper()
+		if err := e.DB.Model(&models.Book{}).Where("id = ?", book.ID).UpdateColumn(column, value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("reads are cached", func(t *testing.T) {
+		get(t)
+		list(t)
+		bypass(t, "title", "Bypassed")
+		if b := get(t); b.Title != "Go" {
+			t.Fatalf("title = %q, want cached Go", b.Title)
+		}
+		if books := list(t); len(books) != 1 || books[0].Title != "Go" {
+			t.Fatalf("list = %+v, want cached", books)
+		}
+	})
+
+	t.Run("update invalidates", func(t *testing.T) {
+		res := e.Do("PUT", path, map[string]interface{}{"title": "Go 2", "stock": 2}, reader, testutil.Header("If-Match", `"1"`))
+		testutil.RequireStatus(t, res, http.StatusOK)
+		if b := get(t); b.Title != "Go 2" || b.Version != 2 {
+			t.Fatalf("got %+v", b)
+		}
+		if books := list(t); books[0].Title != "Go 2" {
+			t.Fatalf("list = %+v", books)
+		}
+	})
+
+	t.Run("borrow and return invalidate", func(t *testing.T) {
+		testutil.RequireStatus(t, e.Do("POST", borrowPath(student, book), nil, reader), http.StatusOK)
+		if b := get(t); b.Stock != 1 {
+			t.Fatalf("stock after borrow = %d, want 1", b.Stock)
+		}
+		if books := list(t); books[0].Stock != 1 {
+			t.Fatalf("list stock after borrow = %d, want 1", books[0].Stock)
+		}
+		testutil.RequireStatus(t, e.Do("POST", returnPath(student, book), nil, reader), http.StatusOK)
+		if b := get(t); b.Stock != 2 {
+			t.Fatalf("stock after return = %d, want 2", b.Stock)
+		}
+	})
+
+	t.Run("version conflict invalidates stale entry", func(t *testing.T) {
+		cached := get(t)
+		bypass(t, "version", 10)
+		res := e.Do("PUT", path, map[string]interface{}{"title": "Stale"}, reader,
+			testutil.Header("If-Match", fmt.Sprintf(`"%d"`, cached.Version)))
+		testutil.RequireProblem(t, res, http.StatusPreconditionFailed, "PRECONDITION_FAILED")
+		if b := get(t); b.Version != 10 {
+			t.Fatalf("version = %d, want 10 after conflict", b.Version)
+		}
+	})
+
+	t.Run("delete invalidates", func(t *testing.T) {
+		testutil.RequireStatus(t, e.Do("DELETE", path, nil, reader), http.StatusNoContent)
+		testutil.RequireProblem(t, e.Do("GET", path, nil, reader), http.StatusNotFound, "BOOK_NOT_FOUND")
+		if books := list(t); len(books) != 0 {
+			t.Fatalf("list = %+v, want empty", books)
+		}
+	})
+
+	t.Run("create invalidates list", func(t *testing.T) {
+		res := e.Do("POST", "/api/v1/books", map[string]interface{}{"title": "New"}, reader)
+		testutil.RequireStatus(t, res, http.StatusCreated)
+		if books := list(t); len(books) != 1 || books[0].Title != "New" {
+			t.Fatalf("list = %+v", books)
+		}
+	})
+
+	t.Run("import invalidates", func(t *testing.T) {
+		imported := e.CreateBook(testutil.Title("Cached"), func(b *models.Book) { b.ISBN = "9780262510875" })
+		cachedPath := fmt.Sprintf("/api/v1/books/%d", imported.ID)
+		testutil.RequireStatus(t, e.Do("GET", cachedPath, nil, reader), http.StatusOK)
+		_, err := e.App.Books.Import(context.Background(), []service.BookInput{{Title: "Imported", ISBN: "9780262510875", Stock: 1}}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b models.Book
+		res := e.Do("GET", cachedPath, nil, reader)
+		testutil.Decode(t, res, &b)
+		if b.Title != "Imported" {
+			t.Fatalf("title = %q after import", b.Title)
+		}
+	})
+}
+
+func TestBookCacheDisabledWithMemoryStore(t *testing.T) {
+	e := testutil.New(t, testutil.Cache, testutil.MemoryStore)
+	if e.App.Cache != nil {
+		t.Fatal("cache enabled without redis")
+	}
+}
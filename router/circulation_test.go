@@ -0,0 +1,223 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func borrowPath(s *models.Student, b *models.Book) string {
+	return fmt.Sprintf("/api/v1/students/%d/books/%d/borrow", s.ID, b.ID)
+}
+
+func returnPath(s *models.Student, b *models.Book) string {
+	return fmt.Sprintf("/api/v1/students/%d/books/%d/return", s.ID, b.ID)
+}
+
+func TestCirculation(t *testing.T) {
+	e := testutil.New(t)
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+
+	student := e.CreateStudent()
+	book := e.CreateBook(testutil.Stock(2))
+	empty := e.CreateBook(testutil.Stock(0))
+	deletedBook := e.CreateBook()
+	e.SoftDelete(deletedBook)
+	deletedStudent := e.CreateStudent()
+	e.SoftDelete(deletedStudent)
+
+	// 借出后被删除的书也要能归还
+	lent := e.CreateBook(testutil.Stock(0))
+	e.CreateLoan(student, lent)
+	e.SoftDelete(lent)
+
+	stock := func(b *models.Book, want uint) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, _ *httptest.ResponseRecorder) {
+			got := &models.Book{ID: b.ID}
+			e.Reload(got)
+			if got.Stock != want {
+				t.Fatalf("stock = %d, want %d", got.Stock, want)
+			}
+		}
+	}
+	missingStudent := &models.Student{ID: 9999}
+	missingBook := &models.Book{ID: 9999}
+
+	runCases(t, e, []apiCase{
+		{name: "borrow requires login", method: "POST", path: borrowPath(student, book), status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "borrow", method: "POST", path: borrowPath(student, book), opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var l models.Book_Student
+				testutil.Decode(t, res, &l)
+				if l.ID == 0 || l.Status != models.BorrowStatusBorrowed || l.BookID != book.ID || l.StudentID != student.ID {
+					t.Fatalf("got %+v", l)
+				}
+				stock(book, 1)(t, res)
+			}},
+		{name: "borrow bumps ETag", method: "GET", path: fmt.Sprintf("/api/v1/books/%d", book.ID),
+			opts: opts(reader, testutil.Header("If-None-Match", `"1"`)), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				if res.Header().Get("ETag") != `"2"` {
+					t.Fatalf("ETag = %q", res.Header().Get("ETag"))
+				}
+			}},
+		{name: "borrow out of stock", method: "POST", path: borrowPath(student, empty), opts: opts(reader),
+			status: http.StatusBadRequest, code: "BOOK_OUT_OF_STOCK", check: stock(empty, 0)},
+		{name: "borrow deleted book", method: "POST", path: borrowPath(student, deletedBook), opts: opts(reader),
+			status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "borrow deleted student", method: "POST", path: borrowPath(deletedStudent, book), opts: opts(reader),
+			status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "borrow missing student", method: "POST", path: borrowPath(missingStudent, book), opts: opts(reader),
+			status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "borrow missing book", method: "POST", path: borrowPath(student, missingBook), opts: opts(reader),
+			status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "borrow invalid student id", method: "POST", path: "/api/v1/students/x/books/1/borrow", opts: opts(reader),
+			status: http.StatusBadRequest, code: "INVALID_STUDENT_ID"},
+		{name: "borrow invalid book id", method: "POST", path: "/api/v1/students/1/books/x/borrow", opts: opts(reader),
+			status: http.StatusBadRequest, code: "INVALID_BOOK_ID"},
+
+		{name: "list loans", method: "GET", path: fmt.Sprintf("/api/v1/students/%d/books", student.ID), opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var s models.Student
+				testutil.Decode(t, res, &s)
+				if len(s.Book_Student) != 2 {
+					t.Fatalf("loans = %+v", s.Book_Student)
+				}
+			}},
+		{name: "list loans of deleted student", method: "GET", path: fmt.Sprintf("/api/v1/students/%d/books", deletedStudent.ID),
+			opts: opts(reader), status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "list loans of deleted student as admin", method: "GET",
+			path: fmt.Sprintf("/api/v1/students/%d/books?include_deleted=true", deletedStudent.ID), opts: opts(admin), status: http.StatusOK},
+
+		{name: "return", method: "POST", path: returnPath(student, book), opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var l models.Book_Student
+				testutil.Decode(t, res, &l)
+				if l.Status != models.BorrowStatusReturned || l.ReturnedAt.IsZero() {
+					t.Fatalf("got %+v", l)
+				}
+				stock(book, 2)(t, res)
+			}},
+		{name: "return twice", method: "POST", path: returnPath(student, book), opts: opts(reader),
+			status: http.StatusNotFound, code: "BORROW_RECORD_NOT_FOUND", check: stock(book, 2)},
+		{name: "return never borrowed", method: "POST", path: returnPath(student, empty), opts: opts(reader),
+			status: http.StatusNotFound, code: "BORROW_RECORD_NOT_FOUND"},
+		{name: "return deleted book", method: "POST", path: returnPath(student, lent), opts: opts(reader),
+			status: http.StatusOK, check: stock(lent, 1)},
+	})
+}
+
+// TestBorrowLastCopy 并发借最后一本书，只有一个请求成功，库存不会变成负数
+func TestBorrowLastCopy(t *testing.T) {
+	e := testutil.New(t)
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+	book := e.CreateBook(testutil.Stock(1))
+
+	const n = 8
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		s := e.CreateStudent()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = e.Do("POST", borrowPath(s, book), nil, reader).Code
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, c := range codes {
+		switch c {
+		case http.StatusOK:
+			ok++
+		case http.StatusBadRequest:
+		default:
+			t.Fatalf("unexpected status %d in %v", c, codes)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d borrows succeeded, want 1: %v", ok, codes)
+	}
+	e.Reload(book)
+	if book.Stock != 0 {
+		t.Fatalf("stock = %d, want 0", book.Stock)
+	}
+	requireCount(t, e, &models.Book_Student{}, "book_id = ?", book.ID, 1)
+}
+
+// 借书和删除同时进行时，要么借书成功、删除因未归还的借阅被拒绝，要么删除成功、借书返回不存在，
+// 不会出现已删除的书上挂着未归还的借阅
+func TestBorrowWhileDeleting(t *testing.T) {
+	e := testutil.New(t)
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+
+	for range 20 {
+		s, b := e.CreateStudent(), e.CreateBook(testutil.Stock(1))
+		var borrow, del int
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			borrow = e.Do("POST", borrowPath(s, b), nil, reader).Code
+		}()
+		go func() {
+			defer wg.Done()
+			del = e.Do("DELETE", fmt.Sprintf("/api/v1/books/%d", b.ID), nil, reader).Code
+		}()
+		wg.Wait()
+
+		switch {
+		case borrow == http.StatusOK && del == http.StatusConflict:
+			requireCount(t, e, &models.Book_Student{}, "book_id = ?", b.ID, 1)
+		case borrow == http.StatusNotFound && del == http.StatusNoContent:
+			requireCount(t, e, &models.Book_Student{}, "book_id = ?", b.ID, 0)
+		default:
+			t.Fatalf("borrow = %d, delete = %d", borrow, del)
+		}
+	}
+}
+
+// 同一笔借阅并发归还时只有一个请求成功，库存只加一
+func TestReturnConcurrently(t *testing.T) {
+	e := testutil.New(t)
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+	s, b := e.CreateStudent(), e.CreateBook(testutil.Stock(0))
+	e.CreateLoan(s, b)
+
+	const n = 8
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = e.Do("POST", returnPath(s, b), nil, reader).Code
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, c := range codes {
+		switch c {
+		case http.StatusOK:
+			ok++
+		case http.StatusNotFound, http.StatusConflict:
+		default:
+			t.Fatalf("unexpected status %d in %v", c, codes)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d returns succeeded, want 1: %v", ok, codes)
+	}
+	e.Reload(b)
+	if b.Stock != 1 {
+		t.Fatalf("stock = %d, want 1", b.Stock)
+	}
+}
@@ -0,0 +1,42 @@
+package router_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"trae-go/testutil"
+)
+
+// apiCase 一个接口用例。依次执行，修改数据的用例应使用自己的 fixture，避免相互影响
+type apiCase struct {
+	name   string
+	method string
+	path   string
+	body   interface{}
+	opts   []testutil.RequestOption
+	status int
+	code   string                                             // 错误响应的 code，为空时不检查
+	check  func(t *testing.T, res *httptest.ResponseRecorder) // 额外的断言
+}
+
+func runCases(t *testing.T, e *testutil.Env, cases []apiCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := e.Do(tc.method, tc.path, tc.body, tc.opts...)
+			if tc.code != "" {
+				testutil.RequireProblem(t, res, tc.status, tc.code)
+			} else {
+				testutil.RequireStatus(t, res, tc.status)
+			}
+			if tc.check != nil {
+				tc.check(t, res)
+			}
+		})
+	}
+}
+
+// opts 简化用例中的请求选项列表
+func opts(o ...testutil.RequestOption) []testutil.RequestOption {
+	return o
+}
@@ -0,0 +1,100 @@
+package router_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/service"
+	"trae-go/testutil"
+)
+
+// 命令行的 import / seed 通过 service 批量导入
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	isbn := func(s string) testutil.BookOption { return func(b *models.Book) { b.ISBN = s } }
+	eventCount := func(t *testing.T, e *testutil.Env, typ string, id uint) int64 {
+		t.Helper()
+		var n int64
+		e.DB.Model(&models.OutboxEvent{}).Where("type = ? AND aggregate_id = ?", typ, id).Count(&n)
+		return n
+	}
+
+	t.Run("books", func(t *testing.T) {
+		e := testutil.New(t)
+		student := e.CreateStudent()
+		lent := e.CreateBook(testutil.Title("Go"), isbn("9780134190440"), testutil.Stock(1))
+		e.CreateLoan(student, lent)
+		same := e.CreateBook(testutil.Title("SICP"), isbn("9780262510875"), testutil.Stock(2))
+
+		res, err := e.App.Books.Import(ctx, []service.BookInput{
+			{Title: "The Go Programming Language", ISBN: "9780134190440", Stock: 3},
+			{Title: "SICP", Author: "Author", ISBN: "9780262510875", Stock: 2},
+			{Title: "DDIA", ISBN: "9781449373320", Stock: 1},
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Created != 1 || res.Updated != 1 || res.Unchanged != 1 {
+			t.Fatalf("result = %+v", res)
+		}
+		// 馆藏 3 本、借出 1 本，库存为 2，而不是直接写成 3
+		e.Reload(lent)
+		if lent.Stock != 2 || lent.Title != "The Go Programming Language" {
+			t.Fatalf("book = %+v", lent)
+		}
+		if eventCount(t, e, events.BookUpdated, lent.ID) != 1 || eventCount(t, e, events.BookUpdated, same.ID) != 0 {
+			t.Fatal("book.updated events not recorded")
+		}
+		requireCount(t, e, &models.OutboxEvent{}, "type = ?", events.BookCreated, 1)
+	})
+
+	t.Run("fewer copies than loans", func(t *testing.T) {
+		e := testutil.New(t)
+		lent := e.CreateBook(isbn("9780134190440"), testutil.Stock(0))
+		e.CreateLoan(e.CreateStudent(), lent)
+		e.CreateLoan(e.CreateStudent(), lent)
+
+		_, err := e.App.Books.Import(ctx, []service.BookInput{
+			{Title: "New", ISBN: "9781449373320", Stock: 1},
+			{Title: "Go", ISBN: "9780134190440", Stock: 1},
+		}, false)
+		if err == nil || !strings.Contains(err.Error(), "record 2") {
+			t.Fatalf("import = %v", err)
+		}
+		// 整批回滚
+		requireCount(t, e, &models.Book{}, "isbn = ?", "9781449373320", 0)
+		requireCount(t, e, &models.OutboxEvent{}, "aggregate_type = ?", "book", 0)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		e := testutil.New(t)
+		res, err := e.App.Students.Import(ctx, []service.StudentInput{{Name: "Alice", Email: "alice@example.com"}}, true)
+		if err != nil || res.Created != 1 {
+			t.Fatalf("import = %+v, %v", res, err)
+		}
+		requireCount(t, e, &models.Student{}, "email = ?", "alice@example.com", 0)
+		requireCount(t, e, &models.OutboxEvent{}, "aggregate_type = ?", "student", 0)
+	})
+
+	t.Run("students", func(t *testing.T) {
+		e := testutil.New(t)
+		existing := e.CreateStudent()
+		res, err := e.App.Students.Import(ctx, []service.StudentInput{
+			{Name: "Renamed", Email: existing.Email},
+			{Name: "Bob", Email: "bob@example.com"},
+		}, false)
+		if err != nil || res.Created != 1 || res.Updated != 1 {
+			t.Fatalf("import = %+v, %v", res, err)
+		}
+		e.Reload(existing)
+		if existing.Name != "Renamed" || existing.Version != 2 {
+			t.Fatalf("student = %+v", existing)
+		}
+		if eventCount(t, e, events.StudentUpdated, existing.ID) != 1 {
+			t.Fatal("student.updated event not recorded")
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"trae-go/middleware"
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestNotifications(t *testing.T) {
+	e := testutil.New(t)
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+	student := e.CreateStudent()
+	noEmail := e.CreateStudent(func(s *models.Student) { s.Email = "" })
+	book := e.CreateBook(testutil.Title("Go 语言"))
+
+	prefsPath := fmt.Sprintf("/api/v1/students/%d/notification-preferences", student.ID)
+	pickupBy := time.Now().Add(72 * time.Hour).Format(time.RFC3339)
+	holdReady := func(s *models.Student) map[string]interface{} {
+		return map[string]interface{}{"student_id": s.ID, "book_id": book.ID, "pickup_by": pickupBy}
+	}
+	prefs := func(locale string, holdReady bool) map[string]interface{} {
+		return map[string]interface{}{"locale": locale, "due_soon": true, "overdue": true, "hold_ready": holdReady, "account": true}
+	}
+	var queued models.Notification
+
+	runCases(t, e, []apiCase{
+		{name: "default preferences", method: "GET", path: prefsPath, opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var p models.NotificationPreference
+				testutil.Decode(t, res, &p)
+				if p.StudentID != student.ID || p.Locale != "" || !p.DueSoon || !p.Overdue || !p.HoldReady || !p.Account {
+					t.Fatalf("preferences = %+v", p)
+				}
+			}},
+		{name: "preferences of missing student", method: "GET", path: "/api/v1/students/9999/notification-preferences", opts: opts(reader),
+			status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "invalid locale", method: "PUT", path: prefsPath, opts: opts(reader), body: prefs("fr", true),
+			status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "missing switches", method: "PUT", path: prefsPath, opts: opts(reader), body: map[string]interface{}{"locale": "en"},
+			status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "opt out of hold notices", method: "PUT", path: prefsPath, opts: opts(reader), body: prefs("en", false), status: http.StatusOK},
+		{name: "hold ready opted out", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(admin), body: holdReady(student),
+			status: http.StatusConflict, code: "NOTIFICATION_OPTED_OUT"},
+		{name: "opt back in", method: "PUT", path: prefsPath, opts: opts(reader), body: prefs("en", true), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var p models.NotificationPreference
+				testutil.Decode(t, res, &p)
+				if p.Locale != "en" || !p.HoldReady {
+					t.Fatalf("preferences = %+v", p)
+				}
+			}},
+
+		{name: "hold ready requires admin", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(reader), body: holdReady(student),
+			status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "hold ready", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(admin), body: holdReady(student),
+			status: http.StatusAccepted,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				testutil.Decode(t, res, &queued)
+				if queued.Status != models.NotificationPending || queued.Locale != "en" || queued.Recipient != student.Email ||
+					!strings.Contains(queued.Subject, "Go 语言") || !strings.Contains(queued.TextBody, "Hi "+student.Name) {
+					t.Fatalf("notification = %+v", queued)
+				}
+			}},
+		{name: "hold ready twice", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(admin), body: holdReady(student),
+			status: http.StatusConflict, code: "NOTIFICATION_DUPLICATE"},
+		{name: "hold ready without email", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(admin), body: holdReady(noEmail),
+			status: http.StatusUnprocessableEntity, code: "STUDENT_NO_EMAIL"},
+		{name: "hold ready missing book", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(admin),
+			body:   map[string]interface{}{"student_id": student.ID, "book_id": 9999, "pickup_by": pickupBy},
+			status: http.StatusNotFound, code: "BOOK_NOT_FOUND"},
+		{name: "hold ready in the past", method: "POST", path: "/api/v1/admin/notifications/hold-ready", opts: opts(admin),
+			body:   map[string]interface{}{"student_id": student.ID, "book_id": book.ID, "pickup_by": time.Now().Add(-time.Hour).Format(time.RFC3339)},
+			status: http.StatusBadRequest, code: "INVALID_PICKUP_BY"},
+
+		{name: "history", method: "GET", path: fmt.Sprintf("/api/v1/admin/notifications?student_id=%d&kind=hold_ready", student.ID), opts: opts(admin),
+			status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var list []models.Notification
+				testutil.Decode(t, res, &list)
+				if len(list) != 1 || list[0].ID != queued.ID {
+					t.Fatalf("notifications = %+v", list)
+				}
+			}},
+		{name: "history invalid kind", method: "GET", path: "/api/v1/admin/notifications?kind=spam", opts: opts(admin),
+			status: http.StatusBadRequest, code: "INVALID_QUERY"},
+		{name: "history invalid student_id in zh", method: "GET", path: "/api/v1/admin/notifications?student_id=x",
+			opts: opts(admin, testutil.Header("Accept-Language", "zh-CN")), status: http.StatusBadRequest, code: "INVALID_QUERY",
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var p middleware.Problem
+				testutil.Decode(t, res, &p)
+				if p.Detail != "学生 ID 无效" {
+					t.Fatalf("detail = %q", p.Detail)
+				}
+			}},
+		{name: "history requires admin", method: "GET", path: "/api/v1/admin/notifications", opts: opts(reader),
+			status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "get missing", method: "GET", path: "/api/v1/admin/notifications/9999", opts: opts(admin),
+			status: http.StatusNotFound, code: "NOTIFICATION_NOT_FOUND"},
+	})
+
+	// 用例的路径在运行前生成，依赖上面入队结果的用例单独运行
+	path := fmt.Sprintf("/api/v1/admin/notifications/%d", queued.ID)
+	runCases(t, e, []apiCase{
+		{name: "get", method: "GET", path: path, opts: opts(admin), status: http.StatusOK},
+		{name: "resend pending", method: "POST", path: path + "/resend", opts: opts(admin),
+			status: http.StatusConflict, code: "NOTIFICATION_PENDING"},
+	})
+
+	// 发送失败后可以重新发送
+	e.DB.Model(&queued).Updates(map[string]interface{}{"status": models.NotificationFailed, "attempts": 5, "next_attempt_at": nil})
+	runCases(t, e, []apiCase{
+		{name: "resend failed", method: "POST", path: path + "/resend", opts: opts(admin), status: http.StatusAccepted,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var n models.Notification
+				testutil.Decode(t, res, &n)
+				if n.Status != models.NotificationPending || n.Attempts != 0 || n.NextAttemptAt == nil {
+					t.Fatalf("resent = %+v", n)
+				}
+			}},
+	})
+}
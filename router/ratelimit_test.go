@@ -0,0 +1,188 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+
+	"trae-go/config"
+	"trae-go/middleware"
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+// exhaust 连续发送 n 个请求并要求全部通过，再发送一个并要求被限流
+func exhaust(t *testing.T, e *testutil.Env, n int, path string, o ...testutil.RequestOption) {
+	t.Helper()
+	for i := range n {
+		res := e.Do("GET", path, nil, o...)
+		if res.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d limited early", i+1)
+		}
+	}
+	res := e.Do("GET", path, nil, o...)
+	testutil.RequireProblem(t, res, http.StatusTooManyRequests, "TOO_MANY_REQUEST")
+	for _, h := range []string{middleware.RateLimitLimitHeader, middleware.RateLimitResetHeader, middleware.RetryAfterHeader} {
+		if res.Header().Get(h) == "" {
+			t.Fatalf("missing %s header", h)
+		}
+	}
+	if got := res.Header().Get(middleware.RateLimitRemainingHeader); got != "0" {
+		t.Fatalf("%s = %q, want 0", middleware.RateLimitRemainingHeader, got)
+	}
+}
+
+func TestIPRateLimit(t *testing.T) {
+	for _, driver := range []struct {
+		name string
+		opts []testutil.Option
+	}{
+		{"redis", nil},
+		{"memory", []testutil.Option{testutil.MemoryStore}},
+	} {
+		t.Run(driver.name, func(t *testing.T) {
+			e := testutil.New(t, append(driver.opts, func(c *config.Config) { c.RateLimit.IP.Limit = 3 })...)
+			client := testutil.RemoteAddr("10.0.0.1:1234")
+			exhaust(t, e, 3, "/problems", client)
+
+			// 其他 IP 不受影响
+			res := e.Do("GET", "/problems", nil, testutil.RemoteAddr("10.0.0.2:1234"))
+			testutil.RequireStatus(t, res, http.StatusOK)
+			if got := res.Header().Get(middleware.RateLimitRemainingHeader); got != "2" {
+				t.Fatalf("%s = %q, want 2", middleware.RateLimitRemainingHeader, got)
+			}
+			// 探针不计入限流
+			testutil.RequireStatus(t, e.Do("GET", "/healthz", nil, client), http.StatusOK)
+		})
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	t.Run("global", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) { c.RateLimit.Global.Limit = 2 })
+		e.Do("GET", "/problems", nil, testutil.RemoteAddr("10.0.0.1:1"))
+		e.Do("GET", "/problems", nil, testutil.RemoteAddr("10.0.0.2:1"))
+		testutil.RequireProblem(t, e.Do("GET", "/problems", nil, testutil.RemoteAddr("10.0.0.3:1")),
+			http.StatusTooManyRequests, "TOO_MANY_REQUEST")
+	})
+
+	t.Run("token bucket", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) {
+			c.RateLimit.IP = config.RateLimitPolicy{Algorithm: "token_bucket", Limit: 2, Window: "1h"}
+		})
+		exhaust(t, e, 2, "/problems")
+	})
+
+	t.Run("allow list", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) {
+			c.RateLimit.IP = config.RateLimitPolicy{Limit: 1, AllowIPs: []string{"10.1.0.0/16"}}
+		})
+		for range 3 {
+			testutil.RequireStatus(t, e.Do("GET", "/problems", nil, testutil.RemoteAddr("10.1.2.3:1")), http.StatusOK)
+		}
+		exhaust(t, e, 1, "/problems", testutil.RemoteAddr("10.2.0.1:1"))
+	})
+
+	t.Run("auth group", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) {
+			c.RateLimit.Policies = map[string]config.RateLimitPolicy{"login": {Limit: 2, Groups: []string{"auth"}}}
+		})
+		login := map[string]interface{}{"user_name": "nobody", "password": "x"}
+		for range 2 {
+			testutil.RequireStatus(t, e.Do("POST", "/api/v1/user/login", login), http.StatusUnauthorized)
+		}
+		testutil.RequireProblem(t, e.Do("POST", "/api/v1/user/login", login), http.StatusTooManyRequests, "TOO_MANY_REQUEST")
+		// 其他路由组不受该策略限制
+		testutil.RequireStatus(t, e.Do("GET", "/problems", nil), http.StatusOK)
+	})
+
+	t.Run("per user with admin exempt", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) {
+			c.RateLimit.Policies = map[string]config.RateLimitPolicy{"books": {
+				Limit:  2,
+				Key:    "user",
+				Groups: []string{"books"},
+				Roles:  map[string]config.RateLimitOverride{models.UserRoleAdmin: {Exempt: true}},
+			}}
+		})
+		alice := testutil.Token(e.Login("alice", models.UserRoleStudent))
+		bob := testutil.Token(e.Login("bob", models.UserRoleStudent))
+		admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+		// 身份按原样匹配，"Admin" 不是管理员，也不享受管理员的豁免
+		fake := testutil.Token(e.Login("mallory", "Admin"))
+
+		// 同一 IP 下按用户分别计数
+		exhaust(t, e, 2, "/api/v1/books", alice)
+		testutil.RequireStatus(t, e.Do("GET", "/api/v1/books", nil, bob), http.StatusOK)
+		for range 3 {
+			testutil.RequireStatus(t, e.Do("GET", "/api/v1/books", nil, admin), http.StatusOK)
+		}
+		exhaust(t, e, 2, "/api/v1/books", fake)
+	})
+
+	t.Run("hot reload", func(t *testing.T) {
+		e := testutil.New(t)
+		for range 3 {
+			testutil.RequireStatus(t, e.Do("GET", "/problems", nil), http.StatusOK)
+		}
+		e.SetConfig(func(c *config.Config) { c.RateLimit.IP.Limit = 1 })
+		exhaust(t, e, 0, "/problems")
+	})
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	client := testutil.RemoteAddr("203.0.113.7:1234")
+	forwardedFor := func(ip string) testutil.RequestOption { return testutil.Header("X-Forwarded-For", ip) }
+
+	t.Run("spoofed header ignored", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) {
+			c.RateLimit.IP = config.RateLimitPolicy{Limit: 2, AllowIPs: []string{"10.9.0.0/16"}}
+		})
+		// 每次换一个 X-Forwarded-For，仍按连接的对端地址计数
+		for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+			testutil.RequireStatus(t, e.Do("GET", "/problems", nil, client, forwardedFor(ip)), http.StatusOK)
+		}
+		// 冒充 allow_ips 中的地址也不能绕过限流
+		testutil.RequireProblem(t, e.Do("GET", "/problems", nil, client, forwardedFor("10.9.0.1")),
+			http.StatusTooManyRequests, "TOO_MANY_REQUEST")
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		e := testutil.New(t, func(c *config.Config) {
+			c.Server.TrustedProxies = []string{"203.0.113.0/24"}
+			c.RateLimit.IP = config.RateLimitPolicy{Limit: 1, AllowIPs: []string{"10.9.0.0/16"}}
+		})
+		// 经过可信代理的请求按 X-Forwarded-For 中的客户端分别计数
+		exhaust(t, e, 1, "/problems", client, forwardedFor("198.51.100.1"))
+		testutil.RequireStatus(t, e.Do("GET", "/problems", nil, client, forwardedFor("198.51.100.2")), http.StatusOK)
+		for range 2 {
+			testutil.RequireStatus(t, e.Do("GET", "/problems", nil, client, forwardedFor("10.9.0.1")), http.StatusOK)
+		}
+	})
+}
+
+func TestRateLimitAPIKey(t *testing.T) {
+	e := testutil.New(t, func(c *config.Config) {
+		c.RateLimit.Policies = map[string]config.RateLimitPolicy{"partner": {
+			Limit:     2,
+			Key:       "api_key",
+			Groups:    []string{"books"},
+			Keys:      []string{"partner-a", "partner-b"},
+			AllowKeys: []string{"internal-sync-key"},
+		}}
+	})
+	token := testutil.Token(e.Login("alice", models.UserRoleStudent))
+	apiKey := func(k string) testutil.RequestOption { return testutil.Header(middleware.APIKeyHeader, k) }
+
+	// 配置过的 key 分别计数
+	exhaust(t, e, 2, "/api/v1/books", token, apiKey("partner-a"))
+	testutil.RequireStatus(t, e.Do("GET", "/api/v1/books", nil, token, apiKey("partner-b")), http.StatusOK)
+	for range 3 {
+		testutil.RequireStatus(t, e.Do("GET", "/api/v1/books", nil, token, apiKey("internal-sync-key")), http.StatusOK)
+	}
+	// 未知的 key 按 IP 计数，每次换一个值也拿不到新的额度
+	for _, k := range []string{"random-1", "random-2"} {
+		testutil.RequireStatus(t, e.Do("GET", "/api/v1/books", nil, token, apiKey(k)), http.StatusOK)
+	}
+	testutil.RequireProblem(t, e.Do("GET", "/api/v1/books", nil, token, apiKey("random-3")),
+		http.StatusTooManyRequests, "TOO_MANY_REQUEST")
+}
@@ -2,70 +2,130 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
-	"gorm.io/gorm"
 
+	"trae-go/app"
 	"trae-go/config"
 	"trae-go/handlers"
 	"trae-go/middleware"
+	"trae-go/pkg/metrics"
 
 	_ "trae-go/docs"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.uber.org/zap"
 )
 
-func SetupRouter(db *gorm.DB, rdb *redis.Client) *gin.Engine {
+// SetupRouter 注册路由，依赖全部来自 a；限流和 CORS 每次请求读取 a.Config()，配置热更新后立即生效
+func SetupRouter(a *app.App) *gin.Engine {
+	middleware.SetupValidator()
 	r := gin.New()
-	bookHandler := handlers.NewBookHandler(db)
-	studentHandler := handlers.NewStudentHandler(db)
-	userHanlder := handlers.NewUserHanlder(db, rdb)
+	// gin 默认信任所有代理，任何客户端都能用 X-Forwarded-For 指定自己的 IP；只信任配置的反向代理
+	if err := r.SetTrustedProxies(a.Config().Server.TrustedProxies); err != nil {
+		a.Logger.Error("invalid server.trusted_proxies, trusting no proxy", zap.Error(err))
+		r.SetTrustedProxies(nil)
+	}
+	db, st := a.DB, a.Store
+	bookHandler := handlers.NewBookHandler(a.Books, a.Circulation)
+	studentHandler := handlers.NewStudentHandler(a.Students)
+	userHanlder := handlers.NewUserHanlder(db, st, a.Config().Auth.TokenTTL())
+	problemHandler := handlers.NewProblemHandler()
+	healthHandler := handlers.NewHealthHandler(db, a.Redis, a.Replicas, a.Lifecycle)
+	webhookHandler := handlers.NewWebhookHandler(a.Webhooks)
+	notificationHandler := handlers.NewNotificationHandler(a.Notifier)
+	rateLimitConfig := func() config.RateLimitConfig { return a.Config().RateLimit }
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	r.Static("/static/avatars", "./static/avatars")
 
+	// 探针和指标在全局中间件之前注册：不计入限流、不写访问日志，Redis 故障时也能正常返回
+	r.GET("/healthz", healthHandler.Healthz)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler(a.Metrics)))
+
+	// logger 放入请求 context，之后的中间件和 handler 通过 logger.Ctx 取用
+	r.Use(middleware.LoggerMiddleware(a.Logger))
 	r.Use(middleware.RecoveryMiddleware())
+	// 解析上游的 traceparent 并为每个请求创建 span，需在 RequestID 之前以便用 trace ID 作为请求 ID
+	r.Use(otelgin.Middleware(a.Config().Tracing.ServiceName))
 	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.RequestCountMiddleware())
+	r.Use(middleware.ReadYourWritesMiddleware())
+	r.Use(middleware.MetricsMiddleware())
 	r.Use(middleware.LoggingMiddleware())
-	r.Use(middleware.RedisRateLimiterMiddleware(rdb))
-	r.Use(middleware.CorsMiddleware(config.AppConfig.Cors.AllowOrigins))
+	r.Use(middleware.StoreRateLimiterMiddleware(st, rateLimitConfig))
+	r.Use(middleware.CorsMiddleware(func() []string { return a.Config().Cors.AllowOrigins }))
 	r.Use(middleware.ErrorHandlingMiddleware())
 
+	// 错误码文档，problem+json 的 type 字段指向这里
+	r.GET("/problems", problemHandler.ListProblemTypes)
+	r.GET("/problems/:type", problemHandler.GetProblemType)
+
 	api := r.Group("/api")
 	v1 := api.Group("/v1")
 
 	// 不需要登录的接口（公开接口）
+	// 具名限流策略按路由组挂载，组名即配置中 ratelimit.policies.*.groups 的取值
 	publicUser := v1.Group("/user")
+	publicUser.Use(middleware.RateLimitGroupMiddleware(st, rateLimitConfig, "auth"))
 	publicUser.POST("/register", userHanlder.UserRegister)
 	publicUser.POST("/login", userHanlder.UserLogin)
 	publicUser.POST("/uploadAvatar", userHanlder.UploadAvatar)
 
 	// 需要登录的接口
 	authRequired := v1.Group("")
-	authRequired.Use(middleware.AuthenticationMiddleware(db, rdb))
+	authRequired.Use(middleware.AuthenticationMiddleware(db, st))
 
 	authUser := authRequired.Group("/user")
+	authUser.Use(middleware.RateLimitGroupMiddleware(st, rateLimitConfig, "user"))
+	authUser.GET("/profile", userHanlder.GetProfile)
 	authUser.PUT("/profile", userHanlder.UpdateUser)
+	authUser.PATCH("/profile", userHanlder.PatchUser)
 	authUser.DELETE("/:user_name", userHanlder.UserDelte)
+	authUser.POST("/:user_name/restore", middleware.AdminRequired(), userHanlder.UserRestore)
+
+	admin := authRequired.Group("/admin")
+	admin.Use(middleware.AdminRequired(), middleware.RateLimitGroupMiddleware(st, rateLimitConfig, "admin"))
+	admin.GET("/status", healthHandler.AdminStatus)
+	admin.GET("/webhooks", webhookHandler.ListWebhooks)
+	admin.POST("/webhooks", webhookHandler.CreateWebhook)
+	admin.GET("/webhooks/deliveries", webhookHandler.ListDeliveries)
+	admin.GET("/webhooks/deliveries/:id", webhookHandler.GetDelivery)
+	admin.POST("/webhooks/deliveries/:id/redeliver", webhookHandler.RedeliverDelivery)
+	admin.GET("/webhooks/:id", webhookHandler.GetWebhook)
+	admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+	admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+	admin.GET("/notifications", notificationHandler.ListNotifications)
+	admin.POST("/notifications/hold-ready", notificationHandler.NotifyHoldReady)
+	admin.GET("/notifications/:id", notificationHandler.GetNotification)
+	admin.POST("/notifications/:id/resend", notificationHandler.ResendNotification)
 
 	books := authRequired.Group("/books")
+	books.Use(middleware.RateLimitGroupMiddleware(st, rateLimitConfig, "books"))
 	books.GET("", bookHandler.ListBooks)
 	books.GET("/:id", bookHandler.GetBook)
 	books.POST("", bookHandler.CreateBook)
 	books.PUT("/:id", bookHandler.UpdateBook)
+	books.PATCH("/:id", bookHandler.PatchBook)
 	books.DELETE("/:id", bookHandler.DeleteBook)
+	books.POST("/:id/restore", middleware.AdminRequired(), bookHandler.RestoreBook)
 
 	students := authRequired.Group("/students")
+	students.Use(middleware.RateLimitGroupMiddleware(st, rateLimitConfig, "students"))
 	students.GET("/panic", studentHandler.PanicTest)
 	students.GET("", studentHandler.ListStudents)
 	students.GET("/:id", studentHandler.GetStudent)
 	students.POST("", studentHandler.CreatStudent)
 	students.PUT("/:id", studentHandler.UpdateStudent)
+	students.PATCH("/:id", studentHandler.PatchStudent)
 	students.DELETE("/:id", studentHandler.DeleteStudent)
+	students.POST("/:id/restore", middleware.AdminRequired(), studentHandler.RestoreStudent)
 	students.GET("/:id/books", bookHandler.ListStudentBooks)
-	students.POST("/:student_id/books/:book_id/borrow", bookHandler.BookABook)
-	students.POST("/:student_id/books/:book_id/return", bookHandler.ReturnABook)
+	// gin 要求同一层级的通配符同名，这里学生 ID 统一用 :id
+	students.POST("/:id/books/:book_id/borrow", bookHandler.BookABook)
+	students.POST("/:id/books/:book_id/return", bookHandler.ReturnABook)
+	students.GET("/:id/notification-preferences", notificationHandler.GetNotificationPreferences)
+	students.PUT("/:id/notification-preferences", notificationHandler.UpdateNotificationPreferences)
 
 	return r
 }
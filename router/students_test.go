@@ -0,0 +1,100 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestStudents(t *testing.T) {
+	e := testutil.New(t)
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+
+	student := e.CreateStudent(func(s *models.Student) { s.Name = "Tom" })
+	deleted := e.CreateStudent()
+	e.SoftDelete(deleted)
+	toUpdate, toPatch, toDelete, toRestore, borrower := e.CreateStudent(), e.CreateStudent(), e.CreateStudent(), e.CreateStudent(), e.CreateStudent()
+	e.SoftDelete(toRestore)
+	e.CreateLoan(borrower, e.CreateBook())
+
+	path := func(s *models.Student) string { return fmt.Sprintf("/api/v1/students/%d", s.ID) }
+	listLen := func(want int) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, res *httptest.ResponseRecorder) {
+			var students []models.Student
+			testutil.Decode(t, res, &students)
+			if len(students) != want {
+				t.Fatalf("len = %d, want %d", len(students), want)
+			}
+		}
+	}
+
+	runCases(t, e, []apiCase{
+		{name: "list requires login", method: "GET", path: "/api/v1/students", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "list hides deleted", method: "GET", path: "/api/v1/students", opts: opts(reader), status: http.StatusOK, check: listLen(5)},
+		{name: "list include_deleted as student", method: "GET", path: "/api/v1/students?include_deleted=true", opts: opts(reader),
+			status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "list include_deleted as admin", method: "GET", path: "/api/v1/students?include_deleted=true", opts: opts(admin),
+			status: http.StatusOK, check: listLen(7)},
+
+		{name: "get", method: "GET", path: path(student), opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var s models.Student
+				testutil.Decode(t, res, &s)
+				if s.Name != "Tom" || res.Header().Get("ETag") != `"1"` {
+					t.Fatalf("got %+v, ETag %q", s, res.Header().Get("ETag"))
+				}
+			}},
+		{name: "get not modified", method: "GET", path: path(student), opts: opts(reader, testutil.Header("If-None-Match", `"1"`)),
+			status: http.StatusNotModified},
+		{name: "get invalid id", method: "GET", path: "/api/v1/students/x", opts: opts(reader), status: http.StatusBadRequest, code: "INVALID_ID"},
+		{name: "get missing", method: "GET", path: "/api/v1/students/9999", opts: opts(reader), status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "get deleted", method: "GET", path: path(deleted), opts: opts(reader), status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "get deleted as admin", method: "GET", path: path(deleted) + "?include_deleted=true", opts: opts(admin), status: http.StatusOK},
+
+		{name: "create", method: "POST", path: "/api/v1/students", opts: opts(reader),
+			body: map[string]interface{}{"name": "Ann", "email": "ann@example.com"}, status: http.StatusCreated},
+		{name: "create invalid email", method: "POST", path: "/api/v1/students", opts: opts(reader),
+			body: map[string]interface{}{"name": "Ann", "email": "ann"}, status: http.StatusBadRequest, code: "VALIDATION_FAILED",
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				p := testutil.RequireProblem(t, res, http.StatusBadRequest, "VALIDATION_FAILED")
+				if len(p.Errors) != 1 || p.Errors[0].Field != "email" {
+					t.Fatalf("errors = %+v", p.Errors)
+				}
+			}},
+
+		{name: "put requires If-Match", method: "PUT", path: path(toUpdate), opts: opts(reader),
+			body: map[string]interface{}{"name": "New", "email": "new@example.com"}, status: http.StatusPreconditionRequired, code: "PRECONDITION_REQUIRED"},
+		{name: "put stale ETag", method: "PUT", path: path(toUpdate), opts: opts(reader, testutil.Header("If-Match", `"3"`)),
+			body: map[string]interface{}{"name": "New", "email": "new@example.com"}, status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED"},
+		{name: "put", method: "PUT", path: path(toUpdate), opts: opts(reader, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"name": "New", "email": "new@example.com"}, status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var s models.Student
+				testutil.Decode(t, res, &s)
+				if s.Name != "New" || s.Version != 2 {
+					t.Fatalf("got %+v", s)
+				}
+			}},
+
+		{name: "patch", method: "PATCH", path: path(toPatch), opts: opts(reader, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"email": "patched@example.com"}, status: http.StatusOK},
+		{name: "patch invalid email", method: "PATCH", path: path(toPatch), opts: opts(reader, testutil.Header("If-Match", `"2"`)),
+			body: map[string]interface{}{"email": "nope"}, status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "patch read-only id", method: "PATCH", path: path(toPatch), opts: opts(reader, testutil.Header("If-Match", `"2"`)),
+			body: map[string]interface{}{"id": 1000}, status: http.StatusUnprocessableEntity, code: "FIELD_READ_ONLY"},
+
+		{name: "delete", method: "DELETE", path: path(toDelete), opts: opts(reader), status: http.StatusOK},
+		{name: "delete missing", method: "DELETE", path: path(toDelete), opts: opts(reader), status: http.StatusNotFound, code: "STUDENT_NOT_FOUND"},
+		{name: "delete with active loan", method: "DELETE", path: path(borrower), opts: opts(reader), status: http.StatusConflict, code: "CONFLICT"},
+
+		{name: "restore as student", method: "POST", path: path(toRestore) + "/restore", opts: opts(reader), status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "restore", method: "POST", path: path(toRestore) + "/restore", opts: opts(admin), status: http.StatusOK},
+		{name: "restore not deleted", method: "POST", path: path(toRestore) + "/restore", opts: opts(admin),
+			status: http.StatusConflict, code: "STUDENT_NOT_DELETED"},
+	})
+}
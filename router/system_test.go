@@ -0,0 +1,86 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"trae-go/config"
+	"trae-go/middleware"
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestSystem(t *testing.T) {
+	e := testutil.New(t, func(c *config.Config) {
+		c.Cors.AllowOrigins = []string{"https://library.example.com"}
+	})
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+
+	bodyContains := func(s string) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, res *httptest.ResponseRecorder) {
+			if !strings.Contains(res.Body.String(), s) {
+				t.Fatalf("body does not contain %q: %s", s, res.Body.String())
+			}
+		}
+	}
+	header := func(key, want string) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, res *httptest.ResponseRecorder) {
+			if got := res.Header().Get(key); got != want {
+				t.Fatalf("%s = %q, want %q", key, got, want)
+			}
+		}
+	}
+
+	runCases(t, e, []apiCase{
+		{name: "healthz", method: "GET", path: "/healthz", status: http.StatusOK},
+		{name: "readyz", method: "GET", path: "/readyz", status: http.StatusOK, check: bodyContains(`"redis":"ok"`)},
+		{name: "metrics", method: "GET", path: "/metrics", status: http.StatusOK, check: bodyContains("library_")},
+		{name: "swagger", method: "GET", path: "/swagger/index.html", status: http.StatusOK},
+
+		{name: "problem types", method: "GET", path: "/problems", status: http.StatusOK, check: bodyContains("BOOK_NOT_FOUND")},
+		{name: "problem type", method: "GET", path: "/problems/book-not-found", status: http.StatusOK},
+		{name: "unknown problem type", method: "GET", path: "/problems/no-such-problem", status: http.StatusNotFound},
+
+		{name: "admin status", method: "GET", path: "/api/v1/admin/status", opts: opts(admin), status: http.StatusOK},
+		{name: "admin status as student", method: "GET", path: "/api/v1/admin/status", opts: opts(reader), status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "panic recovered", method: "GET", path: "/api/v1/students/panic", opts: opts(reader),
+			status: http.StatusInternalServerError, code: "INTERNAL_ERROR"},
+
+		{name: "request id echoed", method: "GET", path: "/api/v1/books", opts: opts(reader, testutil.Header(middleware.RequestIDHeader, "rid-1")),
+			status: http.StatusOK, check: header(middleware.RequestIDHeader, "rid-1")},
+		{name: "request id generated", method: "GET", path: "/api/v1/books", opts: opts(reader), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				if res.Header().Get(middleware.RequestIDHeader) == "" {
+					t.Fatal("missing request id")
+				}
+			}},
+		{name: "cors allowed origin", method: "GET", path: "/api/v1/books", opts: opts(reader, testutil.Header("Origin", "https://library.example.com")),
+			status: http.StatusOK, check: header("Access-Control-Allow-Origin", "https://library.example.com")},
+		{name: "cors disallowed origin", method: "GET", path: "/api/v1/books", opts: opts(reader, testutil.Header("Origin", "https://evil.example.com")),
+			status: http.StatusForbidden},
+	})
+}
+
+// 同一进程中的两个实例：退出状态和数据库指标各自独立
+func TestInstancesAreIsolated(t *testing.T) {
+	draining, serving := testutil.New(t), testutil.New(t)
+	serving.CreateLoan(serving.CreateStudent(), serving.CreateBook())
+	draining.App.Lifecycle.StartDraining()
+
+	testutil.RequireStatus(t, draining.Do("GET", "/readyz", nil), http.StatusServiceUnavailable)
+	testutil.RequireStatus(t, serving.Do("GET", "/readyz", nil), http.StatusOK)
+
+	for _, tc := range []struct {
+		e    *testutil.Env
+		want string
+	}{{draining, "library_active_loans 0\n"}, {serving, "library_active_loans 1\n"}} {
+		res := tc.e.Do("GET", "/metrics", nil)
+		testutil.RequireStatus(t, res, http.StatusOK)
+		if !strings.Contains(res.Body.String(), tc.want) {
+			t.Errorf("metrics do not contain %q", tc.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package router_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestUsers(t *testing.T) {
+	e := testutil.New(t)
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	e.CreateUser("taken", models.UserRoleStudent)
+	profile := testutil.Token(e.Login("profile", models.UserRoleStudent))
+	victim := e.CreateUser("victim", models.UserRoleStudent)
+	victimToken := testutil.Token(e.TokenFor(victim))
+	gone := e.CreateUser("gone", models.UserRoleStudent)
+	e.SoftDelete(gone)
+
+	register := func(body map[string]interface{}) map[string]interface{} {
+		if _, ok := body["password"]; !ok {
+			body["password"] = testutil.Password
+		}
+		return body
+	}
+	etagIs := func(want string) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, res *httptest.ResponseRecorder) {
+			if got := res.Header().Get("ETag"); got != want {
+				t.Fatalf("ETag = %q, want %q", got, want)
+			}
+		}
+	}
+
+	runCases(t, e, []apiCase{
+		{name: "register", method: "POST", path: "/api/v1/user/register",
+			body: register(map[string]interface{}{"user_name": "newbie", "born_date": "2006/01/02", "ide": "student"}), status: http.StatusCreated,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var u models.User
+				testutil.Decode(t, res, &u)
+				if u.Name != "newbie" || u.BornDate.Year() != 2006 {
+					t.Fatalf("got %+v", u)
+				}
+			}},
+		{name: "register existing", method: "POST", path: "/api/v1/user/register",
+			body: register(map[string]interface{}{"user_name": "taken"}), status: http.StatusBadRequest, code: "USER_ALREADY_EXISTS"},
+		{name: "register deleted name", method: "POST", path: "/api/v1/user/register",
+			body: register(map[string]interface{}{"user_name": "gone"}), status: http.StatusBadRequest, code: "USER_ALREADY_EXISTS"},
+		{name: "register as admin", method: "POST", path: "/api/v1/user/register",
+			body: register(map[string]interface{}{"user_name": "boss", "ide": "admin"}), status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "register as Admin", method: "POST", path: "/api/v1/user/register",
+			body: register(map[string]interface{}{"user_name": "boss", "ide": " Admin"}), status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "register short password", method: "POST", path: "/api/v1/user/register",
+			body: map[string]interface{}{"user_name": "shorty", "password": "1"}, status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "register bad born_date", method: "POST", path: "/api/v1/user/register",
+			body: register(map[string]interface{}{"user_name": "baddate", "born_date": "yesterday"}), status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "register invalid json", method: "POST", path: "/api/v1/user/register", body: "{", status: http.StatusBadRequest, code: "INVALID_JSON"},
+
+		{name: "login", method: "POST", path: "/api/v1/user/login",
+			body: map[string]interface{}{"user_name": "taken", "password": testutil.Password}, status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var body struct{ Token string }
+				testutil.Decode(t, res, &body)
+				testutil.RequireStatus(t, e.Do("GET", "/api/v1/user/profile", nil, testutil.Token(body.Token)), http.StatusOK)
+			}},
+		{name: "login wrong password", method: "POST", path: "/api/v1/user/login",
+			body: map[string]interface{}{"user_name": "taken", "password": "wrong"}, status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS"},
+		{name: "login unknown user", method: "POST", path: "/api/v1/user/login",
+			body: map[string]interface{}{"user_name": "nobody", "password": testutil.Password}, status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS"},
+		{name: "login deleted user", method: "POST", path: "/api/v1/user/login",
+			body: map[string]interface{}{"user_name": "gone", "password": testutil.Password}, status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS"},
+
+		{name: "profile requires login", method: "GET", path: "/api/v1/user/profile", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "profile invalid token", method: "GET", path: "/api/v1/user/profile", opts: opts(testutil.Token("nope")),
+			status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "profile", method: "GET", path: "/api/v1/user/profile", opts: opts(profile), status: http.StatusOK, check: etagIs(`"1"`)},
+		{name: "profile not modified", method: "GET", path: "/api/v1/user/profile", opts: opts(profile, testutil.Header("If-None-Match", `"1"`)),
+			status: http.StatusNotModified},
+		{name: "put profile requires If-Match", method: "PUT", path: "/api/v1/user/profile", opts: opts(profile),
+			body: map[string]interface{}{"sex": "female"}, status: http.StatusPreconditionRequired, code: "PRECONDITION_REQUIRED"},
+		{name: "put profile", method: "PUT", path: "/api/v1/user/profile", opts: opts(profile, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"sex": "female", "born_date": "2001-02-03"}, status: http.StatusOK, check: etagIs(`"2"`)},
+		{name: "put profile stale", method: "PUT", path: "/api/v1/user/profile", opts: opts(profile, testutil.Header("If-Match", `"1"`)),
+			body: map[string]interface{}{"sex": "male"}, status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED"},
+		{name: "patch profile", method: "PATCH", path: "/api/v1/user/profile", opts: opts(profile, testutil.Header("If-Match", `"2"`)),
+			body: map[string]interface{}{"sex": "male"}, status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var u models.User
+				testutil.Decode(t, res, &u)
+				if u.Sex != "male" || u.BornDate.Year() != 2001 || u.Version != 3 {
+					t.Fatalf("got %+v", u)
+				}
+			}},
+		{name: "patch identity", method: "PATCH", path: "/api/v1/user/profile", opts: opts(profile, testutil.Header("If-Match", `"3"`)),
+			body: map[string]interface{}{"ide": "admin"}, status: http.StatusUnprocessableEntity, code: "FIELD_READ_ONLY"},
+		{name: "patch password", method: "PATCH", path: "/api/v1/user/profile", opts: opts(profile, testutil.Header("If-Match", `"3"`)),
+			body: map[string]interface{}{"password": "x"}, status: http.StatusUnprocessableEntity, code: "FIELD_READ_ONLY"},
+
+		{name: "delete user", method: "DELETE", path: "/api/v1/user/victim", opts: opts(admin), status: http.StatusNoContent},
+		{name: "deleted user's token", method: "GET", path: "/api/v1/user/profile", opts: opts(victimToken),
+			status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "delete missing user", method: "DELETE", path: "/api/v1/user/victim", opts: opts(admin), status: http.StatusNotFound, code: "USER_NOT_FOUND"},
+
+		{name: "restore as student", method: "POST", path: "/api/v1/user/victim/restore", opts: opts(profile), status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "restore", method: "POST", path: "/api/v1/user/victim/restore", opts: opts(admin), status: http.StatusOK},
+		{name: "restore not deleted", method: "POST", path: "/api/v1/user/victim/restore", opts: opts(admin),
+			status: http.StatusConflict, code: "USER_NOT_DELETED"},
+		{name: "restore missing", method: "POST", path: "/api/v1/user/nobody/restore", opts: opts(admin),
+			status: http.StatusNotFound, code: "USER_NOT_FOUND"},
+	})
+}
+
+func TestUploadAvatar(t *testing.T) {
+	e := testutil.New(t)
+	// 头像写到工作目录下的 static/avatars
+	t.Chdir(t.TempDir())
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("avatar", "me.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("png"))
+	w.Close()
+
+	res := e.Do("POST", "/api/v1/user/uploadAvatar", body.Bytes(), testutil.Header("Content-Type", w.FormDataContentType()))
+	testutil.RequireStatus(t, res, http.StatusOK)
+	var out struct {
+		AvatarURL string `json:"avatar_url"`
+	}
+	testutil.Decode(t, res, &out)
+	if !strings.HasSuffix(out.AvatarURL, ".png") {
+		t.Fatalf("avatar_url = %q", out.AvatarURL)
+	}
+
+	res = e.Do("POST", "/api/v1/user/uploadAvatar", "", testutil.Header("Content-Type", w.FormDataContentType()))
+	testutil.RequireProblem(t, res, http.StatusInternalServerError, "SAVE_FILE_FAILED")
+}
@@ -0,0 +1,146 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"trae-go/handlers"
+	"trae-go/middleware"
+	"trae-go/models"
+	"trae-go/testutil"
+)
+
+func TestWebhooksAdmin(t *testing.T) {
+	e := testutil.New(t)
+	admin := testutil.Token(e.Login("admin", models.UserRoleAdmin))
+	reader := testutil.Token(e.Login("reader", models.UserRoleStudent))
+
+	create := func(body map[string]interface{}) handlers.WebhookCreated {
+		t.Helper()
+		res := e.Do("POST", "/api/v1/admin/webhooks", body, admin)
+		testutil.RequireStatus(t, res, http.StatusCreated)
+		var hook handlers.WebhookCreated
+		testutil.Decode(t, res, &hook)
+		return hook
+	}
+	sis := create(map[string]interface{}{"url": "https://sis.example.com/hooks", "event_types": []string{"book.borrowed", "book.returned"}})
+	if !strings.HasPrefix(sis.Secret, "whsec_") || !sis.Active {
+		t.Fatalf("created webhook = %+v", sis)
+	}
+	toDelete := create(map[string]interface{}{"url": "http://parents.example.com/hooks", "event_types": []string{"*"}, "secret": "a-secret-of-16-chars"})
+
+	// 投递记录由 Dispatcher 和 Worker 产生，这里直接写入
+	now := time.Now()
+	dead := &models.WebhookDelivery{WebhookID: sis.ID, EventID: 1, EventType: "book.borrowed", Payload: `{}`, Status: models.DeliveryDead, Attempts: 8, LastError: "webhook responded 503"}
+	pending := &models.WebhookDelivery{WebhookID: sis.ID, EventID: 2, EventType: "book.returned", Payload: `{}`, Status: models.DeliveryPending, NextAttemptAt: &now}
+	for _, d := range []*models.WebhookDelivery{dead, pending} {
+		if err := e.DB.Create(d).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.DB.Create(&models.WebhookAttempt{DeliveryID: dead.ID, Attempt: 8, StatusCode: 503, Error: "webhook responded 503"})
+
+	hooksPath := func(id uint) string { return fmt.Sprintf("/api/v1/admin/webhooks/%d", id) }
+	deliveryPath := func(id uint) string { return fmt.Sprintf("/api/v1/admin/webhooks/deliveries/%d", id) }
+	deliveries := func(want ...uint) func(*testing.T, *httptest.ResponseRecorder) {
+		return func(t *testing.T, res *httptest.ResponseRecorder) {
+			var list []models.WebhookDelivery
+			testutil.Decode(t, res, &list)
+			got := make([]uint, len(list))
+			for i, d := range list {
+				got[i] = d.ID
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("deliveries = %v, want %v", got, want)
+			}
+		}
+	}
+
+	runCases(t, e, []apiCase{
+		{name: "requires admin", method: "GET", path: "/api/v1/admin/webhooks", opts: opts(reader), status: http.StatusForbidden, code: "FORBIDDEN"},
+		{name: "list hides secret", method: "GET", path: "/api/v1/admin/webhooks", opts: opts(admin), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				if strings.Contains(res.Body.String(), "secret") {
+					t.Fatalf("list exposes secret: %s", res.Body)
+				}
+			}},
+		{name: "create invalid url", method: "POST", path: "/api/v1/admin/webhooks", opts: opts(admin),
+			body: map[string]interface{}{"url": "ftp://example.com", "event_types": []string{"*"}}, status: http.StatusBadRequest, code: "INVALID_WEBHOOK_URL"},
+		{name: "create unknown event type", method: "POST", path: "/api/v1/admin/webhooks", opts: opts(admin),
+			body: map[string]interface{}{"url": "https://example.com", "event_types": []string{"book.burned"}}, status: http.StatusBadRequest, code: "INVALID_EVENT_TYPE"},
+		{name: "unknown event type in zh", method: "POST", path: "/api/v1/admin/webhooks", opts: opts(admin, testutil.Header("Accept-Language", "zh-CN")),
+			body: map[string]interface{}{"url": "https://example.com", "event_types": []string{"book.burned"}}, status: http.StatusBadRequest, code: "INVALID_EVENT_TYPE",
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var p middleware.Problem
+				testutil.Decode(t, res, &p)
+				details, _ := p.Details.(map[string]interface{})
+				if p.Detail != "未知的事件类型" || details["type"] != "book.burned" {
+					t.Fatalf("problem = %+v", p)
+				}
+			}},
+		{name: "create without event types", method: "POST", path: "/api/v1/admin/webhooks", opts: opts(admin),
+			body: map[string]interface{}{"url": "https://example.com"}, status: http.StatusBadRequest, code: "VALIDATION_FAILED"},
+		{name: "get missing", method: "GET", path: hooksPath(9999), opts: opts(admin), status: http.StatusNotFound, code: "WEBHOOK_NOT_FOUND"},
+		{name: "update", method: "PUT", path: hooksPath(sis.ID), opts: opts(admin),
+			body:   map[string]interface{}{"url": "https://sis.example.com/v2/hooks", "event_types": []string{"book.borrowed"}, "description": "SIS"},
+			status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var hook models.Webhook
+				testutil.Decode(t, res, &hook)
+				if hook.URL != "https://sis.example.com/v2/hooks" || len(hook.EventTypes) != 1 || hook.Description != "SIS" {
+					t.Fatalf("updated webhook = %+v", hook)
+				}
+			}},
+		{name: "delete", method: "DELETE", path: hooksPath(toDelete.ID), opts: opts(admin), status: http.StatusNoContent},
+		{name: "get deleted", method: "GET", path: hooksPath(toDelete.ID), opts: opts(admin), status: http.StatusNotFound, code: "WEBHOOK_NOT_FOUND"},
+
+		{name: "list deliveries", method: "GET", path: "/api/v1/admin/webhooks/deliveries", opts: opts(admin), status: http.StatusOK,
+			check: deliveries(pending.ID, dead.ID)},
+		{name: "dead letters", method: "GET", path: "/api/v1/admin/webhooks/deliveries?status=dead", opts: opts(admin), status: http.StatusOK,
+			check: deliveries(dead.ID)},
+		{name: "deliveries page", method: "GET", path: fmt.Sprintf("/api/v1/admin/webhooks/deliveries?webhook_id=%d&before_id=%d&limit=1", sis.ID, pending.ID),
+			opts: opts(admin), status: http.StatusOK, check: deliveries(dead.ID)},
+		{name: "deliveries invalid status", method: "GET", path: "/api/v1/admin/webhooks/deliveries?status=lost", opts: opts(admin),
+			status: http.StatusBadRequest, code: "INVALID_QUERY"},
+		{name: "deliveries invalid webhook_id", method: "GET", path: "/api/v1/admin/webhooks/deliveries?webhook_id=x", opts: opts(admin),
+			status: http.StatusBadRequest, code: "INVALID_QUERY",
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var p middleware.Problem
+				testutil.Decode(t, res, &p)
+				if p.Detail != "invalid webhook_id" {
+					t.Fatalf("detail = %q", p.Detail)
+				}
+			}},
+		{name: "delivery with attempt log", method: "GET", path: deliveryPath(dead.ID), opts: opts(admin), status: http.StatusOK,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var d handlers.WebhookDeliveryDetail
+				testutil.Decode(t, res, &d)
+				if d.Status != models.DeliveryDead || len(d.AttemptLog) != 1 || d.AttemptLog[0].StatusCode != 503 {
+					t.Fatalf("delivery = %+v", d)
+				}
+			}},
+		{name: "delivery missing", method: "GET", path: deliveryPath(9999), opts: opts(admin), status: http.StatusNotFound, code: "DELIVERY_NOT_FOUND"},
+		{name: "redeliver pending", method: "POST", path: deliveryPath(pending.ID) + "/redeliver", opts: opts(admin),
+			status: http.StatusConflict, code: "DELIVERY_PENDING"},
+		{name: "redeliver dead letter", method: "POST", path: deliveryPath(dead.ID) + "/redeliver", opts: opts(admin), status: http.StatusAccepted,
+			check: func(t *testing.T, res *httptest.ResponseRecorder) {
+				var d models.WebhookDelivery
+				testutil.Decode(t, res, &d)
+				if d.Status != models.DeliveryPending || d.Attempts != 0 || d.NextAttemptAt == nil {
+					t.Fatalf("redelivered = %+v", d)
+				}
+			}},
+		{name: "dead letters after redeliver", method: "GET", path: "/api/v1/admin/webhooks/deliveries?status=dead", opts: opts(admin),
+			status: http.StatusOK, check: deliveries()},
+		{name: "disable moves pending to dead letters", method: "PUT", path: hooksPath(sis.ID), opts: opts(admin),
+			body: map[string]interface{}{"url": "https://sis.example.com/v2/hooks", "event_types": []string{"*"}, "active": false}, status: http.StatusOK},
+		{name: "dead letters after disable", method: "GET", path: "/api/v1/admin/webhooks/deliveries?status=dead", opts: opts(admin),
+			status: http.StatusOK, check: deliveries(pending.ID, dead.ID)},
+		{name: "redeliver to disabled webhook", method: "POST", path: deliveryPath(dead.ID) + "/redeliver", opts: opts(admin),
+			status: http.StatusConflict, code: "WEBHOOK_INACTIVE"},
+	})
+}
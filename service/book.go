@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/repository"
+)
+
+// BookInput 创建 / 更新图书时可写的字段
+type BookInput struct {
+	Title  string
+	Author string
+	ISBN   string
+	Stock  uint
+}
+
+// BookService 图书目录
+type BookService interface {
+	// List withDeleted 为 true 时包含已软删除的图书
+	List(ctx context.Context, withDeleted bool) ([]models.Book, error)
+	Get(ctx context.Context, id uint, withDeleted bool) (*models.Book, error)
+	Create(ctx context.Context, in BookInput) (*models.Book, error)
+	// Update 图书版本号仍为 version 时更新，否则返回 ErrVersionConflict
+	Update(ctx context.Context, id, version uint, in BookInput) (*models.Book, error)
+	// Delete 软删除；存在未归还的借阅时返回 *ActiveLoansError
+	Delete(ctx context.Context, id uint, mode HistoryMode) error
+	// Restore 恢复已软删除的图书
+	Restore(ctx context.Context, id uint) (*models.Book, error)
+	// Import 批量导入：按 ISBN 更新未删除的图书，其余新建，整批在一个事务中。
+	// Stock 为馆藏总数，少于已借出的册数时整批失败；dryRun 为 true 时不写入
+	Import(ctx context.Context, rows []BookInput, dryRun bool) (ImportResult, error)
+}
+
+type bookService struct {
+	repos repository.Repositories
+}
+
+func NewBookService(repos repository.Repositories) BookService {
+	return &bookService{repos: repos}
+}
+
+func (s *bookService) List(ctx context.Context, withDeleted bool) ([]models.Book, error) {
+	return s.repos.Books().List(ctx, withDeleted)
+}
+
+func (s *bookService) Get(ctx context.Context, id uint, withDeleted bool) (*models.Book, error) {
+	book, err := s.repos.Books().Get(ctx, id, withDeleted)
+	return book, notFound(err, ErrBookNotFound)
+}
+
+func (s *bookService) Create(ctx context.Context, in BookInput) (*models.Book, error) {
+	book := &models.Book{Title: in.Title, Author: in.Author, ISBN: in.ISBN, Stock: in.Stock}
+	err := s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		if err := r.Books().Create(ctx, book); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.BookCreated, book.ID, events.BookOf(book))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+func (s *bookService) Update(ctx context.Context, id, version uint, in BookInput) (*models.Book, error) {
+	var book *models.Book
+	err := s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		ok, err := r.Books().Update(ctx, &models.Book{
+			ID: id, Version: version, Title: in.Title, Author: in.Author, ISBN: in.ISBN, Stock: in.Stock,
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrVersionConflict
+		}
+		if book, err = r.Books().Get(ctx, id, false); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.BookUpdated, id, events.BookOf(book))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+func (s *bookService) Delete(ctx context.Context, id uint, mode HistoryMode) error {
+	return deleteGuarded(ctx, s.repos, "book", repository.LoanFilter{BookID: id}, mode,
+		func(r repository.Repositories) error { return r.Books().Lock(ctx, id) },
+		func(r repository.Repositories) (bool, error) {
+			ok, err := r.Books().Delete(ctx, id)
+			if err != nil || !ok {
+				return ok, err
+			}
+			return true, emit(ctx, r, events.BookDeleted, id, events.Deleted{ID: id, History: string(mode)})
+		}, ErrBookNotFound)
+}
+
+func (s *bookService) Restore(ctx context.Context, id uint) (*models.Book, error) {
+	book, err := s.Get(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !book.DeletedAt.Valid {
+		return nil, ErrBookNotDeleted
+	}
+	err = s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		ok, err := r.Books().Restore(ctx, id, book.Version)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrVersionConflict
+		}
+		if book, err = r.Books().Get(ctx, id, false); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.BookRestored, id, events.BookOf(book))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
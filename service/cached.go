@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"trae-go/models"
+	"trae-go/pkg/cache"
+	"trae-go/pkg/logger"
+	"trae-go/pkg/replica"
+
+	"go.uber.org/zap"
+)
+
+// booksTag 图书列表的缓存标签，任何一本书变化都会失效
+const booksTag = "books"
+
+func bookTag(id uint) string {
+	return fmt.Sprintf("book:%d", id)
+}
+
+// cachedBookService 未删除图书的详情和列表走缓存，修改成功后按标签失效。
+// 包含已删除图书的查询只有管理员使用，不缓存
+type cachedBookService struct {
+	BookService
+	cache *cache.Cache
+}
+
+// NewCachedBookService 在 books 外加一层缓存。借书 / 还书也会改变库存，
+// 同一个 cache 需要通过 NewCachedCirculationService 包装借还书服务
+func NewCachedBookService(books BookService, c *cache.Cache) BookService {
+	return &cachedBookService{BookService: books, cache: c}
+}
+
+func (s *cachedBookService) List(ctx context.Context, withDeleted bool) ([]models.Book, error) {
+	if withDeleted {
+		return s.BookService.List(ctx, true)
+	}
+	return cache.Fetch(ctx, s.cache, "books", "books", []string{booksTag}, func(ctx context.Context) ([]models.Book, error) {
+		return s.BookService.List(replica.UsePrimary(ctx), false)
+	})
+}
+
+func (s *cachedBookService) Get(ctx context.Context, id uint, withDeleted bool) (*models.Book, error) {
+	if withDeleted {
+		return s.BookService.Get(ctx, id, true)
+	}
+	return cache.Fetch(ctx, s.cache, "book", bookTag(id), []string{bookTag(id)}, func(ctx context.Context) (*models.Book, error) {
+		return s.BookService.Get(replica.UsePrimary(ctx), id, false)
+	})
+}
+
+func (s *cachedBookService) Create(ctx context.Context, in BookInput) (*models.Book, error) {
+	book, err := s.BookService.Create(ctx, in)
+	if err == nil {
+		invalidateBooks(ctx, s.cache)
+	}
+	return book, err
+}
+
+func (s *cachedBookService) Update(ctx context.Context, id, version uint, in BookInput) (*models.Book, error) {
+	book, err := s.BookService.Update(ctx, id, version, in)
+	invalidateBooksAfter(ctx, s.cache, err, id)
+	return book, err
+}
+
+func (s *cachedBookService) Delete(ctx context.Context, id uint, mode HistoryMode) error {
+	err := s.BookService.Delete(ctx, id, mode)
+	invalidateBooksAfter(ctx, s.cache, err, id)
+	return err
+}
+
+func (s *cachedBookService) Restore(ctx context.Context, id uint) (*models.Book, error) {
+	book, err := s.BookService.Restore(ctx, id)
+	invalidateBooksAfter(ctx, s.cache, err, id)
+	return book, err
+}
+
+func (s *cachedBookService) Import(ctx context.Context, rows []BookInput, dryRun bool) (ImportResult, error) {
+	res, err := s.BookService.Import(ctx, rows, dryRun)
+	if err == nil && len(res.IDs) > 0 && !dryRun {
+		invalidateBooks(ctx, s.cache, res.IDs...)
+	}
+	return res, err
+}
+
+// cachedCirculationService 借书 / 还书改变库存后使图书缓存失效
+type cachedCirculationService struct {
+	CirculationService
+	cache *cache.Cache
+}
+
+func NewCachedCirculationService(circulation CirculationService, c *cache.Cache) CirculationService {
+	return &cachedCirculationService{CirculationService: circulation, cache: c}
+}
+
+func (s *cachedCirculationService) Borrow(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error) {
+	loan, err := s.CirculationService.Borrow(ctx, studentID, bookID)
+	invalidateBooksAfter(ctx, s.cache, err, bookID)
+	return loan, err
+}
+
+func (s *cachedCirculationService) Return(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error) {
+	loan, err := s.CirculationService.Return(ctx, studentID, bookID)
+	invalidateBooksAfter(ctx, s.cache, err, bookID)
+	return loan, err
+}
+
+// invalidateBooksAfter 写入成功后使图书 id 和列表的缓存失效。
+// 版本冲突、库存不足说明客户端看到的可能是缓存中的旧数据（如绕过服务直接改了数据库），同样失效
+func invalidateBooksAfter(ctx context.Context, c *cache.Cache, err error, id uint) {
+	if err == nil || errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrOutOfStock) {
+		invalidateBooks(ctx, c, id)
+	}
+}
+
+// invalidateBooks 写入已经提交，失效失败只记录日志，旧缓存最迟在 TTL 后过期
+func invalidateBooks(ctx context.Context, c *cache.Cache, ids ...uint) {
+	tags := []string{booksTag}
+	for _, id := range ids {
+		tags = append(tags, bookTag(id))
+	}
+	if err := c.Invalidate(ctx, tags...); err != nil {
+		logger.Ctx(ctx).Error("book cache invalidation failed", zap.Strings("tags", tags), zap.Error(err))
+	}
+}
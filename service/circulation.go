@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/pkg/metrics"
+	"trae-go/repository"
+)
+
+// CirculationService 借书和还书
+type CirculationService interface {
+	// Borrow 学生借一本书：库存减一并创建借阅记录。库存不足时返回 ErrOutOfStock
+	Borrow(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error)
+	// Return 归还学生借的书，已软删除的书也能归还
+	Return(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error)
+	// StudentLoans 学生及其全部借阅记录
+	StudentLoans(ctx context.Context, studentID uint, withDeleted bool) (*models.Student, error)
+}
+
+type circulationService struct {
+	repos repository.Repositories
+	now   func() time.Time
+}
+
+func NewCirculationService(repos repository.Repositories) CirculationService {
+	return &circulationService{repos: repos, now: time.Now}
+}
+
+func (s *circulationService) Borrow(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error) {
+	loan := &models.Book_Student{
+		BookID:     bookID,
+		StudentID:  studentID,
+		BorrowedAt: s.now(),
+		Status:     models.BorrowStatusBorrowed,
+	}
+	err := s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		// 在事务内锁住学生和图书：并发的删除要等借书提交，之后会看到这笔未归还的借阅；
+		// 先提交的删除则让这里返回不存在，不会给已删除的学生或图书新建借阅
+		if err := r.Students().Lock(ctx, studentID); err != nil {
+			return notFound(err, ErrStudentNotFound)
+		}
+		if err := r.Books().Lock(ctx, bookID); err != nil {
+			return notFound(err, ErrBookNotFound)
+		}
+		// 库存变化同样算一次修改，版本号加一，让客户端缓存的 ETag 失效
+		ok, err := r.Books().AdjustStock(ctx, bookID, -1)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrOutOfStock
+		}
+		if err := r.Loans().Create(ctx, loan); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.BookBorrowed, bookID, events.LoanOf(loan))
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.Checkouts.Inc()
+	return loan, nil
+}
+
+func (s *circulationService) Return(ctx context.Context, studentID, bookID uint) (*models.Book_Student, error) {
+	var loan *models.Book_Student
+	err := s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		var err error
+		loan, err = r.Loans().FindActive(ctx, studentID, bookID)
+		if err != nil {
+			return notFound(err, ErrLoanNotFound)
+		}
+		// 锁住借阅记录后按状态条件更新：并发归还同一笔借阅时只有一个成功，库存只加一次
+		if err := r.Loans().Lock(ctx, loan.ID); err != nil {
+			return notFound(err, ErrLoanNotFound)
+		}
+		loan.Status = models.BorrowStatusReturned
+		loan.ReturnedAt = s.now()
+		ok, err := r.Loans().MarkReturned(ctx, loan.ID, loan.ReturnedAt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrLoanReturned
+		}
+		if ok, err = r.Books().AdjustStock(ctx, bookID, 1); err != nil {
+			return err
+		}
+		if !ok {
+			return ErrBookNotFound
+		}
+		return emit(ctx, r, events.BookReturned, bookID, events.LoanOf(loan))
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.Returns.Inc()
+	return loan, nil
+}
+
+func (s *circulationService) StudentLoans(ctx context.Context, studentID uint, withDeleted bool) (*models.Student, error) {
+	student, err := s.repos.Students().GetWithLoans(ctx, studentID, withDeleted)
+	return student, notFound(err, ErrStudentNotFound)
+}
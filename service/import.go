@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/repository"
+)
+
+// ImportResult 批量导入的结果
+type ImportResult struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	IDs       []uint // 新建和更新的记录，缓存按它失效
+}
+
+// errDryRun 试运行时让事务回滚
+var errDryRun = errors.New("dry run")
+
+// importAll 在一个事务中逐条调用 fn，任一条失败则整批回滚；dryRun 为 true 时执行完同样回滚
+func importAll[T any](ctx context.Context, repos repository.Repositories, rows []T, dryRun bool,
+	fn func(r repository.Repositories, res *ImportResult, row T) error) (ImportResult, error) {
+	var res ImportResult
+	err := repos.Transaction(ctx, func(r repository.Repositories) error {
+		res = ImportResult{}
+		for i, row := range rows {
+			if err := fn(r, &res, row); err != nil {
+				return fmt.Errorf("record %d: %w", i+1, err)
+			}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return res, err
+}
+
+func (s *bookService) Import(ctx context.Context, rows []BookInput, dryRun bool) (ImportResult, error) {
+	return importAll(ctx, s.repos, rows, dryRun, func(r repository.Repositories, res *ImportResult, in BookInput) error {
+		var cur *models.Book
+		if in.ISBN != "" {
+			b, err := r.Books().FindByISBN(ctx, in.ISBN)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+			cur = b
+		}
+		if cur == nil {
+			book := &models.Book{Title: in.Title, Author: in.Author, ISBN: in.ISBN, Stock: in.Stock}
+			if err := r.Books().Create(ctx, book); err != nil {
+				return err
+			}
+			res.Created++
+			res.IDs = append(res.IDs, book.ID)
+			return emit(ctx, r, events.BookCreated, book.ID, events.BookOf(book))
+		}
+
+		// 锁住后重新读取，借书和还书要等导入提交
+		if err := r.Books().Lock(ctx, cur.ID); err != nil {
+			return err
+		}
+		cur, err := r.Books().Get(ctx, cur.ID, false)
+		if err != nil {
+			return err
+		}
+		active, err := r.Loans().ListActive(ctx, repository.LoanFilter{BookID: cur.ID})
+		if err != nil {
+			return err
+		}
+		// 导入的 stock 是馆藏总数，当前馆藏是库存加上借出未还的。库存按新旧馆藏数的差值调整，
+		// 即导入值减去借出的册数，不会把借出的书算回库存
+		onLoan := uint(len(active))
+		if in.Stock < onLoan {
+			return fmt.Errorf("book %s: stock %d is less than the %d copies on loan", in.ISBN, in.Stock, onLoan)
+		}
+		stock := in.Stock - onLoan
+		if cur.Title == in.Title && cur.Author == in.Author && cur.Stock == stock {
+			res.Unchanged++
+			return nil
+		}
+		ok, err := r.Books().Update(ctx, &models.Book{
+			ID: cur.ID, Version: cur.Version, Title: in.Title, Author: in.Author, ISBN: cur.ISBN, Stock: stock,
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrVersionConflict
+		}
+		if cur, err = r.Books().Get(ctx, cur.ID, false); err != nil {
+			return err
+		}
+		res.Updated++
+		res.IDs = append(res.IDs, cur.ID)
+		return emit(ctx, r, events.BookUpdated, cur.ID, events.BookOf(cur))
+	})
+}
+
+func (s *studentService) Import(ctx context.Context, rows []StudentInput, dryRun bool) (ImportResult, error) {
+	return importAll(ctx, s.repos, rows, dryRun, func(r repository.Repositories, res *ImportResult, in StudentInput) error {
+		cur, err := r.Students().FindByEmail(ctx, in.Email)
+		if errors.Is(err, repository.ErrNotFound) {
+			student := &models.Student{Name: in.Name, Email: in.Email}
+			if err := r.Students().Create(ctx, student); err != nil {
+				return err
+			}
+			res.Created++
+			res.IDs = append(res.IDs, student.ID)
+			return emit(ctx, r, events.StudentCreated, student.ID, events.StudentOf(student))
+		}
+		if err != nil {
+			return err
+		}
+		if cur.Name == in.Name {
+			res.Unchanged++
+			return nil
+		}
+		ok, err := r.Students().Update(ctx, &models.Student{ID: cur.ID, Version: cur.Version, Name: in.Name, Email: cur.Email})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrVersionConflict
+		}
+		if cur, err = r.Students().Get(ctx, cur.ID, false); err != nil {
+			return err
+		}
+		res.Updated++
+		res.IDs = append(res.IDs, cur.ID)
+		return emit(ctx, r, events.StudentUpdated, cur.ID, events.StudentOf(cur))
+	})
+}
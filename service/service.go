@@ -0,0 +1,108 @@
+// Package service 图书、学生和借还书的业务规则：库存检查、借阅记录、删除时的借阅完整性和乐观锁。
+// 只依赖 repository 接口，HTTP handler、命令行和后台任务都可以复用
+package service
+
+import (
+	"context"
+	"errors"
+
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/repository"
+)
+
+// service 返回的业务错误，handler 按这些错误映射 HTTP 状态码
+var (
+	ErrBookNotFound      = errors.New("book not found")
+	ErrStudentNotFound   = errors.New("student not found")
+	ErrLoanNotFound      = errors.New("borrow record not found")
+	ErrLoanReturned      = errors.New("book already returned")
+	ErrOutOfStock        = errors.New("book out of stock")
+	ErrVersionConflict   = errors.New("resource has been modified")
+	ErrBookNotDeleted    = errors.New("book is not deleted")
+	ErrStudentNotDeleted = errors.New("student is not deleted")
+)
+
+// ActiveLoansError 存在未归还的借阅，拒绝删除图书或学生
+type ActiveLoansError struct {
+	Subject string // book 或 student
+	Loans   []models.Book_Student
+}
+
+func (e *ActiveLoansError) Error() string {
+	return e.Subject + " has active loans"
+}
+
+// HistoryMode 删除图书 / 学生时对已结束借阅历史的处理方式
+type HistoryMode string
+
+const (
+	HistoryKeep    HistoryMode = "keep"    // 保留历史（默认），软删除后记录仍然有效
+	HistoryArchive HistoryMode = "archive" // 把已结束的借阅移到 loan_archives
+	HistoryCascade HistoryMode = "cascade" // 直接删除已结束的借阅
+)
+
+// Valid 是否为支持的处理方式
+func (m HistoryMode) Valid() bool {
+	switch m {
+	case HistoryKeep, HistoryArchive, HistoryCascade:
+		return true
+	}
+	return false
+}
+
+// notFound 把 repository.ErrNotFound 换成具体的业务错误
+func notFound(err, target error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return target
+	}
+	return err
+}
+
+// emit 在 r 所在的事务中写入领域事件，与数据修改一起提交或回滚
+func emit(ctx context.Context, r repository.Repositories, typ string, aggregateID uint, data any) error {
+	e, err := events.New(typ, aggregateID, data)
+	if err != nil {
+		return err
+	}
+	return r.Outbox().Append(ctx, e)
+}
+
+// deleteGuarded 在一个事务里：锁住要删除的记录，检查 f 对应的未归还借阅，按 mode 处理已结束的借阅，最后软删除记录。
+// 加锁后并发的借书要等删除提交，不会在检查之后插入新的借阅。记录不存在时回滚，不动借阅历史
+func deleteGuarded(ctx context.Context, repos repository.Repositories, subject string, f repository.LoanFilter,
+	mode HistoryMode, lock func(r repository.Repositories) error, del func(r repository.Repositories) (bool, error),
+	errNotFound error) error {
+	return repos.Transaction(ctx, func(r repository.Repositories) error {
+		if err := lock(r); err != nil {
+			return notFound(err, errNotFound)
+		}
+		active, err := r.Loans().ListActive(ctx, f)
+		if err != nil {
+			return err
+		}
+		if len(active) > 0 {
+			return &ActiveLoansError{Subject: subject, Loans: active}
+		}
+
+		switch mode {
+		case HistoryArchive:
+			if err := r.Loans().ArchiveClosed(ctx, f); err != nil {
+				return err
+			}
+		case HistoryCascade:
+			if err := r.Loans().DeleteClosed(ctx, f); err != nil {
+				return err
+			}
+		}
+
+		deleted, err := del(r)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return errNotFound
+		}
+		return nil
+	})
+}
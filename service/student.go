@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/repository"
+)
+
+// StudentInput 创建 / 更新学生时可写的字段
+type StudentInput struct {
+	Name  string
+	Email string
+}
+
+// StudentService 学生档案
+type StudentService interface {
+	List(ctx context.Context, withDeleted bool) ([]models.Student, error)
+	Get(ctx context.Context, id uint, withDeleted bool) (*models.Student, error)
+	Create(ctx context.Context, in StudentInput) (*models.Student, error)
+	// Update 学生版本号仍为 version 时更新，否则返回 ErrVersionConflict
+	Update(ctx context.Context, id, version uint, in StudentInput) (*models.Student, error)
+	// Delete 软删除；存在未归还的借阅时返回 *ActiveLoansError
+	Delete(ctx context.Context, id uint, mode HistoryMode) error
+	Restore(ctx context.Context, id uint) (*models.Student, error)
+	// Import 批量导入：按邮箱更新未删除学生的姓名，其余新建，同 BookService.Import
+	Import(ctx context.Context, rows []StudentInput, dryRun bool) (ImportResult, error)
+}
+
+type studentService struct {
+	repos repository.Repositories
+}
+
+func NewStudentService(repos repository.Repositories) StudentService {
+	return &studentService{repos: repos}
+}
+
+func (s *studentService) List(ctx context.Context, withDeleted bool) ([]models.Student, error) {
+	return s.repos.Students().List(ctx, withDeleted)
+}
+
+func (s *studentService) Get(ctx context.Context, id uint, withDeleted bool) (*models.Student, error) {
+	student, err := s.repos.Students().Get(ctx, id, withDeleted)
+	return student, notFound(err, ErrStudentNotFound)
+}
+
+func (s *studentService) Create(ctx context.Context, in StudentInput) (*models.Student, error) {
+	student := &models.Student{Name: in.Name, Email: in.Email}
+	err := s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		if err := r.Students().Create(ctx, student); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.StudentCreated, student.ID, events.StudentOf(student))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+func (s *studentService) Update(ctx context.Context, id, version uint, in StudentInput) (*models.Student, error) {
+	var student *models.Student
+	err := s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		ok, err := r.Students().Update(ctx, &models.Student{ID: id, Version: version, Name: in.Name, Email: in.Email})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrVersionConflict
+		}
+		if student, err = r.Students().Get(ctx, id, false); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.StudentUpdated, id, events.StudentOf(student))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+func (s *studentService) Delete(ctx context.Context, id uint, mode HistoryMode) error {
+	return deleteGuarded(ctx, s.repos, "student", repository.LoanFilter{StudentID: id}, mode,
+		func(r repository.Repositories) error { return r.Students().Lock(ctx, id) },
+		func(r repository.Repositories) (bool, error) {
+			ok, err := r.Students().Delete(ctx, id)
+			if err != nil || !ok {
+				return ok, err
+			}
+			return true, emit(ctx, r, events.StudentDeleted, id, events.Deleted{ID: id, History: string(mode)})
+		}, ErrStudentNotFound)
+}
+
+func (s *studentService) Restore(ctx context.Context, id uint) (*models.Student, error) {
+	student, err := s.Get(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !student.DeletedAt.Valid {
+		return nil, ErrStudentNotDeleted
+	}
+	err = s.repos.Transaction(ctx, func(r repository.Repositories) error {
+		ok, err := r.Students().Restore(ctx, id, student.Version)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrVersionConflict
+		}
+		if student, err = r.Students().Get(ctx, id, false); err != nil {
+			return err
+		}
+		return emit(ctx, r, events.StudentRestored, id, events.StudentOf(student))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return student, nil
+}
@@ -0,0 +1,91 @@
+package testutil
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"testing"
+
+	"trae-go/config"
+)
+
+// 环境变量 TEST_DB_DRIVER 选择测试使用的数据库：sqlite（默认，内存数据库）、mysql 或 postgres。
+// mysql / postgres 从 TEST_DB_HOST、TEST_DB_PORT、TEST_DB_USER、TEST_DB_PASSWORD、TEST_DB_SSLMODE 读取连接参数，
+// 该用户需要有建库权限：每个 Env 创建一个独立的数据库，测试结束时删除。
+//
+//	TEST_DB_DRIVER=mysql TEST_DB_PASSWORD=secret go test ./...
+
+// Driver 当前测试使用的数据库驱动
+func Driver() string {
+	return cmp.Or(os.Getenv("TEST_DB_DRIVER"), "sqlite")
+}
+
+// testDatabase 名为 name 的测试数据库的连接配置
+func testDatabase(name string) config.DatabaseConfig {
+	driver := Driver()
+	if driver == "sqlite" {
+		return config.DatabaseConfig{
+			Driver: "sqlite",
+			DSN:    "file:" + name + "?mode=memory&cache=shared",
+			// 内存数据库在最后一个连接关闭时销毁，保持一个常驻连接
+			MaxIdleConns:    1,
+			MaxOpenConns:    1,
+			ConnMaxLifetime: "0s",
+		}
+	}
+	port, user := "3306", "root"
+	if driver == "postgres" {
+		port, user = "5432", "postgres"
+	}
+	return config.DatabaseConfig{
+		Driver:          driver,
+		Host:            cmp.Or(os.Getenv("TEST_DB_HOST"), "127.0.0.1"),
+		Port:            cmp.Or(os.Getenv("TEST_DB_PORT"), port),
+		User:            cmp.Or(os.Getenv("TEST_DB_USER"), user),
+		Password:        os.Getenv("TEST_DB_PASSWORD"),
+		DBName:          name,
+		SSLMode:         cmp.Or(os.Getenv("TEST_DB_SSLMODE"), "disable"),
+		TimeZone:        "UTC",
+		MaxIdleConns:    2,
+		MaxOpenConns:    10,
+		ConnMaxLifetime: "1h",
+	}
+}
+
+// createDatabase mysql / postgres 下为 cfg.DBName 建库，测试结束时删除；sqlite 不需要
+func createDatabase(t testing.TB, cfg config.DatabaseConfig) {
+	t.Helper()
+	if cfg.Driver == "sqlite" {
+		return
+	}
+	admin := cfg
+	admin.DBName = ""
+	if cfg.Driver == "postgres" {
+		admin.DBName = "postgres"
+	}
+	admin.MaxIdleConns, admin.MaxOpenConns = 1, 1
+	db, err := config.OpenDatabase(admin, false)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", cfg.Driver, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql db: %v", err)
+	}
+	if err := db.Exec("CREATE DATABASE " + cfg.DBName).Error; err != nil {
+		sqlDB.Close()
+		t.Fatalf("create database %s: %v", cfg.DBName, err)
+	}
+	// 先于服务的连接注册，清理时最后执行
+	t.Cleanup(func() {
+		defer sqlDB.Close()
+		if err := db.Exec("DROP DATABASE IF EXISTS " + cfg.DBName).Error; err != nil {
+			t.Errorf("drop database %s: %v", cfg.DBName, err)
+		}
+	})
+}
+
+// databaseName 测试数据库名，带上进程号，多个包的测试同时运行时不会冲突
+func databaseName(n int64) string {
+	return fmt.Sprintf("trae_test_%d_%d", os.Getpid(), n)
+}
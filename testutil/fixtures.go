@@ -0,0 +1,142 @@
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"trae-go/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Password CreateUser 创建的用户的密码
+const Password = "password123"
+
+// fixtureSeq fixture 默认名称的序号
+var fixtureSeq atomic.Int64
+
+// CreateUser 直接写库创建用户，role 为 models.UserRoleAdmin 或 models.UserRoleStudent
+func (e *Env) CreateUser(userName, role string) *models.User {
+	e.T.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+	if err != nil {
+		e.T.Fatalf("hash password: %v", err)
+	}
+	u := &models.User{Name: userName, Password: string(hash), Identify: role}
+	e.create(u)
+	return u
+}
+
+// TokenFor 为用户创建登录会话，返回放在 Authorization 请求头中的 token
+func (e *Env) TokenFor(u *models.User) string {
+	e.T.Helper()
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	if err := e.App.Store.SetSession(context.Background(), token, uint(u.ID), time.Hour); err != nil {
+		e.T.Fatalf("create session: %v", err)
+	}
+	return token
+}
+
+// Login 创建用户并返回其 token
+func (e *Env) Login(userName, role string) string {
+	e.T.Helper()
+	return e.TokenFor(e.CreateUser(userName, role))
+}
+
+// BookOption 修改 CreateBook 的默认值
+type BookOption func(*models.Book)
+
+// Stock 设置库存
+func Stock(n uint) BookOption {
+	return func(b *models.Book) { b.Stock = n }
+}
+
+// Title 设置书名
+func Title(title string) BookOption {
+	return func(b *models.Book) { b.Title = title }
+}
+
+// CreateBook 创建图书，默认库存 1
+func (e *Env) CreateBook(opts ...BookOption) *models.Book {
+	e.T.Helper()
+	b := &models.Book{Title: name("Book", fixtureSeq.Add(1)), Author: "Author", Stock: 1}
+	for _, opt := range opts {
+		opt(b)
+	}
+	e.create(b)
+	return b
+}
+
+// StudentOption 修改 CreateStudent 的默认值
+type StudentOption func(*models.Student)
+
+// CreateStudent 创建学生
+func (e *Env) CreateStudent(opts ...StudentOption) *models.Student {
+	e.T.Helper()
+	n := name("student", fixtureSeq.Add(1))
+	s := &models.Student{Name: n, Email: n + "@example.com"}
+	for _, opt := range opts {
+		opt(s)
+	}
+	e.create(s)
+	return s
+}
+
+// LoanOption 修改 CreateLoan 的默认值
+type LoanOption func(*models.Book_Student)
+
+// Returned 已归还的借阅
+func Returned(l *models.Book_Student) {
+	l.Status = models.BorrowStatusReturned
+	l.ReturnedAt = l.BorrowedAt.Add(24 * time.Hour)
+}
+
+// BorrowedAt 设置借出时间，用于构造逾期借阅
+func BorrowedAt(t time.Time) LoanOption {
+	return func(l *models.Book_Student) { l.BorrowedAt = t }
+}
+
+// CreateLoan 直接写库创建借阅记录，默认未归还。只写借阅表，不修改库存
+func (e *Env) CreateLoan(s *models.Student, b *models.Book, opts ...LoanOption) *models.Book_Student {
+	e.T.Helper()
+	l := &models.Book_Student{
+		BookID:     b.ID,
+		StudentID:  s.ID,
+		BorrowedAt: time.Now(),
+		Status:     models.BorrowStatusBorrowed,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	e.create(l)
+	return l
+}
+
+// SoftDelete 软删除 fixture（图书、学生或用户）
+func (e *Env) SoftDelete(model interface{}) {
+	e.T.Helper()
+	if err := e.DB.Delete(model).Error; err != nil {
+		e.T.Fatalf("soft delete %T: %v", model, err)
+	}
+}
+
+// Reload 从数据库重新读取 fixture，包括已软删除的
+func (e *Env) Reload(model interface{}) {
+	e.T.Helper()
+	if err := e.DB.Unscoped().First(model).Error; err != nil {
+		e.T.Fatalf("reload %T: %v", model, err)
+	}
+}
+
+func (e *Env) create(model interface{}) {
+	e.T.Helper()
+	if err := e.DB.Session(&gorm.Session{}).Create(model).Error; err != nil {
+		e.T.Fatalf("create %T: %v", model, err)
+	}
+}
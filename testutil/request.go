@@ -0,0 +1,88 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"trae-go/middleware"
+)
+
+// RequestOption 修改测试请求
+type RequestOption func(*http.Request)
+
+// Token 以 token 对应的用户身份请求
+func Token(token string) RequestOption {
+	return Header("Authorization", token)
+}
+
+// Header 设置请求头
+func Header(key, value string) RequestOption {
+	return func(r *http.Request) { r.Header.Set(key, value) }
+}
+
+// RemoteAddr 设置客户端地址，用于按 IP 限流的测试
+func RemoteAddr(addr string) RequestOption {
+	return func(r *http.Request) { r.RemoteAddr = addr }
+}
+
+// Do 发送一个请求。body 为 string / []byte 时原样发送，其他值编码为 JSON；
+// 默认 Content-Type 为 application/json，可用 Header 覆盖
+func (e *Env) Do(method, path string, body interface{}, opts ...RequestOption) *httptest.ResponseRecorder {
+	e.T.Helper()
+	var rd io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		rd = bytes.NewBufferString(b)
+	case []byte:
+		rd = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			e.T.Fatalf("encode body: %v", err)
+		}
+		rd = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, rd)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	w := httptest.NewRecorder()
+	e.Router.ServeHTTP(w, req)
+	return w
+}
+
+// Decode 把响应体解码到 v
+func Decode(t testing.TB, res *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(res.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", res.Body.String(), err)
+	}
+}
+
+// RequireStatus 状态码不符时终止测试并打印响应体
+func RequireStatus(t testing.TB, res *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if res.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", res.Code, status, res.Body.String())
+	}
+}
+
+// RequireProblem 要求响应为 problem+json 且状态码、错误码一致，返回解析后的 Problem
+func RequireProblem(t testing.TB, res *httptest.ResponseRecorder, status int, code string) middleware.Problem {
+	t.Helper()
+	RequireStatus(t, res, status)
+	var p middleware.Problem
+	Decode(t, res, &p)
+	if p.Code != code {
+		t.Fatalf("problem code = %q, want %q; body: %s", p.Code, code, res.Body.String())
+	}
+	return p
+}
@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"trae-go/events"
+	"trae-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConsumerName Dispatcher 在 events.Relay 中的消费者名，配置的 events.sinks 不能使用
+const ConsumerName = "webhooks"
+
+// Dispatcher events.Sink：为订阅了该事件的每个启用中的订阅写一条待发送的投递，由 Worker 发送。
+// Relay 重复投递同一事件时，已有的投递记录不会重复创建
+type Dispatcher struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewDispatcher db 应使用主库（replica.Primary）
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{db: db, now: time.Now}
+}
+
+// Consumer 作为 events.Relay 的消费者
+func (d *Dispatcher) Consumer() events.Consumer {
+	return events.Consumer{Name: ConsumerName, Sink: d}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, e events.Event) error {
+	db := d.db.WithContext(ctx)
+	var hooks []models.Webhook
+	if err := db.Where("active = ?", true).Order("id").Find(&hooks).Error; err != nil {
+		return err
+	}
+	var deliveries []models.WebhookDelivery
+	var payload []byte
+	// MySQL 的 datetime(3) 会把更细的精度四舍五入，截断到毫秒，避免存入的时间晚于当前时间而没有立即到期
+	now := d.now().Truncate(time.Millisecond)
+	for i := range hooks {
+		if !subscribed(&hooks[i], e.Type) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(e); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     hooks[i].ID,
+			EventID:       e.ID,
+			EventType:     e.Type,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 投递请求的请求头
+const (
+	HeaderWebhookID = "X-Webhook-ID"        // 订阅 ID
+	HeaderDelivery  = "X-Webhook-Delivery"  // 投递 ID，重试和重新投递时不变，接收方可以用它或事件 ID 去重
+	HeaderEventID   = "X-Event-ID"          // 领域事件 ID
+	HeaderEventType = "X-Event-Type"        // 领域事件类型
+	HeaderTimestamp = "X-Webhook-Timestamp" // 签名时间，Unix 秒
+	HeaderSignature = "X-Webhook-Signature" // v1=<hex(HMAC-SHA256(secret, "<timestamp>.<body>"))>
+)
+
+// DefaultTolerance Verify 建议使用的时间容差，超出的请求视为重放
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredTimestamp = errors.New("webhook timestamp outside tolerance")
+)
+
+// Sign 计算签名头的值。签名内容包含时间戳，接收方校验时间戳即可拒绝重放的旧请求
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 供接收方使用：校验时间戳头 timestamp 与 now 相差不超过 tolerance，且签名头 signature 匹配。
+// signature 可以包含多个逗号分隔的签名，任意一个匹配即可（便于轮换密钥）
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	t := time.Unix(ts, 0)
+	if d := now.Sub(t); d > tolerance || d < -tolerance {
+		return ErrExpiredTimestamp
+	}
+	want := Sign(secret, t, body)
+	for _, sig := range strings.Split(signature, ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(sig)), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// NewSecret 生成随机的签名密钥
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
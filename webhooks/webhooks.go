@@ -0,0 +1,271 @@
+// Package webhooks 通过 /admin/webhooks 管理的 webhook 订阅：订阅的增删改查、把领域事件分发为投递记录的 Dispatcher，
+// 以及带签名发送、失败按指数退避重试的 Worker。
+//
+// Dispatcher 是 events.Relay 的一个内置消费者（名为 webhooks），事件到达时为每个匹配的订阅写一条投递记录；
+// Worker 发送到期的投递，重试次数用完后投递进入死信（status 为 dead），可以通过接口手动重新投递。
+// 每次请求都记录在 webhook_attempts 中，作为投递日志。
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"trae-go/events"
+	"trae-go/models"
+
+	"gorm.io/gorm"
+)
+
+// AllEvents 订阅全部事件类型
+const AllEvents = "*"
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidURL       = errors.New("webhook url must be an absolute http(s) URL")
+	ErrNoEventTypes     = errors.New("webhook must subscribe to at least one event type")
+	// ErrDeliveryPending 投递仍在等待发送或重试，不需要重新投递
+	ErrDeliveryPending = errors.New("webhook delivery is still pending")
+	// ErrInactive 订阅已停用，不能重新投递
+	ErrInactive = errors.New("webhook is not active")
+)
+
+// UnknownEventTypeError 订阅了未定义的事件类型
+type UnknownEventTypeError struct {
+	Type string
+}
+
+func (e *UnknownEventTypeError) Error() string {
+	return fmt.Sprintf("unknown event type %q", e.Type)
+}
+
+// Input 创建或更新订阅的参数
+type Input struct {
+	URL         string
+	EventTypes  []string
+	Secret      string // 为空时：创建时自动生成，更新时保留原密钥
+	Description string
+	Active      bool
+}
+
+// DeliveryFilter 投递列表的查询条件，零值表示不过滤
+type DeliveryFilter struct {
+	WebhookID uint
+	Status    string // pending、succeeded 或 dead（死信列表）
+	BeforeID  uint   // 只返回 ID 小于它的投递，用于翻页
+	Limit     int    // 默认 50，最多 200
+}
+
+// Manager 订阅和投递记录的管理
+type Manager struct {
+	db *gorm.DB
+}
+
+func NewManager(db *gorm.DB) *Manager {
+	return &Manager{db: db}
+}
+
+// List 全部订阅（不含已删除的）
+func (m *Manager) List(ctx context.Context) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	err := m.db.WithContext(ctx).Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+func (m *Manager) Get(ctx context.Context, id uint) (*models.Webhook, error) {
+	var hook models.Webhook
+	if err := m.db.WithContext(ctx).Take(&hook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// Create 创建订阅，返回的 Secret 为（自动生成的）签名密钥
+func (m *Manager) Create(ctx context.Context, in Input) (*models.Webhook, error) {
+	types, err := validate(in)
+	if err != nil {
+		return nil, err
+	}
+	secret := in.Secret
+	if secret == "" {
+		if secret, err = NewSecret(); err != nil {
+			return nil, err
+		}
+	}
+	hook := &models.Webhook{URL: in.URL, EventTypes: types, Secret: secret, Description: in.Description, Active: in.Active}
+	if err := m.db.WithContext(ctx).Create(hook).Error; err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Update 全量更新订阅。停用时尚未送达的投递转入死信，重新启用后可以手动重新投递
+func (m *Manager) Update(ctx context.Context, id uint, in Input) (*models.Webhook, error) {
+	types, err := validate(in)
+	if err != nil {
+		return nil, err
+	}
+	var hook models.Webhook
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Take(&hook, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		hook.URL, hook.EventTypes, hook.Description, hook.Active = in.URL, types, in.Description, in.Active
+		if in.Secret != "" {
+			hook.Secret = in.Secret
+		}
+		if err := tx.Save(&hook).Error; err != nil {
+			return err
+		}
+		if !hook.Active {
+			return killPending(tx, id, "webhook disabled")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// Delete 软删除订阅，尚未送达的投递转入死信；投递记录保留到 webhooks.retention 后清理
+func (m *Manager) Delete(ctx context.Context, id uint) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.Webhook{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return killPending(tx, id, "webhook deleted")
+	})
+}
+
+// killPending 把订阅尚未送达的投递转入死信
+func killPending(tx *gorm.DB, webhookID uint, reason string) error {
+	return tx.Model(&models.WebhookDelivery{}).
+		Where("webhook_id = ? AND status = ?", webhookID, models.DeliveryPending).
+		Updates(map[string]interface{}{"status": models.DeliveryDead, "next_attempt_at": nil, "last_error": reason}).Error
+}
+
+// Deliveries 按 ID 倒序列出投递记录
+func (m *Manager) Deliveries(ctx context.Context, f DeliveryFilter) ([]models.WebhookDelivery, error) {
+	q := m.db.WithContext(ctx).Order("id DESC")
+	if f.WebhookID != 0 {
+		q = q.Where("webhook_id = ?", f.WebhookID)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.BeforeID != 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	var deliveries []models.WebhookDelivery
+	err := q.Limit(min(limit, maxListLimit)).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Delivery 一条投递记录和它的投递日志（按时间顺序）
+func (m *Manager) Delivery(ctx context.Context, id uint) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	db := m.db.WithContext(ctx)
+	var d models.WebhookDelivery
+	if err := db.Take(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrDeliveryNotFound
+		}
+		return nil, nil, err
+	}
+	var attempts []models.WebhookAttempt
+	if err := db.Where("delivery_id = ?", id).Order("id").Find(&attempts).Error; err != nil {
+		return nil, nil, err
+	}
+	return &d, attempts, nil
+}
+
+// Redeliver 把已送达或进入死信的投递重新排入队列，立即发送并重新开始计算尝试次数
+func (m *Manager) Redeliver(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Take(&d, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeliveryNotFound
+			}
+			return err
+		}
+		if d.Status == models.DeliveryPending {
+			return ErrDeliveryPending
+		}
+		var hook models.Webhook
+		if err := tx.Take(&hook, d.WebhookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInactive
+			}
+			return err
+		}
+		if !hook.Active {
+			return ErrInactive
+		}
+		now := time.Now().Truncate(time.Millisecond)
+		// 带上状态条件，并发的重新投递只有一个生效
+		res := tx.Model(&d).Where("status = ?", d.Status).
+			Updates(map[string]interface{}{"status": models.DeliveryPending, "attempts": 0, "next_attempt_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDeliveryPending
+		}
+		d.Status, d.Attempts, d.NextAttemptAt = models.DeliveryPending, 0, &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// validate 校验地址和事件类型，返回去重后的事件类型
+func validate(in Input) ([]string, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if len(in.EventTypes) == 0 {
+		return nil, ErrNoEventTypes
+	}
+	types := make([]string, 0, len(in.EventTypes))
+	for _, typ := range in.EventTypes {
+		if typ != AllEvents && !events.Known(typ) {
+			return nil, &UnknownEventTypeError{Type: typ}
+		}
+		if !slices.Contains(types, typ) {
+			types = append(types, typ)
+		}
+	}
+	return types, nil
+}
+
+// subscribed 订阅是否包含事件类型 typ
+func subscribed(hook *models.Webhook, typ string) bool {
+	return slices.Contains(hook.EventTypes, AllEvents) || slices.Contains(hook.EventTypes, typ)
+}
@@ -0,0 +1,333 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trae-go/config"
+	"trae-go/events"
+	"trae-go/models"
+	"trae-go/testutil"
+	"trae-go/webhooks"
+)
+
+// receiver 记录收到的请求，status 返回每次请求的状态码
+type receiver struct {
+	mu     sync.Mutex
+	got    []*http.Request
+	bodies [][]byte
+	status func(n int) int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.got = append(r.got, req)
+	r.bodies = append(r.bodies, body)
+	n := len(r.got)
+	r.mu.Unlock()
+	code := http.StatusOK
+	if r.status != nil {
+		code = r.status(n)
+	}
+	w.WriteHeader(code)
+	io.WriteString(w, "received "+http.StatusText(code))
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.got)
+}
+
+func newReceiver(t *testing.T, status func(n int) int) (*receiver, string) {
+	r := &receiver{status: status}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func createWebhook(t *testing.T, e *testutil.Env, url string, types ...string) *models.Webhook {
+	t.Helper()
+	hook, err := e.App.Webhooks.Create(context.Background(), webhooks.Input{
+		URL: url, EventTypes: types, Secret: "test-secret-0123456789", Active: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+func publish(t *testing.T, e *testutil.Env, id uint, typ string) {
+	t.Helper()
+	ev := events.Event{ID: id, Type: typ, AggregateType: "book", AggregateID: 1, OccurredAt: time.Now(), Data: []byte(`{"id":1}`)}
+	if err := webhooks.NewDispatcher(e.DB).Publish(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newWorker(e *testutil.Env, maxAttempts int) *webhooks.Worker {
+	return webhooks.NewWorker(e.DB, config.WebhooksConfig{MaxAttempts: maxAttempts, Backoff: "1m", MaxBackoff: "3m", Timeout: "2s"})
+}
+
+func process(t *testing.T, w *webhooks.Worker) int {
+	t.Helper()
+	n, err := w.Process(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// makeDue 让等待重试的投递立即到期
+func makeDue(t *testing.T, e *testutil.Env) {
+	t.Helper()
+	err := e.DB.Model(&models.WebhookDelivery{}).Where("status = ?", models.DeliveryPending).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func delivery(t *testing.T, e *testutil.Env, id uint) (*models.WebhookDelivery, []models.WebhookAttempt) {
+	t.Helper()
+	d, attempts, err := e.App.Webhooks.Delivery(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, attempts
+}
+
+func onlyDelivery(t *testing.T, e *testutil.Env) *models.WebhookDelivery {
+	t.Helper()
+	list, err := e.App.Webhooks.Deliveries(context.Background(), webhooks.DeliveryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("deliveries = %d, want 1", len(list))
+	}
+	return &list[0]
+}
+
+func TestSignedDelivery(t *testing.T) {
+	e := testutil.New(t)
+	recv, url := newReceiver(t, nil)
+	hook := createWebhook(t, e, url, events.BookBorrowed)
+
+	publish(t, e, 7, events.BookBorrowed)
+	if n := process(t, newWorker(e, 3)); n != 1 {
+		t.Fatalf("processed %d deliveries, want 1", n)
+	}
+	if recv.count() != 1 {
+		t.Fatalf("received %d requests, want 1", recv.count())
+	}
+	req, body := recv.got[0], recv.bodies[0]
+	if err := webhooks.Verify(hook.Secret, req.Header.Get(webhooks.HeaderTimestamp), req.Header.Get(webhooks.HeaderSignature), body, time.Now(), webhooks.DefaultTolerance); err != nil {
+		t.Fatalf("verify signature: %v", err)
+	}
+	if req.Header.Get(webhooks.HeaderEventID) != "7" || req.Header.Get(webhooks.HeaderEventType) != events.BookBorrowed {
+		t.Fatalf("headers = %v", req.Header)
+	}
+	if !strings.Contains(string(body), `"type":"book.borrowed"`) {
+		t.Fatalf("body = %s", body)
+	}
+
+	d, attempts := delivery(t, e, onlyDelivery(t, e).ID)
+	if d.Status != models.DeliverySucceeded || d.DeliveredAt == nil || d.NextAttemptAt != nil || d.LastStatusCode != http.StatusOK {
+		t.Fatalf("delivery = %+v", d)
+	}
+	if len(attempts) != 1 || attempts[0].StatusCode != http.StatusOK || attempts[0].Response != "received OK" {
+		t.Fatalf("attempts = %+v", attempts)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":1}`)
+	sig := webhooks.Sign("secret", now, body)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	if err := webhooks.Verify("secret", ts, sig, body, now, time.Minute); err != nil {
+		t.Fatalf("valid signature: %v", err)
+	}
+	if err := webhooks.Verify("secret", ts, "v1=old, "+sig, body, now, time.Minute); err != nil {
+		t.Fatalf("one of several signatures: %v", err)
+	}
+	if err := webhooks.Verify("other", ts, sig, body, now, time.Minute); !errors.Is(err, webhooks.ErrInvalidSignature) {
+		t.Fatalf("wrong secret: %v", err)
+	}
+	if err := webhooks.Verify("secret", ts, sig, []byte(`{"id":2}`), now, time.Minute); !errors.Is(err, webhooks.ErrInvalidSignature) {
+		t.Fatalf("tampered body: %v", err)
+	}
+	if err := webhooks.Verify("secret", ts, sig, body, now.Add(2*time.Minute), time.Minute); !errors.Is(err, webhooks.ErrExpiredTimestamp) {
+		t.Fatalf("replayed request: %v", err)
+	}
+}
+
+func TestRetryBackoffAndDeadLetter(t *testing.T) {
+	e := testutil.New(t)
+	recv, url := newReceiver(t, func(int) int { return http.StatusServiceUnavailable })
+	createWebhook(t, e, url, webhooks.AllEvents)
+	w := newWorker(e, 3)
+
+	publish(t, e, 1, events.BookCreated)
+	id := onlyDelivery(t, e).ID
+	// 退避：1m、2m，第三次失败后进入死信
+	for i, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		start := time.Now()
+		process(t, w)
+		d, _ := delivery(t, e, id)
+		if d.Status != models.DeliveryPending || d.Attempts != i+1 || d.LastStatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("after attempt %d: %+v", i+1, d)
+		}
+		if got := d.NextAttemptAt.Sub(start); got < wait || got > wait+5*time.Second {
+			t.Fatalf("attempt %d: retry in %v, want %v", i+1, got, wait)
+		}
+		// 未到期的投递不发送
+		if process(t, w) != 0 {
+			t.Fatal("sent delivery before it was due")
+		}
+		makeDue(t, e)
+	}
+	process(t, w)
+	d, attempts := delivery(t, e, id)
+	if d.Status != models.DeliveryDead || d.NextAttemptAt != nil || len(attempts) != 3 || recv.count() != 3 {
+		t.Fatalf("delivery = %+v, attempts = %d, requests = %d", d, len(attempts), recv.count())
+	}
+	dead, err := e.App.Webhooks.Deliveries(context.Background(), webhooks.DeliveryFilter{Status: models.DeliveryDead})
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("dead letters = %v, %v", dead, err)
+	}
+
+	// 手动重新投递：重新开始计算尝试次数，投递日志保留
+	recv.status = nil
+	if _, err := e.App.Webhooks.Redeliver(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.App.Webhooks.Redeliver(context.Background(), id); !errors.Is(err, webhooks.ErrDeliveryPending) {
+		t.Fatalf("redeliver pending delivery: %v", err)
+	}
+	process(t, w)
+	d, attempts = delivery(t, e, id)
+	if d.Status != models.DeliverySucceeded || d.Attempts != 1 || len(attempts) != 4 || attempts[3].Attempt != 1 {
+		t.Fatalf("after redeliver: %+v, attempts = %+v", d, attempts)
+	}
+}
+
+func TestDispatcherFansOutOnce(t *testing.T) {
+	e := testutil.New(t)
+	_, url := newReceiver(t, nil)
+	borrows := createWebhook(t, e, url, events.BookBorrowed)
+	all := createWebhook(t, e, url, webhooks.AllEvents)
+	off := createWebhook(t, e, url, webhooks.AllEvents)
+	if _, err := e.App.Webhooks.Update(context.Background(), off.ID, webhooks.Input{URL: url, EventTypes: off.EventTypes, Active: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, e, 1, events.BookBorrowed)
+	publish(t, e, 2, events.StudentCreated)
+	// Relay 至少一次投递，同一事件重复到达时不重复创建投递
+	publish(t, e, 1, events.BookBorrowed)
+
+	count := func(hook uint) int {
+		list, err := e.App.Webhooks.Deliveries(context.Background(), webhooks.DeliveryFilter{WebhookID: hook})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(list)
+	}
+	if count(borrows.ID) != 1 || count(all.ID) != 2 || count(off.ID) != 0 {
+		t.Fatalf("deliveries: borrows=%d all=%d disabled=%d", count(borrows.ID), count(all.ID), count(off.ID))
+	}
+}
+
+func TestDeletedWebhookDeadLetters(t *testing.T) {
+	e := testutil.New(t)
+	recv, url := newReceiver(t, nil)
+	hook := createWebhook(t, e, url, webhooks.AllEvents)
+	publish(t, e, 1, events.BookCreated)
+	id := onlyDelivery(t, e).ID
+
+	if err := e.App.Webhooks.Delete(context.Background(), hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	process(t, newWorker(e, 3))
+	d, _ := delivery(t, e, id)
+	if d.Status != models.DeliveryDead || d.LastError != "webhook deleted" || recv.count() != 0 {
+		t.Fatalf("delivery = %+v, requests = %d", d, recv.count())
+	}
+	if _, err := e.App.Webhooks.Redeliver(context.Background(), id); !errors.Is(err, webhooks.ErrInactive) {
+		t.Fatalf("redeliver to deleted webhook: %v", err)
+	}
+}
+
+func TestRedirectIsNotFollowed(t *testing.T) {
+	e := testutil.New(t)
+	target, targetURL := newReceiver(t, nil)
+	srv := httptest.NewServer(http.RedirectHandler(targetURL, http.StatusFound))
+	t.Cleanup(srv.Close)
+	createWebhook(t, e, srv.URL, webhooks.AllEvents)
+
+	publish(t, e, 1, events.BookCreated)
+	process(t, newWorker(e, 3))
+	d := onlyDelivery(t, e)
+	if d.Status != models.DeliveryPending || d.LastStatusCode != http.StatusFound || target.count() != 0 {
+		t.Fatalf("delivery = %+v, redirected requests = %d", d, target.count())
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	e := testutil.New(t)
+	_, url := newReceiver(t, nil)
+	hook := createWebhook(t, e, url, webhooks.AllEvents)
+	old, recent := time.Now().Add(-2*time.Hour), time.Now()
+
+	// 超过保留期的已送达和死信投递被删除，等待重试的和保留期内的保留
+	rows := []struct {
+		status  string
+		updated time.Time
+		removed bool
+	}{
+		{models.DeliverySucceeded, old, true},
+		{models.DeliveryDead, old, true},
+		{models.DeliveryPending, old, false},
+		{models.DeliverySucceeded, recent, false},
+	}
+	ids := make([]uint, len(rows))
+	for i, r := range rows {
+		d := &models.WebhookDelivery{WebhookID: hook.ID, EventID: uint(i + 1), EventType: events.BookCreated, Payload: `{}`, Status: r.status}
+		if err := e.DB.Create(d).Error; err != nil {
+			t.Fatal(err)
+		}
+		e.DB.Create(&models.WebhookAttempt{DeliveryID: d.ID, Attempt: 1})
+		e.DB.Model(d).UpdateColumn("updated_at", r.updated)
+		ids[i] = d.ID
+	}
+
+	w := webhooks.NewWorker(e.DB, config.WebhooksConfig{Retention: "1h"})
+	n, err := w.Cleanup(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("cleanup = %d, %v", n, err)
+	}
+	for i, r := range rows {
+		_, attempts, err := e.App.Webhooks.Delivery(context.Background(), ids[i])
+		if r.removed {
+			var left int64
+			e.DB.Model(&models.WebhookAttempt{}).Where("delivery_id = ?", ids[i]).Count(&left)
+			if !errors.Is(err, webhooks.ErrDeliveryNotFound) || left != 0 {
+				t.Fatalf("%s delivery updated %v: err = %v, attempt log = %d", r.status, r.updated, err, left)
+			}
+		} else if err != nil || len(attempts) != 1 {
+			t.Fatalf("%s delivery updated %v: err = %v, attempt log = %d", r.status, r.updated, err, len(attempts))
+		}
+	}
+}
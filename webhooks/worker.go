@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"trae-go/config"
+	"trae-go/jobs"
+	"trae-go/models"
+	"trae-go/pkg/logger"
+	"trae-go/pkg/metrics"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultBackoff      = 10 * time.Second
+	defaultMaxBackoff   = time.Hour
+	defaultPollInterval = time.Second
+	defaultConcurrency  = 4
+	defaultRetention    = 30 * 24 * time.Hour
+
+	// batchSize 每次查询的到期投递数
+	batchSize = 100
+	// leaseMargin 发送期间投递的 next_attempt_at 推迟到请求超时之后再加上它，
+	// 实例在发送中途退出时，其他实例在这之后重试
+	leaseMargin = 30 * time.Second
+	// maxLogText 投递日志中错误和响应体保留的最大字节数
+	maxLogText = 1024
+	// userAgent 投递请求的 User-Agent
+	userAgent = "trae-go-webhooks/1"
+)
+
+// Worker 发送到期的投递。多个实例可以同时运行：每条投递发送前先用条件更新占用，只有一个实例发送
+type Worker struct {
+	db          *gorm.DB
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	interval    time.Duration
+	concurrency int
+	retention   time.Duration
+	now         func() time.Time
+}
+
+// NewWorker db 应使用主库（replica.Primary）
+func NewWorker(db *gorm.DB, cfg config.WebhooksConfig) *Worker {
+	w := &Worker{
+		db:          db,
+		timeout:     config.Duration(cfg.Timeout, defaultTimeout),
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     config.Duration(cfg.Backoff, defaultBackoff),
+		maxBackoff:  config.Duration(cfg.MaxBackoff, defaultMaxBackoff),
+		interval:    config.Duration(cfg.PollInterval, defaultPollInterval),
+		concurrency: cfg.Concurrency,
+		retention:   config.Duration(cfg.Retention, defaultRetention),
+		now:         time.Now,
+	}
+	if w.maxAttempts <= 0 {
+		w.maxAttempts = defaultMaxAttempts
+	}
+	if w.concurrency <= 0 {
+		w.concurrency = defaultConcurrency
+	}
+	w.client = &http.Client{
+		Timeout: w.timeout,
+		// 不跟随重定向，3xx 视为失败，避免签名请求被转发到订阅以外的地址
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return w
+}
+
+// Run 循环发送到期的投递并定期清理旧记录，直到 ctx 结束
+func (w *Worker) Run(ctx context.Context) {
+	jobs.Poller{
+		Name:      "webhook deliveries",
+		Interval:  w.interval,
+		BatchSize: batchSize,
+		Retention: w.retention,
+		Process:   w.Process,
+		Cleanup:   w.Cleanup,
+	}.Run(ctx)
+}
+
+// Process 发送一批到期的投递，最多同时进行 concurrency 个请求，返回本次查询到的投递数
+func (w *Worker) Process(ctx context.Context) (int, error) {
+	var due []models.WebhookDelivery
+	err := w.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, w.now()).
+		Order("next_attempt_at").Limit(batchSize).Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sem := make(chan struct{}, w.concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for i := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(d *models.WebhookDelivery) {
+			defer func() { <-sem; wg.Done() }()
+			if err := w.deliver(ctx, d); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(&due[i])
+	}
+	wg.Wait()
+	return len(due), errors.Join(errs...)
+}
+
+// deliver 占用并发送一条投递，记录投递日志并更新状态
+func (w *Worker) deliver(ctx context.Context, d *models.WebhookDelivery) error {
+	db := w.db.WithContext(ctx)
+	now := w.now()
+	claimed, err := jobs.Claim(db, &models.WebhookDelivery{}, d.ID, models.DeliveryPending, now, now.Add(w.timeout+leaseMargin))
+	if err != nil || !claimed {
+		return err
+	}
+
+	var hook models.Webhook
+	if err := db.Unscoped().Take(&hook, d.WebhookID).Error; err != nil {
+		return err
+	}
+	if reason := inactiveReason(&hook); reason != "" {
+		metrics.WebhookAttempts.WithLabelValues(models.DeliveryDead).Inc()
+		return db.Model(d).Updates(map[string]interface{}{
+			"status": models.DeliveryDead, "next_attempt_at": nil, "last_error": reason,
+		}).Error
+	}
+
+	attempt := w.send(ctx, &hook, d)
+	if ctx.Err() != nil {
+		return jobs.Release(ctx, db, d, now)
+	}
+	return w.record(ctx, d, attempt)
+}
+
+func inactiveReason(hook *models.Webhook) string {
+	switch {
+	case hook.DeletedAt.Valid:
+		return "webhook deleted"
+	case !hook.Active:
+		return "webhook disabled"
+	}
+	return ""
+}
+
+// send 发送一次签名请求，返回投递日志（未写入数据库）
+func (w *Worker) send(ctx context.Context, hook *models.Webhook, d *models.WebhookDelivery) models.WebhookAttempt {
+	attempt := models.WebhookAttempt{DeliveryID: d.ID, Attempt: d.Attempts + 1}
+	// 签名时间和耗时使用真实时间，w.now 只用于安排重试
+	start := time.Now()
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderWebhookID, strconv.FormatUint(uint64(hook.ID), 10))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(HeaderEventID, strconv.FormatUint(uint64(d.EventID), 10))
+	req.Header.Set(HeaderEventType, d.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, start, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.DurationMS = time.Since(start).Milliseconds()
+		return attempt
+	}
+	defer res.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, maxLogText))
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	attempt.StatusCode = res.StatusCode
+	attempt.Response = string(snippet)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		attempt.Error = "webhook responded " + res.Status
+	}
+	return attempt
+}
+
+// record 写入投递日志并更新投递：成功则结束，失败则按退避安排下次重试，次数用完进入死信
+func (w *Worker) record(ctx context.Context, d *models.WebhookDelivery, attempt models.WebhookAttempt) error {
+	attempt.Error = jobs.Truncate(attempt.Error, maxLogText)
+	attempt.Response = jobs.Truncate(attempt.Response, maxLogText)
+	now := w.now()
+	attempt.CreatedAt = now
+	updates := map[string]interface{}{
+		"attempts":         attempt.Attempt,
+		"last_status_code": attempt.StatusCode,
+		"last_error":       attempt.Error,
+	}
+	result := "retry"
+	switch {
+	case attempt.Error == "":
+		result = models.DeliverySucceeded
+		updates["status"], updates["next_attempt_at"], updates["delivered_at"] = models.DeliverySucceeded, nil, now
+	case attempt.Attempt >= w.maxAttempts:
+		result = models.DeliveryDead
+		updates["status"], updates["next_attempt_at"] = models.DeliveryDead, nil
+	default:
+		updates["next_attempt_at"] = now.Add(jobs.Backoff(w.backoff, w.maxBackoff, attempt.Attempt))
+	}
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		// 发送期间订阅被删除或停用时投递已转入死信，不再覆盖
+		return tx.Model(d).Where("status = ?", models.DeliveryPending).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	metrics.WebhookAttempts.WithLabelValues(result).Inc()
+	if result != models.DeliverySucceeded {
+		logger.Ctx(ctx).Warn("webhook delivery failed",
+			zap.Uint("delivery_id", d.ID),
+			zap.Uint("webhook_id", d.WebhookID),
+			zap.Int("attempt", attempt.Attempt),
+			zap.String("result", result),
+			zap.String("error", attempt.Error),
+		)
+	}
+	return nil
+}
+
+// Cleanup 删除更新时间早于 retention 的已结束投递（已送达和死信）及其投递日志，返回删除的投递数
+func (w *Worker) Cleanup(ctx context.Context) (int64, error) {
+	var n int64
+	cutoff := w.now().Add(-w.retention)
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		done := tx.Model(&models.WebhookDelivery{}).Select("id").
+			Where("status <> ? AND updated_at < ?", models.DeliveryPending, cutoff)
+		if err := tx.Where("delivery_id IN (?)", done).Delete(&models.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("status <> ? AND updated_at < ?", models.DeliveryPending, cutoff).
+			Delete(&models.WebhookDelivery{})
+		n = res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}